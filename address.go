@@ -0,0 +1,55 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"errors"
+	"net"
+)
+
+//-----------------------------------------------------------------------------
+// Address options beyond the address and its subnet. Routing designs built
+// on DevTap interfaces may need a broadcast address other than the subnet's
+// (or none), and IPv6 anycast addresses, which the host answers to without
+// using them as a source (RFC 4291 2.6).
+//
+// On Linux an anycast address isn't an address of the interface but a
+// membership of a socket, which the Interface holds until it's closed; the
+// host only takes it when forwarding (net.ipv6.conf.*.forwarding), and the
+// subnet is unused. The BSDs add it as an address flagged IN6_IFF_ANYCAST.
+
+var ErrAddressOptions = errors.New("address options don't apply to the address family")
+
+// AddressOptions are the options of an address added with AddAddressWith.
+type AddressOptions struct {
+	// the broadcast address of an IPv4 address, instead of the last address
+	// of the subnet; net.IPv4zero for none. On the BSDs, only DevTap
+	// interfaces have one.
+	Broadcast net.IP
+	// add the IPv6 address as an anycast address
+	Anycast bool
+}
+
+// check returns ErrAddressOptions if o doesn't fit ip.
+func (o *AddressOptions) check(ip net.IP) error {
+	if ip.To4() != nil && o.Anycast || ip.To4() == nil && o.Broadcast != nil {
+		return ErrAddressOptions
+	}
+	if o.Broadcast != nil && o.Broadcast.To4() == nil {
+		return ErrAddressOptions
+	}
+	return nil
+}
+
+// AddAddress adds an IP address to the tunnel interface.
+func (t *Interface) AddAddress(ip net.IP, subnet *net.IPNet) error {
+	return t.AddAddressWith(ip, subnet, AddressOptions{})
+}
+
+//-----------------------------------------------------------------------------
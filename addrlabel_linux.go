@@ -0,0 +1,135 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+// IPv6 address labels (ip addrlabel), with rtnetlink's RTM_NEWADDRLABEL and
+// RTM_DELADDRLABEL, which netlink doesn't have: the labels of the policy
+// table of RFC 6724, which source address selection prefers to match the
+// destination's with (its rule 6). The labels here are those of the
+// Interface: the kernel applies them to the addresses on it, and the
+// destinations routed through it.
+
+// the attributes of an address label
+const (
+	ifalAddress = 1
+	ifalLabel   = 2
+)
+
+// ifAddrLabelMsg is a struct ifaddrlblmsg.
+type ifAddrLabelMsg struct {
+	family, prefixLen uint8
+	index             uint32
+}
+
+func (m *ifAddrLabelMsg) Len() int {
+	return 12
+}
+
+func (m *ifAddrLabelMsg) Serialize() []byte {
+	b := make([]byte, 12)
+	b[0], b[2] = m.family, m.prefixLen
+	nl.NativeEndian().PutUint32(b[4:8], m.index)
+	return b
+}
+
+// addrLabel sends the address label message typ, of label for p, for the
+// Interface.
+func (t *Interface) addrLabel(typ int, p netip.Prefix, label uint32) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	p, _, ok := canonical(p)
+	if !ok || !p.Addr().Is6() || p.Addr().Is4In6() && p.Bits() < 96 {
+		return ErrInvalidPrefix
+	}
+	ifi, err := net.InterfaceByName(t.Name())
+	if err != nil {
+		return err
+	}
+	flags := unix.NLM_F_ACK
+	if typ == unix.RTM_NEWADDRLABEL {
+		flags |= unix.NLM_F_CREATE | unix.NLM_F_EXCL
+	}
+	req := nl.NewNetlinkRequest(typ, flags)
+	req.AddData(&ifAddrLabelMsg{family: unix.AF_INET6, prefixLen: uint8(p.Bits()), index: uint32(ifi.Index)})
+	a := p.Addr().As16()
+	req.AddData(nl.NewRtAttr(ifalAddress, a[:]))
+	req.AddData(nl.NewRtAttr(ifalLabel, nl.Uint32Attr(label)))
+	if _, err := req.Execute(unix.NETLINK_ROUTE, 0); err != nil {
+		return errors.Wrapf(err, "tuntap: Can't change the address label of %s on %s", p, t.name)
+	}
+	return nil
+}
+
+// AddAddressLabel gives the IPv6 prefix p the label label, on the tunnel
+// interface, as ip addrlabel add does. It returns ErrInvalidPrefix if p
+// isn't IPv6.
+func (t *Interface) AddAddressLabel(p netip.Prefix, label uint32) error {
+	if err := t.addrLabel(unix.RTM_NEWADDRLABEL, p, label); err != nil {
+		return err
+	}
+	t.applied(t.labelEntry(p, label))
+	return nil
+}
+
+// DelAddressLabel removes the label label of the IPv6 prefix p, on the
+// tunnel interface.
+func (t *Interface) DelAddressLabel(p netip.Prefix, label uint32) error {
+	if err := t.addrLabel(unix.RTM_DELADDRLABEL, p, label); err != nil {
+		return err
+	}
+	t.reverted(t.labelEntry(p, label))
+	return nil
+}
+
+// PreferSource makes the host prefer src, an IPv6 address of the tunnel
+// interface, as the source of the traffic to dst through it, whatever the
+// other addresses of the host: it gives both the label of the interface,
+// InterfaceLabel. It returns ErrInvalidPrefix if dst and src aren't IPv6;
+// IPv4 source selection goes by the routes, whose Src sets the address.
+func (t *Interface) PreferSource(dst netip.Prefix, src netip.Addr) error {
+	label, err := t.InterfaceLabel()
+	if err != nil {
+		return err
+	}
+	if !src.Is6() || src.Is4In6() {
+		return ErrInvalidPrefix
+	}
+	if err := t.AddAddressLabel(dst, label); err != nil {
+		return err
+	}
+	if err := t.AddAddressLabel(netip.PrefixFrom(src.WithZone(""), 128), label); err != nil {
+		t.DelAddressLabel(dst, label)
+		return err
+	}
+	return nil
+}
+
+// InterfaceLabel returns the address label PreferSource uses for the
+// tunnel interface, unique to it: RouteProtocol in the top byte, and the
+// index of the interface. The kernel's default labels are all under 100.
+func (t *Interface) InterfaceLabel() (uint32, error) {
+	ifi, err := net.InterfaceByName(t.Name())
+	if err != nil {
+		return 0, err
+	}
+	return RouteProtocol<<24 | uint32(ifi.Index)&0xffffff, nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,116 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+//-----------------------------------------------------------------------------
+// Address announcements. After a failover, the neighbours of the new active
+// node still have the MAC address of the old one in their caches, and keep
+// sending it traffic until the entries time out. Announce has the interface
+// send a gratuitous ARP (an ARP announcement, RFC 5227) for each of its IPv4
+// addresses and an unsolicited Neighbor Advertisement with the override flag
+// (RFC 4861 7.2.6) for each of its IPv6 ones, so that they update right away.
+//
+// The announcements go out of the interface as if the host sent them: the
+// application reads them from a tun/tap Interface, to forward to the far end
+// of the tunnel like the other packets, and a raw Interface transmits them
+// on its link. As they can get lost, calling Announce a couple of times, a
+// second or two apart, is customary. DevTun interfaces have no link layer,
+// hence no ARP, and only announce their IPv6 addresses.
+
+// announcements returns the frames announcing ips, from mac, which is nil
+// for DevTun's bare IPv6 packets.
+func announcements(mac net.HardwareAddr, ips []net.IP) []Packet {
+	var pkts []Packet
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			if mac != nil {
+				pkts = append(pkts, garp(mac, ip4))
+			}
+		} else if ip.IsGlobalUnicast() || ip.IsLinkLocalUnicast() {
+			pkts = append(pkts, unsolicitedNA(mac, ip.To16()))
+		}
+	}
+	return pkts
+}
+
+// garp returns the ARP announcement of ip: a broadcast request from ip for
+// ip.
+func garp(mac net.HardwareAddr, ip net.IP) Packet {
+	b := make([]byte, 60) // padded to the minimum Ethernet frame
+	copy(b[0:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	copy(b[6:12], mac)
+	binary.BigEndian.PutUint16(b[12:14], ETH_P_ARP)
+	a := b[14:]
+	binary.BigEndian.PutUint16(a[0:2], 1) // Ethernet
+	binary.BigEndian.PutUint16(a[2:4], ETH_P_IP)
+	a[4], a[5] = 6, 4
+	binary.BigEndian.PutUint16(a[6:8], 1) // request
+	copy(a[8:14], mac)
+	copy(a[14:18], ip)
+	// the target hardware address stays zero
+	copy(a[24:28], ip)
+	return Packet{Protocol: ETH_P_ARP, Body: b, L3Offset: 14, Dir: DirWrite}
+}
+
+// unsolicitedNA returns the Neighbor Advertisement of ip to all nodes, with
+// the override flag, carrying mac as target link-layer address if it isn't
+// nil.
+func unsolicitedNA(mac net.HardwareAddr, ip net.IP) Packet {
+	l3 := 0
+	if mac != nil {
+		l3 = 14
+	}
+	icmpLen := 24
+	if mac != nil {
+		icmpLen += 8
+	}
+	b := make([]byte, l3+40+icmpLen)
+	allNodes := net.ParseIP("ff02::1")
+	if mac != nil {
+		copy(b[0:6], []byte{0x33, 0x33, 0, 0, 0, 1})
+		copy(b[6:12], mac)
+		binary.BigEndian.PutUint16(b[12:14], ETH_P_IPV6)
+	}
+	h := b[l3:]
+	h[0] = 0x60
+	binary.BigEndian.PutUint16(h[4:6], uint16(icmpLen))
+	h[6] = 58  // ICMPv6
+	h[7] = 255 // hop limit, as neighbor discovery requires
+	copy(h[8:24], ip)
+	copy(h[24:40], allNodes)
+	m := h[40:]
+	m[0] = 136  // Neighbor Advertisement
+	m[4] = 0x20 // override
+	copy(m[8:24], ip)
+	if mac != nil {
+		m[24], m[25] = 2, 1 // target link-layer address, 8 bytes
+		copy(m[26:32], mac)
+	}
+	binary.BigEndian.PutUint16(m[2:4], icmp6Checksum(ip, allNodes, m))
+	return Packet{Protocol: ETH_P_IPV6, Body: b, L3Offset: l3, Dir: DirWrite}
+}
+
+// icmp6Checksum returns the checksum of the ICMPv6 message m from src to
+// dst, whose checksum field is zero.
+func icmp6Checksum(src, dst net.IP, m []byte) uint16 {
+	b := make([]byte, 40+len(m))
+	copy(b[0:16], src)
+	copy(b[16:32], dst)
+	binary.BigEndian.PutUint32(b[32:36], uint32(len(m)))
+	b[39] = 58
+	copy(b[40:], m)
+	return checksum(b)
+}
+
+//-----------------------------------------------------------------------------
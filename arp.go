@@ -0,0 +1,180 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+)
+
+//-----------------------------------------------------------------------------
+// ARP for DevTap Interfaces. The host behind a tap interface resolves the
+// IPv4 addresses it talks to with ARP before sending them anything, so a
+// user-mode network stack or router on the other side has to answer, or
+// nothing gets through. An ARPResponder answers the requests for the
+// addresses it's given with their MAC addresses, and, as a proxy ARP
+// (RFC 1027), those for any address of the subnets it's given with the MAC
+// address of the subnet: the router's, which then forwards what it gets.
+//
+// Answer builds the reply to a request read, for the application to write
+// back; an Interface opened WithARPResponder does it as it reads, and only
+// passes on the ARP it didn't answer. The replies go back to the MAC
+// address the request came from, through the same VLAN.
+//
+// The proxy doesn't answer the probes and announcements (RFC 5227) of the
+// host: these are for its own address, and answering them would make it
+// give the address up as taken.
+
+var ErrARPAddress = errors.New("ARP answers need an IPv4 address and an Ethernet MAC address")
+
+// ARPResponder answers ARP requests for a set of IPv4 addresses. It is safe
+// for concurrent use.
+type ARPResponder struct {
+	lock     sync.RWMutex
+	addrs    map[netip.Addr]net.HardwareAddr
+	proxies  map[netip.Prefix]net.HardwareAddr
+	answered atomic.Uint64
+}
+
+// NewARPResponder returns a responder answering for no addresses.
+func NewARPResponder() *ARPResponder {
+	return &ARPResponder{
+		addrs:   make(map[netip.Addr]net.HardwareAddr),
+		proxies: make(map[netip.Prefix]net.HardwareAddr),
+	}
+}
+
+// WithARPResponder has the Interface, which must be a DevTap, answer the
+// ARP requests it reads with r, rather than return them.
+func WithARPResponder(r *ARPResponder) Option {
+	return func(c *config) { c.arp = r }
+}
+
+// Set answers the requests for ip with mac. It returns ErrARPAddress if ip
+// isn't IPv4.
+func (r *ARPResponder) Set(ip netip.Addr, mac net.HardwareAddr) error {
+	ip = ip.Unmap()
+	if !ip.Is4() || len(mac) != 6 {
+		return ErrARPAddress
+	}
+	r.lock.Lock()
+	r.addrs[ip] = append(net.HardwareAddr(nil), mac...)
+	r.lock.Unlock()
+	return nil
+}
+
+// Remove stops answering for ip.
+func (r *ARPResponder) Remove(ip netip.Addr) {
+	r.lock.Lock()
+	delete(r.addrs, ip.Unmap())
+	r.lock.Unlock()
+}
+
+// Proxy answers the requests for the addresses of subnet, but those Set,
+// with mac. It returns ErrARPAddress if subnet isn't IPv4.
+func (r *ARPResponder) Proxy(subnet netip.Prefix, mac net.HardwareAddr) error {
+	subnet, _, ok := canonical(subnet)
+	if !ok || !subnet.Addr().Is4() || len(mac) != 6 {
+		return ErrARPAddress
+	}
+	r.lock.Lock()
+	r.proxies[subnet] = append(net.HardwareAddr(nil), mac...)
+	r.lock.Unlock()
+	return nil
+}
+
+// Unproxy stops answering for the addresses of subnet.
+func (r *ARPResponder) Unproxy(subnet netip.Prefix) {
+	if subnet, _, ok := canonical(subnet); ok {
+		r.lock.Lock()
+		delete(r.proxies, subnet)
+		r.lock.Unlock()
+	}
+}
+
+// Answered returns the number of requests answered.
+func (r *ARPResponder) Answered() uint64 {
+	return r.answered.Load()
+}
+
+// lookup returns the MAC address to answer for ip with, nil if none, with
+// whether it's a proxy's.
+func (r *ARPResponder) lookup(ip netip.Addr) (net.HardwareAddr, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if mac := r.addrs[ip]; mac != nil {
+		return mac, false
+	}
+	// the longest prefix wins
+	var mac net.HardwareAddr
+	bits := -1
+	for p, m := range r.proxies {
+		if p.Bits() > bits && p.Contains(ip) {
+			mac, bits = m, p.Bits()
+		}
+	}
+	return mac, true
+}
+
+// Answer returns the ARP reply to pkt, and true, if pkt is an Ethernet ARP
+// request for one of the addresses r answers for.
+func (r *ARPResponder) Answer(pkt *Packet) (Packet, bool) {
+	a := pkt.ip()
+	if pkt.Protocol != ETH_P_ARP || pkt.L3Offset < 14 || len(a) < 28 ||
+		binary.BigEndian.Uint16(a[0:2]) != 1 || binary.BigEndian.Uint16(a[2:4]) != ETH_P_IP ||
+		a[4] != 6 || a[5] != 4 || binary.BigEndian.Uint16(a[6:8]) != 1 {
+		return Packet{}, false
+	}
+	sha, spa, tpa := a[8:14], a[14:18], a[24:28]
+	sender := netip.AddrFrom4([4]byte{spa[0], spa[1], spa[2], spa[3]})
+	target := netip.AddrFrom4([4]byte{tpa[0], tpa[1], tpa[2], tpa[3]})
+	mac, proxy := r.lookup(target)
+	if mac == nil {
+		return Packet{}, false
+	}
+	if proxy && (sender.IsUnspecified() || sender == target) {
+		// a probe or announcement of the host's own address
+		return Packet{}, false
+	}
+	if sha[0]&1 != 0 {
+		// no answering to a multicast address
+		return Packet{}, false
+	}
+
+	reply := icmpReply(pkt, 28)
+	copy(reply[6:12], mac)
+	reply = append(reply, 0, 1, 0x08, 0, 6, 4, 0, 2)
+	reply = append(reply, mac...)
+	reply = append(reply, tpa...)
+	reply = append(reply, sha...)
+	reply = append(reply, spa...)
+	for len(reply) < 60 {
+		// padded to the minimum Ethernet frame
+		reply = append(reply, 0)
+	}
+	r.answered.Add(1)
+	return Packet{Body: reply, Protocol: ETH_P_ARP, L3Offset: pkt.L3Offset, Dir: DirWrite}, true
+}
+
+// answerARP returns whether the packet read, pkt, is an ARP request the
+// Interface answered.
+func (t *Interface) answerARP(pkt *Packet) bool {
+	reply, ok := t.arp.Answer(pkt)
+	if ok {
+		// best effort: the host asks again
+		t.writePacket(reply)
+	}
+	return ok
+}
+
+//-----------------------------------------------------------------------------
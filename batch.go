@@ -0,0 +1,95 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"sync/atomic"
+)
+
+//-----------------------------------------------------------------------------
+// Adaptive batched reads. A BatchReader waits for one packet, then takes
+// whatever else is already queued in the kernel, up to its current batch
+// size. The batch size follows the load: it doubles while batches come back
+// full, which means packets are arriving faster than they're consumed, and
+// halves when they come back mostly empty. At low load batches are single
+// packets which are handed over as soon as they arrive; under load the cost
+// per read is amortized over many packets.
+
+// BatchReader reads packets from an Interface in batches. It must be used by
+// only one goroutine at a time.
+type BatchReader struct {
+	t    *Interface
+	bufs [][]byte
+	pkts []Packet
+	size int32 // current batch size, accessed atomically
+}
+
+// NewBatchReader returns a BatchReader for t which reads packets of up to
+// bufSize bytes (including any header the device adds), in batches of up to
+// maxBatch packets.
+func NewBatchReader(t *Interface, bufSize, maxBatch int) *BatchReader {
+	if maxBatch < 1 {
+		maxBatch = 1
+	}
+	r := &BatchReader{
+		t:    t,
+		bufs: make([][]byte, maxBatch),
+		pkts: make([]Packet, 0, maxBatch),
+		size: 1,
+	}
+	// one allocation for all the buffers
+	mem := make([]byte, bufSize*maxBatch)
+	for i := range r.bufs {
+		r.bufs[i] = mem[i*bufSize : (i+1)*bufSize : (i+1)*bufSize]
+	}
+	return r
+}
+
+// ReadBatch waits for a packet, and returns it along with the packets which
+// were already queued behind it, up to the current batch size. Queued packets
+// which can't be parsed are dropped. The Packets' Bodies are only valid until
+// the next call to ReadBatch.
+func (r *BatchReader) ReadBatch() ([]Packet, error) {
+	size := int(atomic.LoadInt32(&r.size))
+	pkts, err := r.t.readBatch(r.bufs[:size], r.pkts[:0])
+	r.pkts = pkts
+	if len(pkts) == 0 {
+		return nil, err
+	}
+	// an error after the first packet comes back on the next ReadBatch
+
+	r.adapt(len(r.pkts), size)
+	return r.pkts, nil
+}
+
+// adapt updates the batch size after a batch of n packets was read with the
+// batch size at size.
+func (r *BatchReader) adapt(n, size int) {
+	switch {
+	case n == size && size < len(r.bufs):
+		size *= 2
+		if size > len(r.bufs) {
+			size = len(r.bufs)
+		}
+	case n <= size/4:
+		// the gap between the thresholds keeps the size from flapping
+		size /= 2
+	default:
+		return
+	}
+	atomic.StoreInt32(&r.size, int32(size))
+}
+
+// BatchSize returns the current batch size. It may be called from any
+// goroutine, for instance to export it as a metric.
+func (r *BatchReader) BatchSize() int {
+	return int(atomic.LoadInt32(&r.size))
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,337 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// Fast failure detection, with Bidirectional Forwarding Detection (RFC 5880)
+// in asynchronous mode: each end sends control packets every interval (100ms
+// by default), and the session goes down when none has come for Multiplier
+// intervals, so that a dead path is detected in a fraction of a second,
+// against the seconds of keepalives. The control packets are BFD's, and the
+// state machine, timers and poll sequences are too, but for the echo
+// function and authentication, which a tunnel's own encryption makes moot.
+//
+// The control packets go through the tunnel's data path, to test it: the
+// application sends them with BFDConfig.Send, and hands over those it
+// receives to Received, as for a PMTUProber. While the session is up, the
+// packets received are signs of life of the path of a FailoverPair, if set,
+// which fails over within its window once they stop.
+
+var ErrBFDPacket = errors.New("invalid BFD control packet")
+
+// BFDState is the state of a BFD session.
+type BFDState uint8
+
+const (
+	BFDAdminDown BFDState = iota
+	BFDDown
+	BFDInit
+	BFDUp
+)
+
+func (s BFDState) String() string {
+	switch s {
+	case BFDAdminDown:
+		return "AdminDown"
+	case BFDDown:
+		return "Down"
+	case BFDInit:
+		return "Init"
+	case BFDUp:
+		return "Up"
+	}
+	return fmt.Sprintf("BFDState(%d)", uint8(s))
+}
+
+// the diagnostics of a state change, as BFD has them
+const (
+	bfdDiagNone         = 0
+	bfdDiagTimeExpired  = 1
+	bfdDiagNeighborDown = 3
+	bfdDiagAdminDown    = 7
+)
+
+// the flags of the control packets
+const (
+	bfdPoll  = 0x20
+	bfdFinal = 0x10
+)
+
+const bfdPacketLen = 24
+
+// BFDConfig configures a BFDSession. Zero fields take the defaults.
+type BFDConfig struct {
+	// sends a control packet to the peer, through the tunnel; required
+	Send func(msg []byte) error
+	// how often to send, and to receive at least, control packets while
+	// up, 100ms by default; never faster than once a second otherwise, as
+	// BFD requires
+	Interval time.Duration
+	// how many intervals without a control packet the session goes down
+	// after, 3 by default
+	Multiplier int
+	// called, if set, when the state of the session changes, one change at
+	// a time. It mustn't call the session.
+	OnState func(from, to BFDState)
+	// if set, the control packets received while up are signs of life of
+	// the path Path of Failover
+	Failover *FailoverPair
+	Path     int
+}
+
+// BFDSession is a BFD session with the peer of a tunnel. It is safe for
+// concurrent use.
+type BFDSession struct {
+	cfg BFDConfig
+
+	lock        sync.Mutex
+	state       BFDState
+	diag        uint8
+	local       uint32 // the discriminators
+	remote      uint32
+	remoteState BFDState
+	// the intervals the peer asks for, in microseconds
+	remoteTx, remoteRx uint32
+	remoteMult         uint8
+	last               time.Time // the last control packet received
+	final              bool      // to answer a poll
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewBFDSession returns a session, down, and starts sending control packets.
+func NewBFDSession(cfg BFDConfig) *BFDSession {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 100 * time.Millisecond
+	}
+	if cfg.Multiplier <= 0 {
+		cfg.Multiplier = 3
+	}
+	if cfg.Multiplier > 255 {
+		cfg.Multiplier = 255
+	}
+	s := &BFDSession{
+		cfg:   cfg,
+		state: BFDDown,
+		kick:  make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	for s.local == 0 {
+		s.local = rand.Uint32()
+	}
+	goLabeled(nil, "bfd", s.run)
+	return s
+}
+
+// State returns the state of the session.
+func (s *BFDSession) State() BFDState {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.state
+}
+
+// Close takes the session administratively down, telling the peer, and
+// stops it.
+func (s *BFDSession) Close() {
+	s.once.Do(func() {
+		s.lock.Lock()
+		s.setState(BFDAdminDown, bfdDiagAdminDown)
+		msg := s.packet()
+		s.lock.Unlock()
+		s.cfg.Send(msg)
+		close(s.stop)
+	})
+	<-s.done
+}
+
+// micros returns d in microseconds.
+func micros(d time.Duration) uint32 {
+	return uint32(d / time.Microsecond)
+}
+
+// packet returns the control packet to send. It's called with s locked.
+func (s *BFDSession) packet() []byte {
+	b := make([]byte, bfdPacketLen)
+	b[0] = 1<<5 | s.diag
+	b[1] = byte(s.state) << 6
+	if s.final {
+		b[1] |= bfdFinal
+		s.final = false
+	}
+	b[2] = byte(s.cfg.Multiplier)
+	b[3] = bfdPacketLen
+	binary.BigEndian.PutUint32(b[4:], s.local)
+	binary.BigEndian.PutUint32(b[8:], s.remote)
+	binary.BigEndian.PutUint32(b[12:], micros(s.txInterval(false)))
+	binary.BigEndian.PutUint32(b[16:], micros(s.cfg.Interval))
+	// no echo function: RequiredMinEchoRxInterval is 0
+	return b
+}
+
+// txInterval returns how often to send, as asked, or as the peer can take
+// if remote. It's called with s locked.
+func (s *BFDSession) txInterval(remote bool) time.Duration {
+	tx := s.cfg.Interval
+	if s.state != BFDUp && tx < time.Second {
+		tx = time.Second
+	}
+	if rx := time.Duration(s.remoteRx) * time.Microsecond; remote && rx > tx {
+		tx = rx
+	}
+	return tx
+}
+
+// detectTime returns how long without a control packet the session goes
+// down after, 0 if the peer isn't known. It's called with s locked.
+func (s *BFDSession) detectTime() time.Duration {
+	if s.remoteMult == 0 {
+		return 0
+	}
+	rx := s.cfg.Interval
+	if tx := time.Duration(s.remoteTx) * time.Microsecond; tx > rx {
+		rx = tx
+	}
+	return time.Duration(s.remoteMult) * rx
+}
+
+// setState changes the state of the session. It's called with s locked.
+func (s *BFDSession) setState(state BFDState, diag uint8) {
+	if state == s.state {
+		return
+	}
+	from := s.state
+	s.state, s.diag = state, diag
+	if state == BFDDown || state == BFDAdminDown {
+		s.remote, s.remoteMult = 0, 0
+	}
+	if s.cfg.OnState != nil {
+		s.cfg.OnState(from, state)
+	}
+	// tell the peer right away
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Received handles a control packet from the peer.
+func (s *BFDSession) Received(msg []byte) error {
+	if len(msg) < bfdPacketLen || msg[0]>>5 != 1 || int(msg[3]) < bfdPacketLen || int(msg[3]) > len(msg) {
+		return ErrBFDPacket
+	}
+	flags, mult := msg[1], msg[2]
+	yours, mine := binary.BigEndian.Uint32(msg[4:]), binary.BigEndian.Uint32(msg[8:])
+	remoteState := BFDState(flags >> 6)
+	if mult == 0 || yours == 0 || flags&bfdPoll != 0 && flags&bfdFinal != 0 || flags&0x04 != 0 {
+		// authentication (A) isn't supported
+		return ErrBFDPacket
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if mine != 0 && mine != s.local || mine == 0 && remoteState != BFDDown && remoteState != BFDAdminDown {
+		return ErrBFDPacket
+	}
+	if s.state == BFDAdminDown {
+		return nil
+	}
+	s.remote, s.remoteState, s.remoteMult = yours, remoteState, mult
+	s.remoteTx, s.remoteRx = binary.BigEndian.Uint32(msg[12:]), binary.BigEndian.Uint32(msg[16:])
+	s.last = time.Now()
+
+	switch {
+	case remoteState == BFDAdminDown:
+		if s.state != BFDDown {
+			s.setState(BFDDown, bfdDiagNeighborDown)
+		}
+	case s.state == BFDDown:
+		if remoteState == BFDDown {
+			s.setState(BFDInit, bfdDiagNone)
+		} else if remoteState == BFDInit {
+			s.setState(BFDUp, bfdDiagNone)
+		}
+	case s.state == BFDInit:
+		if remoteState == BFDInit || remoteState == BFDUp {
+			s.setState(BFDUp, bfdDiagNone)
+		}
+	case s.state == BFDUp:
+		if remoteState == BFDDown {
+			s.setState(BFDDown, bfdDiagNeighborDown)
+		}
+	}
+	if flags&bfdPoll != 0 {
+		s.final = true
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+	if s.state == BFDUp && s.cfg.Failover != nil {
+		s.cfg.Failover.Alive(s.cfg.Path)
+	}
+	return nil
+}
+
+func (s *BFDSession) run() {
+	defer close(s.done)
+	tx := time.NewTimer(0)
+	defer tx.Stop()
+	// the detection time is at least Interval: check it twice as often
+	check := time.NewTicker(s.cfg.Interval / 2)
+	defer check.Stop()
+	for {
+		select {
+		case <-tx.C:
+		case <-s.kick:
+			if !tx.Stop() {
+				select {
+				case <-tx.C:
+				default:
+				}
+			}
+		case <-check.C:
+			s.lock.Lock()
+			if d := s.detectTime(); d != 0 && (s.state == BFDUp || s.state == BFDInit) && time.Since(s.last) > d {
+				s.setState(BFDDown, bfdDiagTimeExpired)
+			}
+			s.lock.Unlock()
+			continue
+		case <-s.stop:
+			return
+		}
+		s.lock.Lock()
+		msg := s.packet()
+		interval := s.txInterval(true)
+		// the jitter BFD requires: 75 to 100% of the interval, or 90% if the
+		// peer's multiplier is 1
+		jitter := 0.75 + 0.25*rand.Float64()
+		if s.remoteMult == 1 {
+			jitter = 0.75 + 0.15*rand.Float64()
+		}
+		s.lock.Unlock()
+		// a control packet lost is made up for by the next
+		s.cfg.Send(msg)
+		tx.Reset(time.Duration(float64(interval) * jitter))
+	}
+}
+
+//-----------------------------------------------------------------------------
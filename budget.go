@@ -0,0 +1,99 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"sync/atomic"
+)
+
+//-----------------------------------------------------------------------------
+// Memory limits for the package's queues. Under a packet storm a queue which
+// grows without bound ends up taking the host down with it, so each queue
+// (PacketRing, CoalescingWriter) can be given a MemoryBudget, shared with
+// other queues or not, and a DropPolicy saying what to do when a packet
+// doesn't fit. Packets count against a budget by the size of the buffer
+// holding them, not just their length, since that's what they cost.
+//
+// LeasedPackets held by the application outside of any queue aren't counted;
+// bounding those is up to the application.
+
+// DropPolicy says what a queue does with a packet which doesn't fit.
+type DropPolicy int
+
+const (
+	// Wait for room in the queue, pushing back on the producer. When it's the
+	// MemoryBudget which is exhausted, there's nobody to wait for (the budget
+	// may be used up by other queues), so the packet is dropped as with
+	// DropTail.
+	BlockWhenFull DropPolicy = iota
+	// Drop the new packet.
+	DropTail
+	// Drop the oldest queued packets to make room for the new one. Favors
+	// fresh packets, which is usually right for real time traffic.
+	DropOldest
+)
+
+// MemoryBudget is a limit on the bytes held by the queues sharing it. It is
+// safe for concurrent use.
+type MemoryBudget struct {
+	limit int64
+	used  atomic.Int64
+}
+
+// NewMemoryBudget returns a budget of limit bytes.
+func NewMemoryBudget(limit int64) *MemoryBudget {
+	return &MemoryBudget{limit: limit}
+}
+
+// Limit returns the budget's limit in bytes.
+func (b *MemoryBudget) Limit() int64 {
+	return b.limit
+}
+
+// InUse returns the number of bytes currently held against the budget.
+func (b *MemoryBudget) InUse() int64 {
+	return b.used.Load()
+}
+
+// reserve takes n bytes from the budget if they're available. A nil budget is
+// unlimited.
+func (b *MemoryBudget) reserve(n int) bool {
+	if b == nil {
+		return true
+	}
+	for {
+		used := b.used.Load()
+		if used+int64(n) > b.limit {
+			return false
+		}
+		if b.used.CompareAndSwap(used, used+int64(n)) {
+			return true
+		}
+	}
+}
+
+// release returns n bytes reserved earlier.
+func (b *MemoryBudget) release(n int) {
+	if b != nil {
+		b.used.Add(-int64(n))
+	}
+}
+
+// footprint is what the packet costs while it's queued.
+func (l *LeasedPacket) footprint() int {
+	switch {
+	case l.buf.small != nil:
+		return len(l.buf.small)
+	case l.buf.large != nil:
+		return len(*l.buf.large)
+	}
+	return cap(l.Body)
+}
+
+//-----------------------------------------------------------------------------
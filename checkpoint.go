@@ -0,0 +1,162 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// Checkpoint and restore of a FlowTracker, so that a gateway restarted for
+// an upgrade goes on with the flows it was tracking rather than seeing them
+// start over: the old process writes the flows with Checkpoint (to a file,
+// typically, written aside and renamed into place), and the new one reads
+// them back with Restore before tracking any packet.
+//
+// The checkpoint is a "TTFL" magic and version, the number of flows, and
+// then a fixed size record per flow, all big endian. The times are kept as
+// they were, so a flow's idle time includes the restart. Version 1
+// checkpoints, from before the flows had a direction, restore as DirRead
+// flows.
+
+var ErrCheckpoint = errors.New("not a flow checkpoint, or a truncated one")
+
+const (
+	checkpointMagic   = "TTFL"
+	checkpointVersion = 2
+	// key, start, last seen, packets, bytes, trace context and direction;
+	// version 1 has no direction
+	flowRecordLen = 16 + 16 + 1 + 2 + 2 + 8 + 8 + 8 + 8 + 16 + 8 + 1 + 1
+)
+
+// Checkpoint writes the flows being tracked to w.
+func (ft *FlowTracker) Checkpoint(w io.Writer) error {
+	flows := ft.Flows()
+	bw := bufio.NewWriter(w)
+	hdr := make([]byte, 0, 12)
+	hdr = append(hdr, checkpointMagic...)
+	hdr = binary.BigEndian.AppendUint32(hdr, checkpointVersion)
+	hdr = binary.BigEndian.AppendUint32(hdr, uint32(len(flows)))
+	bw.Write(hdr)
+	rec := make([]byte, 0, flowRecordLen)
+	for i := range flows {
+		f := &flows[i]
+		rec = append(rec[:0], f.Key.Src[:]...)
+		rec = append(rec, f.Key.Dst[:]...)
+		rec = append(rec, f.Key.Proto)
+		rec = binary.BigEndian.AppendUint16(rec, f.Key.SrcPort)
+		rec = binary.BigEndian.AppendUint16(rec, f.Key.DstPort)
+		rec = binary.BigEndian.AppendUint64(rec, uint64(f.Start.UnixNano()))
+		rec = binary.BigEndian.AppendUint64(rec, uint64(f.LastSeen.UnixNano()))
+		rec = binary.BigEndian.AppendUint64(rec, f.Packets)
+		rec = binary.BigEndian.AppendUint64(rec, f.Bytes)
+		rec = append(rec, f.Trace.TraceID[:]...)
+		rec = append(rec, f.Trace.SpanID[:]...)
+		rec = append(rec, f.Trace.Flags, byte(f.Dir))
+		bw.Write(rec)
+	}
+	return bw.Flush()
+}
+
+// readFlows reads the flows of a checkpoint.
+func readFlows(r io.Reader) ([]Flow, error) {
+	br := bufio.NewReader(r)
+	var hdr [12]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil || string(hdr[:4]) != checkpointMagic {
+		return nil, ErrCheckpoint
+	}
+	v := binary.BigEndian.Uint32(hdr[4:8])
+	if v != 1 && v != checkpointVersion {
+		return nil, errors.New("unsupported flow checkpoint version")
+	}
+	n := binary.BigEndian.Uint32(hdr[8:12])
+	var flows []Flow
+	var buf [flowRecordLen]byte
+	rec := buf[:]
+	if v == 1 {
+		rec = rec[:flowRecordLen-1]
+	}
+	for i := uint32(0); i < n; i++ {
+		if _, err := io.ReadFull(br, rec); err != nil {
+			return nil, ErrCheckpoint
+		}
+		var f Flow
+		b := rec
+		b = b[copy(f.Key.Src[:], b):]
+		b = b[copy(f.Key.Dst[:], b):]
+		f.Key.Proto = b[0]
+		f.Key.SrcPort = binary.BigEndian.Uint16(b[1:3])
+		f.Key.DstPort = binary.BigEndian.Uint16(b[3:5])
+		f.Start = time.Unix(0, int64(binary.BigEndian.Uint64(b[5:13])))
+		f.LastSeen = time.Unix(0, int64(binary.BigEndian.Uint64(b[13:21])))
+		f.Packets = binary.BigEndian.Uint64(b[21:29])
+		f.Bytes = binary.BigEndian.Uint64(b[29:37])
+		b = b[37:]
+		b = b[copy(f.Trace.TraceID[:], b):]
+		b = b[copy(f.Trace.SpanID[:], b):]
+		f.Trace.Flags = b[0]
+		if v != 1 {
+			f.Dir = Direction(b[1])
+		}
+		flows = append(flows, f)
+	}
+	return flows, nil
+}
+
+// Restore reads a checkpoint from r and tracks its flows, which the
+// FlowObserver is told start (it's new too). Flows which have been idle for
+// longer than the idle timeout meanwhile, or already tracked, or over
+// maxFlows, are left out. Nothing is restored if the checkpoint is broken.
+// It returns the number of flows restored.
+func (ft *FlowTracker) Restore(r io.Reader) (int, error) {
+	flows, err := readFlows(r)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	var restored []*Flow
+	ft.lock.Lock()
+	if ft.closed {
+		ft.lock.Unlock()
+		return 0, nil
+	}
+	for i := range flows {
+		f := &flows[i]
+		if ft.idle > 0 && now.Sub(f.LastSeen) >= ft.idle || ft.flows[f.Key] != nil {
+			continue
+		}
+		if ft.maxFlows > 0 && len(ft.flows) >= ft.maxFlows {
+			break
+		}
+		ft.flows[f.Key] = f
+		restored = append(restored, f)
+	}
+	if len(ft.flows) != 0 && ft.timer == nil && ft.idle > 0 {
+		ft.timer = time.AfterFunc(ft.idle/2, ft.sweep)
+	}
+	// packets may update the flows as soon as it's unlocked
+	snaps := make([]Flow, len(restored))
+	for i, f := range restored {
+		snaps[i] = *f
+	}
+	ft.lock.Unlock()
+
+	if ft.obs != nil {
+		for i := range snaps {
+			ft.obs.FlowStarted(&snaps[i])
+		}
+	}
+	return len(restored), nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,162 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+//-----------------------------------------------------------------------------
+// Teardown. OnClose hooks run as an Interface is closed, and an Interface
+// opened WithCleanup undoes the configuration it made when it's closed: the
+// addresses and routes added through it are removed, and the sysctls set
+// through it restored, which matters for persistent interfaces, which the
+// kernel doesn't clean up. Such Interfaces
+// are also in a process-wide registry, with the application's own cleanups
+// (RegisterCleanup), which RunCleanups closes and runs, and which
+// CleanupOnSignal runs when the process is killed by a signal.
+//
+// This is best effort: nothing runs when the process is killed with SIGKILL
+// or crashes, and a sealed Interface can't undo its configuration.
+
+// WithCleanup makes the Interface undo the changes made through it
+// (AddAddress, AddRoute, IPv6Forwarding...) when it's closed, and registers
+// it for RunCleanups.
+func WithCleanup() Option {
+	return func(c *config) { c.cleanup = true }
+}
+
+// OnClose registers f to be called when the Interface is closed, before the
+// device is: the hooks run last registered first, as deferred calls do. If
+// the Interface is already closed, f isn't called.
+func (t *Interface) OnClose(f func()) {
+	t.closeLock.Lock()
+	t.onClose = append(t.onClose, f)
+	t.closeLock.Unlock()
+}
+
+// runOnClose runs the OnClose hooks, once.
+func (t *Interface) runOnClose() {
+	t.closeLock.Lock()
+	hooks := t.onClose
+	t.onClose = nil
+	t.closeLock.Unlock()
+	t.inOnClose.Store(true)
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+	t.inOnClose.Store(false)
+	if t.cleanup {
+		cleanupLock.Lock()
+		delete(cleanupIfs, t)
+		cleanupLock.Unlock()
+	}
+}
+
+// dropOnClose forgets the OnClose hooks without running them, and takes t
+// out of the registry.
+func (t *Interface) dropOnClose() {
+	t.closeLock.Lock()
+	t.onClose = nil
+	t.closeLock.Unlock()
+	if t.cleanup {
+		cleanupLock.Lock()
+		delete(cleanupIfs, t)
+		cleanupLock.Unlock()
+	}
+}
+
+var cleanupLock sync.Mutex
+var cleanupIfs = make(map[*Interface]bool)
+var cleanups []*func()
+
+// registerCleanup adds t, opened WithCleanup, to the registry.
+func registerCleanup(t *Interface) {
+	cleanupLock.Lock()
+	cleanupIfs[t] = true
+	cleanupLock.Unlock()
+}
+
+// RegisterCleanup registers f to be run by RunCleanups, and returns the
+// function unregistering it: an nftables rule to delete, say.
+func RegisterCleanup(f func()) (unregister func()) {
+	p := &f
+	cleanupLock.Lock()
+	cleanups = append(cleanups, p)
+	cleanupLock.Unlock()
+	return func() {
+		cleanupLock.Lock()
+		defer cleanupLock.Unlock()
+		for i, c := range cleanups {
+			if c == p {
+				cleanups = append(cleanups[:i], cleanups[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// RunCleanups closes the Interfaces opened WithCleanup and still open, and
+// then runs the cleanups registered with RegisterCleanup, last registered
+// first, and unregisters them. It's meant to be called as the process exits.
+func RunCleanups() {
+	cleanupLock.Lock()
+	ifs := make([]*Interface, 0, len(cleanupIfs))
+	for t := range cleanupIfs {
+		ifs = append(ifs, t)
+	}
+	fs := cleanups
+	cleanups = nil
+	cleanupLock.Unlock()
+
+	for _, t := range ifs {
+		t.Close()
+	}
+	for i := len(fs) - 1; i >= 0; i-- {
+		(*fs[i])()
+	}
+}
+
+// CleanupOnSignal makes the first of sigs (SIGINT and SIGTERM if none) the
+// process gets call RunCleanups, and then kill the process with the signal
+// as if it had never been handled. It returns a function to stop handling
+// them.
+func CleanupOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, sigs...)
+	goLabeled(nil, "cleanup on signal", func() {
+		select {
+		case sig := <-c:
+			RunCleanups()
+			signal.Reset(sig)
+			if p, err := os.FindProcess(os.Getpid()); err == nil && p.Signal(sig) == nil {
+				// the signal is on its way; don't return to the application
+				select {}
+			}
+			os.Exit(1)
+		case <-done:
+		}
+	})
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(done)
+		})
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,233 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// Write coalescing. A relay forwarding many small packets spends most of its
+// time in write syscalls; a CoalescingWriter trades a bounded bit of latency
+// for fewer, larger batches by holding packets for a short window and
+// handing them to the Interface together.
+//
+// How much a batch saves depends on the backend. Raw (AF_PACKET) Interfaces
+// send a batch with sendmmsg(2), one syscall for the lot. A tun/tap file
+// descriptor can only take one packet per write(2), so there the batch is
+// written packet by packet, but still from one place, back to back, which
+// keeps the writing goroutine hot. The packets aren't merged into GSO
+// super-packets.
+//
+// When writing a batch fails, the packets of the batch left unwritten are
+// dropped, and counted in Drops.
+
+var ErrCoalescerClosed = errors.New("coalescing writer closed")
+
+// CoalescingWriter queues packets and writes them to its Interface in batches.
+// It is safe for concurrent use.
+type CoalescingWriter struct {
+	t        *Interface
+	window   time.Duration
+	maxBatch int
+
+	lock    sync.Mutex
+	queue   []Packet
+	bufs    []packetBuf // the pooled buffers backing queue
+	timer   *time.Timer
+	flushed chan struct{} // closed and replaced by each flush
+	err     error         // the first error of a background flush
+	closed  bool
+
+	budget *MemoryBudget
+	policy DropPolicy
+	drops  atomic.Uint64
+}
+
+// NewCoalescingWriter returns a writer which flushes the packets queued on it
+// window after the first of them arrived (e.g. 50 * time.Microsecond), or as
+// soon as maxBatch are queued, whichever comes first.
+func NewCoalescingWriter(t *Interface, window time.Duration, maxBatch int) *CoalescingWriter {
+	if maxBatch < 1 {
+		maxBatch = 1
+	}
+	idle := make(chan struct{})
+	close(idle)
+	return &CoalescingWriter{
+		t:        t,
+		window:   window,
+		maxBatch: maxBatch,
+		flushed:  idle,
+	}
+}
+
+// SetLimits makes the queued packets count against budget, and sets what
+// WritePacket does when a packet doesn't fit; with BlockWhenFull it writes
+// out the queue early to make room. It must be called before the writer is
+// used.
+func (w *CoalescingWriter) SetLimits(budget *MemoryBudget, policy DropPolicy) {
+	w.budget = budget
+	w.policy = policy
+}
+
+// Drops returns the number of packets the writer has dropped, for lack of
+// room or after an error writing their batch.
+func (w *CoalescingWriter) Drops() uint64 {
+	return w.drops.Load()
+}
+
+// WritePacket queues a copy of pkt; the caller may reuse pkt.Body as soon as
+// it returns. The copy is what counts against the budget: the whole pooled
+// buffer it's in. An error from writing an earlier batch in the background
+// is returned by the next call to WritePacket or Flush.
+func (w *CoalescingWriter) WritePacket(pkt Packet) error {
+	var buf packetBuf
+	buf, pkt.Body = copyBuffer(pkt.Body)
+
+	w.lock.Lock()
+	if w.closed {
+		w.lock.Unlock()
+		buf.put()
+		return ErrCoalescerClosed
+	}
+	if err := w.err; err != nil {
+		w.err = nil
+		w.lock.Unlock()
+		buf.put()
+		return err
+	}
+	if cost := cap(pkt.Body); !w.budget.reserve(cost) {
+		if !w.makeRoom(cost) {
+			w.lock.Unlock()
+			w.drops.Add(1)
+			buf.put()
+			return nil
+		}
+		// makeRoom may have unlocked w for a flush
+		if w.closed {
+			w.lock.Unlock()
+			w.budget.release(cost)
+			buf.put()
+			return ErrCoalescerClosed
+		}
+	}
+	w.queue = append(w.queue, pkt)
+	w.bufs = append(w.bufs, buf)
+	if len(w.queue) >= w.maxBatch {
+		return w.flushLocked()
+	}
+	if w.timer == nil {
+		w.timer = time.AfterFunc(w.window, w.timerFlush)
+	}
+	w.lock.Unlock()
+	return nil
+}
+
+// Flush writes the queued packets now.
+func (w *CoalescingWriter) Flush() error {
+	w.lock.Lock()
+	if err := w.err; err != nil {
+		w.err = nil
+		w.lock.Unlock()
+		return err
+	}
+	return w.flushLocked()
+}
+
+// Close flushes the queued packets and stops the writer. It doesn't close
+// the Interface.
+func (w *CoalescingWriter) Close() error {
+	w.lock.Lock()
+	if w.closed {
+		w.lock.Unlock()
+		return nil
+	}
+	w.closed = true
+	err := w.err
+	w.err = nil
+	if ferr := w.flushLocked(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// makeRoom tries to free cost bytes of the budget according to the policy,
+// and reserves them. It's called with w locked, and returns with w locked.
+func (w *CoalescingWriter) makeRoom(cost int) bool {
+	switch w.policy {
+	case DropOldest:
+		for len(w.queue) != 0 {
+			w.budget.release(cap(w.queue[0].Body))
+			w.bufs[0].put()
+			w.queue, w.bufs = w.queue[1:], w.bufs[1:]
+			w.drops.Add(1)
+			if w.budget.reserve(cost) {
+				return true
+			}
+		}
+	case BlockWhenFull:
+		if len(w.queue) != 0 {
+			if err := w.flushLocked(); err != nil {
+				w.lock.Lock()
+				if w.err == nil {
+					w.err = err
+				}
+			} else {
+				w.lock.Lock()
+			}
+			return w.budget.reserve(cost)
+		}
+	}
+	return false
+}
+
+func (w *CoalescingWriter) timerFlush() {
+	w.lock.Lock()
+	if err := w.flushLocked(); err != nil {
+		w.lock.Lock()
+		if w.err == nil {
+			w.err = err
+		}
+		w.lock.Unlock()
+	}
+}
+
+// flushLocked takes the queue, unlocks w and writes the queue out. Batches
+// are written in order: a flush waits for the previous one to complete.
+func (w *CoalescingWriter) flushLocked() error {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	queue, bufs := w.queue, w.bufs
+	w.queue, w.bufs = nil, nil
+	prev, done := w.flushed, make(chan struct{})
+	w.flushed = done
+	w.lock.Unlock()
+
+	<-prev
+	defer close(done)
+	if len(queue) == 0 {
+		return nil
+	}
+	n, err := w.t.writeBatch(queue)
+	if err != nil {
+		w.drops.Add(uint64(len(queue) - n))
+	}
+	for i, buf := range bufs {
+		w.budget.release(cap(queue[i].Body))
+		buf.put()
+	}
+	return err
+}
+
+//-----------------------------------------------------------------------------
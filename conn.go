@@ -0,0 +1,157 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//-----------------------------------------------------------------------------
+// Standard library views of an Interface, for the code written against io
+// and net rather than Packets: ReadWriteCloser and PacketConn. Like the
+// device, both are packet oriented, each Read or Write being one packet, so
+// that unlike Serial's stream, an io.Copy between them and a datagram socket
+// keeps the packets whole. The packets are the Bodies of the Packets: IP
+// packets for DevTun, Ethernet frames for DevTap, without the header the
+// device puts in front of them.
+
+// InterfaceAddr is the net.Addr of an Interface, its name.
+type InterfaceAddr struct {
+	Name string
+	Kind DevKind
+}
+
+// Network returns "tun" or "tap".
+func (a *InterfaceAddr) Network() string {
+	if a.Kind == DevTap {
+		return "tap"
+	}
+	return "tun"
+}
+
+func (a *InterfaceAddr) String() string {
+	return a.Name
+}
+
+// ReadWriteCloser returns the Interface as an io.ReadWriteCloser, whose
+// Read reads a packet, Write writes one, and Close closes the Interface.
+func (t *Interface) ReadWriteCloser() io.ReadWriteCloser {
+	return &packetConn{t}
+}
+
+// PacketConn returns the Interface as a net.PacketConn, whose ReadFrom
+// reads a packet, WriteTo writes one, and Close closes the Interface. The
+// addresses are the Interface's InterfaceAddr, which WriteTo ignores. The
+// deadlines apply to the reads and writes of the Interface, ReadPacket and
+// WritePacket included.
+func (t *Interface) PacketConn() net.PacketConn {
+	return &packetConn{t}
+}
+
+// packetConn is the ReadWriteCloser and the PacketConn of an Interface.
+type packetConn struct {
+	t *Interface
+}
+
+// Read reads a packet into b. Reading goes through b, which must hold the
+// device's 4-byte header as well as the packet, so MaxPacket+4 bytes; of a
+// packet too large, the start is returned, with io.ErrShortBuffer. The
+// virtio-net header of an Interface opened WithVnetHdr is dropped.
+func (c *packetConn) Read(b []byte) (int, error) {
+	pkt, err := c.t.ReadPacket(b)
+	if err != nil {
+		return 0, connErr(err)
+	}
+	n := copy(b, pkt.Body)
+	if pkt.Truncated {
+		return n, io.ErrShortBuffer
+	}
+	return n, nil
+}
+
+// Write writes the packet b, whose protocol goes by its IP version (DevTun)
+// or Ethernet type (DevTap). It returns ErrJumboPacket if b is larger than
+// MaxPacket.
+func (c *packetConn) Write(b []byte) (int, error) {
+	pkt := Packet{Body: b}
+	if c.t.kind == DevTap {
+		if len(b) >= 14 {
+			pkt.Protocol = binary.BigEndian.Uint16(b[12:14])
+		}
+	} else {
+		pkt.Protocol = ipProtocol(b)
+	}
+	if err := c.t.WritePacket(pkt); err != nil {
+		return 0, connErr(err)
+	}
+	return len(b), nil
+}
+
+// connErr returns os.ErrDeadlineExceeded for an error of a deadline, as it's
+// a net.Error whose Timeout is true, unlike the *os.PathError around it.
+func connErr(err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return os.ErrDeadlineExceeded
+	}
+	return err
+}
+
+func (c *packetConn) Close() error {
+	return c.t.Close()
+}
+
+// ReadFrom reads a packet into b, like Read.
+func (c *packetConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, err := c.Read(b)
+	if n == 0 && err != nil {
+		return 0, nil, err
+	}
+	return n, c.LocalAddr(), err
+}
+
+// WriteTo writes the packet b, like Write, whatever addr.
+func (c *packetConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.Write(b)
+}
+
+func (c *packetConn) LocalAddr() net.Addr {
+	return &InterfaceAddr{Name: c.t.name, Kind: c.t.kind}
+}
+
+func (c *packetConn) SetDeadline(d time.Time) error {
+	if err := c.SetReadDeadline(d); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(d)
+}
+
+// SetReadDeadline sets the read deadline, which the ReadPacketContext calls
+// interrupting the reads put back when they're done.
+func (c *packetConn) SetReadDeadline(d time.Time) error {
+	t := c.t
+	t.interruptLock.Lock()
+	defer t.interruptLock.Unlock()
+	t.readDeadline = d
+	if t.interrupting != 0 {
+		return nil
+	}
+	return t.closedErr(t.file.SetReadDeadline(d))
+}
+
+func (c *packetConn) SetWriteDeadline(d time.Time) error {
+	return c.t.closedErr(c.t.file.SetWriteDeadline(d))
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,460 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// A minimal DHCPv4 server (RFC 2131) for DevTap Interfaces, the last piece
+// of user-mode networking for the virtual machines behind a tap interface,
+// with the ARPResponder: a DHCPServer hands out the address (or the few
+// addresses) of its pool to the clients which ask, with the mask of the
+// subnet and the router, DNS servers and MTU configured, and keeps the
+// leases in memory.
+//
+// Answer builds the reply to a request read, for the application to write
+// back; an Interface opened WithDHCPServer does it as it reads, and only
+// passes on the DHCP it didn't answer. Relayed requests aren't answered,
+// and the leases are for the client's MAC address, whatever its client
+// identifier: it's for a link with a host or two, not for a network.
+
+var ErrDHCPConfig = errors.New("invalid DHCP server configuration")
+
+// DHCP message types and options
+const (
+	dhcpDiscover = 1
+	dhcpOffer    = 2
+	dhcpRequest  = 3
+	dhcpDecline  = 4
+	dhcpAck      = 5
+	dhcpNak      = 6
+	dhcpRelease  = 7
+	dhcpInform   = 8
+
+	dhcpOptMask      = 1
+	dhcpOptRouter    = 3
+	dhcpOptDNS       = 6
+	dhcpOptMTU       = 26
+	dhcpOptRequested = 50
+	dhcpOptLease     = 51
+	dhcpOptType      = 53
+	dhcpOptServerID  = 54
+	dhcpOptEnd       = 255
+)
+
+// the magic cookie after the fixed part of a DHCP message
+var dhcpMagic = []byte{99, 130, 83, 99}
+
+// DHCPServerConfig configures a DHCPServer. Zero fields take the defaults.
+type DHCPServerConfig struct {
+	// the subnet of the clients, and the address and MAC address of the
+	// server in it; required
+	Subnet netip.Prefix
+	Server netip.Addr
+	MAC    net.HardwareAddr
+	// the first address handed out, the one after Server by default, and
+	// how many from there, 1 by default
+	Start netip.Addr
+	Count int
+	// the options given the clients, if set
+	Router netip.Addr
+	DNS    []netip.Addr
+	MTU    int
+	// how long the leases last, 1h by default
+	LeaseTime time.Duration
+}
+
+// DHCPLease is an address a DHCPServer handed out.
+type DHCPLease struct {
+	MAC     net.HardwareAddr
+	Addr    netip.Addr
+	Expires time.Time
+}
+
+// DHCPServer answers DHCP requests with its pool's addresses. It is safe for
+// concurrent use.
+type DHCPServer struct {
+	cfg  DHCPServerConfig
+	pool []netip.Addr
+
+	lock sync.Mutex
+	// the leases, and the offers, by MAC address
+	leases map[[6]byte]*dhcpLease
+	// the addresses declined, found in use by another host, until when
+	declined map[netip.Addr]time.Time
+}
+
+type dhcpLease struct {
+	addr    netip.Addr
+	expires time.Time
+	bound   bool // acknowledged, not just offered
+}
+
+// WithDHCPServer has the Interface, which must be a DevTap, answer the DHCP
+// requests it reads with s, rather than return them.
+func WithDHCPServer(s *DHCPServer) Option {
+	return func(c *config) { c.dhcp = s }
+}
+
+// NewDHCPServer returns a server with no leases. It returns ErrDHCPConfig if
+// the pool or server aren't IPv4 addresses of the subnet.
+func NewDHCPServer(cfg DHCPServerConfig) (*DHCPServer, error) {
+	subnet, _, ok := canonical(cfg.Subnet)
+	if !ok || !subnet.Addr().Is4() || subnet.Bits() > 30 || len(cfg.MAC) != 6 {
+		return nil, ErrDHCPConfig
+	}
+	cfg.Subnet, cfg.Server = subnet, cfg.Server.Unmap()
+	cfg.MAC = append(net.HardwareAddr(nil), cfg.MAC...)
+	if !cfg.Start.IsValid() {
+		cfg.Start = cfg.Server.Next()
+	}
+	if cfg.Count <= 0 {
+		cfg.Count = 1
+	}
+	if cfg.LeaseTime <= 0 {
+		cfg.LeaseTime = time.Hour
+	}
+	if !subnet.Contains(cfg.Server) {
+		return nil, ErrDHCPConfig
+	}
+	s := &DHCPServer{
+		cfg:      cfg,
+		leases:   make(map[[6]byte]*dhcpLease),
+		declined: make(map[netip.Addr]time.Time),
+	}
+	bcast := lastAddr(subnet)
+	for a, i := cfg.Start.Unmap(), 0; i < cfg.Count; a, i = a.Next(), i+1 {
+		if !subnet.Contains(a) || a == subnet.Addr() || a == bcast || a == cfg.Server {
+			return nil, ErrDHCPConfig
+		}
+		s.pool = append(s.pool, a)
+	}
+	return s, nil
+}
+
+// SetMTU sets the MTU given the clients from now on, none if under 68. It
+// fits SetTunnelMTU's notify, so that the clients follow the Interface's
+// MTU:
+//
+//	t.SetTunnelMTU(overhead, server.SetMTU)
+//
+// The clients only get it as they renew their leases.
+func (s *DHCPServer) SetMTU(mtu int) {
+	s.lock.Lock()
+	s.cfg.MTU = mtu
+	s.lock.Unlock()
+}
+
+// lastAddr returns the last address of the IPv4 prefix p, its broadcast
+// address.
+func lastAddr(p netip.Prefix) netip.Addr {
+	a := p.Addr().As4()
+	n := binary.BigEndian.Uint32(a[:]) | (1<<uint(32-p.Bits()) - 1)
+	binary.BigEndian.PutUint32(a[:], n)
+	return netip.AddrFrom4(a)
+}
+
+// Leases returns the addresses leased, in order.
+func (s *DHCPServer) Leases() []DHCPLease {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var leases []DHCPLease
+	now := time.Now()
+	for mac, l := range s.leases {
+		if l.bound && now.Before(l.expires) {
+			leases = append(leases, DHCPLease{MAC: append(net.HardwareAddr(nil), mac[:]...), Addr: l.addr, Expires: l.expires})
+		}
+	}
+	sort.Slice(leases, func(i, j int) bool { return leases[i].Addr.Less(leases[j].Addr) })
+	return leases
+}
+
+// dhcpOptions returns the options of the DHCP message m, by code, and false
+// if m is malformed.
+func dhcpOptions(m []byte) (map[byte][]byte, bool) {
+	if len(m) < 240 || !bytes.Equal(m[236:240], dhcpMagic) {
+		return nil, false
+	}
+	opts := make(map[byte][]byte)
+	for b := m[240:]; len(b) != 0 && b[0] != dhcpOptEnd; {
+		if b[0] == 0 {
+			b = b[1:]
+			continue
+		}
+		if len(b) < 2 || len(b) < 2+int(b[1]) {
+			return nil, false
+		}
+		// options repeated are concatenated (RFC 3396)
+		opts[b[0]] = append(opts[b[0]], b[2:2+b[1]]...)
+		b = b[2+b[1]:]
+	}
+	return opts, true
+}
+
+// optAddr returns the IPv4 address of the option (or field) o, if it is one
+// and isn't 0.0.0.0.
+func optAddr(o []byte) netip.Addr {
+	if len(o) != 4 || o[0]|o[1]|o[2]|o[3] == 0 {
+		return netip.Addr{}
+	}
+	return netip.AddrFrom4([4]byte{o[0], o[1], o[2], o[3]})
+}
+
+// Answer returns the reply to pkt, and true, if pkt is a DHCP request s
+// answers. Releases and declines need no reply, but return true as well,
+// with an empty Packet.
+func (s *DHCPServer) Answer(pkt *Packet) (Packet, bool) {
+	ip := pkt.ip()
+	proto, at, frag := pkt.IPProto()
+	if pkt.Protocol != ETH_P_IP || pkt.L3Offset < 14 || proto != 17 || frag || at == 0 {
+		return Packet{}, false
+	}
+	at -= pkt.L3Offset
+	end := int(binary.BigEndian.Uint16(ip[2:]))
+	if end > len(ip) || at+8 > end {
+		return Packet{}, false
+	}
+	u := ip[at:end]
+	if binary.BigEndian.Uint16(u[0:]) != 68 || binary.BigEndian.Uint16(u[2:]) != 67 {
+		return Packet{}, false
+	}
+	m := u[8:]
+	opts, ok := dhcpOptions(m)
+	// requests only, from Ethernet clients, not relayed
+	if !ok || m[0] != 1 || m[1] != 1 || m[2] != 6 || optAddr(m[24:28]).IsValid() || len(opts[dhcpOptType]) != 1 {
+		return Packet{}, false
+	}
+	var mac [6]byte
+	copy(mac[:], m[28:34])
+	if id := optAddr(opts[dhcpOptServerID]); id.IsValid() && id != s.cfg.Server {
+		// for another server; the client took its offer
+		s.lock.Lock()
+		if l := s.leases[mac]; l != nil && !l.bound {
+			delete(s.leases, mac)
+		}
+		s.lock.Unlock()
+		return Packet{}, false
+	}
+	ciaddr := optAddr(m[12:16])
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	switch opts[dhcpOptType][0] {
+	case dhcpDiscover:
+		addr := s.allocate(mac, optAddr(opts[dhcpOptRequested]), now)
+		if !addr.IsValid() {
+			// the pool is exhausted
+			return Packet{}, false
+		}
+		if l := s.leases[mac]; l == nil || l.addr != addr {
+			s.leases[mac] = &dhcpLease{addr: addr, expires: now.Add(time.Minute)}
+		}
+		return s.reply(pkt, m, dhcpOffer, addr, ciaddr), true
+	case dhcpRequest:
+		want := optAddr(opts[dhcpOptRequested])
+		if !want.IsValid() {
+			// renewing or rebinding
+			want = ciaddr
+		}
+		if addr := s.allocate(mac, want, now); addr.IsValid() && addr == want {
+			s.leases[mac] = &dhcpLease{addr: addr, expires: now.Add(s.cfg.LeaseTime), bound: true}
+			return s.reply(pkt, m, dhcpAck, addr, ciaddr), true
+		}
+		if !s.cfg.Subnet.Contains(want) {
+			if id := optAddr(opts[dhcpOptServerID]); !id.IsValid() {
+				// a client rebooting on another network is for its server to
+				// set straight (RFC 2131, 4.3.2)
+				return Packet{}, false
+			}
+		}
+		delete(s.leases, mac)
+		return s.reply(pkt, m, dhcpNak, netip.Addr{}, netip.Addr{}), true
+	case dhcpDecline:
+		if l := s.leases[mac]; l != nil && l.addr == optAddr(opts[dhcpOptRequested]) {
+			s.declined[l.addr] = now.Add(s.cfg.LeaseTime)
+			delete(s.leases, mac)
+		}
+		return Packet{}, true
+	case dhcpRelease:
+		if l := s.leases[mac]; l != nil && l.addr == ciaddr {
+			delete(s.leases, mac)
+		}
+		return Packet{}, true
+	case dhcpInform:
+		return s.reply(pkt, m, dhcpAck, netip.Addr{}, ciaddr), true
+	}
+	return Packet{}, false
+}
+
+// allocate returns the address to lease to mac: its own, want if it's
+// free, or the first free one of the pool; the zero Addr if none is. It's
+// called with s locked.
+func (s *DHCPServer) allocate(mac [6]byte, want netip.Addr, now time.Time) netip.Addr {
+	taken := make(map[netip.Addr]bool)
+	for m, l := range s.leases {
+		if now.After(l.expires) {
+			delete(s.leases, m)
+		} else if m != mac {
+			taken[l.addr] = true
+		}
+	}
+	for a, until := range s.declined {
+		if now.After(until) {
+			delete(s.declined, a)
+		} else {
+			taken[a] = true
+		}
+	}
+	free := func(a netip.Addr) bool {
+		if taken[a] {
+			return false
+		}
+		for _, p := range s.pool {
+			if p == a {
+				return true
+			}
+		}
+		return false
+	}
+	if l := s.leases[mac]; l != nil && (!want.IsValid() || want == l.addr) && free(l.addr) {
+		return l.addr
+	}
+	if want.IsValid() && free(want) {
+		return want
+	}
+	for _, a := range s.pool {
+		if free(a) {
+			return a
+		}
+	}
+	return netip.Addr{}
+}
+
+// reply returns the DHCP message typ answering the request m of pkt, leasing
+// addr, if valid, to the client at ciaddr, if valid.
+func (s *DHCPServer) reply(pkt *Packet, m []byte, typ byte, addr, ciaddr netip.Addr) Packet {
+	r := make([]byte, 236, 300)
+	r[0], r[1], r[2] = 2, 1, 6
+	copy(r[4:8], m[4:8])     // the transaction ID
+	copy(r[10:12], m[10:12]) // the flags
+	copy(r[28:44], m[28:44]) // the client's hardware address
+	server := s.cfg.Server.As4()
+	if typ != dhcpNak {
+		copy(r[12:16], m[12:16])
+		if addr.IsValid() {
+			a := addr.As4()
+			copy(r[16:20], a[:])
+		}
+		copy(r[20:24], server[:])
+	}
+	r = append(r, dhcpMagic...)
+	r = append(r, dhcpOptType, 1, typ, dhcpOptServerID, 4)
+	r = append(r, server[:]...)
+	if typ != dhcpNak {
+		if addr.IsValid() {
+			r = append(r, dhcpOptLease, 4)
+			r = binary.BigEndian.AppendUint32(r, uint32(s.cfg.LeaseTime/time.Second))
+		}
+		mask := net.CIDRMask(s.cfg.Subnet.Bits(), 32)
+		r = append(r, dhcpOptMask, 4)
+		r = append(r, mask...)
+		if s.cfg.Router.IsValid() && s.cfg.Router.Unmap().Is4() {
+			a := s.cfg.Router.Unmap().As4()
+			r = append(r, dhcpOptRouter, 4)
+			r = append(r, a[:]...)
+		}
+		var dns []byte
+		for _, d := range s.cfg.DNS {
+			if d = d.Unmap(); d.Is4() && len(dns) < 252 {
+				a := d.As4()
+				dns = append(dns, a[:]...)
+			}
+		}
+		if len(dns) != 0 {
+			r = append(r, dhcpOptDNS, byte(len(dns)))
+			r = append(r, dns...)
+		}
+		if s.cfg.MTU >= 68 {
+			r = append(r, dhcpOptMTU, 2)
+			r = binary.BigEndian.AppendUint16(r, uint16(s.cfg.MTU))
+		}
+	}
+	r = append(r, dhcpOptEnd)
+	for len(r) < 300 {
+		// the minimum BOOTP message, which old clients insist on
+		r = append(r, 0)
+	}
+
+	// where to: the client's address, if it has one, or its MAC address,
+	// unless it can't take unicast before it has an address (RFC 2131,
+	// 4.1)
+	dst, dstMAC := netip.AddrFrom4([4]byte{255, 255, 255, 255}), net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	switch {
+	case typ == dhcpNak:
+	case ciaddr.IsValid():
+		dst, dstMAC = ciaddr, pkt.SrcMAC()
+	case m[10]&0x80 == 0 && addr.IsValid():
+		dst, dstMAC = addr, net.HardwareAddr(m[28:34])
+	}
+	b := icmpReply(pkt, 28+len(r))
+	copy(b[0:6], dstMAC)
+	copy(b[6:12], s.cfg.MAC)
+	hdr := len(b)
+	d := dst.As4()
+	b = append(b, 0x45, 0, 0, 0, 0, 0, 0, 0, 64, 17, 0, 0)
+	b = append(b, server[:]...)
+	b = append(b, d[:]...)
+	b = append(b, 0, 67, 0, 68, 0, 0, 0, 0)
+	b = append(b, r...)
+	binary.BigEndian.PutUint16(b[hdr+2:], uint16(len(b)-hdr))
+	binary.BigEndian.PutUint16(b[hdr+10:], checksum(b[hdr:hdr+20]))
+	u := b[hdr+20:]
+	binary.BigEndian.PutUint16(u[4:], uint16(len(u)))
+	binary.BigEndian.PutUint16(u[6:], udp4Checksum(server[:], d[:], u))
+	return Packet{Body: b, Protocol: ETH_P_IP, L3Offset: pkt.L3Offset, Dir: DirWrite}
+}
+
+// udp4Checksum returns the checksum of the UDP datagram u from src to dst,
+// whose checksum field is zero.
+func udp4Checksum(src, dst []byte, u []byte) uint16 {
+	b := make([]byte, 12+len(u))
+	copy(b[0:4], src)
+	copy(b[4:8], dst)
+	b[9] = 17
+	binary.BigEndian.PutUint16(b[10:12], uint16(len(u)))
+	copy(b[12:], u)
+	if sum := checksum(b); sum != 0 {
+		return sum
+	}
+	// 0 is no checksum
+	return 0xffff
+}
+
+// answerDHCP returns whether the packet read, pkt, is a DHCP request the
+// Interface answered.
+func (t *Interface) answerDHCP(pkt *Packet) bool {
+	reply, ok := t.dhcp.Answer(pkt)
+	if ok && reply.Body != nil {
+		// best effort: the client asks again
+		t.writePacket(reply)
+	}
+	return ok
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,548 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math"
+	mrand "math/rand"
+	"net"
+	"net/netip"
+	"path"
+	"sync"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// DHCPv6 prefix delegation (RFC 8415) over the tunnel, for gateways handing
+// out addresses to the networks behind them. A PDClient asks the servers on
+// the link of the Interface (the far end of the tunnel, or a relay there)
+// for a prefix, and keeps it renewed: the usual Solicit, Advertise, Request
+// and Reply, then Renew at T1 and Rebind at T2 until the prefix expires, and
+// a Release when it's stopped. It only does prefix delegation, and takes
+// the first Advertise rather than waiting for better ones.
+//
+// The prefix goes to the application (OnPrefix), and, if Downstream is set,
+// the first address of its first /64 (::1) to that Interface, which is what
+// the hosts behind it get their addresses from. If Advertise is set, the
+// router of that NDPResponder advertises the /64, for the hosts to
+// autoconfigure their addresses in, and withdraws it when it's lost.
+//
+// The client binds the DHCPv6 client port (546) on the link-local address
+// of the Interface, which takes privileges, and waits for the address to be
+// usable (past duplicate address detection) once the interface is up.
+
+var ErrNoPrefix = errors.New("no server delegated a prefix")
+
+// DHCPv6 message types and options
+const (
+	dhcp6Solicit   = 1
+	dhcp6Advertise = 2
+	dhcp6Request   = 3
+	dhcp6Renew     = 5
+	dhcp6Rebind    = 6
+	dhcp6Reply     = 7
+	dhcp6Release   = 8
+
+	dhcp6OptClientID    = 1
+	dhcp6OptServerID    = 2
+	dhcp6OptElapsedTime = 8
+	dhcp6OptStatusCode  = 13
+	dhcp6OptIAPD        = 25
+	dhcp6OptIAPrefix    = 26
+)
+
+// the address of all the DHCPv6 servers and relays on the link
+var dhcp6Servers = net.ParseIP("ff02::1:2")
+
+// PDConfig configures a PDClient. Zero fields take the defaults.
+type PDConfig struct {
+	// the DUID identifying the client to the servers, which should be the
+	// same across runs. By default a DUID-LL made of the MAC address of the
+	// Interface, or a random DUID-UUID for a DevTun one.
+	DUID []byte
+	// the identifier of the prefix delegation, 1 by default
+	IAID uint32
+	// the length of the prefix to hint the servers at, none by default
+	HintLength int
+	// if set, gets the address ::1 of the first /64 of the prefix
+	Downstream *Interface
+	// if set, and a router (see NDPResponder.SetRouter), advertises the
+	// first /64 of the prefix, with its lifetimes, and withdraws it,
+	// advertising it with lifetimes of 0, when it's lost or replaced
+	Advertise *NDPResponder
+	// called, if set, with the prefix when it's delegated, renewed or
+	// changes, and with a zero Prefix when it's lost
+	OnPrefix func(p DelegatedPrefix)
+}
+
+// DelegatedPrefix is a prefix delegated to the client.
+type DelegatedPrefix struct {
+	Prefix netip.Prefix
+	// how long the prefix is preferred, and valid, from Obtained
+	Preferred, Valid time.Duration
+	Obtained         time.Time
+}
+
+// PDClient obtains a prefix delegated over an Interface, and keeps it.
+type PDClient struct {
+	t   *Interface
+	cfg PDConfig
+
+	lock   sync.Mutex
+	prefix DelegatedPrefix
+	// the downstream address assigned
+	assigned netip.Prefix
+	// the /64 last withdrawn from Advertise
+	withdrawn netip.Prefix
+}
+
+// NewPDClient returns a client for prefixes delegated over t. Run runs it.
+func NewPDClient(t *Interface, cfg PDConfig) *PDClient {
+	if cfg.IAID == 0 {
+		cfg.IAID = 1
+	}
+	if cfg.DUID == nil {
+		if mac, _ := t.HardwareAddr(); len(mac) != 0 {
+			cfg.DUID = append([]byte{0, 3, 0, 1}, mac...)
+		} else {
+			cfg.DUID = make([]byte, 2+16)
+			cfg.DUID[1] = 4
+			rand.Read(cfg.DUID[2:])
+		}
+	}
+	return &PDClient{t: t, cfg: cfg}
+}
+
+// Prefix returns the prefix delegated, false if there's none.
+func (c *PDClient) Prefix() (DelegatedPrefix, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.prefix, c.prefix.Prefix.IsValid()
+}
+
+// Run obtains a prefix and keeps it until ctx is done, obtaining another
+// when it's lost, and then releases it and returns ctx's error. It returns
+// other errors if it can't talk DHCPv6 on the Interface.
+func (c *PDClient) Run(ctx context.Context) error {
+	conn, err := c.listen(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	for {
+		server, p, t1, t2, err := c.obtain(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for err == nil {
+			c.set(p)
+			server, p, t1, t2, err = c.keep(ctx, conn, server, p, t1, t2)
+		}
+		if ctx.Err() != nil {
+			c.release(conn, server, p)
+		}
+		c.set(DelegatedPrefix{})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// listen binds the client port on the link-local address of the
+// interface, once it's usable.
+func (c *PDClient) listen(ctx context.Context) (*net.UDPConn, error) {
+	name := path.Base(c.t.Name())
+	for {
+		itf, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := itf.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			ipn, ok := a.(*net.IPNet)
+			if !ok || !ipn.IP.IsLinkLocalUnicast() || ipn.IP.To4() != nil {
+				continue
+			}
+			conn, lerr := net.ListenUDP("udp6", &net.UDPAddr{IP: ipn.IP, Port: 546, Zone: name})
+			if lerr == nil {
+				return conn, nil
+			}
+			err = lerr
+		}
+		// no address yet, or a tentative one
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				err = ctx.Err()
+			}
+			return nil, err
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// a DHCPv6 message
+type dhcp6Msg struct {
+	typ  uint8
+	xid  [3]byte
+	opts map[uint16][][]byte
+}
+
+// parseDHCP6 parses the DHCPv6 message b.
+func parseDHCP6(b []byte) (*dhcp6Msg, bool) {
+	if len(b) < 4 {
+		return nil, false
+	}
+	m := &dhcp6Msg{typ: b[0]}
+	copy(m.xid[:], b[1:4])
+	opts, ok := dhcp6Options(b[4:])
+	m.opts = opts
+	return m, ok
+}
+
+// dhcp6Options parses the options b.
+func dhcp6Options(b []byte) (map[uint16][][]byte, bool) {
+	opts := make(map[uint16][][]byte)
+	for len(b) != 0 {
+		if len(b) < 4 {
+			return nil, false
+		}
+		code, l := binary.BigEndian.Uint16(b), int(binary.BigEndian.Uint16(b[2:]))
+		if 4+l > len(b) {
+			return nil, false
+		}
+		opts[code] = append(opts[code], b[4:4+l])
+		b = b[4+l:]
+	}
+	return opts, true
+}
+
+// appendDHCP6Opt appends the option code, made of data, to b.
+func appendDHCP6Opt(b []byte, code uint16, data ...[]byte) []byte {
+	l := 0
+	for _, d := range data {
+		l += len(d)
+	}
+	b = binary.BigEndian.AppendUint16(b, code)
+	b = binary.BigEndian.AppendUint16(b, uint16(l))
+	for _, d := range data {
+		b = append(b, d...)
+	}
+	return b
+}
+
+// dhcp6Success returns whether the status code of the options, if any, is
+// Success.
+func dhcp6Success(opts map[uint16][][]byte) bool {
+	s := opts[dhcp6OptStatusCode]
+	return len(s) == 0 || len(s[0]) >= 2 && binary.BigEndian.Uint16(s[0]) == 0
+}
+
+// iaPD builds the IA_PD option of the client, with the prefix p if valid,
+// or the hint.
+func (c *PDClient) iaPD(p netip.Prefix) []byte {
+	var ia [12]byte
+	binary.BigEndian.PutUint32(ia[:], c.cfg.IAID)
+	var prefix []byte
+	switch {
+	case p.IsValid():
+		a := p.Addr().As16()
+		prefix = append(make([]byte, 8), byte(p.Bits()))
+		prefix = appendDHCP6Opt(nil, dhcp6OptIAPrefix, prefix, a[:])
+	case c.cfg.HintLength != 0:
+		prefix = append(make([]byte, 8), byte(c.cfg.HintLength))
+		prefix = appendDHCP6Opt(nil, dhcp6OptIAPrefix, prefix, make([]byte, 16))
+	}
+	return appendDHCP6Opt(nil, dhcp6OptIAPD, ia[:], prefix)
+}
+
+// lease returns the prefix m delegates to the client, and T1 and T2.
+func (c *PDClient) lease(m *dhcp6Msg, now time.Time) (DelegatedPrefix, time.Duration, time.Duration, bool) {
+	if !dhcp6Success(m.opts) {
+		return DelegatedPrefix{}, 0, 0, false
+	}
+	for _, ia := range m.opts[dhcp6OptIAPD] {
+		if len(ia) < 12 || binary.BigEndian.Uint32(ia) != c.cfg.IAID {
+			continue
+		}
+		opts, ok := dhcp6Options(ia[12:])
+		if !ok || !dhcp6Success(opts) {
+			continue
+		}
+		for _, ip := range opts[dhcp6OptIAPrefix] {
+			if len(ip) < 25 {
+				continue
+			}
+			valid := dhcp6Lifetime(ip[4:])
+			if valid == 0 || ip[8] > 128 {
+				continue
+			}
+			var a16 [16]byte
+			copy(a16[:], ip[9:25])
+			a := netip.AddrFrom16(a16)
+			p := DelegatedPrefix{
+				Prefix:    netip.PrefixFrom(a, int(ip[8])).Masked(),
+				Preferred: dhcp6Lifetime(ip[0:]),
+				Valid:     valid,
+				Obtained:  now,
+			}
+			t1, t2 := dhcp6Lifetime(ia[4:]), dhcp6Lifetime(ia[8:])
+			if t1 == 0 || t2 == 0 || t1 > t2 {
+				// left to the client: RFC 8415 suggests .5 and .8 of the
+				// preferred lifetime
+				t1, t2 = p.Preferred/2, p.Preferred*4/5
+			}
+			return p, t1, t2, true
+		}
+	}
+	return DelegatedPrefix{}, 0, 0, false
+}
+
+// dhcp6Lifetime returns the lifetime in seconds at the start of b, the
+// longest Duration for infinity.
+func dhcp6Lifetime(b []byte) time.Duration {
+	s := binary.BigEndian.Uint32(b)
+	if s == math.MaxUint32 {
+		return math.MaxInt64
+	}
+	return time.Duration(s) * time.Second
+}
+
+// exchange sends a message of type typ with opts to server (nil for any),
+// retransmitting it from irt up to mrt apart, until a reply of type want
+// which accept takes comes, ctx is done, or until is reached.
+func (c *PDClient) exchange(ctx context.Context, conn *net.UDPConn, typ, want uint8, opts []byte, server []byte, irt, mrt time.Duration, until time.Time, accept func(m *dhcp6Msg) bool) (*dhcp6Msg, error) {
+	var xid [3]byte
+	rand.Read(xid[:])
+	start := time.Now()
+	dst := &net.UDPAddr{IP: dhcp6Servers, Port: 547, Zone: path.Base(c.t.Name())}
+	rt := time.Duration(float64(irt) * (1 + 0.2*(mrand.Float64()-0.5)))
+	buf := make([]byte, 1500)
+	for {
+		elapsed := time.Since(start) / (10 * time.Millisecond)
+		if elapsed > 0xffff {
+			elapsed = 0xffff
+		}
+		var el [2]byte
+		binary.BigEndian.PutUint16(el[:], uint16(elapsed))
+		msg := append([]byte{typ}, xid[:]...)
+		msg = appendDHCP6Opt(msg, dhcp6OptClientID, c.cfg.DUID)
+		msg = appendDHCP6Opt(msg, dhcp6OptElapsedTime, el[:])
+		msg = append(msg, opts...)
+		if _, err := conn.WriteToUDP(msg, dst); err != nil && ctx.Err() == nil {
+			return nil, err
+		}
+
+		deadline := time.Now().Add(rt)
+		if !until.IsZero() && until.Before(deadline) {
+			deadline = until
+		}
+		conn.SetReadDeadline(deadline)
+		for {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			n, _, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			m, ok := parseDHCP6(buf[:n])
+			if !ok || m.typ != want || m.xid != xid {
+				continue
+			}
+			if id := m.opts[dhcp6OptClientID]; len(id) == 0 || !bytes.Equal(id[0], c.cfg.DUID) {
+				continue
+			}
+			if id := m.opts[dhcp6OptServerID]; len(id) == 0 || server != nil && !bytes.Equal(id[0], server) {
+				continue
+			}
+			if accept(m) {
+				return m, nil
+			}
+		}
+		if !until.IsZero() && !time.Now().Before(until) {
+			return nil, ErrNoPrefix
+		}
+		rt = 2*rt + time.Duration(float64(rt)*0.2*(mrand.Float64()-0.5))
+		if mrt != 0 && rt > mrt {
+			rt = time.Duration(float64(mrt) * (1 + 0.2*(mrand.Float64()-0.5)))
+		}
+	}
+}
+
+// obtain solicits a prefix, and requests it from the first server to
+// advertise one, until one is delegated or ctx is done.
+func (c *PDClient) obtain(ctx context.Context, conn *net.UDPConn) ([]byte, DelegatedPrefix, time.Duration, time.Duration, error) {
+	for {
+		adv, err := c.exchange(ctx, conn, dhcp6Solicit, dhcp6Advertise, c.iaPD(netip.Prefix{}), nil, time.Second, time.Hour, time.Time{}, func(m *dhcp6Msg) bool {
+			_, _, _, ok := c.lease(m, time.Now())
+			return ok
+		})
+		if err != nil {
+			return nil, DelegatedPrefix{}, 0, 0, err
+		}
+		server := adv.opts[dhcp6OptServerID][0]
+		offered, _, _, _ := c.lease(adv, time.Now())
+		opts := appendDHCP6Opt(nil, dhcp6OptServerID, server)
+		opts = append(opts, c.iaPD(offered.Prefix)...)
+		var p DelegatedPrefix
+		var t1, t2 time.Duration
+		_, err = c.exchange(ctx, conn, dhcp6Request, dhcp6Reply, opts, server, time.Second, 30*time.Second, time.Now().Add(time.Minute), func(m *dhcp6Msg) bool {
+			var ok bool
+			p, t1, t2, ok = c.lease(m, time.Now())
+			return ok
+		})
+		if err == nil {
+			return server, p, t1, t2, nil
+		}
+		if ctx.Err() != nil {
+			return nil, DelegatedPrefix{}, 0, 0, ctx.Err()
+		}
+		// the server didn't come through: solicit again
+	}
+}
+
+// keep renews p with server at T1, or with any server at T2, until it
+// expires; it returns the server and prefix renewed, or the ones given and
+// an error once the prefix is lost or ctx is done.
+func (c *PDClient) keep(ctx context.Context, conn *net.UDPConn, server []byte, p DelegatedPrefix, t1, t2 time.Duration) ([]byte, DelegatedPrefix, time.Duration, time.Duration, error) {
+	if t1 == math.MaxInt64 {
+		<-ctx.Done()
+		return server, p, t1, t2, ctx.Err()
+	}
+	expiry := p.Obtained.Add(p.Valid)
+	if p.Valid == math.MaxInt64 {
+		expiry = time.Time{}
+	}
+	select {
+	case <-ctx.Done():
+		return server, p, t1, t2, ctx.Err()
+	case <-time.After(time.Until(p.Obtained.Add(t1))):
+	}
+	var np DelegatedPrefix
+	var nt1, nt2 time.Duration
+	accept := func(m *dhcp6Msg) bool {
+		var ok bool
+		np, nt1, nt2, ok = c.lease(m, time.Now())
+		return ok
+	}
+
+	// Renew with the server until T2
+	rebind := p.Obtained.Add(t2)
+	opts := appendDHCP6Opt(nil, dhcp6OptServerID, server)
+	opts = append(opts, c.iaPD(p.Prefix)...)
+	if !expiry.IsZero() && expiry.Before(rebind) {
+		rebind = expiry
+	}
+	m, err := c.exchange(ctx, conn, dhcp6Renew, dhcp6Reply, opts, server, 10*time.Second, 10*time.Minute, rebind, accept)
+	if err == nil {
+		return m.opts[dhcp6OptServerID][0], np, nt1, nt2, nil
+	}
+	if ctx.Err() != nil {
+		return server, p, t1, t2, ctx.Err()
+	}
+	// then Rebind with any server until it expires
+	m, err = c.exchange(ctx, conn, dhcp6Rebind, dhcp6Reply, c.iaPD(p.Prefix), nil, 10*time.Second, 10*time.Minute, expiry, accept)
+	if err != nil {
+		return server, p, t1, t2, err
+	}
+	return m.opts[dhcp6OptServerID][0], np, nt1, nt2, nil
+}
+
+// release gives p back to server, sending the Release once.
+func (c *PDClient) release(conn *net.UDPConn, server []byte, p DelegatedPrefix) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	conn.SetReadDeadline(time.Time{})
+	opts := appendDHCP6Opt(nil, dhcp6OptServerID, server)
+	opts = append(opts, c.iaPD(p.Prefix)...)
+	c.exchange(ctx, conn, dhcp6Release, dhcp6Reply, opts, server, time.Second, 0, time.Now().Add(time.Second), func(*dhcp6Msg) bool { return true })
+}
+
+// set makes p the prefix delegated, assigning it downstream and telling the
+// application.
+func (c *PDClient) set(p DelegatedPrefix) {
+	c.lock.Lock()
+	old := c.prefix
+	c.prefix = p
+	if p.Prefix != old.Prefix && c.cfg.Downstream != nil {
+		if c.assigned.IsValid() {
+			c.cfg.Downstream.DelPrefix(c.assigned)
+			c.assigned = netip.Prefix{}
+		}
+		if p.Prefix.IsValid() && p.Prefix.Bits() <= 64 {
+			a := p.Prefix.Addr().As16()
+			a[15] = 1
+			assigned := netip.PrefixFrom(netip.AddrFrom16(a), 64)
+			if c.cfg.Downstream.AddPrefix(assigned) == nil {
+				c.assigned = assigned
+			}
+		}
+	}
+	if c.cfg.Advertise != nil {
+		c.advertise(old, p)
+	}
+	c.lock.Unlock()
+	if c.cfg.OnPrefix != nil && (p.Prefix.IsValid() || old.Prefix.IsValid()) {
+		c.cfg.OnPrefix(p)
+	}
+}
+
+// advertise has the router of Advertise withdraw the first /64 of old, if it
+// isn't that of p, and advertise that of p.
+func (c *PDClient) advertise(old, p DelegatedPrefix) {
+	r := c.cfg.Advertise
+	old64, p64 := first64(old.Prefix), first64(p.Prefix)
+	if old64.IsValid() && old64 != p64 {
+		// only the last one withdrawn stays advertised
+		if c.withdrawn.IsValid() {
+			r.RemovePrefix(c.withdrawn)
+		}
+		r.SetPrefix(RouterPrefix{Prefix: old64, ValidLifetime: -1, PreferredLifetime: -1})
+		c.withdrawn = old64
+	}
+	if !p64.IsValid() {
+		return
+	}
+	if p64 == c.withdrawn {
+		c.withdrawn = netip.Prefix{}
+	}
+	preferred := p.Preferred
+	if preferred == 0 {
+		preferred = -1
+	}
+	r.SetPrefix(RouterPrefix{Prefix: p64, ValidLifetime: p.Valid, PreferredLifetime: preferred})
+}
+
+// first64 returns the first /64 of p, if p is one or shorter.
+func first64(p netip.Prefix) netip.Prefix {
+	if !p.IsValid() || !p.Addr().Is6() || p.Bits() > 64 {
+		return netip.Prefix{}
+	}
+	return netip.PrefixFrom(p.Addr(), 64).Masked()
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,110 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// Dual-stack diagnostics. When "the VPN doesn't work", it's often one family
+// that's broken while the other is fine, and applications doing happy
+// eyeballs hide which. ProbeDualStack connects over IPv4 and IPv6 at the same
+// time from the tunnel's own addresses, so that the probes go through the
+// tunnel, and reports per family whether the target answered and how fast.
+
+// FamilyProbe is the outcome of probing one address family.
+type FamilyProbe struct {
+	Target    string // host:port
+	Source    net.IP // the tunnel address probed from
+	Reachable bool
+	// how long the target took to answer
+	Latency time.Duration
+	// why the target isn't reachable
+	Err error
+}
+
+func (p FamilyProbe) String() string {
+	if p.Reachable {
+		return fmt.Sprintf("%s reachable in %v", p.Target, p.Latency.Round(time.Microsecond))
+	}
+	return fmt.Sprintf("%s unreachable: %v", p.Target, p.Err)
+}
+
+// DualStackReport is the outcome of ProbeDualStack.
+type DualStackReport struct {
+	IPv4, IPv6 FamilyProbe
+}
+
+func (r DualStackReport) String() string {
+	return "IPv4: " + r.IPv4.String() + "; IPv6: " + r.IPv6.String()
+}
+
+// ProbeDualStack opens TCP connections to target4 and target6 (host:port,
+// the host an address of the right family) at once, from the Interface's
+// IPv4 and IPv6 addresses, and reports how each went. A target refusing the
+// connection is reachable: the refusal came back through the tunnel. The
+// probes give up when ctx is done.
+func (t *Interface) ProbeDualStack(ctx context.Context, target4, target6 string) DualStackReport {
+	addrs, err := t.GetAddrList()
+	var r DualStackReport
+	done := make(chan struct{})
+	go func() {
+		r.IPv4 = probeFamily(ctx, "tcp4", target4, pickSource(addrs, true), err)
+		close(done)
+	}()
+	r.IPv6 = probeFamily(ctx, "tcp6", target6, pickSource(addrs, false), err)
+	<-done
+	return r
+}
+
+// pickSource returns the first address of addrs of the given family which
+// isn't link-local, or nil.
+func pickSource(addrs [][]byte, ipv4 bool) net.IP {
+	for _, a := range addrs {
+		ip := net.IP(a)
+		if (ip.To4() != nil) == ipv4 && !ip.IsLinkLocalUnicast() {
+			return ip
+		}
+	}
+	return nil
+}
+
+// probeFamily connects to target over network from source.
+func probeFamily(ctx context.Context, network, target string, source net.IP, addrErr error) FamilyProbe {
+	p := FamilyProbe{Target: target, Source: source}
+	switch {
+	case addrErr != nil:
+		p.Err = addrErr
+		return p
+	case source == nil:
+		p.Err = errors.New("no address of the family on the interface")
+		return p
+	}
+	d := net.Dialer{LocalAddr: &net.TCPAddr{IP: source}}
+	start := time.Now()
+	c, err := d.DialContext(ctx, network, target)
+	p.Latency = time.Since(start)
+	if err == nil {
+		c.Close()
+		p.Reachable = true
+	} else if errors.Is(err, syscall.ECONNREFUSED) {
+		p.Reachable = true
+	} else {
+		p.Err = err
+	}
+	return p
+}
+
+//-----------------------------------------------------------------------------
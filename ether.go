@@ -0,0 +1,74 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+//-----------------------------------------------------------------------------
+// Accessors of the Ethernet header of DevTap packets. They go by L3Offset,
+// so they work on the Packets the package returns for DevTap Interfaces and
+// on those made with ParseFrame, and return zero values for DevTun packets,
+// which have no Ethernet header.
+
+// frame returns the Ethernet header, tags included, or nil if there's none.
+func (p *Packet) frame() []byte {
+	if p.L3Offset < 14 || p.L3Offset > len(p.Body) {
+		return nil
+	}
+	return p.Body[:p.L3Offset]
+}
+
+// DstMAC returns the destination MAC address of the frame. The address
+// aliases Body.
+func (p *Packet) DstMAC() net.HardwareAddr {
+	if f := p.frame(); f != nil {
+		return net.HardwareAddr(f[0:6])
+	}
+	return nil
+}
+
+// SrcMAC returns the source MAC address of the frame. The address aliases
+// Body.
+func (p *Packet) SrcMAC() net.HardwareAddr {
+	if f := p.frame(); f != nil {
+		return net.HardwareAddr(f[6:12])
+	}
+	return nil
+}
+
+// EtherType returns the type field of the Ethernet header: ETH_P_8021Q or
+// ETH_P_8021AD for a tagged frame, whose payload's type is in Protocol.
+func (p *Packet) EtherType() uint16 {
+	if f := p.frame(); f != nil {
+		return binary.BigEndian.Uint16(f[12:14])
+	}
+	return 0
+}
+
+// VLAN returns the VLAN ID and priority (PCP) of the outermost 802.1Q (or
+// 802.1ad) tag of the frame, and false if it isn't tagged.
+func (p *Packet) VLAN() (id int, pcp int, ok bool) {
+	f := p.frame()
+	if len(f) < 18 {
+		return 0, 0, false
+	}
+	tci := binary.BigEndian.Uint16(f[14:16])
+	return int(tci & 0xfff), int(tci >> 13), true
+}
+
+// Payload returns the payload of the frame behind the Ethernet header and
+// tags (the IP packet, for IP), or the whole Body of a DevTun packet.
+func (p *Packet) Payload() []byte {
+	return p.ip()
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,94 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+//-----------------------------------------------------------------------------
+// Demultiplexing of the frames of DevTap Interfaces by EtherType. Besides IP
+// a tap interface carries whatever the host puts on the link: LLDP, slow
+// protocols, experimental types (0x88b5 and 0x88b6, IEEE 802 local
+// experimental)... An EtherTypeMux calls the handler registered for the
+// EtherType of each frame, so that the code for each protocol doesn't have
+// to sift through all the frames.
+//
+// Dispatch does it for a frame read; an Interface opened WithEtherTypeMux
+// does it as it reads, and only returns the frames of the EtherTypes with no
+// handler. The EtherType is the frame's Protocol, so that of the payload of
+// 802.1Q tagged frames. The handlers run on the goroutine reading, and get
+// the frame in the buffer given to ReadPacket, which they must copy what
+// they keep of, as the next read reuses it.
+
+// EtherType values of the protocols commonly seen alongside IP.
+const (
+	ETH_P_SLOW  uint16 = 0x8809 // LACP and the other IEEE 802.3 slow protocols
+	ETH_P_LLDP  uint16 = 0x88cc
+	ETH_P_802EX uint16 = 0x88b5 // IEEE 802 local experimental EtherType 1
+)
+
+// EtherTypeHandler handles a frame of the EtherType it's registered for.
+type EtherTypeHandler func(pkt *Packet)
+
+// EtherTypeMux calls the handlers registered for EtherTypes. It is safe for
+// concurrent use.
+type EtherTypeMux struct {
+	lock     sync.RWMutex
+	handlers map[uint16]EtherTypeHandler
+	handled  atomic.Uint64
+}
+
+// NewEtherTypeMux returns a mux with no handlers.
+func NewEtherTypeMux() *EtherTypeMux {
+	return &EtherTypeMux{handlers: make(map[uint16]EtherTypeHandler)}
+}
+
+// WithEtherTypeMux has the Interface, which must be a DevTap, pass the frames
+// it reads to the handlers of m, rather than return them.
+func WithEtherTypeMux(m *EtherTypeMux) Option {
+	return func(c *config) { c.etherTypes = m }
+}
+
+// Handle registers h for the frames of etherType, replacing the handler
+// registered before, if any.
+func (m *EtherTypeMux) Handle(etherType uint16, h EtherTypeHandler) {
+	m.lock.Lock()
+	m.handlers[etherType] = h
+	m.lock.Unlock()
+}
+
+// Remove unregisters the handler of etherType.
+func (m *EtherTypeMux) Remove(etherType uint16) {
+	m.lock.Lock()
+	delete(m.handlers, etherType)
+	m.lock.Unlock()
+}
+
+// Handled returns the number of frames passed to handlers.
+func (m *EtherTypeMux) Handled() uint64 {
+	return m.handled.Load()
+}
+
+// Dispatch calls the handler of the EtherType of pkt, and returns whether
+// there is one.
+func (m *EtherTypeMux) Dispatch(pkt *Packet) bool {
+	m.lock.RLock()
+	h := m.handlers[pkt.Protocol]
+	m.lock.RUnlock()
+	if h == nil {
+		return false
+	}
+	m.handled.Add(1)
+	h(pkt)
+	return true
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,551 @@
+// Command noisepeer is a minimal point-to-point VPN peer built on the tuntap
+// package, shown as a realistic reference for wiring a tun device to an
+// encrypted UDP transport.
+//
+// Two peers each hold an X25519 key pair and know the other's public key.
+// The peer given -peer initiates a handshake; the other learns its endpoint
+// from the handshake. After that, IP packets read from the tun device are
+// encrypted and sent to the peer, and packets received from the peer are
+// decrypted and written to the tun device.
+//
+//	noisepeer -genkey
+//	noisepeer -key <priv> -peerkey <pub> -addr 10.9.0.1/24 -listen :51900
+//	noisepeer -key <priv> -peerkey <pub> -addr 10.9.0.2/24 -peer 192.0.2.1:51900 -routes 192.168.1.0/24
+//
+// The handshake is deliberately simple (ephemeral-ephemeral plus
+// static-static X25519, like a stripped-down Noise KK, with its messages
+// MACed with a key from the static-static secret, and timestamped
+// initiations against replays) and sits behind the Handshaker interface, so
+// a complete Noise implementation can be swapped in. The responder only
+// moves to a new session, and the peer's endpoint only moves, on a data
+// message which decrypts. It has no rekeying, cookies or keepalives and is
+// not a substitute for WireGuard.
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mistsys/tuntap"
+	"github.com/mistsys/tuntap/transport"
+)
+
+// message types on the wire
+const (
+	msgInitiation = 1
+	msgResponse   = 2
+	msgData       = 4
+)
+
+// overhead of a data message: its header and the AES-GCM tag
+const dataOverhead = transport.HeaderLen + 16
+
+// Session holds the keys derived by a handshake.
+type Session struct {
+	send, recv *transport.Framer
+	lock       sync.Mutex
+	counter    uint64
+	replay     transport.ReplayWindow
+}
+
+// Seal encrypts one packet into a data message.
+func (s *Session) Seal(pkt []byte) []byte {
+	s.lock.Lock()
+	ctr := s.counter
+	s.counter++
+	s.lock.Unlock()
+	return s.send.Seal(make([]byte, 0, dataOverhead+len(pkt)), ctr, pkt)
+}
+
+var errReplayed = errors.New("replayed data message")
+
+// Open decrypts a data message, rejecting replays.
+func (s *Session) Open(msg []byte) ([]byte, error) {
+	seq, err := transport.SeqNumber(msg)
+	if err != nil {
+		return nil, err
+	}
+	if !s.replay.Check(seq) {
+		return nil, errReplayed
+	}
+	seq, pkt, err := s.recv.Open(nil, msg)
+	if err != nil {
+		return nil, err
+	}
+	if !s.replay.Accept(seq) {
+		return nil, errReplayed
+	}
+	return pkt, nil
+}
+
+// Handshaker produces Sessions. Implementations must be usable by both the
+// initiator (Initiate, then Finish) and responder (Respond), and must only
+// return Sessions from messages the peer authenticated.
+type Handshaker interface {
+	Initiate() ([]byte, error)
+	Respond(msg []byte) ([]byte, *Session, error)
+	Finish(msg []byte) (*Session, error)
+}
+
+// the sizes of the handshake messages: the type, the ephemeral public key,
+// the timestamp of an initiation, and the MAC
+const (
+	macLen        = 16
+	initiationLen = 1 + 32 + 8 + macLen
+	responseLen   = 1 + 32 + macLen
+)
+
+// how far off the peer's clock may be, or how old an initiation may get on
+// its way
+const maxSkew = 2 * time.Minute
+
+var errBadHandshake = errors.New("bad handshake message")
+
+// kkHandshake is the simple handshake described in the package comment. The
+// messages are authenticated with a MAC keyed from the static-static shared
+// secret, so that only the peer can make them, and an initiation carries a
+// timestamp, which must be recent and later than that of the previous
+// initiation accepted, so that it can't be replayed.
+type kkHandshake struct {
+	static *ecdh.PrivateKey
+	peer   *ecdh.PublicKey
+	ss     []byte // the static-static shared secret
+	macKey []byte
+
+	lock      sync.Mutex
+	ephemeral *ecdh.PrivateKey // our ephemeral key while initiating
+	initMAC   []byte           // and the MAC of the initiation
+	lastInit  uint64           // the timestamp of the last initiation accepted
+}
+
+func newKKHandshake(static *ecdh.PrivateKey, peer *ecdh.PublicKey) (*kkHandshake, error) {
+	ss, err := static.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+	m := hmac.New(sha256.New, ss)
+	m.Write([]byte("mac"))
+	return &kkHandshake{static: static, peer: peer, ss: ss, macKey: m.Sum(nil)}, nil
+}
+
+// mac returns the MAC of the parts of a message.
+func (h *kkHandshake) mac(parts ...[]byte) []byte {
+	m := hmac.New(sha256.New, h.macKey)
+	for _, p := range parts {
+		m.Write(p)
+	}
+	return m.Sum(nil)[:macLen]
+}
+
+func (h *kkHandshake) Initiate() ([]byte, error) {
+	e, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	msg := append([]byte{msgInitiation}, e.PublicKey().Bytes()...)
+	msg = binary.BigEndian.AppendUint64(msg, uint64(time.Now().UnixNano()))
+	mac := h.mac(msg)
+	h.lock.Lock()
+	h.ephemeral, h.initMAC = e, mac
+	h.lock.Unlock()
+	return append(msg, mac...), nil
+}
+
+func (h *kkHandshake) Respond(msg []byte) ([]byte, *Session, error) {
+	if len(msg) != initiationLen {
+		return nil, nil, errBadHandshake
+	}
+	body, mac := msg[:initiationLen-macLen], msg[initiationLen-macLen:]
+	if !hmac.Equal(mac, h.mac(body)) {
+		return nil, nil, errors.New("unauthenticated handshake initiation")
+	}
+	ts := binary.BigEndian.Uint64(body[1+32:])
+	if d := time.Since(time.Unix(0, int64(ts))); d > maxSkew || d < -maxSkew {
+		return nil, nil, errors.New("stale handshake initiation")
+	}
+	h.lock.Lock()
+	fresh := ts > h.lastInit
+	if fresh {
+		h.lastInit = ts
+	}
+	h.lock.Unlock()
+	if !fresh {
+		return nil, nil, errors.New("replayed handshake initiation")
+	}
+
+	e, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	s, err := h.derive(e, body[1:1+32], false)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp := append([]byte{msgResponse}, e.PublicKey().Bytes()...)
+	// bound to the initiation it answers
+	return append(resp, h.mac(resp, mac)...), s, nil
+}
+
+func (h *kkHandshake) Finish(msg []byte) (*Session, error) {
+	if len(msg) != responseLen {
+		return nil, errBadHandshake
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.ephemeral == nil {
+		return nil, errors.New("unexpected handshake response")
+	}
+	body, mac := msg[:responseLen-macLen], msg[responseLen-macLen:]
+	if !hmac.Equal(mac, h.mac(body, h.initMAC)) {
+		return nil, errors.New("unauthenticated handshake response")
+	}
+	e := h.ephemeral
+	h.ephemeral, h.initMAC = nil, nil
+	return h.derive(e, body[1:], true)
+}
+
+// derive computes the session keys from our ephemeral key e and the peer's
+// ephemeral public key.
+func (h *kkHandshake) derive(e *ecdh.PrivateKey, peerEphemeral []byte, initiator bool) (*Session, error) {
+	pe, err := ecdh.X25519().NewPublicKey(peerEphemeral)
+	if err != nil {
+		return nil, err
+	}
+	ee, err := e.ECDH(pe)
+	if err != nil {
+		return nil, err
+	}
+	// HKDF-SHA256 with the transcript of ephemeral keys as salt
+	var salt []byte
+	if initiator {
+		salt = append(e.PublicKey().Bytes(), pe.Bytes()...)
+	} else {
+		salt = append(pe.Bytes(), e.PublicKey().Bytes()...)
+	}
+	ext := hmac.New(sha256.New, salt)
+	ext.Write(ee)
+	ext.Write(h.ss)
+	prk := ext.Sum(nil)
+	expand := func(label string) *transport.Framer {
+		m := hmac.New(sha256.New, prk)
+		m.Write([]byte(label))
+		m.Write([]byte{1})
+		f, _ := transport.NewFramer(transport.AES256GCM, m.Sum(nil), msgData)
+		return f
+	}
+	s := &Session{send: expand("initiator"), recv: expand("responder")}
+	if !initiator {
+		s.send, s.recv = s.recv, s.send
+	}
+	return s, nil
+}
+
+// how many packets the datapath moves at once between the tun device and
+// the socket
+const batchSize = 64
+
+// peer is the tunnel state shared by the two directions of the datapath.
+type peer struct {
+	tun  *tuntap.Interface
+	conn *net.UDPConn
+	hs   Handshaker
+
+	lock     sync.Mutex
+	endpoint *net.UDPAddr
+	session  *Session
+	// the session of a handshake we responded to, until the initiator
+	// proves it has it with a data message
+	pending *Session
+
+	// the packets decrypted, for writing to the tun device
+	toTun chan tuntap.Packet
+}
+
+func (p *peer) current() (*Session, *net.UDPAddr) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.session, p.endpoint
+}
+
+// tunToUDP encrypts packets read from the tun device and sends them to the peer.
+func (p *peer) tunToUDP() {
+	bufs := make([][]byte, batchSize)
+	for i := range bufs {
+		bufs[i] = make([]byte, 65536)
+	}
+	for {
+		pkts, err := p.tun.ReadPackets(bufs)
+		if err != nil {
+			log.Fatalln("tun read:", err)
+		}
+		s, ep := p.current()
+		if s == nil || ep == nil {
+			continue
+		}
+		for _, pkt := range pkts {
+			if !pkt.Truncated {
+				p.conn.WriteToUDP(s.Seal(pkt.Body), ep)
+			}
+		}
+	}
+}
+
+// writeTun writes the packets decrypted to the tun device, as many at once
+// as are queued.
+func (p *peer) writeTun() {
+	pkts := make([]tuntap.Packet, 0, batchSize)
+	for pkt := range p.toTun {
+		pkts = append(pkts[:0], pkt)
+	more:
+		for len(pkts) < batchSize {
+			select {
+			case pkt := <-p.toTun:
+				pkts = append(pkts, pkt)
+			default:
+				break more
+			}
+		}
+		for len(pkts) != 0 {
+			n, err := p.tun.WritePackets(pkts)
+			if err != nil {
+				// skip the packet which failed
+				log.Println("tun write:", err)
+				n++
+			}
+			pkts = pkts[n:]
+		}
+	}
+}
+
+// open decrypts the data message msg from the UDP address from with the
+// current session, or with the pending one, which it then makes current.
+// Either way, the peer is now at from.
+func (p *peer) open(msg []byte, from *net.UDPAddr) ([]byte, bool) {
+	p.lock.Lock()
+	s, pending := p.session, p.pending
+	p.lock.Unlock()
+	var body []byte
+	err := errors.New("no session")
+	if s != nil {
+		body, err = s.Open(msg)
+	}
+	if err != nil && pending != nil {
+		if body, err = pending.Open(msg); err == nil {
+			s = pending
+		}
+	}
+	if err != nil {
+		return nil, false
+	}
+	p.lock.Lock()
+	if s == p.pending {
+		p.session, p.pending = s, nil
+		log.Println("session established with", from)
+	}
+	if s == p.session {
+		p.endpoint = from
+	}
+	p.lock.Unlock()
+	return body, true
+}
+
+// udpToTun handles handshake messages and decrypts data messages into the tun device.
+func (p *peer) udpToTun() {
+	buf := make([]byte, 65536)
+	for {
+		n, from, err := p.conn.ReadFromUDP(buf)
+		if err != nil {
+			log.Fatalln("udp read:", err)
+		}
+		if n == 0 {
+			continue
+		}
+		msg := buf[:n]
+		switch msg[0] {
+		case msgInitiation:
+			resp, s, err := p.hs.Respond(msg)
+			if err != nil {
+				log.Println("handshake from", from, "failed:", err)
+				continue
+			}
+			// the session only replaces the current one, and the endpoint
+			// only moves, once a data message proves the initiator has it
+			p.lock.Lock()
+			p.pending = s
+			p.lock.Unlock()
+			p.conn.WriteToUDP(resp, from)
+		case msgResponse:
+			s, err := p.hs.Finish(msg)
+			if err != nil {
+				log.Println("handshake with", from, "failed:", err)
+				continue
+			}
+			// the endpoint stays the one we initiated with
+			p.lock.Lock()
+			p.session = s
+			ep := p.endpoint
+			p.lock.Unlock()
+			// an empty data message, to confirm the session to the responder
+			p.conn.WriteToUDP(s.Seal(nil), ep)
+			log.Println("session established with", ep)
+		case msgData:
+			body, ok := p.open(msg, from)
+			if !ok || len(body) == 0 {
+				continue
+			}
+			pkt := tuntap.Packet{Body: body}
+			switch body[0] >> 4 {
+			case 4:
+				pkt.Protocol = tuntap.ETH_P_IP
+			case 6:
+				pkt.Protocol = tuntap.ETH_P_IPV6
+			default:
+				continue
+			}
+			p.toTun <- pkt
+		}
+	}
+}
+
+// initiate sends handshake initiations until a session is established.
+func (p *peer) initiate(ep *net.UDPAddr) {
+	p.lock.Lock()
+	p.endpoint = ep
+	p.lock.Unlock()
+	for {
+		if s, _ := p.current(); s != nil {
+			return
+		}
+		msg, err := p.hs.Initiate()
+		if err != nil {
+			log.Fatalln("handshake:", err)
+		}
+		p.conn.WriteToUDP(msg, ep)
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func parseKey(s string) ([]byte, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 32 {
+		return nil, fmt.Errorf("key %q must be 64 hex digits", s)
+	}
+	return b, nil
+}
+
+func main() {
+	genkey := flag.Bool("genkey", false, "generate and print a key pair, then exit")
+	tunName := flag.String("tun", "tun%d", "tun device name or pattern")
+	key := flag.String("key", "", "our X25519 private key (hex)")
+	peerKey := flag.String("peerkey", "", "the peer's X25519 public key (hex)")
+	listen := flag.String("listen", ":0", "UDP address to listen on")
+	endpoint := flag.String("peer", "", "UDP endpoint of the peer; if set, we initiate the handshake")
+	addr := flag.String("addr", "", "address/prefix of the tunnel interface, e.g. 10.9.0.1/24")
+	routes := flag.String("routes", "", "comma-separated prefixes behind the peer, routed through the tunnel")
+	underlayMTU := flag.Int("underlay-mtu", 1500, "MTU of the path to the peer")
+	flag.Parse()
+
+	if *genkey {
+		k, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println("private", hex.EncodeToString(k.Bytes()))
+		fmt.Println("public ", hex.EncodeToString(k.PublicKey().Bytes()))
+		return
+	}
+
+	kb, err := parseKey(*key)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	static, err := ecdh.X25519().NewPrivateKey(kb)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	pb, err := parseKey(*peerKey)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	peerPub, err := ecdh.X25519().NewPublicKey(pb)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	ip, subnet, err := net.ParseCIDR(*addr)
+	if err != nil {
+		log.Fatalln("bad -addr:", err)
+	}
+
+	tun, err := tuntap.Open(*tunName, tuntap.DevTun)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer tun.Close()
+
+	// the tunnel MTU is what's left of the underlay's after our encapsulation,
+	// assuming the worst case of an IPv6 underlay
+	mtu, err := tun.SetTunnelMTU(tuntap.TunnelOverhead{
+		UnderlayMTU: *underlayMTU,
+		Underlay:    tuntap.OverheadUDP6,
+		Framing:     dataOverhead,
+	})
+	if err != nil {
+		log.Fatalln("setting MTU:", err)
+	}
+	if err = tun.AddAddress(ip, subnet); err != nil {
+		log.Fatalln("adding address:", err)
+	}
+	if err = tun.Up(); err != nil {
+		log.Fatalln("bringing up:", err)
+	}
+	if *routes != "" {
+		for _, r := range strings.Split(*routes, ",") {
+			dst, err := netip.ParsePrefix(strings.TrimSpace(r))
+			if err != nil {
+				log.Fatalln("bad -routes:", err)
+			}
+			if err = tun.AddRoutePrefix(dst, netip.Addr{}); err != nil {
+				log.Fatalln("adding route:", err)
+			}
+		}
+	}
+
+	laddr, err := net.ResolveUDPAddr("udp", *listen)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("%s is up with MTU %d, listening on %s", tun.Name(), mtu, conn.LocalAddr())
+
+	hs, err := newKKHandshake(static, peerPub)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	p := &peer{tun: tun, conn: conn, hs: hs, toTun: make(chan tuntap.Packet, batchSize)}
+	if *endpoint != "" {
+		ep, err := net.ResolveUDPAddr("udp", *endpoint)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		go p.initiate(ep)
+	}
+	go p.tunToUDP()
+	go p.writeTun()
+	p.udpToTun()
+}
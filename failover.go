@@ -0,0 +1,199 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// Active/standby failover. A FailoverPair manages two paths, 0 the primary
+// and 1 the standby, each an Interface: two interfaces (over two uplinks,
+// say), or the same one twice, when the two paths are transports of the
+// application's, under one interface. The application reports the signs of
+// life of each path with Alive (keepalive replies from the far end, usually),
+// and the pair fails over to the standby when the active path has been
+// silent for the detection window while the standby wasn't, and calls back.
+// WritePacket goes to the active path's Interface; reading both is the
+// application's business, as a standby path may still deliver packets.
+//
+// The configuration methods of the pair apply to both Interfaces (once if
+// they're the same), so that the standby is ready to take over.
+
+// FailoverConfig configures a FailoverPair.
+type FailoverConfig struct {
+	// how long the active path may be silent before failing over, 3s by
+	// default. Failover happens within 1.25 Window of the last sign of life.
+	Window time.Duration
+	// go back to the primary as soon as it's alive again, which also undoes
+	// a Failover away from a live primary
+	Preempt bool
+	// called, if set, when the active path changes, one event at a time.
+	// It mustn't call Failover or Close.
+	OnFailover func(FailoverEvent)
+}
+
+// FailoverEvent is a change of the active path.
+type FailoverEvent struct {
+	From, To int // the paths, 0 or 1
+	// how long From had been silent; 0 when it was alive (a Failover call,
+	// or preemption)
+	Silent time.Duration
+}
+
+// FailoverPair coordinates a primary and a standby path. It is safe for
+// concurrent use.
+type FailoverPair struct {
+	ifs [2]*Interface
+	cfg FailoverConfig
+
+	active atomic.Int32
+	last   [2]atomic.Int64 // UnixNano of the last sign of life of each path
+
+	lock sync.Mutex // serializes changes of the active path
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewFailoverPair returns a pair of paths over primary and standby, which
+// may be the same Interface, with the primary active; both start out alive.
+func NewFailoverPair(primary, standby *Interface, cfg FailoverConfig) *FailoverPair {
+	if cfg.Window == 0 {
+		cfg.Window = 3 * time.Second
+	}
+	f := &FailoverPair{
+		ifs:  [2]*Interface{primary, standby},
+		cfg:  cfg,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	now := time.Now().UnixNano()
+	f.last[0].Store(now)
+	f.last[1].Store(now)
+	goLabeled(primary, "failover", f.run)
+	return f
+}
+
+// Alive reports a sign of life of path (0 or 1).
+func (f *FailoverPair) Alive(path int) {
+	f.last[path].Store(time.Now().UnixNano())
+}
+
+// Active returns the active path.
+func (f *FailoverPair) Active() int {
+	return int(f.active.Load())
+}
+
+// Interface returns the Interface of the active path.
+func (f *FailoverPair) Interface() *Interface {
+	return f.ifs[f.Active()]
+}
+
+// WritePacket writes pkt to the Interface of the active path.
+func (f *FailoverPair) WritePacket(pkt Packet) error {
+	return f.Interface().WritePacket(pkt)
+}
+
+// Failover makes the other path active, whether the active one is alive or
+// not, e.g. for maintenance.
+func (f *FailoverPair) Failover() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.switchTo(1-f.Active(), 0)
+}
+
+// Close stops watching the paths. It leaves the Interfaces open.
+func (f *FailoverPair) Close() {
+	f.once.Do(func() { close(f.stop) })
+	<-f.done
+}
+
+func (f *FailoverPair) run() {
+	defer close(f.done)
+	ticker := time.NewTicker(f.cfg.Window / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f.check()
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// check fails over if the active path has been silent for the window, or
+// preempts.
+func (f *FailoverPair) check() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	active := f.Active()
+	silent := f.silent(active)
+	switch {
+	case silent >= f.cfg.Window && f.silent(1-active) < f.cfg.Window:
+		f.switchTo(1-active, silent)
+	case f.cfg.Preempt && active != 0 && f.silent(0) < f.cfg.Window/4:
+		f.switchTo(0, 0)
+	}
+}
+
+// silent returns how long path has been silent.
+func (f *FailoverPair) silent(path int) time.Duration {
+	return time.Since(time.Unix(0, f.last[path].Load()))
+}
+
+// switchTo makes path active. It's called with f locked.
+func (f *FailoverPair) switchTo(path int, silent time.Duration) {
+	from := f.Active()
+	// the new path gets a full window to prove itself, and so does the old
+	// one once it's back
+	f.last[path].Store(time.Now().UnixNano())
+	f.active.Store(int32(path))
+	if f.cfg.OnFailover != nil {
+		f.cfg.OnFailover(FailoverEvent{From: from, To: path, Silent: silent})
+	}
+}
+
+// each calls fn on each Interface of the pair, once, and returns the first
+// error.
+func (f *FailoverPair) each(fn func(t *Interface) error) error {
+	err := fn(f.ifs[0])
+	if f.ifs[1] != f.ifs[0] {
+		if err1 := fn(f.ifs[1]); err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+// AddAddress adds an IP address to the Interfaces of the pair.
+func (f *FailoverPair) AddAddress(ip net.IP, subnet *net.IPNet) error {
+	return f.each(func(t *Interface) error { return t.AddAddress(ip, subnet) })
+}
+
+// DelAddress removes an IP address from the Interfaces of the pair.
+func (f *FailoverPair) DelAddress(ip net.IP, subnet *net.IPNet) error {
+	return f.each(func(t *Interface) error { return t.DelAddress(ip, subnet) })
+}
+
+// SetMTU sets the MTU of the Interfaces of the pair.
+func (f *FailoverPair) SetMTU(mtu int) error {
+	return f.each(func(t *Interface) error { return t.SetMTU(mtu) })
+}
+
+// Up sets the Interfaces of the pair to the UP state.
+func (f *FailoverPair) Up() error {
+	return f.each(func(t *Interface) error { return t.Up() })
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,81 @@
+//go:build linux || freebsd || darwin || openbsd || netbsd
+
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+// Passing Interfaces between processes. With privilege separation, a
+// privileged helper opens (and configures) the device, and hands it to the
+// unprivileged process running the data path, which never has the
+// privileges to open one itself. SendInterface passes the device's file
+// descriptor over a unix socket (SCM_RIGHTS) along with what NewFromFD
+// needs to know of it, its kind and name, and ReceiveInterface makes an
+// Interface of it on the other end.
+//
+// The message is the kind in one byte followed by the name. Only the device
+// goes across: the Options of the sender's Interface don't, nor do raw and
+// pipe Interfaces, which aren't devices.
+
+// SendInterface sends the device of t over c. t remains open, and may be
+// closed once sent; the receiving process has its own descriptor.
+func SendInterface(c *net.UnixConn, t *Interface) error {
+	msg := append([]byte{byte(t.kind)}, t.name...)
+	return t.control(func(fd uintptr) error {
+		n, oobn, err := c.WriteMsgUnix(msg, unix.UnixRights(int(fd)), nil)
+		if err != nil {
+			return errors.Wrapf(err, "tuntap: Can't send %s", t.name)
+		}
+		if n != len(msg) || oobn == 0 {
+			return errors.Errorf("tuntap: Can't send %s: short write", t.name)
+		}
+		return nil
+	})
+}
+
+// ReceiveInterface waits for an Interface sent over c with SendInterface,
+// and returns it as NewFromFD does.
+func ReceiveInterface(c *net.UnixConn) (*Interface, error) {
+	msg := make([]byte, 1+256)
+	oob := make([]byte, unix.CmsgSpace(4))
+	n, oobn, _, _, err := c.ReadMsgUnix(msg, oob)
+	if err != nil {
+		return nil, errors.Wrap(err, "tuntap: Can't receive an interface")
+	}
+	var fds []int
+	if cmsgs, err := unix.ParseSocketControlMessage(oob[:oobn]); err == nil {
+		for i := range cmsgs {
+			if rights, err := unix.ParseUnixRights(&cmsgs[i]); err == nil {
+				fds = append(fds, rights...)
+			}
+		}
+	}
+	if n < 1 || len(fds) != 1 {
+		for _, fd := range fds {
+			unix.Close(fd)
+		}
+		return nil, errors.New("tuntap: Can't receive an interface: not a SendInterface message")
+	}
+	unix.CloseOnExec(fds[0])
+	t, err := NewFromFD(fds[0], DevKind(msg[0]), string(msg[1:n]))
+	if err != nil {
+		unix.Close(fds[0])
+		return nil, err
+	}
+	return t, nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,35 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+//-----------------------------------------------------------------------------
+// Kernel side packet filters. A classic BPF program attached to the
+// Interface drops the packets the application doesn't want before they're
+// queued for it, saving the copies and wakeups. The program sees the packet
+// as ReadPacket returns it, from the Ethernet header, and its return value
+// is the number of bytes to keep, 0 to drop the packet.
+//
+// On Linux this is TUNATTACHFILTER, which the kernel only takes for DevTap
+// interfaces (it applies to all the queues of a multi-queue one), and
+// SO_ATTACH_FILTER for raw Interfaces. Programs already loaded with bpf(2)
+// attach by their descriptor with SetFilterEBPF, and SetSteeringEBPF has one
+// pick the queue of each packet of a multi-queue interface, for receive
+// side scaling under the application's control.
+
+// BPFInstruction is a classic BPF instruction, struct sock_filter. It has the
+// fields of golang.org/x/net/bpf's RawInstruction, which converts to it:
+// BPFInstruction(ri) for each instruction of an assembled program.
+type BPFInstruction struct {
+	Op uint16
+	Jt uint8
+	Jf uint8
+	K  uint32
+}
+
+//-----------------------------------------------------------------------------
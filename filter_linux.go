@@ -0,0 +1,118 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+
+// sockFprog returns prog as a struct sock_fprog.
+func sockFprog(prog []BPFInstruction) *unix.SockFprog {
+	fprog := &unix.SockFprog{Len: uint16(len(prog))}
+	if len(prog) != 0 {
+		// BPFInstruction has the layout of struct sock_filter
+		fprog.Filter = (*unix.SockFilter)(unsafe.Pointer(&prog[0]))
+	}
+	return fprog
+}
+
+// AttachFilter attaches the classic BPF program prog to the Interface,
+// replacing the one attached before if any.
+func (t *Interface) AttachFilter(prog []BPFInstruction) error {
+	if len(prog) == 0 || len(prog) > bpfMaxInsns {
+		return errors.New("tuntap: BPF programs have 1 to 4096 instructions")
+	}
+	fprog := sockFprog(prog)
+	if t.sendBatch != nil {
+		// a raw Interface, an AF_PACKET socket
+		return t.control(func(fd uintptr) error {
+			if err := unix.SetsockoptSockFprog(int(fd), unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, fprog); err != nil {
+				return errors.Wrapf(err, "tuntap: Can't setsockopt(SO_ATTACH_FILTER) on %s", t.name)
+			}
+			return nil
+		})
+	}
+	if t.kind != DevTap {
+		return ErrNotSupported
+	}
+	return t.control(func(fd uintptr) error {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, unix.TUNATTACHFILTER, uintptr(unsafe.Pointer(fprog)))
+		if errno != 0 {
+			return errors.Wrapf(errno, "tuntap: Can't ioctl(TUNATTACHFILTER) on %s", t.name)
+		}
+		return nil
+	})
+}
+
+// DetachFilter detaches the program attached with AttachFilter. With no
+// program attached, it does nothing on a DevTap interface, and fails with
+// ENOENT on a raw one.
+func (t *Interface) DetachFilter() error {
+	if t.sendBatch != nil {
+		return t.control(func(fd uintptr) error {
+			if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_DETACH_FILTER, 0); err != nil {
+				return errors.Wrapf(err, "tuntap: Can't setsockopt(SO_DETACH_FILTER) on %s", t.name)
+			}
+			return nil
+		})
+	}
+	if t.kind != DevTap {
+		return ErrNotSupported
+	}
+	return t.control(func(fd uintptr) error {
+		// the argument is unused, but must point to a struct sock_fprog
+		var fprog unix.SockFprog
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, unix.TUNDETACHFILTER, uintptr(unsafe.Pointer(&fprog)))
+		if errno != 0 {
+			return errors.Wrapf(errno, "tuntap: Can't ioctl(TUNDETACHFILTER) on %s", t.name)
+		}
+		return nil
+	})
+}
+
+// SetSteeringEBPF makes the eBPF program progFD (a socket filter) choose the
+// queue of each packet of a multi-queue interface, by its return value
+// modulo the number of queues, in place of the kernel's flow hash; -1
+// detaches it. The program is the interface's, for all its queues, and the
+// kernel holds it, so progFD may be closed afterwards.
+func (t *Interface) SetSteeringEBPF(progFD int) error {
+	return t.setEBPF(unix.TUNSETSTEERINGEBPF, "TUNSETSTEERINGEBPF", progFD)
+}
+
+// SetFilterEBPF makes the eBPF program progFD (a socket filter) filter the
+// packets before they're queued, as an AttachFilter program does; -1
+// detaches it.
+func (t *Interface) SetFilterEBPF(progFD int) error {
+	return t.setEBPF(unix.TUNSETFILTEREBPF, "TUNSETFILTEREBPF", progFD)
+}
+
+// setEBPF does req, one of the ioctls taking an eBPF program's descriptor.
+func (t *Interface) setEBPF(req uint, name string, progFD int) error {
+	if t.sendBatch != nil {
+		// a raw Interface isn't a tun device
+		return ErrNotSupported
+	}
+	return t.control(func(fd uintptr) error {
+		if err := unix.IoctlSetPointerInt(int(fd), req, progFD); err != nil {
+			return errors.Wrapf(err, "tuntap: Can't ioctl(%s) on %s", name, t.name)
+		}
+		return nil
+	})
+}
+
+// BPF_MAXINSNS
+const bpfMaxInsns = 4096
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,326 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// Flow tracking. A FlowTracker is fed the packets an application reads or
+// writes, groups them into unidirectional flows by 5-tuple (as NetFlow and
+// IPFIX do), and tells a FlowObserver when a flow starts and when it ends,
+// with its packet and byte counts. The observer is the export hook: to feed
+// OpenTelemetry, for instance, it would count flows and bytes with the
+// application's meter, keeping the package free of any telemetry SDK:
+//
+//	type otelFlows struct {
+//		active metric.Int64UpDownCounter // tunnel.flows.active
+//		bytes  metric.Int64Counter       // tunnel.flow.bytes
+//	}
+//
+//	func (o *otelFlows) FlowStarted(f *tuntap.Flow) {
+//		o.active.Add(context.Background(), 1)
+//	}
+//
+//	func (o *otelFlows) FlowEnded(f *tuntap.Flow, why tuntap.FlowEndReason) {
+//		o.active.Add(context.Background(), -1)
+//		o.bytes.Add(context.Background(), int64(f.Bytes),
+//			metric.WithAttributes(attribute.String("end", why.String())))
+//	}
+
+// FlowKey identifies a flow. IPv4 addresses are stored IPv4-mapped. The
+// ports are 0 for protocols which don't have them, and for fragments other
+// than the first.
+type FlowKey struct {
+	Src, Dst         [16]byte
+	Proto            uint8
+	SrcPort, DstPort uint16
+}
+
+// SrcIP returns the source address.
+func (k *FlowKey) SrcIP() net.IP {
+	return flowIP(k.Src)
+}
+
+// DstIP returns the destination address.
+func (k *FlowKey) DstIP() net.IP {
+	return flowIP(k.Dst)
+}
+
+// SrcAddrPort returns the source address and port.
+func (k *FlowKey) SrcAddrPort() netip.AddrPort {
+	return netip.AddrPortFrom(netip.AddrFrom16(k.Src).Unmap(), k.SrcPort)
+}
+
+// DstAddrPort returns the destination address and port.
+func (k *FlowKey) DstAddrPort() netip.AddrPort {
+	return netip.AddrPortFrom(netip.AddrFrom16(k.Dst).Unmap(), k.DstPort)
+}
+
+func flowIP(a [16]byte) net.IP {
+	ip := net.IP(append([]byte(nil), a[:]...))
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+func (k *FlowKey) String() string {
+	return fmt.Sprintf("%v:%d -> %v:%d proto %d", k.SrcIP(), k.SrcPort, k.DstIP(), k.DstPort, k.Proto)
+}
+
+// Flow is the state of a tracked flow.
+type Flow struct {
+	Key      FlowKey
+	Start    time.Time
+	LastSeen time.Time
+	Packets  uint64
+	Bytes    uint64 // of IP packets, headers included
+	// the trace the flow was tagged with, if any
+	Trace TraceContext
+	// the Dir of the packet which started the flow
+	Dir Direction
+}
+
+// String describes the flow for logs, with its trace if it's tagged.
+func (f *Flow) String() string {
+	s := fmt.Sprintf("%v (%v): %d packets, %d bytes", &f.Key, f.Dir, f.Packets, f.Bytes)
+	if f.Trace.IsValid() {
+		s += ", traceparent " + f.Trace.String()
+	}
+	return s
+}
+
+// FlowEndReason says why a flow ended.
+type FlowEndReason int
+
+const (
+	// no packet for the tracker's idle timeout
+	FlowIdle FlowEndReason = iota
+	// a TCP FIN or RST
+	FlowFinished
+	// the tracker was closed
+	FlowTrackerClosed
+)
+
+func (r FlowEndReason) String() string {
+	switch r {
+	case FlowIdle:
+		return "idle"
+	case FlowFinished:
+		return "finished"
+	case FlowTrackerClosed:
+		return "closed"
+	}
+	return "FlowEndReason(" + fmt.Sprint(int(r)) + ")"
+}
+
+// FlowObserver receives the events of a FlowTracker. The calls are made
+// without the tracker locked, from the goroutine calling Track for
+// FlowStarted and for flows ending with FlowFinished, and otherwise from the
+// tracker's timer or Close. The Flow must not be kept after the call.
+type FlowObserver interface {
+	FlowStarted(f *Flow)
+	FlowEnded(f *Flow, why FlowEndReason)
+}
+
+// FlowTracker tracks the flows of the packets given to it. It is safe for
+// concurrent use.
+type FlowTracker struct {
+	idle     time.Duration
+	maxFlows int
+	obs      FlowObserver
+	tagger   func(key *FlowKey) TraceContext
+
+	lock      sync.Mutex
+	flows     map[FlowKey]*Flow
+	timer     *time.Timer
+	closed    bool
+	untracked uint64
+}
+
+// NewFlowTracker returns a tracker which ends flows idle for idle, and
+// tracks at most maxFlows at once (0 for no limit). obs may be nil, to only
+// use Flows.
+func NewFlowTracker(idle time.Duration, maxFlows int, obs FlowObserver) *FlowTracker {
+	return &FlowTracker{
+		idle:     idle,
+		maxFlows: maxFlows,
+		obs:      obs,
+		flows:    make(map[FlowKey]*Flow),
+	}
+}
+
+// FlowKey returns the key of the flow of the packet, false if it isn't IP.
+// It doesn't allocate.
+func (p *Packet) FlowKey() (FlowKey, bool) {
+	var k FlowKey
+	src := p.SrcAddr()
+	if !src.IsValid() {
+		return k, false
+	}
+	k.Src, k.Dst = src.As16(), p.DstAddr().As16()
+	k.Proto, _, _ = p.IPProto()
+	k.SrcPort, k.DstPort, _ = p.ports()
+	return k, true
+}
+
+// tcpEnds returns whether pkt is a TCP segment with FIN or RST set.
+func tcpEnds(pkt *Packet) bool {
+	flags, _ := pkt.TCPFlags()
+	return flags&(TCPFin|TCPRst) != 0
+}
+
+// Track accounts pkt to its flow, starting the flow if it's new. Packets
+// which aren't IP are ignored.
+func (ft *FlowTracker) Track(pkt Packet) {
+	key, ok := pkt.FlowKey()
+	if !ok {
+		return
+	}
+	now := time.Now()
+	ends := tcpEnds(&pkt)
+
+	ft.lock.Lock()
+	if ft.closed {
+		ft.lock.Unlock()
+		return
+	}
+	f := ft.flows[key]
+	started := f == nil
+	if started {
+		if ft.maxFlows > 0 && len(ft.flows) >= ft.maxFlows {
+			ft.untracked++
+			ft.lock.Unlock()
+			return
+		}
+		f = &Flow{Key: key, Start: now, Dir: pkt.Dir}
+		if ft.tagger != nil {
+			f.Trace = ft.tagger(&key)
+		}
+		ft.flows[key] = f
+		if ft.timer == nil && ft.idle > 0 {
+			ft.timer = time.AfterFunc(ft.idle/2, ft.sweep)
+		}
+	}
+	f.LastSeen = now
+	f.Packets++
+	f.Bytes += uint64(len(pkt.Body))
+	if ends {
+		delete(ft.flows, key)
+	}
+	// other packets may update f as soon as it's unlocked
+	snap := *f
+	ft.lock.Unlock()
+
+	if ft.obs != nil {
+		if started {
+			ft.obs.FlowStarted(&snap)
+		}
+		if ends {
+			ft.obs.FlowEnded(&snap, FlowFinished)
+		}
+	}
+}
+
+// TagFlows makes the tracker call tagger for each new flow, for the trace
+// context to tag it with (the zero TraceContext for none). tagger is called
+// with the tracker locked, so it must be quick, and mustn't call the tracker.
+// TagFlows must be called before the tracker is used.
+func (ft *FlowTracker) TagFlows(tagger func(key *FlowKey) TraceContext) {
+	ft.tagger = tagger
+}
+
+// Tag tags the flow key with tc, replacing any trace context it had. It
+// returns false if the flow isn't being tracked.
+func (ft *FlowTracker) Tag(key FlowKey, tc TraceContext) bool {
+	ft.lock.Lock()
+	defer ft.lock.Unlock()
+	f := ft.flows[key]
+	if f == nil {
+		return false
+	}
+	f.Trace = tc
+	return true
+}
+
+// sweep ends the idle flows, and runs again while there are flows left.
+func (ft *FlowTracker) sweep() {
+	now := time.Now()
+	var ended []*Flow
+	ft.lock.Lock()
+	for k, f := range ft.flows {
+		if now.Sub(f.LastSeen) >= ft.idle {
+			delete(ft.flows, k)
+			ended = append(ended, f)
+		}
+	}
+	if len(ft.flows) != 0 && !ft.closed {
+		ft.timer.Reset(ft.idle / 2)
+	} else {
+		ft.timer = nil
+	}
+	ft.lock.Unlock()
+
+	if ft.obs != nil {
+		for _, f := range ended {
+			ft.obs.FlowEnded(f, FlowIdle)
+		}
+	}
+}
+
+// Flows returns a snapshot of the flows being tracked.
+func (ft *FlowTracker) Flows() []Flow {
+	ft.lock.Lock()
+	defer ft.lock.Unlock()
+	flows := make([]Flow, 0, len(ft.flows))
+	for _, f := range ft.flows {
+		flows = append(flows, *f)
+	}
+	return flows
+}
+
+// Untracked returns the number of packets of new flows which weren't
+// tracked because the tracker was full.
+func (ft *FlowTracker) Untracked() uint64 {
+	ft.lock.Lock()
+	defer ft.lock.Unlock()
+	return ft.untracked
+}
+
+// Close ends all the flows, and stops the tracker; it ignores packets from
+// then on.
+func (ft *FlowTracker) Close() {
+	ft.lock.Lock()
+	if ft.closed {
+		ft.lock.Unlock()
+		return
+	}
+	ft.closed = true
+	if ft.timer != nil {
+		ft.timer.Stop()
+		ft.timer = nil
+	}
+	flows := ft.flows
+	ft.flows = nil
+	ft.lock.Unlock()
+
+	if ft.obs != nil {
+		for _, f := range flows {
+			ft.obs.FlowEnded(f, FlowTrackerClosed)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,485 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/maphash"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// FQ-CoDel (RFC 8290) for the packets an application writes to an Interface.
+// When the application produces packets faster than the kernel takes them,
+// a plain FIFO in front of the Interface fills with the backlog of the bulk
+// flows, and every interactive packet waits behind it. An FQCoDelWriter
+// hashes packets into flow queues by their 5-tuple, serves the flows in
+// deficit round robin, with flows which just became active first, and runs
+// CoDel (RFC 8289) on each queue to keep its standing delay near Target.
+//
+// CoDel signals congestion by dropping, or, with ECN set, by marking the
+// packets which are ECN capable Congestion Experienced (RFC 3168), which
+// spares the sender the loss. Packets dropped because the writer is full are
+// dropped all the same.
+
+var ErrFQCoDelClosed = errors.New("fq-codel writer closed")
+
+// FQCoDelConfig configures an FQCoDelWriter. Zero fields take the defaults,
+// which are those of Linux's fq_codel qdisc.
+type FQCoDelConfig struct {
+	// Flows is the number of flow queues. Default 1024.
+	Flows int
+	// Quantum is the number of bytes a flow may send per round. Default 1514.
+	Quantum int
+	// Target is the queueing delay CoDel aims for. Default 5ms.
+	Target time.Duration
+	// Interval is how long the delay must stay above Target before CoDel
+	// starts dropping, roughly the RTT of the flows. Default 100ms.
+	Interval time.Duration
+	// Limit is the most packets queued, across all flows. Default 10240.
+	Limit int
+	// ECN makes CoDel mark ECN capable packets rather than drop them.
+	ECN bool
+}
+
+// how many packets the writing goroutine hands the Interface at once
+const fqBatch = 64
+
+type fqPacket struct {
+	pkt      Packet
+	buf      packetBuf // the pooled buffer backing pkt
+	enqueued time.Time
+}
+
+type fqFlow struct {
+	queue   []fqPacket
+	bytes   int
+	deficit int
+	active  bool // on one of the lists
+
+	// CoDel state
+	firstAbove time.Time
+	dropNext   time.Time
+	count      uint32
+	lastCount  uint32
+	dropping   bool
+}
+
+// FQCoDelWriter queues packets by flow, and writes them to its Interface
+// from a goroutine of its own. It is safe for concurrent use.
+type FQCoDelWriter struct {
+	t    *Interface
+	cfg  FQCoDelConfig
+	seed maphash.Seed
+
+	lock     sync.Mutex
+	queued   sync.Cond // signaled when a packet is queued, or on Close
+	room     sync.Cond // signaled when packets are dequeued
+	flows    []fqFlow
+	newFlows []*fqFlow
+	oldFlows []*fqFlow
+	count    int   // packets queued
+	err      error // the first error writing to the Interface
+	closed   bool
+	done     chan struct{}
+
+	budget *MemoryBudget
+	policy DropPolicy
+	drops  atomic.Uint64
+	marks  atomic.Uint64
+}
+
+// NewFQCoDelWriter returns a writer for t configured with cfg, and starts its
+// goroutine. It must be stopped with Close.
+func NewFQCoDelWriter(t *Interface, cfg FQCoDelConfig) *FQCoDelWriter {
+	if cfg.Flows <= 0 {
+		cfg.Flows = 1024
+	}
+	if cfg.Quantum <= 0 {
+		cfg.Quantum = 1514
+	}
+	if cfg.Target <= 0 {
+		cfg.Target = 5 * time.Millisecond
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 100 * time.Millisecond
+	}
+	if cfg.Limit <= 0 {
+		cfg.Limit = 10240
+	}
+	w := &FQCoDelWriter{
+		t:      t,
+		cfg:    cfg,
+		seed:   maphash.MakeSeed(),
+		flows:  make([]fqFlow, cfg.Flows),
+		done:   make(chan struct{}),
+		policy: DropOldest,
+	}
+	w.queued.L = &w.lock
+	w.room.L = &w.lock
+	goLabeled(t, "fqcodel", w.run)
+	return w
+}
+
+// SetLimits makes the queued packets count against budget, and sets what
+// WritePacket does when the writer is full: DropOldest (the default) drops
+// from the head of the flow with the largest backlog, as fq_codel does, so
+// the flow causing the congestion pays for it. It must be called before the
+// writer is used.
+func (w *FQCoDelWriter) SetLimits(budget *MemoryBudget, policy DropPolicy) {
+	w.budget = budget
+	w.policy = policy
+}
+
+// Drops returns the number of packets the writer has dropped, whether
+// because it was full or by CoDel.
+func (w *FQCoDelWriter) Drops() uint64 {
+	return w.drops.Load()
+}
+
+// Marks returns the number of packets CoDel has ECN marked instead of
+// dropping them.
+func (w *FQCoDelWriter) Marks() uint64 {
+	return w.marks.Load()
+}
+
+// WritePacket queues a copy of pkt; the caller may reuse pkt.Body as soon as
+// it returns. An error writing earlier packets to the Interface is returned
+// by the next call to WritePacket.
+func (w *FQCoDelWriter) WritePacket(pkt Packet) error {
+	var buf packetBuf
+	buf, pkt.Body = copyBuffer(pkt.Body)
+	drop := buf.put
+	cost := cap(pkt.Body)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for {
+		if w.closed {
+			drop()
+			return ErrFQCoDelClosed
+		}
+		if err := w.err; err != nil {
+			w.err = nil
+			drop()
+			return err
+		}
+		if w.count < w.cfg.Limit {
+			if w.budget.reserve(cost) {
+				break
+			}
+			if w.policy != DropOldest || w.count == 0 {
+				// the budget may be used up by other queues, so there's no
+				// point waiting
+				w.drops.Add(1)
+				drop()
+				return nil
+			}
+			w.dropFattest()
+			continue
+		}
+		switch w.policy {
+		case DropOldest:
+			w.dropFattest()
+		case BlockWhenFull:
+			w.room.Wait()
+		default:
+			w.drops.Add(1)
+			drop()
+			return nil
+		}
+	}
+
+	f := w.flowOf(&pkt)
+	f.queue = append(f.queue, fqPacket{pkt: pkt, buf: buf, enqueued: time.Now()})
+	f.bytes += len(pkt.Body)
+	w.count++
+	if !f.active {
+		f.active = true
+		f.deficit = w.cfg.Quantum
+		w.newFlows = append(w.newFlows, f)
+	}
+	w.queued.Signal()
+	return nil
+}
+
+// Close writes out the queued packets (CoDel still applies) and stops the
+// writer. It doesn't close the Interface.
+func (w *FQCoDelWriter) Close() error {
+	w.lock.Lock()
+	if w.closed {
+		w.lock.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.queued.Signal()
+	w.room.Broadcast()
+	w.lock.Unlock()
+
+	<-w.done
+	w.lock.Lock()
+	err := w.err
+	w.err = nil
+	w.lock.Unlock()
+	return err
+}
+
+// flowOf returns the queue of pkt's flow, hashing the addresses, the IP
+// protocol, and the ports of the protocols which have them.
+func (w *FQCoDelWriter) flowOf(pkt *Packet) *fqFlow {
+	var h maphash.Hash
+	h.SetSeed(w.seed)
+	h.Write(pkt.SIP())
+	h.Write(pkt.DIP())
+	proto, at, frag := pkt.IPProto()
+	h.WriteByte(proto)
+	switch proto {
+	case 6, 17, 132, 136: // TCP, UDP, SCTP, UDP-Lite
+		if !frag && at+4 <= len(pkt.Body) {
+			h.Write(pkt.Body[at : at+4])
+		}
+	}
+	return &w.flows[h.Sum64()%uint64(len(w.flows))]
+}
+
+func (w *FQCoDelWriter) run() {
+	defer close(w.done)
+	batch := make([]Packet, 0, fqBatch)
+	bufs := make([]packetBuf, 0, fqBatch)
+
+	w.lock.Lock()
+	for {
+		for w.count == 0 && !w.closed {
+			w.queued.Wait()
+		}
+		if w.count == 0 {
+			w.lock.Unlock()
+			return
+		}
+		now := time.Now()
+		for len(batch) < fqBatch {
+			p, ok := w.dequeue(now)
+			if !ok {
+				break
+			}
+			batch = append(batch, p.pkt)
+			bufs = append(bufs, p.buf)
+		}
+		w.room.Broadcast()
+		w.lock.Unlock()
+
+		var err error
+		if len(batch) != 0 {
+			_, err = w.t.writeBatch(batch)
+			for i, buf := range bufs {
+				w.budget.release(cap(batch[i].Body))
+				buf.put()
+				batch[i] = Packet{}
+			}
+			batch, bufs = batch[:0], bufs[:0]
+		}
+
+		w.lock.Lock()
+		if err != nil && w.err == nil {
+			w.err = err
+		}
+	}
+}
+
+// dequeue returns the next packet to write, serving the new flows before the
+// old ones, in deficit round robin. It's called with w locked.
+func (w *FQCoDelWriter) dequeue(now time.Time) (fqPacket, bool) {
+	for {
+		list := &w.newFlows
+		if len(*list) == 0 {
+			list = &w.oldFlows
+			if len(*list) == 0 {
+				return fqPacket{}, false
+			}
+		}
+		f := (*list)[0]
+		if f.deficit <= 0 {
+			f.deficit += w.cfg.Quantum
+			*list = (*list)[1:]
+			w.oldFlows = append(w.oldFlows, f)
+			continue
+		}
+		p, ok := w.codel(f, now)
+		if !ok {
+			*list = (*list)[1:]
+			if list == &w.newFlows {
+				// go through the old flows once before leaving, so a flow
+				// can't stay new by sending a packet at a time
+				w.oldFlows = append(w.oldFlows, f)
+			} else {
+				f.active = false
+			}
+			continue
+		}
+		f.deficit -= len(p.pkt.Body)
+		return p, true
+	}
+}
+
+// codel dequeues the head of f, dropping (or marking) the packets CoDel
+// decides to drop.
+func (w *FQCoDelWriter) codel(f *fqFlow, now time.Time) (fqPacket, bool) {
+	p, ok, okToDrop := w.pop(f, now)
+	if !ok {
+		f.dropping = false
+		return p, false
+	}
+	if f.dropping {
+		if !okToDrop {
+			f.dropping = false
+		}
+		for f.dropping && !now.Before(f.dropNext) {
+			f.count++
+			if w.mark(p) {
+				f.dropNext = w.controlLaw(f.dropNext, f.count)
+				break
+			}
+			w.discard(p)
+			p, ok, okToDrop = w.pop(f, now)
+			if !ok {
+				f.dropping = false
+				return p, false
+			}
+			if !okToDrop {
+				f.dropping = false
+			} else {
+				f.dropNext = w.controlLaw(f.dropNext, f.count)
+			}
+		}
+	} else if okToDrop {
+		if !w.mark(p) {
+			w.discard(p)
+			p, ok, _ = w.pop(f, now)
+		}
+		f.dropping = true
+		// if we were dropping recently, resume at about the rate which
+		// was controlling the queue then
+		delta := f.count - f.lastCount
+		if delta > 1 && now.Sub(f.dropNext) < 16*w.cfg.Interval {
+			f.count = delta
+		} else {
+			f.count = 1
+		}
+		f.dropNext = w.controlLaw(now, f.count)
+		f.lastCount = f.count
+		if !ok {
+			return p, false
+		}
+	}
+	return p, true
+}
+
+// pop removes the head of f, and returns whether its delay has been above
+// Target for an Interval.
+func (w *FQCoDelWriter) pop(f *fqFlow, now time.Time) (fqPacket, bool, bool) {
+	if len(f.queue) == 0 {
+		f.firstAbove = time.Time{}
+		return fqPacket{}, false, false
+	}
+	p := f.queue[0]
+	f.queue[0] = fqPacket{}
+	f.queue = f.queue[1:]
+	f.bytes -= len(p.pkt.Body)
+	w.count--
+
+	okToDrop := false
+	if now.Sub(p.enqueued) < w.cfg.Target || f.bytes <= w.cfg.Quantum {
+		// below target, or too little queued to do anything about it
+		f.firstAbove = time.Time{}
+	} else if f.firstAbove.IsZero() {
+		f.firstAbove = now.Add(w.cfg.Interval)
+	} else if !now.Before(f.firstAbove) {
+		okToDrop = true
+	}
+	return p, true, okToDrop
+}
+
+func (w *FQCoDelWriter) controlLaw(t time.Time, count uint32) time.Time {
+	return t.Add(time.Duration(float64(w.cfg.Interval) / math.Sqrt(float64(count))))
+}
+
+// dropFattest drops the head of the flow with the largest backlog.
+func (w *FQCoDelWriter) dropFattest() {
+	var fat *fqFlow
+	for i := range w.flows {
+		if f := &w.flows[i]; len(f.queue) != 0 && (fat == nil || f.bytes > fat.bytes) {
+			fat = f
+		}
+	}
+	p := fat.queue[0]
+	fat.queue[0] = fqPacket{}
+	fat.queue = fat.queue[1:]
+	fat.bytes -= len(p.pkt.Body)
+	w.count--
+	w.discard(p)
+}
+
+// mark sets Congestion Experienced on p if ECN is enabled and p is ECN
+// capable.
+func (w *FQCoDelWriter) mark(p fqPacket) bool {
+	if !w.cfg.ECN || !markCE(&p.pkt) {
+		return false
+	}
+	w.marks.Add(1)
+	return true
+}
+
+// markCE sets the ECN field of an ECN capable (ECT(0) or ECT(1)) IP packet
+// to Congestion Experienced, fixing up the IPv4 header checksum. It returns
+// false if the packet isn't ECN capable.
+func markCE(pkt *Packet) bool {
+	b := pkt.ip()
+	proto := pkt.Protocol
+	if proto == 0 {
+		proto = ipProtocol(b)
+	}
+	switch proto {
+	case ETH_P_IP:
+		if len(b) < 20 || b[1]&3 == 0 {
+			return false
+		}
+		if b[1]&3 == 3 {
+			return true
+		}
+		// incremental update of the checksum (RFC 1624): HC' = ~(~HC + ~m + m')
+		old := binary.BigEndian.Uint16(b[0:2])
+		b[1] |= 3
+		sum := uint32(^binary.BigEndian.Uint16(b[10:12])) + uint32(^old) + uint32(binary.BigEndian.Uint16(b[0:2]))
+		sum = sum&0xffff + sum>>16
+		sum = sum&0xffff + sum>>16
+		binary.BigEndian.PutUint16(b[10:12], ^uint16(sum))
+		return true
+	case ETH_P_IPV6:
+		// the ECN field is the low 2 bits of the traffic class, which
+		// straddles the first 2 bytes
+		if len(b) < 40 || b[1]&0x30 == 0 {
+			return false
+		}
+		b[1] |= 0x30
+		return true
+	}
+	return false
+}
+
+func (w *FQCoDelWriter) discard(p fqPacket) {
+	w.budget.release(cap(p.pkt.Body))
+	p.buf.put()
+	w.drops.Add(1)
+}
+
+//-----------------------------------------------------------------------------
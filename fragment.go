@@ -0,0 +1,204 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+)
+
+//-----------------------------------------------------------------------------
+// Fragmentation, for the packets too large for the path a tunnel sends them
+// over once encapsulated: Fragment(pkt, 1500-60) for a tunnel adding 60
+// bytes on a 1500 byte path. IPv4 packets are split as a router would
+// (RFC 791), unless they have DF set, the sender having asked for an ICMP
+// "fragmentation needed" instead. IPv6 packets get a fragment header, as
+// their source would have added (RFC 8200): routers don't fragment IPv6,
+// but a tunnel endpoint is the source of the packets it sends.
+//
+// The fragments keep what's in front of the IP header (the Ethernet header
+// of a DevTap frame), and are reassembled by the destination, not the far
+// end of the tunnel.
+
+var ErrFragment = errors.New("packet can't be fragmented")
+var ErrDontFragment = errors.New("packet too big, and not to be fragmented")
+
+// the identification of the IPv6 fragments made, starting at random as
+// RFC 7739 recommends
+var fragmentID atomic.Uint32
+
+func init() {
+	fragmentID.Store(rand.Uint32())
+}
+
+// Fragment splits pkt into fragments whose IP packets are no larger than
+// mtu. A packet which fits is returned as is. It returns ErrDontFragment for
+// an IPv4 packet with DF set, and ErrFragment for packets which aren't IP,
+// are truncated, are left to segmentation offload (see VnetHdr), or are too
+// large for the extension headers of IPv6 to leave room in mtu.
+func Fragment(pkt Packet, mtu int) ([]Packet, error) {
+	b := pkt.ip()
+	var n int
+	switch {
+	case pkt.Protocol == ETH_P_IP && len(b) >= 20:
+		n = int(binary.BigEndian.Uint16(b[2:]))
+		if n < 20 || n < int(b[0]&0xf)<<2 {
+			// shorter than its own header
+			return nil, ErrFragment
+		}
+	case pkt.Protocol == ETH_P_IPV6 && len(b) >= 40:
+		n = 40 + int(binary.BigEndian.Uint16(b[4:]))
+	default:
+		return nil, ErrFragment
+	}
+	if n <= mtu {
+		return []Packet{pkt}, nil
+	}
+	if n > len(b) || pkt.Truncated || pkt.Vnet.GSOType != VnetGSONone || pkt.Vnet.Flags&VnetHdrNeedsCsum != 0 {
+		return nil, ErrFragment
+	}
+	if pkt.Protocol == ETH_P_IP {
+		return fragment4(pkt, b[:n], mtu)
+	}
+	return fragment6(pkt, b[:n], mtu)
+}
+
+// fragment returns a fragment of p, whose IP packet is ip.
+func (p *Packet) fragment(ip ...[]byte) Packet {
+	body := append([]byte(nil), p.Body[:p.L3Offset]...)
+	for _, b := range ip {
+		body = append(body, b...)
+	}
+	return Packet{Body: body, Protocol: p.Protocol, L3Offset: p.L3Offset, Dir: p.Dir, Meta: p.Meta}
+}
+
+// fragment4 fragments the IPv4 packet b of pkt.
+func fragment4(pkt Packet, b []byte, mtu int) ([]Packet, error) {
+	if b[6]&0x40 != 0 {
+		return nil, ErrDontFragment
+	}
+	hl := int(b[0]&0xf) << 2
+	if hl < 20 || hl > len(b) {
+		return nil, ErrFragment
+	}
+	// the fragments but the first only have the options to be copied
+	later := append([]byte(nil), b[:20]...)
+	for i := 20; i < hl; {
+		opt := b[i]
+		if opt == 0 { // end of options
+			break
+		}
+		l := 1
+		if opt != 1 { // not a no-op
+			if i+1 >= hl || b[i+1] < 2 || i+int(b[i+1]) > hl {
+				return nil, ErrFragment
+			}
+			l = int(b[i+1])
+		}
+		if opt&0x80 != 0 {
+			later = append(later, b[i:i+l]...)
+		}
+		i += l
+	}
+	for len(later)%4 != 0 {
+		later = append(later, 0)
+	}
+	later[0] = later[0]&0xf0 | byte(len(later)>>2)
+
+	// the packet may itself be a fragment
+	flags := binary.BigEndian.Uint16(b[6:])
+	ofs, more := int(flags&0x1fff)<<3, flags&0x2000 != 0
+
+	var frags []Packet
+	hdr, data := b[:hl], b[hl:]
+	for len(data) != 0 {
+		size := (mtu - len(hdr)) &^ 7
+		if size <= 0 {
+			return nil, ErrFragment
+		}
+		last := size >= len(data)
+		if last {
+			size = len(data)
+		}
+		f := pkt.fragment(hdr, data[:size])
+		h := f.Body[f.L3Offset : f.L3Offset+len(hdr)]
+		binary.BigEndian.PutUint16(h[2:], uint16(len(hdr)+size))
+		fl := uint16(ofs>>3) | flags&0xc000 // the reserved and DF bits as they were
+		if !last || more {
+			fl |= 0x2000
+		}
+		binary.BigEndian.PutUint16(h[6:], fl)
+		h[10], h[11] = 0, 0
+		binary.BigEndian.PutUint16(h[10:], checksum(h))
+		frags = append(frags, f)
+		ofs += size
+		data = data[size:]
+		hdr = later
+	}
+	return frags, nil
+}
+
+// fragment6 fragments the IPv6 packet b of pkt, adding a fragment header
+// after the part of the headers the routers go by.
+func fragment6(pkt Packet, b []byte, mtu int) ([]Packet, error) {
+	// the unfragmentable part goes up to the hop-by-hop and routing headers,
+	// whose next header field (at field) is changed to the fragment header
+	unfrag, field := 40, 6
+	next, at := b[6], 40
+	for next == 0 || next == 43 || next == 60 {
+		if at+2 > len(b) {
+			return nil, ErrFragment
+		}
+		l := 8 + int(b[at+1])*8
+		if at+l > len(b) {
+			return nil, ErrFragment
+		}
+		if next != 60 {
+			unfrag, field = at+l, at
+		}
+		next = b[at]
+		at += l
+	}
+	if next == 44 {
+		// already fragmented
+		return nil, ErrFragment
+	}
+	hdr, data := append([]byte(nil), b[:unfrag]...), b[unfrag:]
+	fh := [8]byte{0: hdr[field]}
+	hdr[field] = 44
+	binary.BigEndian.PutUint32(fh[4:], fragmentID.Add(1))
+
+	var frags []Packet
+	ofs := 0
+	for len(data) != 0 {
+		size := (mtu - len(hdr) - len(fh)) &^ 7
+		if size <= 0 {
+			return nil, ErrFragment
+		}
+		last := size >= len(data)
+		if last {
+			size = len(data)
+		}
+		fl := uint16(ofs)
+		if !last {
+			fl |= 1
+		}
+		binary.BigEndian.PutUint16(fh[2:], fl)
+		f := pkt.fragment(hdr, fh[:], data[:size])
+		binary.BigEndian.PutUint16(f.Body[f.L3Offset+4:], uint16(len(hdr)-40+len(fh)+size))
+		frags = append(frags, f)
+		ofs += size
+		data = data[size:]
+	}
+	return frags, nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,101 @@
+//go:build gofuzz
+
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+//-----------------------------------------------------------------------------
+// Fuzz target for go-fuzz (github.com/dvyukov/go-fuzz). Everything the package
+// parses comes from the network, so none of it may panic whatever the input:
+//
+//	go-fuzz-build github.com/mistsys/tuntap
+//	go-fuzz -bin tuntap-fuzz.zip
+//
+// The first byte of the input selects what is fuzzed, the rest is the input.
+// FuzzPackets, in fuzz_test.go, is the target for go test -fuzz, which also
+// covers the parsers of the responders, reassembly and the rewrites.
+
+// Fuzz is the go-fuzz entry point.
+func Fuzz(data []byte) int {
+	if len(data) == 0 {
+		return -1
+	}
+	sel, b := data[0], data[1:]
+	switch sel % 8 {
+	case 0:
+		// ReadPacket's parsing, for each kind of device and framing
+		for _, t := range []*Interface{
+			{kind: DevTun, framing: framePI},
+			{kind: DevTap, framing: framePI},
+			{kind: DevTun, framing: framePI, vnetHdr: true},
+			{kind: DevTun, framing: frameNone},
+			{kind: DevTap, framing: frameNone},
+		} {
+			if pkt, err := t.parse(b, len(b)); err == nil {
+				fuzzPacket(pkt)
+			}
+		}
+	case 1:
+		// a bare IP packet, as DevTun gives
+		fuzzPacket(Packet{Body: b, Protocol: ipProtocol(b)})
+	case 2:
+		if _, _, err := ParseGTPU(b); err != nil {
+			return 0
+		}
+		_, pkt, err := DecapGTPU(b)
+		if err == nil {
+			fuzzPacket(pkt)
+		}
+	case 3:
+		if _, _, err := ParseGeneve(b); err != nil {
+			return 0
+		}
+		_, pkt, err := DecapGeneve(b)
+		if err == nil {
+			fuzzPacket(pkt)
+		}
+	case 4, 5:
+		udp := sel%8 == 5
+		if _, err := L2TPv3SessionID(b, udp); err != nil {
+			return 0
+		}
+		for _, s := range []L2TPv3Session{
+			{UDP: udp},
+			{UDP: udp, LocalCookie: []byte{1, 2, 3, 4}, Sublayer: true},
+			{UDP: udp, LocalCookie: []byte{1, 2, 3, 4, 5, 6, 7, 8}, Sublayer: true, Sequencing: true},
+		} {
+			s.LocalID, _ = L2TPv3SessionID(b, udp)
+			if pkt, _, err := s.Decap(b); err == nil {
+				fuzzPacket(pkt)
+			}
+		}
+	case 6, 7:
+		f := SerialSLIP
+		if sel%8 == 7 {
+			f = SerialPPP
+		}
+		NewSerialDecoder(f).Feed(b, fuzzPacket)
+	}
+	return 1
+}
+
+// fuzzPacket exercises the Packet accessors.
+func fuzzPacket(pkt Packet) {
+	pkt.DIP()
+	pkt.SIP()
+	pkt.DSCP()
+	if _, at, _ := pkt.IPProto(); at > len(pkt.Body) {
+		// callers slice the Body at the offset
+		panic("IPProto offset past the end of the packet")
+	}
+	pkt.ICMPType()
+	_ = pkt.String()
+}
+
+//-----------------------------------------------------------------------------
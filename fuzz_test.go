@@ -0,0 +1,181 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// Fuzz target for go test. As with the go-fuzz one (fuzz.go), the first
+// argument selects what is fuzzed:
+//
+//	go test -run '^$' -fuzz FuzzPackets
+//
+// Without -fuzz, go test runs the seeds, as a test that the parsers take
+// well-formed packets.
+
+var fuzzMAC = net.HardwareAddr{2, 0, 0, 0, 0, 1}
+
+func FuzzPackets(f *testing.F) {
+	udp4 := fuzzIPv4(17, []byte{0, 68, 0, 67, 0, 8, 0, 0})
+	f.Add(uint8(0), append([]byte{0, 0, 8, 0}, udp4...))
+	f.Add(uint8(1), udp4)
+	f.Add(uint8(1), fuzzIPv4(2, []byte{0x16, 0, 0xfa, 0xfd, 239, 1, 2, 3}))
+	frag := fuzzIPv4(17, []byte{0, 1, 0, 2, 0, 16, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8})
+	frag[6] = 0x20
+	f.Add(uint8(1), frag)
+	f.Add(uint8(1), fuzzIPv6Frag())
+	f.Add(uint8(2), fuzzFrame(ETH_P_ARP, []byte{
+		0, 1, 8, 0, 6, 4, 0, 1,
+		2, 0, 0, 0, 0, 2, 10, 0, 0, 2,
+		0, 0, 0, 0, 0, 0, 10, 0, 0, 1,
+	}))
+	f.Add(uint8(2), fuzzFrame(ETH_P_IP, fuzzDHCPDiscover()))
+	f.Add(uint8(3), []byte{0x30, 0xff, 0, 4, 0, 0, 0, 1, 0x45, 0, 0, 4})
+
+	f.Fuzz(func(t *testing.T, sel uint8, b []byte) {
+		switch sel % 4 {
+		case 0:
+			// ReadPacket's parsing, for each kind of device and framing
+			for _, ifc := range []*Interface{
+				{kind: DevTun, framing: framePI},
+				{kind: DevTap, framing: framePI},
+				{kind: DevTun, framing: framePI, vnetHdr: true},
+				{kind: DevTun, framing: frameNone},
+				{kind: DevTap, framing: frameNone},
+			} {
+				if pkt, err := ifc.parse(b, len(b)); err == nil {
+					fuzzIP(pkt)
+				}
+			}
+		case 1:
+			// a bare IP packet, as DevTun gives
+			fuzzIP(Packet{Body: b, Protocol: ipProtocol(b)})
+		case 2:
+			// an Ethernet frame, as DevTap gives, for the responders
+			pkt, err := ParseFrame(b)
+			if err != nil {
+				return
+			}
+			arp := NewARPResponder()
+			arp.Set(netip.MustParseAddr("10.0.0.1"), fuzzMAC)
+			arp.Answer(&pkt)
+			ndp := NewNDPResponder()
+			ndp.Set(netip.MustParseAddr("fe80::1"), fuzzMAC)
+			ndp.SetRouter(&RouterConfig{MAC: fuzzMAC, Prefixes: []RouterPrefix{{Prefix: netip.MustParsePrefix("2001:db8::/64")}}})
+			ndp.Answer(&pkt)
+			dhcp, err := NewDHCPServer(DHCPServerConfig{
+				Subnet: netip.MustParsePrefix("10.0.0.0/24"),
+				Server: netip.MustParseAddr("10.0.0.1"),
+				MAC:    fuzzMAC,
+				Count:  4,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			dhcp.Answer(&pkt)
+			fuzzIP(pkt)
+		case 3:
+			if _, pkt, err := DecapGTPU(b); err == nil {
+				fuzzIP(pkt)
+			}
+			if _, pkt, err := DecapGeneve(b); err == nil {
+				fuzzIP(pkt)
+			}
+			NewSerialDecoder(SerialSLIP).Feed(b, fuzzIP)
+			NewSerialDecoder(SerialPPP).Feed(b, fuzzIP)
+		}
+	})
+}
+
+// fuzzIP exercises what looks into the IP packet pkt, on copies of it, as
+// the rewrites change it.
+func fuzzIP(pkt Packet) {
+	pkt.Body = append([]byte(nil), pkt.Body...)
+	pkt.DIP()
+	pkt.SIP()
+	pkt.DSCP()
+	if _, at, _ := pkt.IPProto(); at > len(pkt.Body) {
+		// callers slice the Body at the offset
+		panic("IPProto offset past the end of the packet")
+	}
+	pkt.ICMPType()
+	_ = pkt.String()
+	pkt.IPv4Options()
+	ParseMembershipReport(&pkt)
+	if k, ok := pkt.FlowKey(); ok {
+		_ = k.String()
+	}
+	r := NewReassembler(ReassemblyConfig{})
+	r.Add(pkt)
+	Fragment(pkt, 68)
+	if pkt.Vnet.GSOType != VnetGSONone {
+		p := pkt
+		p.Body = append([]byte(nil), pkt.Body...)
+		SegmentGSO(p, [][]byte{make([]byte, 1600), make([]byte, 1600)})
+	}
+
+	p := pkt
+	p.Body = append([]byte(nil), pkt.Body...)
+	p.StripSourceRoute()
+	p.SetTTL(1)
+	p.SetSrcPort(1)
+	p.SetDstPort(2)
+	if p.Protocol == ETH_P_IPV6 {
+		p.SetSIP(netip.MustParseAddr("2001:db8::1"))
+		p.SetDIP(netip.MustParseAddr("2001:db8::2"))
+	} else {
+		p.SetSIP(netip.MustParseAddr("192.0.2.1"))
+		p.SetDIP(netip.MustParseAddr("192.0.2.2"))
+	}
+}
+
+// fuzzIPv4 returns an IPv4 packet of proto carrying payload.
+func fuzzIPv4(proto byte, payload []byte) []byte {
+	b := []byte{0x45, 0, 0, 0, 0, 1, 0, 0, 64, proto, 0, 0, 10, 0, 0, 2, 10, 0, 0, 1}
+	binary.BigEndian.PutUint16(b[2:], uint16(20+len(payload)))
+	binary.BigEndian.PutUint16(b[10:], checksum(b))
+	return append(b, payload...)
+}
+
+// fuzzIPv6Frag returns the first fragment of a UDP datagram over IPv6.
+func fuzzIPv6Frag() []byte {
+	b := make([]byte, 40+8+8)
+	b[0] = 0x60
+	binary.BigEndian.PutUint16(b[4:], 16)
+	b[6], b[7] = 44, 64
+	b[8], b[23] = 0xfe, 1
+	b[24], b[39] = 0xfe, 2
+	b[40] = 17
+	b[43] = 1 // more fragments
+	return b
+}
+
+// fuzzFrame returns a broadcast Ethernet frame from the client, 02:00:00:00:00:02.
+func fuzzFrame(proto uint16, payload []byte) []byte {
+	b := append([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, 2, 0, 0, 0, 0, 2, byte(proto>>8), byte(proto))
+	return append(b, payload...)
+}
+
+// fuzzDHCPDiscover returns a DHCPDISCOVER, in its IPv4 packet.
+func fuzzDHCPDiscover() []byte {
+	m := make([]byte, 240, 244)
+	m[0], m[1], m[2] = 1, 1, 6
+	copy(m[28:], []byte{2, 0, 0, 0, 0, 2})
+	copy(m[236:], []byte{99, 130, 83, 99})
+	m = append(m, 53, 1, 1, 255)
+	u := append([]byte{0, 68, 0, 67, byte((8 + len(m)) >> 8), byte(8 + len(m)), 0, 0}, m...)
+	return fuzzIPv4(17, u)
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,171 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+//-----------------------------------------------------------------------------
+// Geneve (RFC 8926) encapsulation, as used by OVN and other overlay fabrics.
+// Geneve carries either Ethernet frames (from DevTap) or, by giving the
+// ethernet protocol of the payload, bare IP packets (from DevTun).
+
+// the UDP port Geneve is carried on
+const GenevePort = 6081
+
+// Geneve protocol type for Ethernet payloads ("transparent ethernet bridging")
+const ETH_P_TEB uint16 = 0x6558
+
+const (
+	geneveHeaderLen  = 8
+	geneveFlagOAM    = 0x80
+	geneveFlagCrit   = 0x40
+	geneveOptCrit    = 0x80 // the critical bit in an option's type
+	geneveMaxOptions = 63 * 4
+	geneveMaxOptData = 31 * 4 // an option's length is 5 bits of 4-byte units
+)
+
+var ErrGeneveHeader = errors.New("malformed Geneve header")
+var ErrGeneveVersion = errors.New("unsupported Geneve version")
+var ErrGeneveOptions = errors.New("Geneve options too long")
+
+// GeneveOption is one TLV option of a Geneve header.
+type GeneveOption struct {
+	Class uint16
+	Type  uint8 // the most significant bit is the critical bit
+	// the option data. When encoding, it is padded with zeros to a multiple
+	// of 4 bytes
+	Data []byte
+}
+
+// Critical reports whether the receiver must drop the packet if it doesn't
+// understand the option.
+func (o GeneveOption) Critical() bool {
+	return o.Type&geneveOptCrit != 0
+}
+
+// GeneveHeader is the decoded header of a Geneve packet.
+type GeneveHeader struct {
+	VNI      uint32 // 24-bit virtual network identifier
+	Protocol uint16 // ethernet protocol of the payload; ETH_P_TEB for a frame
+	OAM      bool   // control packet, not to be forwarded
+	// set on decode if any option is critical; computed when encoding
+	Critical bool
+	Options  []GeneveOption
+}
+
+// AppendGeneve appends the Geneve packet with header h and payload to dst and
+// returns the extended buffer. It returns ErrGeneveOptions, and dst as it
+// was, if the data of an option is longer than 124 bytes, or the options
+// longer than the 252 bytes Geneve can carry.
+func AppendGeneve(dst []byte, h GeneveHeader, payload []byte) ([]byte, error) {
+	optLen := 0
+	for _, o := range h.Options {
+		if len(o.Data) > geneveMaxOptData {
+			return dst, ErrGeneveOptions
+		}
+		optLen += 4 + (len(o.Data)+3)/4*4
+	}
+	if optLen > geneveMaxOptions {
+		return dst, ErrGeneveOptions
+	}
+	start := len(dst)
+	dst = append(dst, 0, 0, byte(h.Protocol>>8), byte(h.Protocol), byte(h.VNI>>16), byte(h.VNI>>8), byte(h.VNI), 0)
+	var flags byte
+	if h.OAM {
+		flags |= geneveFlagOAM
+	}
+	for _, o := range h.Options {
+		units := (len(o.Data) + 3) / 4
+		if o.Critical() {
+			flags |= geneveFlagCrit
+		}
+		dst = append(dst, byte(o.Class>>8), byte(o.Class), o.Type, byte(units))
+		dst = append(dst, o.Data...)
+		for pad := units*4 - len(o.Data); pad > 0; pad-- {
+			dst = append(dst, 0)
+		}
+	}
+	dst[start] = byte(optLen / 4)
+	dst[start+1] = flags
+	return append(dst, payload...), nil
+}
+
+// ParseGeneve decodes the Geneve packet b (the payload of a UDP datagram) and
+// returns its header and payload. The payload and option data alias b.
+func ParseGeneve(b []byte) (GeneveHeader, []byte, error) {
+	var h GeneveHeader
+	if len(b) < geneveHeaderLen {
+		return h, nil, ErrGeneveHeader
+	}
+	if b[0]>>6 != 0 {
+		return h, nil, ErrGeneveVersion
+	}
+	optLen := int(b[0]&0x3f) * 4
+	h.OAM = b[1]&geneveFlagOAM != 0
+	h.Critical = b[1]&geneveFlagCrit != 0
+	h.Protocol = binary.BigEndian.Uint16(b[2:4])
+	h.VNI = uint32(b[4])<<16 | uint32(b[5])<<8 | uint32(b[6])
+	if geneveHeaderLen+optLen > len(b) {
+		return h, nil, ErrGeneveHeader
+	}
+	opts := b[geneveHeaderLen : geneveHeaderLen+optLen]
+	for len(opts) > 0 {
+		if len(opts) < 4 {
+			return h, nil, ErrGeneveHeader
+		}
+		l := 4 * int(opts[3]&0x1f)
+		if 4+l > len(opts) {
+			return h, nil, ErrGeneveHeader
+		}
+		h.Options = append(h.Options, GeneveOption{
+			Class: binary.BigEndian.Uint16(opts[0:2]),
+			Type:  opts[2],
+			Data:  opts[4 : 4+l],
+		})
+		opts = opts[4+l:]
+	}
+	return h, b[geneveHeaderLen+optLen:], nil
+}
+
+// EncapGeneve returns pkt, read from an Interface of the given kind, wrapped
+// in a Geneve header for network vni with the given options. It returns
+// ErrGeneveOptions if the options don't fit the header (see AppendGeneve).
+func EncapGeneve(vni uint32, pkt Packet, kind DevKind, opts ...GeneveOption) ([]byte, error) {
+	h := GeneveHeader{VNI: vni, Protocol: pkt.Protocol, Options: opts}
+	if kind == DevTap {
+		h.Protocol = ETH_P_TEB
+	}
+	buf := make([]byte, 0, geneveHeaderLen+len(pkt.Body)+16)
+	return AppendGeneve(buf, h, pkt.Body)
+}
+
+// DecapGeneve extracts the payload of the Geneve packet b as a Packet ready
+// to be written to an Interface: an Ethernet frame for a DevTap if the
+// header's Protocol is ETH_P_TEB, otherwise an IP packet for a DevTun. The
+// options are returned in the header; it's up to the caller to drop packets
+// with critical options it doesn't understand. The Packet's Body aliases b.
+func DecapGeneve(b []byte) (GeneveHeader, Packet, error) {
+	h, payload, err := ParseGeneve(b)
+	if err != nil {
+		return h, Packet{}, err
+	}
+	pkt := Packet{Body: payload, Protocol: h.Protocol}
+	if h.Protocol == ETH_P_TEB {
+		if pkt, err = ParseFrame(payload); err != nil {
+			return h, Packet{}, ErrGeneveHeader
+		}
+	}
+	pkt.Dir = DirWrite
+	return h, pkt, nil
+}
+
+//-----------------------------------------------------------------------------
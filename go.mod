@@ -1,9 +1,11 @@
 module github.com/mistsys/tuntap
 
-go 1.12
+go 1.21
 
 require (
 	github.com/pkg/errors v0.9.1
 	github.com/vishvananda/netlink v1.3.0
 	golang.org/x/sys v0.25.0
 )
+
+require github.com/vishvananda/netns v0.0.4 // indirect
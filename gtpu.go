@@ -0,0 +1,200 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+//-----------------------------------------------------------------------------
+// GTP-U (3GPP TS 29.281) framing, for carrying tun packets between a user
+// plane function and a gNB/eNB (or simulators of either) over UDP port 2152.
+
+// the UDP port GTP-U is carried on
+const GTPUPort = 2152
+
+// GTP-U message types
+const (
+	GTPUEchoRequest       uint8 = 1
+	GTPUEchoResponse      uint8 = 2
+	GTPUErrorIndication   uint8 = 26
+	GTPUSupportedExtHdrs  uint8 = 31
+	GTPUEndMarker         uint8 = 254
+	GTPUGPDU              uint8 = 255 // user data (a T-PDU)
+	GTPUExtPDUSessionCont uint8 = 0x85
+)
+
+const (
+	gtpuVersion1  = 0x20 // version 1
+	gtpuPT        = 0x10 // protocol type GTP (as opposed to GTP')
+	gtpuFlagExt   = 0x04
+	gtpuFlagSeq   = 0x02
+	gtpuFlagNPDU  = 0x01
+	gtpuFlagsOpt  = gtpuFlagExt | gtpuFlagSeq | gtpuFlagNPDU
+	gtpuHeaderLen = 8
+)
+
+var ErrGTPUHeader = errors.New("malformed GTP-U header")
+var ErrGTPUNotGPDU = errors.New("GTP-U message is not a G-PDU")
+var ErrGTPUTooLong = errors.New("GTP-U extension header or message too long")
+
+// the longest content of an extension header, whose length is a byte
+// counting 4-byte units
+const gtpuMaxExtContent = 255*4 - 2
+
+// GTPUExtension is one GTP-U extension header.
+type GTPUExtension struct {
+	Type uint8
+	// the content between the length and the next-type bytes. When
+	// encoding, it is padded with zeros to a multiple of 4 bytes (minus the
+	// 2 bytes of framing)
+	Content []byte
+}
+
+// GTPUHeader is the decoded header of a GTP-U message.
+type GTPUHeader struct {
+	Type     uint8 // message type
+	TEID     uint32
+	Sequence uint16
+	NPDU     uint8
+	// which of the optional fields were/are to be present
+	HasSequence bool
+	HasNPDU     bool
+	Extensions  []GTPUExtension
+}
+
+// AppendGTPU appends the GTP-U message with header h and the given payload
+// to dst and returns the extended buffer. It returns ErrGTPUTooLong, and dst
+// as it was, if the content of an extension is longer than its length can
+// say (1018 bytes), or the message longer than 64KiB.
+func AppendGTPU(dst []byte, h GTPUHeader, payload []byte) ([]byte, error) {
+	for _, e := range h.Extensions {
+		if len(e.Content) > gtpuMaxExtContent {
+			return dst, ErrGTPUTooLong
+		}
+	}
+	flags := byte(gtpuVersion1 | gtpuPT)
+	if h.HasSequence {
+		flags |= gtpuFlagSeq
+	}
+	if h.HasNPDU {
+		flags |= gtpuFlagNPDU
+	}
+	if len(h.Extensions) != 0 {
+		flags |= gtpuFlagExt
+	}
+	start := len(dst)
+	dst = append(dst, flags, h.Type, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(dst[start+4:], h.TEID)
+	if flags&gtpuFlagsOpt != 0 {
+		// the optional fields are all present if any one is
+		var next uint8
+		if len(h.Extensions) != 0 {
+			next = h.Extensions[0].Type
+		}
+		dst = append(dst, byte(h.Sequence>>8), byte(h.Sequence), h.NPDU, next)
+		for i, e := range h.Extensions {
+			units := (len(e.Content) + 2 + 3) / 4
+			dst = append(dst, byte(units))
+			dst = append(dst, e.Content...)
+			for pad := units*4 - 2 - len(e.Content); pad > 0; pad-- {
+				dst = append(dst, 0)
+			}
+			next = 0
+			if i+1 < len(h.Extensions) {
+				next = h.Extensions[i+1].Type
+			}
+			dst = append(dst, next)
+		}
+	}
+	dst = append(dst, payload...)
+	n := len(dst) - start - gtpuHeaderLen
+	if n > 0xffff {
+		return dst[:start], ErrGTPUTooLong
+	}
+	binary.BigEndian.PutUint16(dst[start+2:], uint16(n))
+	return dst, nil
+}
+
+// ParseGTPU decodes the GTP-U message b (the payload of a UDP datagram) and
+// returns its header and payload. The payload aliases b, as do the contents
+// of any extension headers.
+func ParseGTPU(b []byte) (GTPUHeader, []byte, error) {
+	var h GTPUHeader
+	if len(b) < gtpuHeaderLen || b[0]&0xf0 != gtpuVersion1|gtpuPT {
+		return h, nil, ErrGTPUHeader
+	}
+	flags := b[0]
+	h.Type = b[1]
+	n := int(binary.BigEndian.Uint16(b[2:4]))
+	h.TEID = binary.BigEndian.Uint32(b[4:8])
+	if gtpuHeaderLen+n > len(b) {
+		return h, nil, ErrGTPUHeader
+	}
+	b = b[gtpuHeaderLen : gtpuHeaderLen+n]
+	if flags&gtpuFlagsOpt != 0 {
+		if len(b) < 4 {
+			return h, nil, ErrGTPUHeader
+		}
+		h.HasSequence = flags&gtpuFlagSeq != 0
+		h.HasNPDU = flags&gtpuFlagNPDU != 0
+		if h.HasSequence {
+			h.Sequence = binary.BigEndian.Uint16(b[0:2])
+		}
+		if h.HasNPDU {
+			h.NPDU = b[2]
+		}
+		next := uint8(0)
+		if flags&gtpuFlagExt != 0 {
+			next = b[3]
+		}
+		b = b[4:]
+		for next != 0 {
+			if len(b) < 1 {
+				return h, nil, ErrGTPUHeader
+			}
+			l := 4 * int(b[0])
+			if l == 0 || l > len(b) {
+				return h, nil, ErrGTPUHeader
+			}
+			h.Extensions = append(h.Extensions, GTPUExtension{Type: next, Content: b[1 : l-1]})
+			next = b[l-1]
+			b = b[l:]
+		}
+	}
+	return h, b, nil
+}
+
+// EncapGTPU returns pkt, which must be an IP packet from a DevTun Interface,
+// wrapped in a GTP-U G-PDU for the tunnel endpoint teid.
+func EncapGTPU(teid uint32, pkt Packet) []byte {
+	buf := make([]byte, 0, gtpuHeaderLen+len(pkt.Body))
+	// an IP packet can't be too long, with no extensions
+	buf, _ = AppendGTPU(buf, GTPUHeader{Type: GTPUGPDU, TEID: teid}, pkt.Body)
+	return buf
+}
+
+// DecapGTPU extracts the IP packet from the G-PDU b, returning it as a Packet
+// ready to be written to a DevTun Interface, along with the TEID. The
+// Packet's Body aliases b. Other GTP-U messages (echo, error indication, end
+// marker...) return ErrGTPUNotGPDU along with the TEID, so the caller can
+// handle them.
+func DecapGTPU(b []byte) (uint32, Packet, error) {
+	h, payload, err := ParseGTPU(b)
+	if err != nil {
+		return 0, Packet{}, err
+	}
+	if h.Type != GTPUGPDU {
+		return h.TEID, Packet{}, ErrGTPUNotGPDU
+	}
+	return h.TEID, Packet{Body: payload, Protocol: ipProtocol(payload), Dir: DirWrite}, nil
+}
+
+//-----------------------------------------------------------------------------
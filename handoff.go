@@ -0,0 +1,322 @@
+//go:build linux || freebsd || darwin || openbsd || netbsd
+
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+// Live handoff, for upgrading a tunnel daemon without taking the tunnel
+// down. The old process hands the Interface over a unix socket to the new
+// one: the file descriptor of the device (SCM_RIGHTS), the settings of the
+// Interface, the flows of a FlowTracker (see Checkpoint) and the
+// application's own state, all in one go. The interface never goes away,
+// as the kernel only destroys it when its last descriptor is closed, and
+// the packets queued meanwhile are there for the new process to read.
+//
+// The old process must stop reading and writing the Interface before
+// Handoff, which closes it once the new process has it: a packet the old
+// process reads after that is lost to the new one. The new process calls
+// TakeOver with a FlowTracker of its own, which gets the flows.
+//
+// The interface stays configured: Handoff doesn't run the OnClose hooks,
+// nor does it undo the configuration made WithCleanup, or record the
+// interface as gone in its journal. The new Interface undoes the same
+// changes when it's closed, WithCleanup, and records in the same journal
+// file. The settings which are values (WithIPv6Only,
+// WithSourceRoutePolicy, WithTracerouteHop...) go across, but the objects
+// with state of their own (the ARP and NDP responders, the DHCP server, the
+// EtherType mux, the latency sampling) don't: the new process gives its own
+// to TakeOver as Options. The anycast addresses are left.
+//
+// Raw Interfaces (OpenRaw) aren't handed off; the new process can just
+// open its own.
+
+var ErrHandoff = errors.New("not an interface handoff, or a truncated one")
+
+const (
+	handoffMagic   = "TTHO"
+	handoffVersion = 2
+)
+
+// handoffExtra is what version 2 of the handoff adds, as JSON.
+type handoffExtra struct {
+	SourceRoute SourceRoutePolicy
+	Hop4, Hop6  netip.Addr
+	Cleanup     bool
+	// the length of the virtio-net header of a FreeBSD tap
+	VnetLen int `json:",omitempty"`
+	// the path of the journal, if any, and the changes to undo
+	Journal string         `json:",omitempty"`
+	Undo    []JournalEntry `json:",omitempty"`
+}
+
+// Handoff sends t, the flows of ft (which may be nil) and state, the
+// application's, over conn to the process calling TakeOver at the other
+// end, and closes t once that process has it.
+func (t *Interface) Handoff(conn *net.UnixConn, ft *FlowTracker, state []byte) error {
+	if t.sendBatch != nil {
+		return ErrNotSupported
+	}
+	var flows bytes.Buffer
+	if ft != nil {
+		if err := ft.Checkpoint(&flows); err != nil {
+			return err
+		}
+	}
+
+	// the settings of the Interface, then the flows and the state
+	b := []byte(handoffMagic)
+	b = binary.BigEndian.AppendUint32(b, handoffVersion)
+	body := binary.BigEndian.AppendUint16(nil, uint16(len(t.name)))
+	body = append(body, t.name...)
+	body = append(body, byte(t.kind), byte(t.framing), byte(t.serial))
+	var flags byte
+	for i, set := range []bool{t.nonblock, t.ipv6Only, t.rejectIPv4, t.vnetHdr} {
+		if set {
+			flags |= 1 << uint(i)
+		}
+	}
+	body = append(body, flags)
+	body = binary.BigEndian.AppendUint32(body, uint32(t.offloads))
+	body = binary.BigEndian.AppendUint64(body, uint64(t.maxPacket.Load()))
+	body = binary.BigEndian.AppendUint64(body, uint64(t.mtuPacket.Load()))
+	body = binary.BigEndian.AppendUint32(body, uint32(flows.Len()))
+	body = append(body, flows.Bytes()...)
+	body = binary.BigEndian.AppendUint32(body, uint32(len(state)))
+	body = append(body, state...)
+	extra := handoffExtra{SourceRoute: t.sourceRoute, Hop4: t.hop4, Hop6: t.hop6, Cleanup: t.cleanup, VnetLen: t.vnetLen}
+	if t.journal != nil {
+		extra.Journal = t.journal.path
+	}
+	t.closeLock.Lock()
+	extra.Undo = t.undo
+	t.closeLock.Unlock()
+	x, err := json.Marshal(&extra)
+	if err != nil {
+		return err
+	}
+	body = binary.BigEndian.AppendUint32(body, uint32(len(x)))
+	body = append(body, x...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(body)))
+
+	// the descriptor goes along with the header
+	rc, err := t.file.SyscallConn()
+	if err != nil {
+		return t.closedErr(err)
+	}
+	var werr error
+	err = rc.Control(func(fd uintptr) {
+		_, _, werr = conn.WriteMsgUnix(b, unix.UnixRights(int(fd)), nil)
+	})
+	if err != nil {
+		return t.closedErr(err)
+	}
+	if werr != nil {
+		return werr
+	}
+	if _, err := conn.Write(body); err != nil {
+		return err
+	}
+
+	// wait for the other end to have it
+	var ack [1]byte
+	if _, err := io.ReadFull(conn, ack[:]); err != nil {
+		return err
+	}
+	// the interface is the new process's now, as it is
+	return t.close(false)
+}
+
+// TakeOver receives an Interface handed off over conn with Handoff, restoring
+// the flows into ft (if it isn't nil; see FlowTracker.Restore), and returns
+// it with the state of the application. Of opts, those of the objects which
+// don't go across (WithARPResponder, WithNDPResponder, WithDHCPServer,
+// WithEtherTypeMux, WithLatencySampling) apply, and WithJournal, which
+// otherwise opens the journal file of the old Interface again.
+func TakeOver(conn *net.UnixConn, ft *FlowTracker, opts ...Option) (*Interface, []byte, error) {
+	hdr := make([]byte, 12)
+	oob := make([]byte, unix.CmsgSpace(4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(hdr, oob)
+	if err != nil {
+		return nil, nil, err
+	}
+	var fd = -1
+	if cmsgs, err := unix.ParseSocketControlMessage(oob[:oobn]); err == nil && len(cmsgs) == 1 {
+		if fds, err := unix.ParseUnixRights(&cmsgs[0]); err == nil && len(fds) == 1 {
+			fd = fds[0]
+		}
+	}
+	if fd < 0 {
+		return nil, nil, ErrHandoff
+	}
+	t, state, extra, err := takeOver(conn, fd, hdr[:n])
+	if err != nil {
+		unix.Close(fd)
+		return nil, nil, err
+	}
+	if err = t.takeOverSettings(extra, opts); err != nil {
+		t.file.Close()
+		return nil, nil, err
+	}
+	// until the ack, the interface is the old process's: a failure leaves
+	// it as it is, and the Interface untracked
+	if ft != nil {
+		flows := state[0]
+		if len(flows) != 0 {
+			if _, err := ft.Restore(bytes.NewReader(flows)); err != nil {
+				t.file.Close()
+				return nil, nil, err
+			}
+		}
+	}
+	if _, err := conn.Write([]byte{1}); err != nil {
+		t.file.Close()
+		return nil, nil, err
+	}
+	t, _ = track(t, nil)
+	if t.cleanup {
+		registerCleanup(t)
+	}
+	if t.ndp != nil {
+		t.advertiseRouter()
+	}
+	return t, state[1], nil
+}
+
+// takeOverSettings sets what version 2 of the handoff, extra, and opts give
+// the Interface taken over.
+func (t *Interface) takeOverSettings(extra *handoffExtra, opts []Option) error {
+	var cfg config
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if (cfg.arp != nil || cfg.ndp != nil || cfg.dhcp != nil || cfg.etherTypes != nil) && t.kind != DevTap {
+		return errors.New("tuntap: responders and EtherType muxes require a DevTap interface")
+	}
+	t.arp, t.ndp, t.dhcp = cfg.arp, cfg.ndp, cfg.dhcp
+	t.etherTypes = cfg.etherTypes
+	t.latency = newLatencySampler(cfg.latency)
+	t.journal = cfg.journal
+	if extra == nil {
+		// from a version 1 handoff
+		return nil
+	}
+	t.sourceRoute, t.hop4, t.hop6 = extra.SourceRoute, extra.Hop4, extra.Hop6
+	t.cleanup = extra.Cleanup
+	t.vnetLen = extra.VnetLen
+	if t.journal == nil && extra.Journal != "" {
+		j, err := OpenJournal(extra.Journal)
+		if err != nil {
+			return err
+		}
+		t.journal = j
+	}
+	if t.cleanup {
+		for _, e := range extra.Undo {
+			t.undoOnClose(e)
+		}
+	}
+	return nil
+}
+
+// takeOver reads the rest of the handoff of fd, which starts with hdr, and
+// returns the Interface, the flows and the application's state, and what
+// version 2 adds (nil from version 1).
+func takeOver(conn *net.UnixConn, fd int, hdr []byte) (*Interface, [2][]byte, *handoffExtra, error) {
+	var state [2][]byte
+	if _, err := io.ReadFull(conn, hdr[len(hdr):cap(hdr)]); err != nil {
+		return nil, state, nil, ErrHandoff
+	}
+	hdr = hdr[:cap(hdr)]
+	if string(hdr[:4]) != handoffMagic {
+		return nil, state, nil, ErrHandoff
+	}
+	version := binary.BigEndian.Uint32(hdr[4:8])
+	if version < 1 || version > handoffVersion {
+		return nil, state, nil, errors.New("unsupported interface handoff version")
+	}
+	body := make([]byte, binary.BigEndian.Uint32(hdr[8:12]))
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return nil, state, nil, ErrHandoff
+	}
+
+	// next returns the next n bytes of body, or nil if there aren't as many
+	next := func(n int) []byte {
+		if n > len(body) {
+			body = nil
+			return nil
+		}
+		b := body[:n]
+		body = body[n:]
+		return b
+	}
+	l := next(2)
+	if l == nil {
+		return nil, state, nil, ErrHandoff
+	}
+	name := next(int(binary.BigEndian.Uint16(l)))
+	settings := next(3 + 1 + 4 + 8 + 8)
+	if settings == nil {
+		return nil, state, nil, ErrHandoff
+	}
+	for i := range state {
+		l := next(4)
+		if l == nil {
+			return nil, state, nil, ErrHandoff
+		}
+		state[i] = next(int(binary.BigEndian.Uint32(l)))
+		if state[i] == nil {
+			return nil, state, nil, ErrHandoff
+		}
+	}
+	var extra *handoffExtra
+	if version >= 2 {
+		l := next(4)
+		if l == nil {
+			return nil, state, nil, ErrHandoff
+		}
+		x := next(int(binary.BigEndian.Uint32(l)))
+		extra = new(handoffExtra)
+		if x == nil || json.Unmarshal(x, extra) != nil {
+			return nil, state, nil, ErrHandoff
+		}
+	}
+
+	t := &Interface{
+		name:     string(name),
+		file:     os.NewFile(uintptr(fd), string(name)),
+		kind:     DevKind(settings[0]),
+		framing:  framing(settings[1]),
+		serial:   SerialFraming(settings[2]),
+		offloads: Offload(binary.BigEndian.Uint32(settings[4:8])),
+	}
+	flags := settings[3]
+	t.nonblock = flags&1 != 0
+	t.ipv6Only = flags&2 != 0
+	t.rejectIPv4 = flags&4 != 0
+	t.vnetHdr = flags&8 != 0
+	t.maxPacket.Store(int64(binary.BigEndian.Uint64(settings[8:16])))
+	t.mtuPacket.Store(int64(binary.BigEndian.Uint64(settings[16:24])))
+	return t, state, extra, nil
+}
+
+//-----------------------------------------------------------------------------
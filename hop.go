@@ -0,0 +1,78 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"net/netip"
+)
+
+//-----------------------------------------------------------------------------
+// Traceroute through userspace gateways. A gateway forwarding the packets
+// it reads from an Interface is a hop of the paths through it, but one which
+// doesn't decrement the TTL, or drops the packets whose TTL runs out without
+// a word: traceroutes show a gap (a "* * *") where it is. WithTracerouteHop
+// makes the Interface act as a router for them: the packets read whose TTL
+// (or hop limit) expires at this hop, 1 or less, are answered with an ICMP
+// time exceeded from the hop's address, and dropped, so that the traceroute
+// shows the gateway under the address given.
+//
+// The packets to the hop itself, and to link-local and multicast
+// addresses, which a router doesn't forward, are passed on as usual, as are
+// those of the family the hop has no address of. Decrementing the TTL of
+// the ones forwarded, with SetTTL, is the gateway's business.
+
+// WithTracerouteHop makes the Interface answer the packets whose TTL
+// expires with ICMP time exceeded, from the first IPv4 and the first IPv6
+// of addrs for the packets of each family.
+func WithTracerouteHop(addrs ...netip.Addr) Option {
+	return func(c *config) {
+		for _, a := range addrs {
+			if a = a.Unmap(); a.Is4() && !c.hop4.IsValid() {
+				c.hop4 = a
+			} else if a.Is6() && !c.hop6.IsValid() {
+				c.hop6 = a.WithZone("")
+			}
+		}
+	}
+}
+
+// TTLExpired returns the number of packets read whose TTL expired at the
+// hop of an Interface opened WithTracerouteHop.
+func (t *Interface) TTLExpired() uint64 {
+	return t.ttlExpired.Load()
+}
+
+// expireTTL returns whether the packet read, pkt, expires at the Interface's
+// hop, answering it.
+func (t *Interface) expireTTL(pkt *Packet) bool {
+	ip := pkt.ip()
+	var ttl uint8
+	var dst, from netip.Addr
+	switch {
+	case pkt.Protocol == ETH_P_IP && len(ip) >= 20 && t.hop4.IsValid():
+		ttl, from = ip[8], t.hop4
+		dst = netip.AddrFrom4([4]byte{ip[16], ip[17], ip[18], ip[19]})
+	case pkt.Protocol == ETH_P_IPV6 && len(ip) >= 40 && t.hop6.IsValid():
+		ttl, from = ip[7], t.hop6
+		dst, _ = netip.AddrFromSlice(ip[24:40])
+	default:
+		return false
+	}
+	if ttl > 1 || dst == from || dst.IsMulticast() || dst.IsLinkLocalUnicast() || dst.IsLoopback() {
+		return false
+	}
+	t.ttlExpired.Add(1)
+	if reply, ok := TimeExceeded(pkt, from); ok {
+		// like a router's, the ICMP is best effort
+		t.writePacket(reply)
+	}
+	return true
+}
+
+//-----------------------------------------------------------------------------
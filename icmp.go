@@ -0,0 +1,206 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+)
+
+//-----------------------------------------------------------------------------
+// ICMP errors, for the routers and tunnel endpoints built on Interfaces:
+// traceroute needs the time exceeded of each hop, and path MTU discovery
+// the packet too big (fragmentation needed, for IPv4) of the link which
+// can't take a packet. The builders answer an offending packet with an
+// ICMPv4 or ICMPv6 error of its family, quoting as much of it as RFC 1812
+// (576 bytes in all) and RFC 4443 (1280 bytes) allow, and return it ready to
+// write to the Interface the offending packet came from: a DevTap frame goes
+// back to the MAC address it came from, through the same VLAN.
+//
+// They don't answer what mustn't be (RFC 1812, 4.3.2.7, and RFC 4443, 2.4):
+// ICMP errors, fragments other than the first, and packets from an address
+// which isn't unicast or to a multicast one (but for the IPv6 packet too
+// big, which path MTU discovery for multicast needs).
+//
+// The errors come from the address given, the router's own; the zero Addr
+// makes them come from the destination of the packet, as if the
+// destination answered.
+
+// UnreachableCode is why a destination is unreachable, which DestUnreachable
+// maps to the ICMPv4 or ICMPv6 code.
+type UnreachableCode int
+
+const (
+	// no route to the destination
+	UnreachableNoRoute UnreachableCode = iota
+	// communication administratively prohibited
+	UnreachableProhibited
+	// the host isn't there (no neighbour answers)
+	UnreachableHost
+	// no application on the port, as a host answers
+	UnreachablePort
+)
+
+// the ICMPv4 and ICMPv6 codes of the UnreachableCodes
+var unreachableCodes = [...][2]uint8{
+	UnreachableNoRoute:    {0, 0},
+	UnreachableProhibited: {13, 1},
+	UnreachableHost:       {1, 3},
+	UnreachablePort:       {3, 4},
+}
+
+// DestUnreachable returns the destination unreachable answering pkt, from
+// from; false if pkt mustn't be answered.
+func DestUnreachable(pkt *Packet, from netip.Addr, code UnreachableCode) (Packet, bool) {
+	if code < 0 || int(code) >= len(unreachableCodes) {
+		return Packet{}, false
+	}
+	if pkt.Protocol == ETH_P_IP {
+		return icmp4Error(pkt, from, 3, unreachableCodes[code][0], 0)
+	}
+	return icmp6Error(pkt, from, 1, unreachableCodes[code][1], 0, false)
+}
+
+// TimeExceeded returns the time exceeded in transit (the TTL, or hop limit,
+// down to 0) answering pkt, from from; false if pkt mustn't be answered.
+func TimeExceeded(pkt *Packet, from netip.Addr) (Packet, bool) {
+	if pkt.Protocol == ETH_P_IP {
+		return icmp4Error(pkt, from, 11, 0, 0)
+	}
+	return icmp6Error(pkt, from, 3, 0, 0, false)
+}
+
+// PacketTooBig returns the packet too big (ICMPv6), or fragmentation needed
+// and DF set (ICMPv4), telling the source of pkt that the path goes through
+// a link of MTU mtu, from from; false if pkt mustn't be answered. It's for
+// the IPv4 packets with DF set, which Fragment refuses, and all IPv6 ones.
+func PacketTooBig(pkt *Packet, from netip.Addr, mtu int) (Packet, bool) {
+	if pkt.Protocol == ETH_P_IP {
+		return icmp4Error(pkt, from, 3, 4, uint32(mtu)&0xffff)
+	}
+	return icmp6Error(pkt, from, 2, 0, uint32(mtu), true)
+}
+
+// icmpReply starts the frame of a reply to pkt: in front of the IP (or ARP)
+// header, what pkt has, going back where it came from.
+func icmpReply(pkt *Packet, size int) []byte {
+	reply := make([]byte, pkt.L3Offset, pkt.L3Offset+size)
+	if pkt.L3Offset != 0 {
+		// back to where the frame came from, through the same VLAN
+		copy(reply, pkt.Body[:pkt.L3Offset])
+		copy(reply[0:6], pkt.Body[6:12])
+		copy(reply[6:12], pkt.Body[0:6])
+	}
+	return reply
+}
+
+// icmp4Error returns the ICMPv4 error typ, code with info in its second
+// word, answering the IPv4 packet pkt.
+func icmp4Error(pkt *Packet, from netip.Addr, typ, code uint8, info uint32) (Packet, bool) {
+	ip := pkt.ip()
+	proto, at, frag := pkt.IPProto()
+	if pkt.Protocol != ETH_P_IP || at == 0 || frag {
+		return Packet{}, false
+	}
+	at -= pkt.L3Offset
+	if proto == 1 {
+		// only queries are answered, not errors
+		if at >= len(ip) {
+			return Packet{}, false
+		}
+		switch ip[at] {
+		case 3, 4, 5, 11, 12:
+			return Packet{}, false
+		}
+	}
+	src, dst := ip[12:16], ip[16:20]
+	if src[0] == 0 || src[0] >= 224 || dst[0] >= 224 {
+		return Packet{}, false
+	}
+	if from.IsValid() {
+		if !from.Is4() {
+			return Packet{}, false
+		}
+		a := from.As4()
+		dst = a[:]
+	}
+	quoted := len(ip)
+	if n := int(binary.BigEndian.Uint16(ip[2:])); n < quoted {
+		quoted = n
+	}
+	if quoted > 576-20-8 {
+		quoted = 576 - 20 - 8
+	}
+
+	reply := icmpReply(pkt, 28+quoted)
+	hdr := len(reply)
+	reply = append(reply,
+		0x45, 0, 0, 0, 0, 0, 0, 0, 64, 1, 0, 0,
+		dst[0], dst[1], dst[2], dst[3], src[0], src[1], src[2], src[3],
+		typ, code, 0, 0)
+	reply = binary.BigEndian.AppendUint32(reply, info)
+	reply = append(reply, ip[:quoted]...)
+	binary.BigEndian.PutUint16(reply[hdr+2:], uint16(len(reply)-hdr))
+	binary.BigEndian.PutUint16(reply[hdr+10:], checksum(reply[hdr:hdr+20]))
+	binary.BigEndian.PutUint16(reply[hdr+22:], checksum(reply[hdr+20:]))
+	return Packet{Body: reply, Protocol: ETH_P_IP, L3Offset: pkt.L3Offset, Dir: DirWrite}, true
+}
+
+// icmp6Error returns the ICMPv6 error typ, code with info in its second
+// word, answering the IPv6 packet pkt, which may be to a multicast address
+// if multicast.
+func icmp6Error(pkt *Packet, from netip.Addr, typ, code uint8, info uint32, multicast bool) (Packet, bool) {
+	ip := pkt.ip()
+	proto, at, frag := pkt.IPProto()
+	if pkt.Protocol != ETH_P_IPV6 || at == 0 || frag {
+		return Packet{}, false
+	}
+	at -= pkt.L3Offset
+	if proto == 58 && (at >= len(ip) || ip[at] < 128) {
+		// an error; the informational messages are from 128 up
+		return Packet{}, false
+	}
+	src, dst := net.IP(ip[8:24]), net.IP(ip[24:40])
+	if src.IsUnspecified() || src.IsMulticast() || dst.IsMulticast() && !multicast {
+		return Packet{}, false
+	}
+	if from.IsValid() {
+		if !from.Is6() || from.Is4In6() {
+			return Packet{}, false
+		}
+		a := from.As16()
+		dst = a[:]
+	} else if dst.IsMulticast() {
+		// the error can't come from there
+		return Packet{}, false
+	}
+	quoted := len(ip)
+	if n := 40 + int(binary.BigEndian.Uint16(ip[4:])); n < quoted {
+		quoted = n
+	}
+	if quoted > 1280-40-8 {
+		quoted = 1280 - 40 - 8
+	}
+
+	reply := icmpReply(pkt, 48+quoted)
+	hdr := len(reply)
+	reply = append(reply, 0x60, 0, 0, 0, 0, 0, 58, 64)
+	reply = append(reply, dst...)
+	reply = append(reply, src...)
+	reply = append(reply, typ, code, 0, 0)
+	reply = binary.BigEndian.AppendUint32(reply, info)
+	reply = append(reply, ip[:quoted]...)
+	m := reply[hdr+40:]
+	binary.BigEndian.PutUint16(reply[hdr+4:], uint16(len(m)))
+	binary.BigEndian.PutUint16(m[2:4], icmp6Checksum(dst, src, m))
+	return Packet{Body: reply, Protocol: ETH_P_IPV6, L3Offset: pkt.L3Offset, Dir: DirWrite}, true
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,119 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+)
+
+//-----------------------------------------------------------------------------
+// ICMP sockets on the interface, for the probes which go through the host's
+// network stack rather than the Interface: echo requests of chosen sizes,
+// sent with DF whatever the host knows of the path MTU, for path MTU
+// discovery, and the echo replies and "too big" errors they bring back.
+// They're raw sockets, so they need CAP_NET_RAW, and see all the ICMP
+// messages the host receives on the interface.
+
+var ErrICMPSize = errors.New("ICMP probe size too small for its headers")
+
+// ICMPConn is an ICMP (ICMPv6) socket bound to the interface of an
+// Interface. As a net.IPConn, its writes take the ICMP message, from its
+// type, and its reads return it, without the IP header.
+type ICMPConn struct {
+	*net.IPConn
+	ipv6 bool
+}
+
+// ListenICMP opens an ICMP socket, or an ICMPv6 one with ipv6, on the
+// interface. Only implemented on Linux.
+func (t *Interface) ListenICMP(ipv6 bool) (*ICMPConn, error) {
+	if err := t.configurable(); err != nil {
+		return nil, err
+	}
+	return listenICMP(t.name, ipv6)
+}
+
+// SendEcho sends an echo request with id and seq to dst, padded for the IP
+// packet to be size bytes. It's sent with DF, so a size larger than the MTU
+// of the interface fails with EMSGSIZE, and one larger than the path MTU
+// brings back a "too big" from the router which can't forward it.
+func (c *ICMPConn) SendEcho(dst netip.Addr, id, seq uint16, size int) error {
+	typ, hdr := uint8(8), 20
+	if c.ipv6 {
+		typ, hdr = 128, 40
+	}
+	if size < hdr+8 {
+		return ErrICMPSize
+	}
+	msg := make([]byte, size-hdr)
+	msg[0] = typ
+	binary.BigEndian.PutUint16(msg[4:], id)
+	binary.BigEndian.PutUint16(msg[6:], seq)
+	if !c.ipv6 {
+		// the kernel computes ICMPv6 checksums, which cover the
+		// pseudo-header, but not ICMPv4 ones
+		binary.BigEndian.PutUint16(msg[2:], checksum(msg))
+	}
+	_, err := c.WriteToIP(msg, &net.IPAddr{IP: dst.AsSlice(), Zone: dst.Zone()})
+	return err
+}
+
+// ICMPMessage is an ICMP (ICMPv6) message received.
+type ICMPMessage struct {
+	Type, Code uint8
+	// what follows the type, code and checksum
+	Body []byte
+	From netip.Addr
+}
+
+// ReadMessage reads an ICMP message into b.
+func (c *ICMPConn) ReadMessage(b []byte) (ICMPMessage, error) {
+	for {
+		n, from, err := c.ReadFromIP(b)
+		if err != nil {
+			return ICMPMessage{}, err
+		}
+		if n < 4 {
+			continue
+		}
+		addr, _ := netip.AddrFromSlice(from.IP)
+		if !c.ipv6 {
+			addr = addr.Unmap()
+		}
+		return ICMPMessage{Type: b[0], Code: b[1], Body: b[4:n], From: addr.WithZone(from.Zone)}, nil
+	}
+}
+
+// EchoReply returns the id and seq of an echo reply; false if m isn't one.
+func (m *ICMPMessage) EchoReply() (id, seq uint16, ok bool) {
+	if m.Type != 0 && m.Type != 129 || m.Code != 0 || len(m.Body) < 4 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint16(m.Body), binary.BigEndian.Uint16(m.Body[2:]), true
+}
+
+// TooBig returns the MTU of a fragmentation needed (ICMPv4) or packet too big
+// (ICMPv6) error, and the packet it quotes; false if m isn't one.
+func (m *ICMPMessage) TooBig() (mtu int, quoted []byte, ok bool) {
+	if len(m.Body) < 4 {
+		return 0, nil, false
+	}
+	switch {
+	case m.Type == 3 && m.Code == 4:
+		return int(binary.BigEndian.Uint16(m.Body[2:])), m.Body[4:], true
+	case m.Type == 2 && m.Code == 0 && m.From.Is6() && !m.From.Is4In6():
+		return int(binary.BigEndian.Uint32(m.Body)), m.Body[4:], true
+	}
+	return 0, nil, false
+}
+
+//-----------------------------------------------------------------------------
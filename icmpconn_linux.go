@@ -0,0 +1,56 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"net"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+
+// listenICMP opens a raw ICMP (ICMPv6) socket bound to the interface ifName
+// (SO_BINDTODEVICE), which sends with DF and ignores the path MTU the host
+// has cached (IP_PMTUDISC_PROBE).
+func listenICMP(ifName string, ipv6 bool) (*ICMPConn, error) {
+	family, proto := unix.AF_INET, unix.IPPROTO_ICMP
+	if ipv6 {
+		family, proto = unix.AF_INET6, unix.IPPROTO_ICMPV6
+	}
+	fd, err := unix.Socket(family, unix.SOCK_RAW|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return nil, errors.Wrap(err, "tuntap: Can't create ICMP socket")
+	}
+	file := os.NewFile(uintptr(fd), "icmp:"+ifName)
+	defer file.Close()
+
+	if err = unix.SetsockoptString(fd, unix.SOL_SOCKET, unix.SO_BINDTODEVICE, ifName); err != nil {
+		return nil, errors.Wrapf(err, "tuntap: Can't bind ICMP socket to %s", ifName)
+	}
+	if ipv6 {
+		err = unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, unix.IPV6_PMTUDISC_PROBE)
+	} else {
+		err = unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_PROBE)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "tuntap: Can't set IP_MTU_DISCOVER on ICMP socket")
+	}
+
+	// FilePacketConn dups the socket, so file is closed either way
+	c, err := net.FilePacketConn(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "tuntap: Can't use ICMP socket")
+	}
+	return &ICMPConn{IPConn: c.(*net.IPConn), ipv6: ipv6}, nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,164 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// Idle detection. A client on battery wants to suspend its tunnel when
+// nothing uses it, and the tunnel's own keepalives mustn't count as use. The
+// application shows an IdleMonitor the packets it reads and writes, and the
+// monitor calls back when none but keepalives have gone by for the timeout,
+// optionally bringing the interface down, and again when traffic resumes.
+//
+// While idle, the application can tear down its datapath (goroutines,
+// buffers, the tunnel's connection...) and leave a single goroutine in
+// WaitActive, which sleeps on the device until a packet other than a
+// keepalive comes, and then hands it over so the datapath can be spun back
+// up. The interface must stay up for that (Down unset): the host can't send
+// anything through an interface which is down, and only the application
+// then wakes it, by its first write.
+
+// IdleConfig configures an IdleMonitor.
+type IdleConfig struct {
+	// how long without traffic the interface is idle after
+	Timeout time.Duration
+	// if set, says which packets are keepalives, which don't count as
+	// traffic
+	IsKeepalive func(pkt *Packet) bool
+	// bring the interface down when it goes idle, and up when traffic
+	// resumes
+	Down bool
+	// called when the interface goes idle, and when it's active again;
+	// err is the error bringing it down or up, if Down. Either may be nil.
+	// They're called one at a time, in order, and mustn't call the monitor.
+	OnIdle   func(err error)
+	OnActive func(err error)
+}
+
+// IdleMonitor watches the traffic of an Interface for idleness. It is safe
+// for concurrent use.
+type IdleMonitor struct {
+	t   *Interface
+	cfg IdleConfig
+
+	last atomic.Int64 // UnixNano of the last packet which wasn't a keepalive
+	idle atomic.Bool
+
+	lock   sync.Mutex // serializes going idle and active
+	timer  *time.Timer
+	closed bool
+}
+
+// NewIdleMonitor returns a monitor of t's traffic, which t starts out having
+// just had.
+func NewIdleMonitor(t *Interface, cfg IdleConfig) *IdleMonitor {
+	m := &IdleMonitor{t: t, cfg: cfg}
+	m.last.Store(time.Now().UnixNano())
+	m.timer = time.AfterFunc(cfg.Timeout, m.check)
+	return m
+}
+
+// Seen accounts pkt, read from or about to be written to the Interface. When
+// the interface was idle, the monitor makes it active again before returning,
+// so that with Down, Seen must be called before writing the packet (a tun
+// device which is down refuses writes).
+func (m *IdleMonitor) Seen(pkt Packet) {
+	if m.cfg.IsKeepalive != nil && m.cfg.IsKeepalive(&pkt) {
+		return
+	}
+	m.last.Store(time.Now().UnixNano())
+	if m.idle.Load() {
+		m.wake()
+	}
+}
+
+// Idle returns whether the interface is idle.
+func (m *IdleMonitor) Idle() bool {
+	return m.idle.Load()
+}
+
+// check runs when the timeout may have expired, and goes idle if it has.
+func (m *IdleMonitor) check() {
+	m.lock.Lock()
+	if m.closed {
+		m.lock.Unlock()
+		return
+	}
+	left := m.cfg.Timeout - time.Since(time.Unix(0, m.last.Load()))
+	if left > 0 {
+		m.timer.Reset(left)
+		m.lock.Unlock()
+		return
+	}
+	var err error
+	if m.cfg.Down {
+		err = m.t.Down()
+	}
+	m.idle.Store(true)
+	if m.cfg.OnIdle != nil {
+		m.cfg.OnIdle(err)
+	}
+	m.lock.Unlock()
+}
+
+// wake makes the interface active again, and starts waiting for the next
+// timeout.
+func (m *IdleMonitor) wake() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.closed || !m.idle.Load() {
+		// someone else woke it up
+		return
+	}
+	var err error
+	if m.cfg.Down {
+		err = m.t.Up()
+	}
+	m.idle.Store(false)
+	m.timer.Reset(m.cfg.Timeout)
+	if m.cfg.OnActive != nil {
+		m.cfg.OnActive(err)
+	}
+}
+
+// WaitActive waits for the monitor to be active again because of a packet
+// read from the Interface, reading the packets into buf, and returns that
+// packet; keepalives which come in the meantime are dropped. It only returns
+// early if the read fails, e.g. with ErrClosed (or ErrWouldBlock, on an
+// Interface opened WithNonblocking). It's meant to be called while the
+// monitor is idle, with nothing else reading the Interface.
+func (m *IdleMonitor) WaitActive(buf []byte) (Packet, error) {
+	for {
+		pkt, err := m.t.ReadPacket(buf)
+		if err != nil {
+			return Packet{}, err
+		}
+		if m.cfg.IsKeepalive != nil && m.cfg.IsKeepalive(&pkt) {
+			continue
+		}
+		m.Seen(pkt)
+		return pkt, nil
+	}
+}
+
+// Close stops the monitor. It leaves the interface as it is, down if it was
+// brought down.
+func (m *IdleMonitor) Close() {
+	m.lock.Lock()
+	m.closed = true
+	m.timer.Stop()
+	m.lock.Unlock()
+}
+
+//-----------------------------------------------------------------------------
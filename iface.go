@@ -0,0 +1,64 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"net"
+	"path"
+)
+
+//-----------------------------------------------------------------------------
+// Introspection of the interface, so that applications handed an Interface
+// don't have to guess what it is, nor poll net.InterfaceByName for its
+// state. Flags reads the flags of the interface the way ifconfig does
+// (SIOCGIFFLAGS), so it's current.
+
+// Kind returns the kind of the Interface: DevTun, or DevTap for the
+// Interfaces exchanging Ethernet frames (tap, raw, macvtap...).
+func (t *Interface) Kind() DevKind {
+	return t.kind
+}
+
+// Index returns the index of the interface, or 0 if it can't be found (it
+// was destroyed, or renamed) or the Interface is sealed.
+func (t *Interface) Index() int {
+	if t.configurable() != nil {
+		return 0
+	}
+	ifi, err := net.InterfaceByName(path.Base(t.name))
+	if err != nil {
+		return 0
+	}
+	return ifi.Index
+}
+
+// HardwareAddr returns the MAC address of the interface, nil for a DevTun
+// interface, which has none.
+func (t *Interface) HardwareAddr() (net.HardwareAddr, error) {
+	if err := t.configurable(); err != nil {
+		return nil, err
+	}
+	ifi, err := net.InterfaceByName(path.Base(t.name))
+	if err != nil {
+		return nil, err
+	}
+	if len(ifi.HardwareAddr) == 0 {
+		return nil, nil
+	}
+	return ifi.HardwareAddr, nil
+}
+
+// IsUp returns whether the interface is UP; false if its flags can't be
+// read (as when the Interface is sealed).
+func (t *Interface) IsUp() bool {
+	flags, err := t.Flags()
+	return err == nil && flags&net.FlagUp != 0
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,42 @@
+//go:build linux || freebsd || darwin || openbsd || netbsd
+
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+
+// netFlags returns the net.Flags of the IFF_ flags of an interface.
+func netFlags(raw int) net.Flags {
+	var flags net.Flags
+	for _, f := range []struct {
+		iff  int
+		flag net.Flags
+	}{
+		{unix.IFF_UP, net.FlagUp},
+		{unix.IFF_BROADCAST, net.FlagBroadcast},
+		{unix.IFF_LOOPBACK, net.FlagLoopback},
+		{unix.IFF_POINTOPOINT, net.FlagPointToPoint},
+		{unix.IFF_MULTICAST, net.FlagMulticast},
+		{unix.IFF_RUNNING, net.FlagRunning},
+	} {
+		if raw&f.iff != 0 {
+			flags |= f.flag
+		}
+	}
+	return flags
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,80 @@
+//go:build darwin || openbsd || netbsd
+
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"net"
+	"path"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+// IPv4 addresses, where struct ifaliasreq is the 4.4BSD one: the name, then
+// the address, destination or broadcast address, and mask as sockaddr_ins.
+
+func inSockAddr(buf []byte, ip net.IP) {
+	// uint8 sin_len, length of this struct
+	buf[0] = sizeofInSockAddr
+	// uint8 sin_family, AF_INET
+	buf[1] = unix.AF_INET
+	// uint16 sin_port, then the 4-byte sin_addr
+	copy(buf[4:8], ip)
+}
+
+// addAddress4 adds an IPv4 address with SIOCAIFADDR. tun interfaces are
+// point-to-point; like wg-quick, this uses the local address as destination.
+// tap interfaces get brd as broadcast address if it isn't nil, else the
+// subnet's. Note no route to the subnet is added; that needs the routing
+// socket.
+func (t *Interface) addAddress4(ip net.IP, subnet *net.IPNet, brd net.IP) error {
+	ip = ip.To4()
+	mask := net.IP(subnet.Mask)
+	if len(mask) == net.IPv6len {
+		mask = mask[12:]
+	}
+	dst := ip
+	if brd != nil && t.kind != DevTap {
+		return ErrNotSupported
+	}
+	if brd != nil {
+		dst = brd.To4()
+	} else if t.kind == DevTap {
+		// the broadcast address of the subnet
+		dst = make(net.IP, net.IPv4len)
+		for i := range dst {
+			dst[i] = ip[i] | ^mask[i]
+		}
+	}
+
+	// build the ifaliasreq structure
+	var ifra [sizeofIfAliasReq]byte
+	copy(ifra[:IFNAMSIZ], path.Base(t.Name()))
+	ofs := IFNAMSIZ
+	// ifra_addr
+	inSockAddr(ifra[ofs:], ip)
+	ofs += sizeofInSockAddr
+	// ifra_dstaddr, or ifra_broadaddr
+	inSockAddr(ifra[ofs:], dst)
+	ofs += sizeofInSockAddr
+	// ifra_mask
+	inSockAddr(ifra[ofs:], mask)
+
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	return ioctl(fd, unix.SIOCAIFADDR, unsafe.Pointer(&ifra))
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,26 @@
+//go:build freebsd || darwin || netbsd
+
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2022-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func ioctl(fd int, req uint, arg unsafe.Pointer) error {
+	_, _, err := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg))
+	if err != 0 {
+		return fmt.Errorf("(%d) %s", err, unix.ErrnoName(err))
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// OpenBSD only allows system calls from libc, so ioctl can't be a raw
+// syscall. unix has no exported ioctl taking an arbitrary pointer, but its
+// IoctlSetWinsize is one, calling into libc.
+func ioctl(fd int, req uint, arg unsafe.Pointer) error {
+	err := unix.IoctlSetWinsize(fd, req, (*unix.Winsize)(arg))
+	if errno, ok := err.(unix.Errno); ok {
+		return fmt.Errorf("(%d) %s", errno, unix.ErrnoName(errno))
+	}
+	return err
+}
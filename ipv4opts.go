@@ -0,0 +1,237 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+)
+
+//-----------------------------------------------------------------------------
+// IPv4 options. IPv4Options parses the options of an IPv4 header, with the
+// record route, source route and timestamp options decoded further.
+//
+// Source routing lets the sender of a packet pick the routers it goes
+// through, past the filters of the gateway, so gateways don't forward
+// source-routed packets (RFC 7126, 4.3 and 4.4). An Interface opened
+// WithSourceRoutePolicy applies the policy to the packets it reads:
+// stripping the option, so that the packet goes to its destination as any
+// other would, or dropping the packet, answering it with an ICMP
+// "administratively prohibited" if the policy is to reject it. The packets
+// whose options are malformed are dropped too, as they can't be checked.
+
+var ErrIPv4Options = errors.New("malformed IPv4 options")
+
+// The types of the common IPv4 options (the copied flag, class and number).
+const (
+	IPv4OptEOL         uint8 = 0
+	IPv4OptNOP         uint8 = 1
+	IPv4OptRecordRoute uint8 = 7
+	IPv4OptTimestamp   uint8 = 68
+	IPv4OptLSRR        uint8 = 131 // loose source and record route
+	IPv4OptSSRR        uint8 = 137 // strict source and record route
+	IPv4OptRouterAlert uint8 = 148
+)
+
+// IPv4Option is an option of an IPv4 header. Data is in the packet's Body.
+type IPv4Option struct {
+	Type uint8
+	// the option's bytes after its type and length
+	Data []byte
+	// the offset in Body of the option
+	Offset int
+}
+
+// Copied returns whether the option is copied into all the fragments of the
+// packet.
+func (o IPv4Option) Copied() bool {
+	return o.Type&0x80 != 0
+}
+
+// SourceRoute returns whether the option is a loose or strict source route.
+func (o IPv4Option) SourceRoute() bool {
+	return o.Type == IPv4OptLSRR || o.Type == IPv4OptSSRR
+}
+
+// Route returns the addresses of a record route or source route option, and
+// the index in them of the next one to be recorded or routed through,
+// len(addrs) once the route is full or done.
+func (o IPv4Option) Route() (addrs []netip.Addr, next int) {
+	if o.Type != IPv4OptRecordRoute && !o.SourceRoute() || len(o.Data) < 1 {
+		return nil, 0
+	}
+	for b := o.Data[1:]; len(b) >= 4; b = b[4:] {
+		a, _ := netip.AddrFromSlice(b[:4])
+		addrs = append(addrs, a)
+	}
+	// the pointer counts from the option's type, and starts at 4
+	next = (int(o.Data[0]) - 4) / 4
+	if next < 0 || next > len(addrs) {
+		next = len(addrs)
+	}
+	return addrs, next
+}
+
+// IPv4Timestamp is an entry of a timestamp option: the time, in milliseconds
+// since midnight UT unless its top bit is set, and the address of the router
+// which recorded it, if the option has them.
+type IPv4Timestamp struct {
+	Addr netip.Addr
+	Time uint32
+}
+
+// Timestamps returns the entries recorded in a timestamp option, and the
+// number of routers which couldn't record theirs for lack of room.
+func (o IPv4Option) Timestamps() (ts []IPv4Timestamp, overflow int) {
+	if o.Type != IPv4OptTimestamp || len(o.Data) < 2 {
+		return nil, 0
+	}
+	size := 4
+	if flag := o.Data[1] & 0xf; flag == 1 || flag == 3 {
+		// each with its address
+		size = 8
+	}
+	end := int(o.Data[0]) - 3
+	if end > len(o.Data) {
+		end = len(o.Data)
+	}
+	for i := 2; i+size <= end; i += size {
+		var t IPv4Timestamp
+		if size == 8 {
+			t.Addr, _ = netip.AddrFromSlice(o.Data[i : i+4])
+		}
+		t.Time = binary.BigEndian.Uint32(o.Data[i+size-4:])
+		ts = append(ts, t)
+	}
+	return ts, int(o.Data[1] >> 4)
+}
+
+// IPv4Options returns the options of an IPv4 packet, but the end of list and
+// no-operation ones; none for other packets. It returns ErrIPv4Options if
+// they can't be parsed.
+func (p *Packet) IPv4Options() ([]IPv4Option, error) {
+	ip := p.ip()
+	if p.Protocol != ETH_P_IP || len(ip) < 20 {
+		return nil, nil
+	}
+	hl := int(ip[0]&0xf) << 2
+	if hl < 20 || hl > len(ip) {
+		return nil, ErrIPv4Options
+	}
+	var opts []IPv4Option
+	for i := 20; i < hl; {
+		typ := ip[i]
+		if typ == IPv4OptEOL {
+			break
+		}
+		if typ == IPv4OptNOP {
+			i++
+			continue
+		}
+		if i+1 >= hl || ip[i+1] < 2 || i+int(ip[i+1]) > hl {
+			return nil, ErrIPv4Options
+		}
+		l := int(ip[i+1])
+		opts = append(opts, IPv4Option{Type: typ, Data: ip[i+2 : i+l], Offset: p.L3Offset + i})
+		i += l
+	}
+	return opts, nil
+}
+
+// StripSourceRoute replaces the source route options of an IPv4 packet with
+// no-operation ones, keeping the length of the header, and updates its
+// checksum. It returns whether there were any.
+func (p *Packet) StripSourceRoute() (bool, error) {
+	opts, err := p.IPv4Options()
+	if err != nil {
+		return false, err
+	}
+	stripped := false
+	for _, o := range opts {
+		if !o.SourceRoute() {
+			continue
+		}
+		// the checksum is updated 16 bits at a time, from the header's start
+		at := o.Offset - p.L3Offset
+		from, to := p.L3Offset+at&^1, p.L3Offset+(at+2+len(o.Data)+1)&^1
+		nops := append([]byte(nil), p.Body[from:to]...)
+		for i := o.Offset - from; i < o.Offset-from+2+len(o.Data); i++ {
+			nops[i] = IPv4OptNOP
+		}
+		p.rewrite(from, nops, true, false)
+		stripped = true
+	}
+	return stripped, nil
+}
+
+// SourceRoutePolicy is what an Interface does with the source-routed IPv4
+// packets it reads.
+type SourceRoutePolicy int
+
+const (
+	// pass them on
+	SourceRouteAccept SourceRoutePolicy = iota
+	// strip their source route options (see StripSourceRoute)
+	SourceRouteStrip
+	// drop them silently
+	SourceRouteDrop
+	// drop them, and answer them with ICMP destination unreachable,
+	// communication administratively prohibited
+	SourceRouteReject
+)
+
+// WithSourceRoutePolicy has the Interface apply policy to the source-routed
+// IPv4 packets it reads, and drop those with malformed options unless the
+// policy is SourceRouteAccept.
+func WithSourceRoutePolicy(policy SourceRoutePolicy) Option {
+	return func(c *config) { c.sourceRoute = policy }
+}
+
+// SourceRouted returns the number of source-routed IPv4 packets (or packets
+// with malformed options) the Interface read, and stripped or dropped.
+func (t *Interface) SourceRouted() uint64 {
+	return t.sourceRouted.Load()
+}
+
+// checkSourceRoute applies the Interface's source route policy to the IPv4
+// packet read, pkt, and returns whether it's dropped.
+func (t *Interface) checkSourceRoute(pkt *Packet) bool {
+	ip := pkt.ip()
+	if len(ip) < 20 || ip[0]&0xf == 5 {
+		// no options: the usual case
+		return false
+	}
+	opts, err := pkt.IPv4Options()
+	if err != nil {
+		t.sourceRouted.Add(1)
+		return true
+	}
+	routed := false
+	for _, o := range opts {
+		routed = routed || o.SourceRoute()
+	}
+	if !routed {
+		return false
+	}
+	t.sourceRouted.Add(1)
+	switch t.sourceRoute {
+	case SourceRouteStrip:
+		pkt.StripSourceRoute()
+		return false
+	case SourceRouteReject:
+		if reply, ok := DestUnreachable(pkt, netip.Addr{}, UnreachableProhibited); ok {
+			// best effort, like a router's
+			t.writePacket(reply)
+		}
+	}
+	return true
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,109 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+)
+
+//-----------------------------------------------------------------------------
+// IPv6-only Interfaces. In an IPv6-only deployment, IPv4 leaking through the
+// tunnel is a misconfiguration at best, so WithIPv6Only makes the Interface
+// refuse it in both directions: IPv4 packets (and ARP, on a DevTap) the host
+// sends are dropped as they're read, or rejected with an ICMP
+// "administratively prohibited" so that the host's applications fail fast
+// and fall back to IPv6, and writing an IPv4 packet fails with
+// ErrIPv4Refused.
+
+var ErrIPv4Refused = errors.New("IPv4 packet refused by an IPv6-only interface")
+
+// errRefused is how parse tells the readers to skip a packet the policy drops
+var errRefused = errors.New("packet refused")
+
+// WithIPv6Only makes the Interface IPv6-only. With reject, the IPv4 packets
+// the host sends are answered with ICMP destination unreachable,
+// communication administratively prohibited (type 3, code 13), rather than
+// dropped silently.
+func WithIPv6Only(reject bool) Option {
+	return func(c *config) {
+		c.ipv6Only = true
+		c.rejectIPv4 = reject
+	}
+}
+
+// IPv4Refused returns the number of IPv4 packets the Interface refused to
+// read or write, being IPv6-only.
+func (t *Interface) IPv4Refused() uint64 {
+	return t.ipv4Refused.Load()
+}
+
+// ipv4Packet returns whether pkt is IPv4 (or ARP).
+func ipv4Packet(pkt *Packet) bool {
+	switch pkt.Protocol {
+	case ETH_P_IP, ETH_P_ARP:
+		return true
+	case 0:
+		return pkt.L3Offset == 0 && ipProtocol(pkt.Body) == ETH_P_IP
+	}
+	return false
+}
+
+// refuseIPv4 returns whether the packet read, pkt, is refused, and rejects
+// it if the Interface is to.
+func (t *Interface) refuseIPv4(pkt *Packet) bool {
+	if !ipv4Packet(pkt) {
+		return false
+	}
+	t.ipv4Refused.Add(1)
+	if t.rejectIPv4 && pkt.Protocol == ETH_P_IP {
+		if reply, ok := prohibited(pkt); ok {
+			// like a router's, the ICMP is best effort
+			t.writePacket(reply)
+		}
+	}
+	return true
+}
+
+// prohibited returns the ICMP administratively prohibited answering the IPv4
+// packet pkt, from its destination, or false if pkt mustn't be answered (see
+// DestUnreachable). Of the ICMP queries, only echo requests are worth
+// answering.
+func prohibited(pkt *Packet) (Packet, bool) {
+	if proto, at, _ := pkt.IPProto(); proto == 1 && (at >= len(pkt.Body) || pkt.Body[at] != 8) {
+		return Packet{}, false
+	}
+	return DestUnreachable(pkt, netip.Addr{}, UnreachableProhibited)
+}
+
+// prohibited6 is prohibited for IPv6 packets.
+func prohibited6(pkt *Packet) (Packet, bool) {
+	if proto, at, _ := pkt.IPProto(); proto == 58 && (at >= len(pkt.Body) || pkt.Body[at] != 128) {
+		return Packet{}, false
+	}
+	return DestUnreachable(pkt, netip.Addr{}, UnreachableProhibited)
+}
+
+// checksum returns the internet checksum (RFC 1071) of b.
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+	if len(b)%2 != 0 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+//-----------------------------------------------------------------------------
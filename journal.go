@@ -0,0 +1,325 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/netip"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+//-----------------------------------------------------------------------------
+// A journal of the changes the package makes to the host through the
+// Interfaces opened WithJournal: the addresses and routes added, and the
+// sysctls set. Each change is appended to the file, and synced, before the
+// method making it returns, as is its undoing (DelAddress...), and the
+// interface going away with everything on it. What a process which didn't
+// shut down cleanly leaves behind is thus in the file, for the next run to
+// list (Leftovers) or undo (Clean), before it opens its interfaces.
+//
+// A journal file must be used by a single process at a time. It's a file of
+// JSON lines, so that a record torn by a crash is only the last line, which
+// is skipped.
+
+// the kinds of JournalEntry
+const (
+	JournalAddress = "address"
+	JournalRoute   = "route"
+	JournalSysctl  = "sysctl"
+	JournalLabel   = "addrlabel"
+)
+
+// JournalEntry is a change made to the host.
+type JournalEntry struct {
+	// JournalAddress, JournalRoute, JournalSysctl or JournalLabel
+	Kind string
+	// the name of the interface
+	Interface string
+	// the address, with the prefix length of its subnet, the destination
+	// of the route, or the prefix labelled
+	Prefix netip.Prefix
+	// the gateway, metric, table and protocol of the route
+	Gateway  netip.Addr
+	Metric   int `json:",omitempty"`
+	Table    int `json:",omitempty"`
+	Protocol int `json:",omitempty"`
+	// the address label
+	Label uint32 `json:",omitempty"`
+	// the sysctl file, and its value before the change
+	Path  string `json:",omitempty"`
+	Value string `json:",omitempty"`
+}
+
+// a line of the journal file
+type journalRecord struct {
+	// "+" for a change, "-" for its undoing, "gone" for the interface going
+	// away
+	Op string
+	JournalEntry
+}
+
+// Journal records the changes made to the host in a file.
+type Journal struct {
+	lock sync.Mutex
+	path string
+	f    *os.File
+}
+
+// OpenJournal opens the journal file at path, creating it if needed.
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{path: path, f: f}, nil
+}
+
+// WithJournal records the changes made through the Interface in j.
+func WithJournal(j *Journal) Option {
+	return func(c *config) { c.journal = j }
+}
+
+// record appends r to the file, and syncs it. It's best effort: the change
+// has been made, and failing it wouldn't undo it.
+func (j *Journal) record(r journalRecord) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.f == nil {
+		return
+	}
+	if _, err := j.f.Write(append(b, '\n')); err == nil {
+		j.f.Sync()
+	}
+}
+
+// Leftovers returns the changes recorded which haven't been undone, in the
+// order they were made.
+func (j *Journal) Leftovers() ([]JournalEntry, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.leftovers()
+}
+
+func (j *Journal) leftovers() ([]JournalEntry, error) {
+	if j.f == nil {
+		return nil, ErrClosed
+	}
+	f, err := os.Open(j.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []JournalEntry
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var r journalRecord
+		if json.Unmarshal(s.Bytes(), &r) != nil {
+			continue
+		}
+		switch r.Op {
+		case "+":
+			entries = append(entries, r.JournalEntry)
+		case "-":
+			for i := len(entries) - 1; i >= 0; i-- {
+				if entries[i] == r.JournalEntry {
+					entries = append(entries[:i], entries[i+1:]...)
+					break
+				}
+			}
+		case "gone":
+			kept := entries[:0]
+			for _, e := range entries {
+				if e.Interface != r.Interface {
+					kept = append(kept, e)
+				}
+			}
+			entries = kept
+		}
+	}
+	return entries, s.Err()
+}
+
+// Clean undoes the changes Leftovers returns, the last made first, and
+// rewrites the journal with those it couldn't undo. The changes on an
+// interface which no longer exists went away with it. It returns the first
+// error undoing one.
+func (j *Journal) Clean() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	entries, err := j.leftovers()
+	if err != nil {
+		return err
+	}
+	var failed []JournalEntry
+	var first error
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if _, err := net.InterfaceByName(e.Interface); err != nil {
+			continue
+		}
+		if err := e.undo(&Interface{name: e.Interface}); err != nil {
+			failed = append([]JournalEntry{e}, failed...)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	if err := j.rewrite(failed); err != nil {
+		return err
+	}
+	return first
+}
+
+// rewrite replaces the file with one recording entries, atomically.
+func (j *Journal) rewrite(entries []JournalEntry) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(j.path), filepath.Base(j.path)+".")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	for _, e := range entries {
+		b, _ := json.Marshal(journalRecord{Op: "+", JournalEntry: e})
+		w.Write(append(b, '\n'))
+	}
+	if err = w.Flush(); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), j.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	f, err := os.OpenFile(j.path, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	j.f.Close()
+	j.f = f
+	return nil
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.f == nil {
+		return nil
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
+
+// undo undoes e, on t.
+func (e JournalEntry) undo(t *Interface) error {
+	switch e.Kind {
+	case JournalAddress:
+		ip, subnet, err := ipNet(e.Prefix)
+		if err != nil {
+			return err
+		}
+		return t.DelAddress(ip, subnet)
+	case JournalRoute:
+		r, err := RouteTo(e.Prefix, e.Gateway)
+		if err != nil {
+			return err
+		}
+		r.Metric, r.Table, r.Protocol = e.Metric, e.Table, e.Protocol
+		return t.DelRoute(r)
+	case JournalSysctl:
+		return ioutil.WriteFile(e.Path, []byte(e.Value), 0)
+	case JournalLabel:
+		return t.DelAddressLabel(e.Prefix, e.Label)
+	}
+	return nil
+}
+
+// addressEntry returns the entry of the address ip on subnet of t.
+func (t *Interface) addressEntry(ip net.IP, subnet *net.IPNet) JournalEntry {
+	p := Route{Dst: &net.IPNet{IP: ip, Mask: subnet.Mask}}.DstPrefix()
+	return JournalEntry{Kind: JournalAddress, Interface: path.Base(t.Name()), Prefix: p}
+}
+
+// routeEntry returns the entry of the route r of t.
+func (t *Interface) routeEntry(r Route) JournalEntry {
+	return JournalEntry{
+		Kind:      JournalRoute,
+		Interface: path.Base(t.Name()),
+		Prefix:    r.DstPrefix(),
+		Gateway:   r.GatewayAddr(),
+		Metric:    r.Metric,
+		Table:     r.Table,
+		Protocol:  r.Protocol,
+	}
+}
+
+// labelEntry returns the entry of the address label label of p on t.
+func (t *Interface) labelEntry(p netip.Prefix, label uint32) JournalEntry {
+	p, _, _ = canonical(p)
+	return JournalEntry{Kind: JournalLabel, Interface: path.Base(t.Name()), Prefix: p, Label: label}
+}
+
+// applied records a change made to the host through t: in its journal, and
+// as an OnClose hook undoing it if t was opened WithCleanup.
+func (t *Interface) applied(e JournalEntry) {
+	if t.journal != nil {
+		t.journal.record(journalRecord{Op: "+", JournalEntry: e})
+	}
+	if t.cleanup {
+		t.undoOnClose(e)
+	}
+}
+
+// undoOnClose registers the OnClose hook undoing e.
+func (t *Interface) undoOnClose(e JournalEntry) {
+	t.closeLock.Lock()
+	t.undo = append(t.undo, e)
+	t.closeLock.Unlock()
+	t.OnClose(func() {
+		// DelAddress and DelRoute record their undoing themselves
+		if e.undo(t) == nil && e.Kind == JournalSysctl {
+			t.reverted(e)
+		}
+	})
+}
+
+// reverted records a change undone through t.
+func (t *Interface) reverted(e JournalEntry) {
+	if t.journal != nil {
+		t.journal.record(journalRecord{Op: "-", JournalEntry: e})
+	}
+}
+
+// closedJournal records the interface of t as gone, if closing t removed it.
+func (t *Interface) closedJournal() {
+	if t.journal == nil {
+		return
+	}
+	name := path.Base(t.Name())
+	if _, err := net.InterfaceByName(name); err != nil {
+		t.journal.record(journalRecord{Op: "gone", JournalEntry: JournalEntry{Interface: name}})
+	}
+}
+
+//-----------------------------------------------------------------------------
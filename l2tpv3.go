@@ -0,0 +1,150 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+//-----------------------------------------------------------------------------
+// L2TPv3 (RFC 3931) data message framing for static Ethernet pseudowires
+// (RFC 4719), so frames from a DevTap Interface can be exchanged with routers
+// configured with static L2TPv3 sessions. There is no control connection:
+// session IDs and cookies are configured on both ends.
+
+const (
+	// IP protocol number of L2TPv3 over IP
+	L2TPv3IPProto = 115
+	// UDP port of L2TPv3 over UDP
+	L2TPv3Port = 1701
+)
+
+const (
+	l2tpv3UDPVersion = 3
+	l2tpv3FlagT      = 0x8000 // control message
+	l2tpv3SublayerS  = 0x40   // sequence number is valid
+)
+
+var ErrL2TPv3Header = errors.New("malformed L2TPv3 header")
+var ErrL2TPv3Control = errors.New("L2TPv3 control message")
+var ErrL2TPv3Session = errors.New("L2TPv3 session ID or cookie mismatch")
+
+// L2TPv3Session is one end of a static L2TPv3 session.
+type L2TPv3Session struct {
+	// the session IDs. Messages are sent with the RemoteID, and received
+	// messages must carry the LocalID. 0 is reserved for control messages.
+	LocalID  uint32
+	RemoteID uint32
+	// the cookies (0, 4 or 8 bytes) sent with and expected in messages
+	LocalCookie  []byte
+	RemoteCookie []byte
+	// UDP encapsulation (RFC 3931 section 4.1.2.1) as opposed to directly
+	// over IP
+	UDP bool
+	// include the default L2-specific sublayer (RFC 4719 section 3.2), and
+	// if Sequencing, number the frames
+	Sublayer   bool
+	Sequencing bool
+
+	seq uint32
+}
+
+func (s *L2TPv3Session) headerLen(cookie []byte) int {
+	n := 4 + len(cookie)
+	if s.UDP {
+		n += 4
+	}
+	if s.Sublayer {
+		n += 4
+	}
+	return n
+}
+
+// Encap appends the L2TPv3 data message carrying frame to dst and returns the
+// extended buffer. It's the payload of an IP datagram with protocol
+// L2TPv3IPProto, or of a UDP datagram if s.UDP.
+func (s *L2TPv3Session) Encap(dst []byte, frame []byte) []byte {
+	if s.UDP {
+		dst = append(dst, 0, l2tpv3UDPVersion, 0, 0)
+	}
+	dst = append(dst, byte(s.RemoteID>>24), byte(s.RemoteID>>16), byte(s.RemoteID>>8), byte(s.RemoteID))
+	dst = append(dst, s.RemoteCookie...)
+	if s.Sublayer {
+		var flags byte
+		var seq uint32
+		if s.Sequencing {
+			flags = l2tpv3SublayerS
+			seq = s.seq & 0xffffff
+			s.seq++
+		}
+		dst = append(dst, flags, byte(seq>>16), byte(seq>>8), byte(seq))
+	}
+	return append(dst, frame...)
+}
+
+// L2TPv3SessionID returns the session ID of the L2TPv3 message b, for
+// dispatching it to the right L2TPv3Session. udp indicates UDP encapsulation.
+// Control messages return ErrL2TPv3Control.
+func L2TPv3SessionID(b []byte, udp bool) (uint32, error) {
+	if udp {
+		if len(b) < 8 || binary.BigEndian.Uint16(b[0:2])&0xf != l2tpv3UDPVersion {
+			return 0, ErrL2TPv3Header
+		}
+		if binary.BigEndian.Uint16(b[0:2])&l2tpv3FlagT != 0 {
+			return 0, ErrL2TPv3Control
+		}
+		b = b[4:]
+	}
+	if len(b) < 4 {
+		return 0, ErrL2TPv3Header
+	}
+	id := binary.BigEndian.Uint32(b[0:4])
+	if id == 0 {
+		return 0, ErrL2TPv3Control
+	}
+	return id, nil
+}
+
+// Decap checks the L2TPv3 data message b belongs to the session and returns
+// the Ethernet frame it carries as a Packet ready to be written to a DevTap
+// Interface, along with the sequence number if the sender numbered it (-1
+// otherwise). The Packet's Body aliases b.
+func (s *L2TPv3Session) Decap(b []byte) (Packet, int, error) {
+	id, err := L2TPv3SessionID(b, s.UDP)
+	if err != nil {
+		return Packet{}, -1, err
+	}
+	if len(b) < s.headerLen(s.LocalCookie) {
+		return Packet{}, -1, ErrL2TPv3Header
+	}
+	if s.UDP {
+		b = b[4:]
+	}
+	if id != s.LocalID || !bytes.Equal(b[4:4+len(s.LocalCookie)], s.LocalCookie) {
+		return Packet{}, -1, ErrL2TPv3Session
+	}
+	b = b[4+len(s.LocalCookie):]
+	seq := -1
+	if s.Sublayer {
+		if b[0]&l2tpv3SublayerS != 0 {
+			seq = int(b[1])<<16 | int(b[2])<<8 | int(b[3])
+		}
+		b = b[4:]
+	}
+	pkt, err := ParseFrame(b)
+	if err != nil {
+		return Packet{}, seq, ErrL2TPv3Header
+	}
+	pkt.Dir = DirWrite
+	return pkt, seq, nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,102 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+
+// the device files the package opens
+var devicePaths = []string{"/dev/net/tun"}
+
+// landlockAccessFS returns the filesystem rights known to the given Landlock
+// ABI version, which are all the ones the ruleset handles (and so forbids,
+// unless a rule allows them).
+func landlockAccessFS(abi uintptr) uint64 {
+	var fs uint64 = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+	if abi >= 2 {
+		fs |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		fs |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	if abi >= 5 {
+		// otherwise TUNSETIFF on a newly opened /dev/net/tun would be denied
+		fs |= unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+	}
+	return fs
+}
+
+func restrictPaths(paths []string) error {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		// ENOSYS if the kernel is too old, EOPNOTSUPP if Landlock is disabled
+		return errors.Wrapf(ErrNotSupported, "tuntap: Landlock unavailable (%v)", errno)
+	}
+	attr := unix.LandlockRulesetAttr{Access_fs: landlockAccessFS(abi)}
+	r, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return errors.Wrap(errno, "tuntap: Can't create Landlock ruleset")
+	}
+	ruleset := int(r)
+	defer unix.Close(ruleset)
+
+	fileAccess := attr.Access_fs & (unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_TRUNCATE | unix.LANDLOCK_ACCESS_FS_IOCTL_DEV)
+	for _, p := range paths {
+		fd, err := unix.Open(p, unix.O_PATH|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return errors.Wrapf(err, "tuntap: Can't open %s", p)
+		}
+		rule := unix.LandlockPathBeneathAttr{Allowed_access: fileAccess, Parent_fd: int32(fd)}
+		var st unix.Stat_t
+		if unix.Fstat(fd, &st) == nil && st.Mode&unix.S_IFMT == unix.S_IFDIR {
+			// a directory: everything beneath it is allowed, listing included
+			rule.Allowed_access |= unix.LANDLOCK_ACCESS_FS_READ_DIR
+		}
+		_, _, errno = unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset), unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&rule)), 0, 0, 0)
+		unix.Close(fd)
+		if errno != 0 {
+			return errors.Wrapf(errno, "tuntap: Can't add Landlock rule for %s", p)
+		}
+	}
+
+	// enforcing a ruleset requires no_new_privs, and both are per thread
+	if err := lockPrivileges(); err != nil {
+		return err
+	}
+	_, _, errno = syscall.AllThreadsSyscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(ruleset), 0, 0)
+	if errno == syscall.ENOTSUP {
+		return errors.New("tuntap: Can't enforce Landlock on all threads in a program using cgo")
+	}
+	if errno != 0 {
+		return errors.Wrap(errno, "tuntap: Can't enforce Landlock ruleset")
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
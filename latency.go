@@ -0,0 +1,180 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// The latency of the device's read and write syscalls. When the throughput
+// of a gateway falls short, the question is whether the kernel or the
+// gateway is the bottleneck. WithLatencySampling times one in so many of the
+// syscalls reading and writing packets, the time spent in the syscall
+// itself, not waiting for the device to be readable or writable, and Stats
+// gives their percentiles. Write latencies growing with the load point at
+// the kernel's side (its queues, or the stack behind the interface); flat
+// syscall latencies, with the throughput still short, at the gateway's.
+//
+// The latencies go in a histogram of 4 buckets per power of two, so that the
+// percentiles are within a quarter of the true ones. The syscalls sampled
+// are the read(2) and write(2) of each packet, and the writev(2) of the
+// packets with virtio-net headers.
+
+// LatencyStats summarizes the latencies of the syscalls sampled.
+type LatencyStats struct {
+	// the syscalls timed
+	Samples uint64
+	// the percentiles of their latencies, and the longest
+	P50, P90, P99, Max time.Duration
+}
+
+// InterfaceStats holds what an Interface measured. It is all zero unless the
+// Interface was opened WithLatencySampling.
+type InterfaceStats struct {
+	// the latencies of the syscalls reading packets, and of those writing
+	// them
+	Read, Write LatencyStats
+}
+
+// WithLatencySampling makes the Interface time one in n of the syscalls
+// reading packets, and one in n of those writing them, for Stats. 1 times
+// them all, at the cost of two clock reads per syscall.
+func WithLatencySampling(n int) Option {
+	return func(c *config) { c.latency = n }
+}
+
+// Stats returns what the Interface measured so far.
+func (t *Interface) Stats() InterfaceStats {
+	if t.latency == nil {
+		return InterfaceStats{}
+	}
+	return InterfaceStats{Read: t.latency.read.stats(), Write: t.latency.write.stats()}
+}
+
+// latencySampler picks the syscalls to time, and holds their latencies.
+type latencySampler struct {
+	every         uint64
+	reads, writes atomic.Uint64
+	read, write   latencyHistogram
+}
+
+// newLatencySampler returns nil, sampling nothing, unless n is positive.
+func newLatencySampler(n int) *latencySampler {
+	if n <= 0 {
+		return nil
+	}
+	return &latencySampler{every: uint64(n)}
+}
+
+// readDue returns whether the next read is to be timed; false if s is nil.
+func (s *latencySampler) readDue() bool {
+	return s != nil && s.reads.Add(1)%s.every == 0
+}
+
+// writeDue returns whether the next write is to be timed; false if s is nil.
+func (s *latencySampler) writeDue() bool {
+	return s != nil && s.writes.Add(1)%s.every == 0
+}
+
+// read reads a packet into buffer, timing the read(2) if it's due.
+func (t *Interface) read(buffer []byte) (int, error) {
+	if !t.latency.readDue() {
+		return t.file.Read(buffer)
+	}
+	return t.readTimed(buffer)
+}
+
+// write writes b, timing the write(2) if it's due.
+func (t *Interface) write(b []byte) (int, error) {
+	if !t.latency.writeDue() {
+		return t.file.Write(b)
+	}
+	return t.writeTimed(b)
+}
+
+// the buckets: the durations under 4ns have one each, and each power of two
+// above 4 buckets, up to time.Duration's 2^63ns
+const latencyBuckets = 62 * 4
+
+// latencyHistogram holds latencies, which it can record concurrently.
+type latencyHistogram struct {
+	counts [latencyBuckets]atomic.Uint64
+	max    atomic.Int64
+}
+
+// latencyBucket returns the bucket of d.
+func latencyBucket(d time.Duration) int {
+	if d < 4 {
+		if d < 0 {
+			return 0
+		}
+		return int(d)
+	}
+	ns := uint64(d)
+	b := bits.Len64(ns)
+	// the 2 bits under the top one pick the bucket of the power of two
+	return (b-2)*4 + int(ns>>uint(b-3)&3)
+}
+
+// latencyBucketMax returns the longest duration in bucket i.
+func latencyBucketMax(i int) time.Duration {
+	if i < 4 {
+		return time.Duration(i)
+	}
+	shift := uint(i/4 - 1)
+	return time.Duration(uint64(4+i%4)<<shift + 1<<shift - 1)
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	h.counts[latencyBucket(d)].Add(1)
+	for {
+		max := h.max.Load()
+		if int64(d) <= max || h.max.CompareAndSwap(max, int64(d)) {
+			return
+		}
+	}
+}
+
+// since records the time since start.
+func (h *latencyHistogram) since(start time.Time) {
+	h.record(time.Since(start))
+}
+
+func (h *latencyHistogram) stats() LatencyStats {
+	var counts [latencyBuckets]uint64
+	var s LatencyStats
+	for i := range counts {
+		counts[i] = h.counts[i].Load()
+		s.Samples += counts[i]
+	}
+	s.Max = time.Duration(h.max.Load())
+	percentile := func(p uint64) time.Duration {
+		// the first bucket holding the p% of the samples
+		rank := (s.Samples*p + 99) / 100
+		var n uint64
+		for i, c := range counts {
+			if n += c; n >= rank && n != 0 {
+				if d := latencyBucketMax(i); d < s.Max {
+					return d
+				}
+				return s.Max
+			}
+		}
+		return s.Max
+	}
+	if s.Samples != 0 {
+		s.P50, s.P90, s.P99 = percentile(50), percentile(90), percentile(99)
+	}
+	return s
+}
+
+//-----------------------------------------------------------------------------
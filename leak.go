@@ -0,0 +1,73 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+//-----------------------------------------------------------------------------
+// Leak detection. A long running daemon which forgets to Close Interfaces
+// holds on to device file descriptors, and to the interfaces themselves if
+// they aren't persistent. OpenInterfaces gives the count to watch, and
+// DetectLeaks finds the culprits: the Interfaces garbage collected without
+// having been closed, and where they were opened.
+
+var openCount atomic.Int64
+
+// OpenInterfaces returns the number of Interfaces opened by this process and
+// not yet closed.
+func OpenInterfaces() int {
+	return int(openCount.Load())
+}
+
+var leakLock sync.Mutex
+var leakReport func(name string, openedAt []byte)
+
+// DetectLeaks makes the Interfaces opened from now on record the stack they
+// were opened from, and report be called with it, from the finalizer
+// goroutine, for each one garbage collected while still open. Recording the
+// stack makes opening an Interface slower, so this is meant for debugging.
+// DetectLeaks(nil) turns detection back off for Interfaces opened afterwards.
+func DetectLeaks(report func(name string, openedAt []byte)) {
+	leakLock.Lock()
+	leakReport = report
+	leakLock.Unlock()
+}
+
+// track accounts for the Interface returned by one of the Open functions.
+func track(t *Interface, err error) (*Interface, error) {
+	if err != nil {
+		return nil, err
+	}
+	openCount.Add(1)
+
+	leakLock.Lock()
+	report := leakReport
+	leakLock.Unlock()
+	if report != nil {
+		stack := debug.Stack()
+		runtime.SetFinalizer(t, func(t *Interface) {
+			if t.state.Load() == stateOpen {
+				report(t.name, stack)
+			}
+		})
+	}
+	return t, nil
+}
+
+// untrack accounts for an Interface being closed.
+func untrack() {
+	openCount.Add(-1)
+}
+
+//-----------------------------------------------------------------------------
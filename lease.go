@@ -0,0 +1,111 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"sync"
+)
+
+//-----------------------------------------------------------------------------
+
+// LeasedPacket is a Packet read into a buffer owned by the package. The
+// Packet's Body remains valid, and is not touched by the package, until
+// Release is called. This makes it safe to hand packets to other goroutines
+// (e.g. a pipeline of processing stages) without copying them, as long as
+// the last stage calls Release.
+type LeasedPacket struct {
+	Packet
+	buf packetBuf
+}
+
+// ReadLeasedPacket reads a single packet from the kernel into a buffer from
+// the package's pool, large enough for the largest packet of the Interface
+// (see MaxPacket), or for a whole GSO super-packet WithVnetHdr. The caller
+// must Release the returned LeasedPacket once it's done with it.
+func (t *Interface) ReadLeasedPacket() (*LeasedPacket, error) {
+	buf, b := getBuffer(t.readSize())
+	pkt, err := t.ReadPacket(b)
+	if err != nil {
+		buf.put()
+		return nil, err
+	}
+	// only the buffer is recycled, never the LeasedPacket, so that a stale
+	// second Release can't end somebody else's lease
+	return &LeasedPacket{Packet: pkt, buf: buf}, nil
+}
+
+// Release ends the lease, returning the buffer to the package for reuse. The
+// Packet (and any slice of its Body) must not be used afterwards. Releasing
+// a LeasedPacket twice is a no-op.
+func (l *LeasedPacket) Release() {
+	if l.buf == (packetBuf{}) {
+		return
+	}
+	l.buf.put()
+	l.buf = packetBuf{}
+	l.Packet = Packet{}
+}
+
+// packetBuf is a buffer from the pools: one of 1600 bytes, or a larger one
+// for jumbo frames and GSO super-packets.
+type packetBuf struct {
+	small *[1600]byte
+	large *[]byte
+}
+
+// free buffers larger than 1600 bytes, of any size
+var largeBuffers sync.Pool
+
+// getBuffer returns a buffer of n bytes or more from the pools, and its
+// bytes.
+func getBuffer(n int) (packetBuf, []byte) {
+	if n <= 1600 {
+		b := buffers.Get().(*[1600]byte)
+		return packetBuf{small: b}, b[:]
+	}
+	b, _ := largeBuffers.Get().(*[]byte)
+	if b == nil || len(*b) < n {
+		// too small for this Interface: left for the GC
+		s := make([]byte, n)
+		b = &s
+	}
+	return packetBuf{large: b}, *b
+}
+
+// copyBuffer returns a copy of b in a buffer from the pools.
+func copyBuffer(b []byte) (packetBuf, []byte) {
+	buf, c := getBuffer(len(b))
+	return buf, c[:copy(c, b)]
+}
+
+// put returns the buffer to its pool, if it's one.
+func (b packetBuf) put() {
+	switch {
+	case b.small != nil:
+		buffers.Put(b.small)
+	case b.large != nil:
+		largeBuffers.Put(b.large)
+	}
+}
+
+// readSize returns the size of the buffers the packets of t are read into:
+// the largest packet and its headers, or 64KB for GSO super-packets. By
+// default, that's the 1600 bytes of the small buffers.
+func (t *Interface) readSize() int {
+	n := 4 + t.MaxPacket()
+	if t.vnetHdr {
+		n += VnetHdrLen + 2
+	}
+	if t.offloads&^OffloadCsum != 0 {
+		n = 4 + VnetHdrLen + 2 + 65535
+	}
+	return n
+}
+
+//-----------------------------------------------------------------------------
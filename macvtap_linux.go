@@ -0,0 +1,147 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+
+// openVtap opens the character device of a macvtap or ipvtap link, creating
+// the link first if needed, and says whether it did. typ is the netlink link
+// type. A link it created is deleted again if it can't be opened.
+func openVtap(typ, ifName, parent string) (*Interface, bool, error) {
+	link, err := netlink.LinkByName(ifName)
+	if err == nil {
+		if link.Type() != typ {
+			return nil, false, errors.Errorf("tuntap: %s exists and is a %s, not a %s", ifName, link.Type(), typ)
+		}
+		t, err := openVtapDevice(link)
+		return t, false, err
+	}
+	link, err = createVtap(typ, ifName, parent)
+	if err != nil {
+		return nil, false, err
+	}
+	t, err := openVtapDevice(link)
+	if err != nil {
+		netlink.LinkDel(link)
+		return nil, false, err
+	}
+	return t, true, nil
+}
+
+// deleteVtap deletes the macvtap or ipvtap link openVtap created.
+func deleteVtap(ifName string) error {
+	link, err := netlink.LinkByName(ifName)
+	if err != nil {
+		return err
+	}
+	return netlink.LinkDel(link)
+}
+
+// openVtapDevice opens the character device of the macvtap or ipvtap link.
+func openVtapDevice(link netlink.Link) (*Interface, error) {
+	ifName := link.Attrs().Name
+	dev, err := vtapDevice(ifName, link.Attrs().Index)
+	if err != nil {
+		return nil, err
+	}
+
+	// as with /dev/net/tun the fd must not be nonblocking until after the
+	// ioctl; see createInterface
+	fd, err := unix.Open(dev, os.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "tuntap: Can't open %s", dev)
+	}
+
+	// macvtap devices default to having a virtio-net header in front of
+	// each frame. Turn it off so frames are read and written as-is.
+	var req ifReq
+	req.Flags = unix.IFF_TAP | unix.IFF_NO_PI
+	err = ioctlIfReq(fd, unix.TUNSETIFF, &req)
+	if err != nil {
+		unix.Close(fd)
+		return nil, errors.Wrapf(err, "tuntap: Can't ioctl(TUNSETIFF) on %s", dev)
+	}
+
+	err = unix.SetNonblock(fd, true)
+	if err != nil {
+		unix.Close(fd)
+		return nil, errors.Wrapf(err, "tuntap: Can't set nonblocking mode on fd %q", dev)
+	}
+
+	file := os.NewFile(uintptr(fd), dev)
+	return &Interface{name: ifName, file: file, kind: DevTap, framing: frameNone}, nil
+}
+
+func createVtap(typ, ifName, parent string) (netlink.Link, error) {
+	p, err := netlink.LinkByName(parent)
+	if err != nil {
+		return nil, errors.Wrapf(err, "tuntap: Can't find parent interface %s", parent)
+	}
+	// NewLinkAttrs, because a zero TxQLen would size the macvtap's queue to nothing
+	attrs := netlink.NewLinkAttrs()
+	attrs.Name = ifName
+	attrs.ParentIndex = p.Attrs().Index
+	var link netlink.Link
+	switch typ {
+	case "macvtap":
+		link = &netlink.Macvtap{Macvlan: netlink.Macvlan{LinkAttrs: attrs, Mode: netlink.MACVLAN_MODE_BRIDGE}}
+	case "ipvtap":
+		link = &netlink.IPVtap{IPVlan: netlink.IPVlan{LinkAttrs: attrs, Mode: netlink.IPVLAN_MODE_L2}}
+	}
+	err = netlink.LinkAdd(link)
+	if err != nil {
+		return nil, errors.Wrapf(err, "tuntap: Can't create %s %s on %s", typ, ifName, parent)
+	}
+	// LinkAdd doesn't fill in the index
+	created, err := netlink.LinkByName(ifName)
+	if err != nil {
+		netlink.LinkDel(link)
+		return nil, errors.Wrapf(err, "tuntap: Can't find %s %s once created", typ, ifName)
+	}
+	return created, nil
+}
+
+// vtapDevice returns the path of the character device of the macvtap/ipvtap
+// link with the given index. Normally udev creates /dev/tapN, but in
+// containers and minimal systems it might not exist, in which case we create
+// the node ourselves from the device numbers in sysfs.
+func vtapDevice(ifName string, index int) (string, error) {
+	dev := fmt.Sprintf("/dev/tap%d", index)
+	if _, err := os.Stat(dev); err == nil {
+		return dev, nil
+	}
+
+	sys := fmt.Sprintf("/sys/class/net/%s/tap%d/dev", ifName, index)
+	b, err := ioutil.ReadFile(sys)
+	if err != nil {
+		return "", errors.Wrapf(err, "tuntap: Can't find the character device of %s", ifName)
+	}
+	var major, minor uint32
+	if _, err = fmt.Sscanf(strings.TrimSpace(string(b)), "%d:%d", &major, &minor); err != nil {
+		return "", errors.Wrapf(err, "tuntap: Can't parse %s", sys)
+	}
+	err = unix.Mknod(dev, unix.S_IFCHR|0600, int(unix.Mkdev(major, minor)))
+	if err != nil && err != unix.EEXIST {
+		return "", errors.Wrapf(err, "tuntap: Can't create %s", dev)
+	}
+	return dev, nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,309 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"runtime"
+	"sync"
+)
+
+//-----------------------------------------------------------------------------
+// A Manager runs the Interfaces of a daemon terminating many tunnels. Each
+// Interface gets a reading goroutine, which costs little as the reads wait
+// in the runtime's poller, and the packets are handled by a shared pool of
+// workers, each Interface's by one worker so that they're handled in order.
+// Packets written through the Manager go to the Interface whose prefixes
+// match their destination best, as a routing table would (a PrefixTable),
+// unless the application's Egress policy says otherwise. Like the kernel's,
+// routes may also be blackholes, dropping the packets silently, or
+// prohibited, answering them with an ICMP administratively prohibited,
+// written back through the Manager toward the source; the Egress policy has
+// no say on these.
+//
+// Packets are read into the package's buffers, as ReadLeasedPacket does:
+// larger ones are truncated. The Interfaces must not be opened
+// WithNonblocking.
+
+var ErrNoRoute = errors.New("no interface routes the destination")
+var ErrProhibited = errors.New("destination administratively prohibited")
+
+// ManagerConfig configures a Manager.
+type ManagerConfig struct {
+	// the number of workers handling the packets, GOMAXPROCS by default
+	Workers int
+	// how many packets may wait for each worker before the reading stops,
+	// 256 by default
+	Queue int
+	// called by a worker with each packet read from t. pkt must not be kept
+	// after it returns.
+	Handle func(t *Interface, pkt *Packet)
+	// called, if set, with the errors reading t. The Manager stops reading
+	// t after any error but ErrShortRead, but keeps routing to it until it's
+	// removed. An Interface closed by the application isn't reported.
+	OnError func(t *Interface, err error)
+	// called, if set, by WritePacket with each packet and the Interface its
+	// destination routes to (nil if none), for the Interface to write it to
+	// instead (nil to drop it, with ErrNoRoute): a split tunneling policy
+	// going by DSCP or port, say. It's called concurrently by the goroutines
+	// writing.
+	Egress func(pkt *Packet, routed *Interface) *Interface
+}
+
+// Manager reads and writes a set of Interfaces. It is safe for concurrent
+// use.
+type Manager struct {
+	cfg     ManagerConfig
+	workers []chan managedPacket
+	wg      sync.WaitGroup // the workers
+	// the readers, including those of Interfaces being removed, which
+	// still send to the workers
+	readers sync.WaitGroup
+
+	lock   sync.Mutex
+	ifs    map[*Interface]*managed
+	next   int // the worker of the next Interface added
+	closed bool
+	routes *PrefixTable // of *Interface, or a routeAction
+}
+
+// the routes not to an Interface
+type routeAction int
+
+const (
+	routeBlackhole routeAction = iota
+	routeProhibit
+)
+
+type managed struct {
+	worker int
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type managedPacket struct {
+	t   *Interface
+	pkt *LeasedPacket
+}
+
+// NewManager returns a Manager with no Interfaces, and starts its workers.
+func NewManager(cfg ManagerConfig) *Manager {
+	if cfg.Workers <= 0 {
+		cfg.Workers = runtime.GOMAXPROCS(0)
+	}
+	if cfg.Queue <= 0 {
+		cfg.Queue = 256
+	}
+	m := &Manager{
+		cfg:     cfg,
+		workers: make([]chan managedPacket, cfg.Workers),
+		ifs:     make(map[*Interface]*managed),
+		routes:  NewPrefixTable(),
+	}
+	for i := range m.workers {
+		c := make(chan managedPacket, cfg.Queue)
+		m.workers[i] = c
+		m.wg.Add(1)
+		goLabeled(nil, "manager worker", func() { m.work(c) })
+	}
+	return m
+}
+
+// work handles the packets of c until it's closed.
+func (m *Manager) work(c chan managedPacket) {
+	defer m.wg.Done()
+	for mp := range c {
+		if m.cfg.Handle != nil {
+			m.cfg.Handle(mp.t, &mp.pkt.Packet)
+		}
+		mp.pkt.Release()
+	}
+}
+
+// Add starts reading t, and routes the destinations of prefixes to it.
+func (m *Manager) Add(t *Interface, prefixes ...netip.Prefix) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	if m.ifs[t] != nil {
+		return errors.New("tuntap: interface already managed")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	mi := &managed{worker: m.next % len(m.workers), cancel: cancel, done: make(chan struct{})}
+	m.next++
+	m.ifs[t] = mi
+	m.readers.Add(1)
+	goLabeled(t, "manager reader", func() { m.read(ctx, t, mi) })
+	for _, p := range prefixes {
+		m.routes.Insert(p, t)
+	}
+	return nil
+}
+
+// read reads t until ctx is done or the reading fails.
+func (m *Manager) read(ctx context.Context, t *Interface, mi *managed) {
+	defer m.readers.Done()
+	defer close(mi.done)
+	for {
+		buf, b := getBuffer(t.readSize())
+		pkt, err := t.ReadPacketContext(ctx, b)
+		if err != nil {
+			buf.put()
+			if ctx.Err() != nil || err == ErrClosed {
+				return
+			}
+			if m.cfg.OnError != nil {
+				m.cfg.OnError(t, err)
+			}
+			if err == ErrShortRead {
+				continue
+			}
+			return
+		}
+		select {
+		case m.workers[mi.worker] <- managedPacket{t: t, pkt: &LeasedPacket{Packet: pkt, buf: buf}}:
+		case <-ctx.Done():
+			buf.put()
+			return
+		}
+	}
+}
+
+// Remove stops reading t and routing to it, and returns once its reading
+// goroutine is done. t is left open for the caller.
+func (m *Manager) Remove(t *Interface) {
+	m.lock.Lock()
+	mi := m.ifs[t]
+	if mi == nil {
+		m.lock.Unlock()
+		return
+	}
+	delete(m.ifs, t)
+	m.routes.DeleteFunc(func(_ netip.Prefix, v interface{}) bool { return v == t })
+	m.lock.Unlock()
+	mi.cancel()
+	<-mi.done
+}
+
+// Route routes the destinations of prefix to t, which must have been Added,
+// replacing the route of prefix if there was one.
+func (m *Manager) Route(prefix netip.Prefix, t *Interface) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.ifs[t] == nil {
+		return errors.New("tuntap: interface not managed")
+	}
+	m.routes.Insert(prefix, t)
+	return nil
+}
+
+// Blackhole makes prefix a blackhole route: the packets written to its
+// destinations are dropped, and WritePacket returns nil.
+func (m *Manager) Blackhole(prefix netip.Prefix) {
+	m.routes.Insert(prefix, routeBlackhole)
+}
+
+// Prohibit makes prefix a prohibited route: the packets written to its
+// destinations are answered with an ICMP destination unreachable,
+// administratively prohibited, and WritePacket returns ErrProhibited.
+func (m *Manager) Prohibit(prefix netip.Prefix) {
+	m.routes.Insert(prefix, routeProhibit)
+}
+
+// Unroute removes the route of prefix.
+func (m *Manager) Unroute(prefix netip.Prefix) {
+	m.routes.Delete(prefix)
+}
+
+// Lookup returns the Interface routing dst, nil if there's none, or if the
+// route is a blackhole or prohibited.
+func (m *Manager) Lookup(dst netip.Addr) *Interface {
+	_, v, _ := m.routes.Lookup(dst)
+	t, _ := v.(*Interface)
+	return t
+}
+
+// WritePacket writes pkt to the Interface routing its destination, or the
+// one the Egress policy picks, or returns ErrNoRoute.
+func (m *Manager) WritePacket(pkt Packet) error {
+	_, v, _ := m.routes.Lookup(pkt.DstAddr())
+	switch v {
+	case routeBlackhole:
+		return nil
+	case routeProhibit:
+		var reply Packet
+		var ok bool
+		switch pkt.Protocol {
+		case ETH_P_IP:
+			reply, ok = prohibited(&pkt)
+		case ETH_P_IPV6:
+			reply, ok = prohibited6(&pkt)
+		}
+		if ok {
+			// best effort; an ICMP error is never answered, so this ends
+			m.WritePacket(reply)
+		}
+		return ErrProhibited
+	}
+	t, _ := v.(*Interface)
+	if m.cfg.Egress != nil {
+		t = m.cfg.Egress(&pkt, t)
+	}
+	if t == nil {
+		return ErrNoRoute
+	}
+	return t.WritePacket(pkt)
+}
+
+// Interfaces returns the Interfaces of the Manager.
+func (m *Manager) Interfaces() []*Interface {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	ifs := make([]*Interface, 0, len(m.ifs))
+	for t := range m.ifs {
+		ifs = append(ifs, t)
+	}
+	return ifs
+}
+
+// Close closes the Interfaces of the Manager, and returns once the workers
+// have handled the packets already read.
+func (m *Manager) Close() error {
+	m.lock.Lock()
+	if m.closed {
+		m.lock.Unlock()
+		return nil
+	}
+	m.closed = true
+	ifs := m.ifs
+	m.ifs = nil
+	m.routes.DeleteFunc(func(netip.Prefix, interface{}) bool { return true })
+	m.lock.Unlock()
+
+	var err error
+	for t, mi := range ifs {
+		mi.cancel()
+		<-mi.done
+		if cerr := t.Close(); err == nil {
+			err = cerr
+		}
+	}
+	m.readers.Wait()
+	for _, c := range m.workers {
+		close(c)
+	}
+	m.wg.Wait()
+	return err
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,55 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+//-----------------------------------------------------------------------------
+// The largest packet WritePacket takes. By default it follows the MTU set
+// through the Interface (WithMTU, SetMTU, SetTunnelMTU...), plus the
+// Ethernet header and two VLAN tags on DevTap, so that jumbo frame overlays
+// just work, and is never below the 1596 bytes the package always took.
+// WithMaxPacket or SetMaxPacket set it outright, e.g. when the MTU is set
+// by someone else. Packets up to the size of the pooled buffers are copied
+// behind their header into one of those; larger ones into a buffer of their
+// own.
+
+// the limit when nothing else is known: a 1600-byte buffer with the header
+const defaultMaxPacket = 1600 - 4
+
+// WithMaxPacket sets the largest packet WritePacket takes (see SetMaxPacket).
+func WithMaxPacket(n int) Option {
+	return func(c *config) { c.maxPacket = n }
+}
+
+// SetMaxPacket sets the largest packet WritePacket takes, above which it
+// returns ErrJumboPacket, in bytes of Packet.Body. 0 goes back to following
+// the MTU.
+func (t *Interface) SetMaxPacket(n int) {
+	t.maxPacket.Store(int64(n))
+}
+
+// MaxPacket returns the largest packet WritePacket takes.
+func (t *Interface) MaxPacket() int {
+	if n := t.maxPacket.Load(); n != 0 {
+		return int(n)
+	}
+	if n := int(t.mtuPacket.Load()); n > defaultMaxPacket {
+		return n
+	}
+	return defaultMaxPacket
+}
+
+// mtuChanged follows the MTU of the interface, set to mtu.
+func (t *Interface) mtuChanged(mtu int) {
+	if t.kind == DevTap {
+		mtu += 14 + 2*4
+	}
+	t.mtuPacket.Store(int64(mtu))
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,128 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+//-----------------------------------------------------------------------------
+// Misuse detection. Some mistakes in using an Interface don't fail, they just
+// corrupt packets now and then: reading into a buffer which a WritePacket on
+// another goroutine is still writing from, two goroutines reading (each then
+// gets an arbitrary half of the traffic), using the Interface after Close.
+// With DetectMisuse the Interface checks for these on every call and reports
+// them. The checks take a lock per call, so this is meant for debugging and
+// tests, not production.
+//
+// Only calls in progress at the same time are compared: a Packet kept, or
+// handed to another goroutine, after its buffer went to the next read isn't
+// caught, as the package can't tell when the application is done with it.
+// LeasedPackets are the way not to have to track that.
+
+// Misuse describes a mistake in the use of an Interface.
+type Misuse struct {
+	Op      string // the method which detected it, e.g. "WritePacket"
+	Problem string
+	Caller  string // file:line of the call to Op
+}
+
+func (m *Misuse) Error() string {
+	return fmt.Sprintf("tuntap: misuse: %s at %s: %s", m.Op, m.Caller, m.Problem)
+}
+
+// DetectMisuse turns on misuse detection, calling report with each problem
+// found. If report is nil, problems are logged with the standard logger. It
+// must be called before the Interface is used.
+func (t *Interface) DetectMisuse(report func(*Misuse)) {
+	if report == nil {
+		report = func(m *Misuse) { log.Print(m) }
+	}
+	t.debug = &misuseDetector{report: report}
+}
+
+// an operation in progress, and the buffer it's using
+type inflight struct {
+	op         string
+	start, end uintptr
+}
+
+type misuseDetector struct {
+	report func(*Misuse)
+
+	lock   sync.Mutex
+	ops    []*inflight
+	closed bool
+}
+
+func span(b []byte) (uintptr, uintptr) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	start := uintptr(unsafe.Pointer(&b[0]))
+	return start, start + uintptr(len(b))
+}
+
+// begin checks that op can start using b, and records it as in progress. The
+// returned function must be called when op is done.
+func (d *misuseDetector) begin(op string, b []byte) func() {
+	me := &inflight{op: op}
+	me.start, me.end = span(b)
+
+	var problems []string
+	d.lock.Lock()
+	if d.closed {
+		problems = append(problems, "called after Close")
+	}
+	for _, o := range d.ops {
+		if o.op == op {
+			problems = append(problems, "concurrent with another "+op+"; calls must be serialized")
+		}
+		if me.start < o.end && o.start < me.end {
+			problems = append(problems, "buffer overlaps the one of a "+o.op+" still in progress")
+		}
+	}
+	d.ops = append(d.ops, me)
+	d.lock.Unlock()
+
+	if len(problems) != 0 {
+		caller := "unknown"
+		// skip begin and the Interface method calling it
+		if _, file, line, ok := runtime.Caller(2); ok {
+			caller = fmt.Sprintf("%s:%d", file, line)
+		}
+		for _, p := range problems {
+			d.report(&Misuse{Op: op, Problem: p, Caller: caller})
+		}
+	}
+
+	return func() {
+		d.lock.Lock()
+		for i, o := range d.ops {
+			if o == me {
+				d.ops = append(d.ops[:i], d.ops[i+1:]...)
+				break
+			}
+		}
+		d.lock.Unlock()
+	}
+}
+
+// close records that the Interface was closed.
+func (d *misuseDetector) close() {
+	d.lock.Lock()
+	d.closed = true
+	d.lock.Unlock()
+}
+
+//-----------------------------------------------------------------------------
@@ -11,6 +11,18 @@ case $GOOS in
         go tool cgo -godefs=true types_freebsd.go >ztypes_freebsd.go
         rm -rf _obj
         ;;
+    darwin)
+        go tool cgo -godefs=true types_darwin.go >ztypes_darwin.go
+        rm -rf _obj
+        ;;
+    openbsd)
+        go tool cgo -godefs=true types_openbsd.go >ztypes_openbsd.go
+        rm -rf _obj
+        ;;
+    netbsd)
+        go tool cgo -godefs=true types_netbsd.go >ztypes_netbsd.go
+        rm -rf _obj
+        ;;
     *)
         echo "Don't know how to compile types for $GOOS"
         exit 1
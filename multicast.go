@@ -0,0 +1,178 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+//-----------------------------------------------------------------------------
+// Multicast membership reports, for the multicast proxies (RFC 4605) and
+// routers built on Interfaces: the hosts behind an Interface say which
+// groups they want with IGMP for IPv4 and MLD for IPv6, and since IGMPv3
+// (RFC 3376) and MLDv2 (RFC 3810), which sources of each group, so that a
+// proxy forwarding a group to them can forward only the sources they asked
+// for (source-specific multicast), or all but those they excluded.
+//
+// ParseMembershipReport returns the group records of a report, of any
+// version. The reports of the older versions (IGMPv1 and v2, MLDv1), which
+// have no source lists, come as the records RFC 3376 (7.3.2) and RFC 3810
+// (8.3.2) translate them to: a report is a GroupModeIsExclude with no
+// sources (all of them), and a leave (MLDv1 done) a GroupChangeToInclude
+// with no sources (none of them). The version tells the proxy to run the
+// group in the compatibility mode of the older hosts.
+
+// GroupRecordType is the type of an IGMPv3 or MLDv2 group record: the
+// current state of the group on the interface, or a change of it.
+type GroupRecordType uint8
+
+const (
+	// the sources wanted are those of the record (a current state)
+	GroupModeIsInclude GroupRecordType = 1
+	// the sources wanted are all but those of the record (a current state)
+	GroupModeIsExclude GroupRecordType = 2
+	// the sources wanted are now those of the record
+	GroupChangeToInclude GroupRecordType = 3
+	// the sources wanted are now all but those of the record
+	GroupChangeToExclude GroupRecordType = 4
+	// the sources of the record are wanted too
+	GroupAllowNewSources GroupRecordType = 5
+	// the sources of the record are no longer wanted
+	GroupBlockOldSources GroupRecordType = 6
+)
+
+// GroupRecord is what a report says of a multicast group.
+type GroupRecord struct {
+	Type    GroupRecordType
+	Group   netip.Addr
+	Sources []netip.Addr
+}
+
+// MembershipReport is an IGMP or MLD membership report.
+type MembershipReport struct {
+	// the version of IGMP (1 to 3) or MLD (1 or 2) of the report
+	Version int
+	// whether it's MLD, for IPv6
+	MLD     bool
+	Records []GroupRecord
+}
+
+// ParseMembershipReport returns the IGMP or MLD membership report (or leave)
+// pkt, and false if pkt isn't one, or is malformed or corrupt. The records
+// of unknown types, and for addresses which aren't multicast, are skipped,
+// as RFC 3376 (4.2.12) requires.
+func ParseMembershipReport(pkt *Packet) (MembershipReport, bool) {
+	ip := pkt.ip()
+	proto, at, frag := pkt.IPProto()
+	if at == 0 || frag {
+		return MembershipReport{}, false
+	}
+	at -= pkt.L3Offset
+	switch {
+	case pkt.Protocol == ETH_P_IP && proto == 2:
+		end := int(binary.BigEndian.Uint16(ip[2:]))
+		if end > len(ip) || at > end {
+			return MembershipReport{}, false
+		}
+		return parseIGMPReport(ip[at:end])
+	case pkt.Protocol == ETH_P_IPV6 && proto == 58:
+		end := 40 + int(binary.BigEndian.Uint16(ip[4:]))
+		if end > len(ip) || at > end {
+			return MembershipReport{}, false
+		}
+		m := ip[at:end]
+		if len(m) < 4 || icmp6Checksum(ip[8:24], ip[24:40], m) != 0 {
+			return MembershipReport{}, false
+		}
+		return parseMLDReport(m)
+	}
+	return MembershipReport{}, false
+}
+
+// parseIGMPReport parses the IGMP message m.
+func parseIGMPReport(m []byte) (MembershipReport, bool) {
+	if len(m) < 8 || checksum(m) != 0 {
+		return MembershipReport{}, false
+	}
+	switch m[0] {
+	case 0x12, 0x16, 0x17: // v1 and v2 reports, v2 leave
+		r := MembershipReport{Version: 2}
+		if m[0] == 0x12 {
+			r.Version = 1
+		}
+		return r, r.legacy(m[0] == 0x17, m[4:8])
+	case 0x22: // v3 report
+		return parseReportRecords(MembershipReport{Version: 3}, m[8:], int(binary.BigEndian.Uint16(m[6:])), 4)
+	}
+	return MembershipReport{}, false
+}
+
+// parseMLDReport parses the MLD message m.
+func parseMLDReport(m []byte) (MembershipReport, bool) {
+	switch m[0] {
+	case 131, 132: // v1 report and done
+		if len(m) < 24 {
+			return MembershipReport{}, false
+		}
+		r := MembershipReport{Version: 1, MLD: true}
+		return r, r.legacy(m[0] == 132, m[8:24])
+	case 143: // v2 report
+		if len(m) < 8 {
+			return MembershipReport{}, false
+		}
+		return parseReportRecords(MembershipReport{Version: 2, MLD: true}, m[8:], int(binary.BigEndian.Uint16(m[6:])), 16)
+	}
+	return MembershipReport{}, false
+}
+
+// legacy makes r the record of a report, or leave, of group by an older
+// version, and returns false if group isn't multicast.
+func (r *MembershipReport) legacy(leave bool, group []byte) bool {
+	g, _ := netip.AddrFromSlice(group)
+	if !g.IsMulticast() {
+		return false
+	}
+	typ := GroupModeIsExclude
+	if leave {
+		typ = GroupChangeToInclude
+	}
+	r.Records = []GroupRecord{{Type: typ, Group: g}}
+	return true
+}
+
+// parseReportRecords adds the n group records of b, of addresses of size
+// bytes, to r.
+func parseReportRecords(r MembershipReport, b []byte, n, size int) (MembershipReport, bool) {
+	for ; n > 0; n-- {
+		// the type, the length of the auxiliary data in 32-bit words, the
+		// number of sources, the group, the sources and the auxiliary data
+		if len(b) < 4+size {
+			return MembershipReport{}, false
+		}
+		typ, aux, sources := GroupRecordType(b[0]), int(b[1])*4, int(binary.BigEndian.Uint16(b[2:]))
+		end := 4 + size + sources*size + aux
+		if len(b) < end {
+			return MembershipReport{}, false
+		}
+		g, _ := netip.AddrFromSlice(b[4 : 4+size])
+		if typ >= GroupModeIsInclude && typ <= GroupBlockOldSources && g.IsMulticast() {
+			rec := GroupRecord{Type: typ, Group: g, Sources: make([]netip.Addr, 0, sources)}
+			for at := 4 + size; at < 4+size+sources*size; at += size {
+				s, _ := netip.AddrFromSlice(b[at : at+size])
+				rec.Sources = append(rec.Sources, s)
+			}
+			r.Records = append(r.Records, rec)
+		}
+		b = b[end:]
+	}
+	return r, true
+}
+
+//-----------------------------------------------------------------------------
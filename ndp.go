@@ -0,0 +1,477 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// Neighbor discovery (RFC 4861) for DevTap Interfaces, the IPv6 counterpart
+// of the ARPResponder: an NDPResponder answers the Neighbor Solicitations
+// of the host behind a tap interface for the addresses it's given, with
+// their MAC addresses, and, given a router configuration, the Router
+// Solicitations with a Router Advertisement, whose prefixes the host
+// autoconfigures its addresses in (SLAAC, RFC 4862) and which makes the
+// router its default one.
+//
+// Answer builds the reply to a solicitation read, for the application to
+// write back, and RouterAdvertisement the unsolicited advertisement to send
+// now and then; an Interface opened WithNDPResponder does both, answering as
+// it reads (and only passing on the neighbor discovery it didn't answer),
+// and advertising every RouterConfig.Interval. The messages come with the
+// hop limit of 255 which shows they're from the link, and the replies go
+// back through the VLAN the solicitation came from.
+
+var ErrNDPAddress = errors.New("neighbor discovery answers need an IPv6 address and an Ethernet MAC address")
+
+// RouterConfig configures the router an NDPResponder advertises. Zero fields
+// take the defaults.
+type RouterConfig struct {
+	// the MAC address of the router; required
+	MAC net.HardwareAddr
+	// the link-local address the advertisements come from, which the hosts
+	// route through; by default, the EUI-64 one of MAC
+	Source netip.Addr
+	// the prefixes advertised
+	Prefixes []RouterPrefix
+	// how long the hosts may use the router as their default router, 30m
+	// by default; negative to not be one
+	Lifetime time.Duration
+	// if set, the MTU of the link
+	MTU int
+	// the managed and other configuration flags, sending the hosts to
+	// DHCPv6 for addresses, or for the rest
+	Managed, Other bool
+	// how often an Interface opened WithNDPResponder advertises, 10m by
+	// default (RFC 4861's MaxRtrAdvInterval)
+	Interval time.Duration
+}
+
+// RouterPrefix is a prefix a router advertises.
+type RouterPrefix struct {
+	Prefix netip.Prefix
+	// how long the prefix, and the addresses autoconfigured in it, are
+	// valid and preferred: 30 and 7 days by default, as RFC 4861 has them;
+	// negative for 0, which withdraws the prefix, or deprecates it
+	ValidLifetime, PreferredLifetime time.Duration
+	// not to autoconfigure addresses in the prefix, or not to take it as on
+	// the link
+	NoAutoconf, OffLink bool
+}
+
+// NDPResponder answers neighbor discovery for a set of IPv6 addresses, and
+// as a router. It is safe for concurrent use.
+type NDPResponder struct {
+	lock     sync.RWMutex
+	addrs    map[netip.Addr]net.HardwareAddr
+	router   *RouterConfig
+	answered atomic.Uint64
+	// signalled when the router changes, for an advertisement to go now
+	changed chan struct{}
+}
+
+// NewNDPResponder returns a responder answering for no addresses, and not a
+// router.
+func NewNDPResponder() *NDPResponder {
+	return &NDPResponder{addrs: make(map[netip.Addr]net.HardwareAddr), changed: make(chan struct{}, 1)}
+}
+
+// WithNDPResponder has the Interface, which must be a DevTap, answer the
+// neighbor and router solicitations it reads with r, rather than return
+// them, and send r's router advertisements.
+func WithNDPResponder(r *NDPResponder) Option {
+	return func(c *config) { c.ndp = r }
+}
+
+// Set answers the solicitations for ip with mac. It returns ErrNDPAddress if
+// ip isn't IPv6 unicast.
+func (r *NDPResponder) Set(ip netip.Addr, mac net.HardwareAddr) error {
+	if !ip.Is6() || ip.Is4In6() || ip.IsMulticast() || ip.IsUnspecified() || len(mac) != 6 {
+		return ErrNDPAddress
+	}
+	r.lock.Lock()
+	r.addrs[ip.WithZone("")] = append(net.HardwareAddr(nil), mac...)
+	r.lock.Unlock()
+	return nil
+}
+
+// Remove stops answering for ip.
+func (r *NDPResponder) Remove(ip netip.Addr) {
+	r.lock.Lock()
+	delete(r.addrs, ip.WithZone(""))
+	r.lock.Unlock()
+}
+
+// SetRouter makes r advertise the router of cfg, and answer the
+// solicitations for its Source address; nil makes it stop being one. It
+// returns ErrNDPAddress if Source isn't link-local, or MAC isn't Ethernet.
+func (r *NDPResponder) SetRouter(cfg *RouterConfig) error {
+	if cfg != nil {
+		c := *cfg
+		if len(c.MAC) != 6 {
+			return ErrNDPAddress
+		}
+		c.MAC = append(net.HardwareAddr(nil), c.MAC...)
+		if !c.Source.IsValid() {
+			// the modified EUI-64 interface identifier (RFC 4291, appendix A)
+			c.Source = netip.AddrFrom16([16]byte{0: 0xfe, 1: 0x80,
+				8: c.MAC[0] ^ 2, 9: c.MAC[1], 10: c.MAC[2], 11: 0xff, 12: 0xfe,
+				13: c.MAC[3], 14: c.MAC[4], 15: c.MAC[5]})
+		}
+		if !c.Source.Is6() || !c.Source.IsLinkLocalUnicast() {
+			return ErrNDPAddress
+		}
+		c.Source = c.Source.WithZone("")
+		c.Prefixes = append([]RouterPrefix(nil), c.Prefixes...)
+		if c.Lifetime == 0 {
+			c.Lifetime = 30 * time.Minute
+		}
+		if c.Interval <= 0 {
+			c.Interval = 10 * time.Minute
+		}
+		cfg = &c
+	}
+	r.lock.Lock()
+	r.router = cfg
+	r.lock.Unlock()
+	r.advertiseNow()
+	return nil
+}
+
+// SetPrefix makes the router advertise p, in place of the prefix it
+// advertised with the same Prefix, if any. It returns false if r isn't a
+// router. An Interface opened WithNDPResponder advertises the change right
+// away.
+func (r *NDPResponder) SetPrefix(p RouterPrefix) bool {
+	r.lock.Lock()
+	rtr := r.router
+	if rtr != nil {
+		// the advertisements being built keep the old one
+		c := *rtr
+		c.Prefixes = make([]RouterPrefix, 0, len(rtr.Prefixes)+1)
+		for _, o := range rtr.Prefixes {
+			if o.Prefix != p.Prefix {
+				c.Prefixes = append(c.Prefixes, o)
+			}
+		}
+		c.Prefixes = append(c.Prefixes, p)
+		r.router = &c
+	}
+	r.lock.Unlock()
+	if rtr == nil {
+		return false
+	}
+	r.advertiseNow()
+	return true
+}
+
+// RemovePrefix stops advertising prefix, without withdrawing it (see
+// RouterPrefix).
+func (r *NDPResponder) RemovePrefix(prefix netip.Prefix) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.router == nil {
+		return
+	}
+	c := *r.router
+	c.Prefixes = nil
+	for _, o := range r.router.Prefixes {
+		if o.Prefix != prefix {
+			c.Prefixes = append(c.Prefixes, o)
+		}
+	}
+	r.router = &c
+}
+
+// advertiseNow has the Interface advertising r's router do it now.
+func (r *NDPResponder) advertiseNow() {
+	select {
+	case r.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Answered returns the number of solicitations answered.
+func (r *NDPResponder) Answered() uint64 {
+	return r.answered.Load()
+}
+
+// lookup returns the MAC address to answer for ip with, nil if none, and
+// whether it's the router's.
+func (r *NDPResponder) lookup(ip netip.Addr) (net.HardwareAddr, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if r.router != nil && r.router.Source == ip {
+		return r.router.MAC, true
+	}
+	return r.addrs[ip], false
+}
+
+// Answer returns the reply to pkt, and true, if pkt is a Neighbor
+// Solicitation for one of the addresses r answers for, or a Router
+// Solicitation and r is a router.
+func (r *NDPResponder) Answer(pkt *Packet) (Packet, bool) {
+	ip := pkt.ip()
+	proto, at, frag := pkt.IPProto()
+	if pkt.Protocol != ETH_P_IPV6 || pkt.L3Offset < 14 || proto != 58 || frag || at == 0 {
+		return Packet{}, false
+	}
+	at -= pkt.L3Offset
+	end := 40 + int(binary.BigEndian.Uint16(ip[4:]))
+	if end > len(ip) || at+8 > end || ip[7] != 255 {
+		// neighbor discovery doesn't cross routers (RFC 4861, 6.1 and 7.1)
+		return Packet{}, false
+	}
+	src, dst, m := ip[8:24], ip[24:40], ip[at:end]
+	if m[1] != 0 || icmp6Checksum(src, dst, m) != 0 {
+		return Packet{}, false
+	}
+	from, _ := netip.AddrFromSlice(src)
+	switch m[0] {
+	case 135:
+		if len(m) < 24 {
+			return Packet{}, false
+		}
+		target, _ := netip.AddrFromSlice(m[8:24])
+		return r.neighborAdvert(pkt, from, target, m[24:])
+	case 133:
+		if len(m) < 8 {
+			return Packet{}, false
+		}
+		return r.solicitedRouterAdvert(pkt, from, m[8:])
+	}
+	return Packet{}, false
+}
+
+// ndOption returns the source link-layer address option of the options b,
+// nil if there's none, and false if b is malformed.
+func ndOption(b []byte) (net.HardwareAddr, bool) {
+	var mac net.HardwareAddr
+	for len(b) != 0 {
+		if len(b) < 8 || b[1] == 0 || len(b) < int(b[1])*8 {
+			return nil, false
+		}
+		if b[0] == 1 && b[1] == 1 {
+			mac = net.HardwareAddr(b[2:8])
+		}
+		b = b[int(b[1])*8:]
+	}
+	return mac, true
+}
+
+// neighborAdvert answers the Neighbor Solicitation pkt, from from, for
+// target, with options opts.
+func (r *NDPResponder) neighborAdvert(pkt *Packet, from, target netip.Addr, opts []byte) (Packet, bool) {
+	if target.IsMulticast() {
+		return Packet{}, false
+	}
+	mac, router := r.lookup(target)
+	sll, ok := ndOption(opts)
+	if mac == nil || !ok || from.IsUnspecified() && sll != nil {
+		return Packet{}, false
+	}
+	m := make([]byte, 32)
+	m[0] = 136
+	m[4] = 0x20 // override
+	if router {
+		m[4] |= 0x80
+	}
+	to, toMAC := from, pkt.SrcMAC()
+	if sll != nil {
+		toMAC = sll
+	}
+	if from.IsUnspecified() {
+		// duplicate address detection: the address is taken, and all nodes
+		// are told, unsolicited (RFC 4861, 7.2.4)
+		to, toMAC = netip.IPv6LinkLocalAllNodes(), nil
+	} else {
+		m[4] |= 0x40 // solicited
+	}
+	t16 := target.As16()
+	copy(m[8:24], t16[:])
+	m[24], m[25] = 2, 1 // target link-layer address, 8 bytes
+	copy(m[26:32], mac)
+	r.answered.Add(1)
+	return ndMessage(pkt, mac, toMAC, target, to, m), true
+}
+
+// solicitedRouterAdvert answers the Router Solicitation pkt, from from, with
+// options opts.
+func (r *NDPResponder) solicitedRouterAdvert(pkt *Packet, from netip.Addr, opts []byte) (Packet, bool) {
+	sll, ok := ndOption(opts)
+	if !ok || from.IsUnspecified() && sll != nil {
+		return Packet{}, false
+	}
+	rtr, m := r.routerAdvert()
+	if rtr == nil {
+		return Packet{}, false
+	}
+	r.answered.Add(1)
+	// to all nodes, as is usual (RFC 4861, 6.2.6)
+	return ndMessage(pkt, rtr.MAC, nil, rtr.Source, netip.IPv6LinkLocalAllNodes(), m), true
+}
+
+// RouterAdvertisement returns the unsolicited Router Advertisement of the
+// router, to all nodes, and false if r isn't a router.
+func (r *NDPResponder) RouterAdvertisement() (Packet, bool) {
+	rtr, m := r.routerAdvert()
+	if rtr == nil {
+		return Packet{}, false
+	}
+	return ndMessage(nil, rtr.MAC, nil, rtr.Source, netip.IPv6LinkLocalAllNodes(), m), true
+}
+
+// routerAdvert returns the router, nil if none, and its Router
+// Advertisement message.
+func (r *NDPResponder) routerAdvert() (*RouterConfig, []byte) {
+	r.lock.RLock()
+	rtr := r.router
+	r.lock.RUnlock()
+	if rtr == nil {
+		return nil, nil
+	}
+	m := make([]byte, 16, 16+8+8+32*len(rtr.Prefixes))
+	m[0] = 134
+	m[4] = 64 // the hop limit the hosts send with
+	if rtr.Managed {
+		m[5] |= 0x80
+	}
+	if rtr.Other {
+		m[5] |= 0x40
+	}
+	if rtr.Lifetime > 0 {
+		lifetime := rtr.Lifetime / time.Second
+		if lifetime > 9000 {
+			lifetime = 9000
+		}
+		binary.BigEndian.PutUint16(m[6:], uint16(lifetime))
+	}
+	// the reachable time and retransmission timer are the hosts'
+	m = append(m, 1, 1)
+	m = append(m, rtr.MAC...)
+	if rtr.MTU != 0 {
+		m = append(m, 5, 1, 0, 0)
+		m = binary.BigEndian.AppendUint32(m, uint32(rtr.MTU))
+	}
+	for _, p := range rtr.Prefixes {
+		prefix, _, ok := canonical(p.Prefix)
+		if !ok || !prefix.Addr().Is6() {
+			continue
+		}
+		valid, preferred := p.ValidLifetime, p.PreferredLifetime
+		switch {
+		case valid == 0:
+			valid = 30 * 24 * time.Hour
+		case valid < 0:
+			valid = 0
+		}
+		switch {
+		case preferred == 0:
+			preferred = 7 * 24 * time.Hour
+		case preferred < 0:
+			preferred = 0
+		}
+		if preferred > valid {
+			preferred = valid
+		}
+		var flags byte
+		if !p.OffLink {
+			flags |= 0x80
+		}
+		if !p.NoAutoconf {
+			flags |= 0x40
+		}
+		m = append(m, 3, 4, byte(prefix.Bits()), flags)
+		m = binary.BigEndian.AppendUint32(m, uint32(valid/time.Second))
+		m = binary.BigEndian.AppendUint32(m, uint32(preferred/time.Second))
+		m = append(m, 0, 0, 0, 0)
+		a := prefix.Addr().As16()
+		m = append(m, a[:]...)
+	}
+	return rtr, m
+}
+
+// ndMessage returns the neighbor discovery message m from src, with MAC
+// address mac, to dst, at toMAC or the MAC address of the multicast dst if
+// nil, as a reply to pkt if not nil.
+func ndMessage(pkt *Packet, mac, toMAC net.HardwareAddr, src, dst netip.Addr, m []byte) Packet {
+	var b []byte
+	if pkt != nil {
+		b = icmpReply(pkt, 40+len(m))
+	} else {
+		b = make([]byte, 14, 14+40+len(m))
+		binary.BigEndian.PutUint16(b[12:14], ETH_P_IPV6)
+	}
+	s, d := src.As16(), dst.As16()
+	if toMAC == nil {
+		// the IPv6 multicast MAC address of dst (RFC 2464, 7)
+		toMAC = net.HardwareAddr{0x33, 0x33, d[12], d[13], d[14], d[15]}
+	}
+	copy(b[0:6], toMAC)
+	copy(b[6:12], mac)
+	l3 := len(b)
+	b = append(b, 0x60, 0, 0, 0, 0, 0, 58, 255)
+	binary.BigEndian.PutUint16(b[l3+4:], uint16(len(m)))
+	b = append(b, s[:]...)
+	b = append(b, d[:]...)
+	at := len(b)
+	b = append(b, m...)
+	binary.BigEndian.PutUint16(b[at+2:], icmp6Checksum(s[:], d[:], b[at:]))
+	return Packet{Body: b, Protocol: ETH_P_IPV6, L3Offset: l3, Dir: DirWrite}
+}
+
+// answerNDP returns whether the packet read, pkt, is a solicitation the
+// Interface answered.
+func (t *Interface) answerNDP(pkt *Packet) bool {
+	reply, ok := t.ndp.Answer(pkt)
+	if ok {
+		// best effort: the host asks again
+		t.writePacket(reply)
+	}
+	return ok
+}
+
+// advertiseRouter sends the router advertisements of the Interface's
+// NDPResponder until it's closed.
+func (t *Interface) advertiseRouter() {
+	stop, done := make(chan struct{}), make(chan struct{})
+	t.OnClose(func() {
+		close(stop)
+		<-done
+	})
+	goLabeled(t, "router advertisements", func() {
+		defer close(done)
+		for {
+			interval := time.Minute
+			if ra, ok := t.ndp.RouterAdvertisement(); ok {
+				t.writePacket(ra)
+				t.ndp.lock.RLock()
+				if rtr := t.ndp.router; rtr != nil {
+					interval = rtr.Interval
+				}
+				t.ndp.lock.RUnlock()
+			}
+			select {
+			case <-time.After(interval):
+			case <-t.ndp.changed:
+			case <-stop:
+				return
+			}
+		}
+	})
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,169 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"path"
+)
+
+//-----------------------------------------------------------------------------
+// net/netip variants of the configuration methods. A netip.Prefix carries an
+// address with its prefix length ("192.0.2.1/24"), which is what an
+// interface address is, and unlike a net.IP it can't be of 4 or 16 bytes for
+// the same IPv4 address, or have a mask which isn't a prefix. IPv4-mapped
+// IPv6 addresses are taken as the IPv4 addresses they map, and zones are
+// ignored, the interface being the zone.
+
+var ErrInvalidPrefix = errors.New("invalid prefix")
+
+// ipNet returns the address of p, and its subnet, as net types.
+func ipNet(p netip.Prefix) (net.IP, *net.IPNet, error) {
+	if !p.IsValid() {
+		return nil, nil, ErrInvalidPrefix
+	}
+	a, bits := p.Addr().Unmap(), p.Bits()
+	if p.Addr().Is4In6() {
+		bits -= 96
+		if bits < 0 {
+			return nil, nil, ErrInvalidPrefix
+		}
+	}
+	a = a.WithZone("")
+	subnet := netip.PrefixFrom(a, bits).Masked()
+	return a.AsSlice(), &net.IPNet{
+		IP:   subnet.Addr().AsSlice(),
+		Mask: net.CIDRMask(bits, a.BitLen()),
+	}, nil
+}
+
+// AddPrefix adds the address of p, on the subnet of p, to the tunnel
+// interface, as AddAddress does.
+func (t *Interface) AddPrefix(p netip.Prefix) error {
+	ip, subnet, err := ipNet(p)
+	if err != nil {
+		return err
+	}
+	return t.AddAddress(ip, subnet)
+}
+
+// DelPrefix removes the address of p from the tunnel interface, as
+// DelAddress does.
+func (t *Interface) DelPrefix(p netip.Prefix) error {
+	ip, subnet, err := ipNet(p)
+	if err != nil {
+		return err
+	}
+	return t.DelAddress(ip, subnet)
+}
+
+// Prefixes returns the addresses of the interface, with the prefix lengths
+// of their subnets. It's GetAddrList with the subnets.
+func (t *Interface) Prefixes() ([]netip.Prefix, error) {
+	if err := t.configurable(); err != nil {
+		return nil, err
+	}
+	itf, err := net.InterfaceByName(path.Base(t.Name()))
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := itf.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	prefixes := []netip.Prefix{}
+	for _, addr := range addrs {
+		ipn, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		a, ok := netip.AddrFromSlice(ipn.IP)
+		if !ok {
+			continue
+		}
+		ones, _ := ipn.Mask.Size()
+		if a.Is4In6() {
+			a = a.Unmap()
+			if len(ipn.Mask) == net.IPv6len {
+				ones -= 96
+			}
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(a, ones))
+	}
+	return prefixes, nil
+}
+
+// RouteTo returns the route to dst (whose address bits past the prefix
+// length are ignored) through gateway, which is the zero Addr for
+// destinations on the link.
+func RouteTo(dst netip.Prefix, gateway netip.Addr) (Route, error) {
+	_, subnet, err := ipNet(dst)
+	if err != nil {
+		return Route{}, err
+	}
+	r := Route{Dst: subnet}
+	if gateway.IsValid() {
+		gw := gateway.Unmap()
+		if gw.Is4() != (subnet.IP.To4() != nil) {
+			return Route{}, ErrInvalidPrefix
+		}
+		r.Gateway = gw.WithZone("").AsSlice()
+	}
+	return r, nil
+}
+
+// AddRoutePrefix adds the route to dst through gateway (see RouteTo), as
+// AddRoute does.
+func (t *Interface) AddRoutePrefix(dst netip.Prefix, gateway netip.Addr) error {
+	r, err := RouteTo(dst, gateway)
+	if err != nil {
+		return err
+	}
+	return t.AddRoute(r)
+}
+
+// DelRoutePrefix removes the route to dst through gateway (see RouteTo), as
+// DelRoute does.
+func (t *Interface) DelRoutePrefix(dst netip.Prefix, gateway netip.Addr) error {
+	r, err := RouteTo(dst, gateway)
+	if err != nil {
+		return err
+	}
+	return t.DelRoute(r)
+}
+
+// DstPrefix returns the destination of r as a netip.Prefix.
+func (r Route) DstPrefix() netip.Prefix {
+	if r.Dst == nil {
+		return netip.Prefix{}
+	}
+	a, ok := netip.AddrFromSlice(r.Dst.IP)
+	if !ok {
+		return netip.Prefix{}
+	}
+	ones, bits := r.Dst.Mask.Size()
+	if a.Is4In6() && bits == 8*net.IPv4len {
+		a = a.Unmap()
+	}
+	return netip.PrefixFrom(a, ones)
+}
+
+// GatewayAddr returns the gateway of r as a netip.Addr, the zero Addr if r
+// has none.
+func (r Route) GatewayAddr() netip.Addr {
+	a, _ := netip.AddrFromSlice(r.Gateway)
+	if r.Gateway.To4() != nil {
+		a = a.Unmap()
+	}
+	return a
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,63 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"strconv"
+)
+
+//-----------------------------------------------------------------------------
+// Network namespaces. Containers get their network interfaces from the
+// outside: a tun device created in the container's network namespace is
+// served by a process which stays in its own. The device is created in the
+// namespace of the thread opening /dev/net/tun, and its file descriptor
+// keeps working wherever the device goes, so OpenInNamespace opens it on a
+// thread which has switched to the namespace (setns(2)) and back, and
+// MoveToNamespace moves an open one there.
+//
+// The methods configuring the interface (AddAddress, SetMTU, Up...) work in
+// the namespace of the calling thread, where an interface elsewhere isn't
+// found; InNamespace runs them in the interface's. The Options given to
+// OpenInNamespace are applied in it.
+
+// NamespaceOfPid returns the path of the network namespace of the process
+// pid, for the functions taking one.
+func NamespaceOfPid(pid int) string {
+	return "/proc/" + strconv.Itoa(pid) + "/ns/net"
+}
+
+// InNamespace calls f on a thread in the network namespace at nsPath, e.g.
+// "/var/run/netns/blue" or NamespaceOfPid(1234), and returns its error. f
+// mustn't start goroutines expecting to be in the namespace. Only
+// implemented on Linux.
+func InNamespace(nsPath string, f func() error) error {
+	return inNamespace(nsPath, f)
+}
+
+// OpenInNamespace opens the interface ifPattern as Open does, but in the
+// network namespace at nsPath. The Interface is used from the caller's
+// namespace as any other. Only implemented on Linux.
+func OpenInNamespace(nsPath string, ifPattern string, kind DevKind, opts ...Option) (*Interface, error) {
+	var t *Interface
+	err := inNamespace(nsPath, func() error {
+		var err error
+		t, err = Open(ifPattern, kind, opts...)
+		return err
+	})
+	if err != nil {
+		if t != nil {
+			// the namespace couldn't be left
+			t.Close()
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,81 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+
+// inNamespace calls f on a goroutine locked to its thread while the thread
+// is in the network namespace at nsPath. A thread which can't be switched
+// back is left locked, so that the runtime discards it rather than let
+// other goroutines run in the namespace.
+func inNamespace(nsPath string, f func() error) error {
+	ns, err := unix.Open(nsPath, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return errors.Wrapf(err, "tuntap: Can't open network namespace %s", nsPath)
+	}
+	defer unix.Close(ns)
+
+	done := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		self, err := unix.Open("/proc/thread-self/ns/net", unix.O_RDONLY|unix.O_CLOEXEC, 0)
+		if err != nil {
+			runtime.UnlockOSThread()
+			done <- errors.Wrap(err, "tuntap: Can't open the current network namespace")
+			return
+		}
+		defer unix.Close(self)
+		if err = unix.Setns(ns, unix.CLONE_NEWNET); err != nil {
+			runtime.UnlockOSThread()
+			done <- errors.Wrapf(err, "tuntap: Can't enter network namespace %s", nsPath)
+			return
+		}
+		ferr := f()
+		if err = unix.Setns(self, unix.CLONE_NEWNET); err != nil {
+			// the thread goes with the goroutine
+			done <- errors.Wrapf(err, "tuntap: Can't leave network namespace %s", nsPath)
+			return
+		}
+		runtime.UnlockOSThread()
+		done <- ferr
+	}()
+	return <-done
+}
+
+// MoveToNamespace moves the interface to the network namespace at nsPath,
+// with netlink. The Interface keeps working; its addresses and routes are
+// lost, and it's DOWN, as with any interface moved.
+func (t *Interface) MoveToNamespace(nsPath string) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	link, err := netlink.LinkByName(t.name)
+	if err != nil {
+		return err
+	}
+	ns, err := unix.Open(nsPath, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return errors.Wrapf(err, "tuntap: Can't open network namespace %s", nsPath)
+	}
+	defer unix.Close(ns)
+	if err = netlink.LinkSetNsFd(link, ns); err != nil {
+		return errors.Wrapf(err, "tuntap: Can't move %s to network namespace %s", t.name, nsPath)
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,74 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"errors"
+)
+
+//-----------------------------------------------------------------------------
+// The MTU of a tunnel follows from what the tunnel adds to each packet: its
+// own framing (a transport.Framer's Overhead, a Geneve or GTP-U header...)
+// and the headers of the underlay it's sent over. TunnelOverhead sums them
+// up once, so that the MTU of the Interface, the MSS clamp (ClampMSS) and the
+// servers advertising the MTU to the hosts behind the tunnel (in router
+// advertisements or DHCP options) all use the same figure.
+
+// the headers a UDP underlay adds to each packet
+const (
+	OverheadUDP4 = 20 + 8
+	OverheadUDP6 = 40 + 8
+)
+
+var ErrMTUTooSmall = errors.New("tunnel overhead leaves less than the minimum IPv4 MTU")
+
+// TunnelOverhead describes what a tunnel adds to the packets of its Interface.
+type TunnelOverhead struct {
+	// of the path the tunnel's packets take, 1500 if 0
+	UnderlayMTU int
+	// the headers of the underlay, e.g. OverheadUDP6
+	Underlay int
+	// the tunnel's own encapsulation and encryption, per packet
+	Framing int
+}
+
+// MTU returns the MTU of an Interface of the given kind going through the
+// tunnel. A DevTap's MTU leaves out the Ethernet header, which the tunnel
+// carries too.
+func (o TunnelOverhead) MTU(kind DevKind) int {
+	mtu := o.UnderlayMTU
+	if mtu == 0 {
+		mtu = 1500
+	}
+	mtu -= o.Underlay + o.Framing
+	if kind == DevTap {
+		mtu -= 14
+	}
+	return mtu
+}
+
+// SetTunnelMTU sets the MTU of t to what o leaves, and passes it on to each
+// of notify (an RA or DHCP server, for instance: see DHCPServer.SetMTU). It returns the MTU, and
+// ErrMTUTooSmall if that's under IPv4's minimum of 68; an IPv6 tunnel needs
+// 1280.
+func (t *Interface) SetTunnelMTU(o TunnelOverhead, notify ...func(mtu int)) (int, error) {
+	mtu := o.MTU(t.kind)
+	if mtu < 68 {
+		return mtu, ErrMTUTooSmall
+	}
+	if err := t.SetMTU(mtu); err != nil {
+		return mtu, err
+	}
+	for _, n := range notify {
+		n(mtu)
+	}
+	return mtu, nil
+}
+
+//-----------------------------------------------------------------------------
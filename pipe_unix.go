@@ -0,0 +1,62 @@
+//go:build linux || freebsd || darwin || openbsd || netbsd
+
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+// In-memory Interfaces. OpenPipe joins two Interfaces back to back over a
+// unix datagram socket pair, which keeps the packets whole and in order, the
+// way a device does, and like a device's, its reads and writes go through
+// the runtime's poller, with the same deadlines, blocking and closing. It
+// takes no privileges, so that applications can run their data path in
+// tests and CI without a tun device (see Soak).
+
+var pipeCount atomic.Int64
+
+// OpenPipe returns two Interfaces of kind kind, joined in memory: the
+// packets written to either are read from the other. They are framed like
+// those of a device without headers: IP packets for DevTun, Ethernet frames
+// for DevTap. There's no network interface behind them, so the methods
+// configuring one (addresses, routes, the MTU...) fail.
+func OpenPipe(kind DevKind) (*Interface, *Interface, error) {
+	if kind != DevTun && kind != DevTap {
+		panic(fmt.Sprintf("tuntap: Unknown tuntap interface type %d", int(kind)))
+	}
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "tuntap: Can't create a socket pair")
+	}
+	n := pipeCount.Add(1) - 1
+	var ifs [2]*Interface
+	for i, fd := range fds {
+		unix.CloseOnExec(fd)
+		if err := unix.SetNonblock(fd, true); err != nil {
+			unix.Close(fds[0])
+			unix.Close(fds[1])
+			return nil, nil, errors.Wrap(err, "tuntap: Can't set nonblocking mode on a socket pair")
+		}
+		name := fmt.Sprintf("pipe%d%c", n, 'a'+i)
+		ifs[i] = &Interface{name: name, file: os.NewFile(uintptr(fd), name), kind: kind, framing: frameNone}
+	}
+	track(ifs[0], nil)
+	track(ifs[1], nil)
+	return ifs[0], ifs[1], nil
+}
+
+//-----------------------------------------------------------------------------
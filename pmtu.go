@@ -0,0 +1,263 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// Packetization layer path MTU discovery (RFC 8899) for tunnels. ICMP "too
+// big" messages rarely make it back to a tunnel endpoint, so the PMTUProber
+// finds the largest packet which goes through by sending probes of the sizes
+// it tries, padded to take as much room on the path as a packet of that size
+// through the tunnel would, and seeing which the far end acknowledges. It
+// then sets the MTU of the Interface, and ClampMSS keeps TCP connections
+// within it. The probes and their acknowledgements are the tunnel's
+// business: the application sends them with PMTUConfig.Probe, and reports
+// the acknowledgements with Acked. Where the far end answers pings, they can
+// be echo requests sent with ICMPConn.SendEcho, and their replies.
+
+// PMTUConfig configures a PMTUProber. Zero fields take the defaults.
+type PMTUConfig struct {
+	// the MTUs to search between, 1280 and 1500 by default; Min is assumed
+	// to go through
+	Min, Max int
+	// sends a probe as large as a packet of size bytes through the
+	// Interface; required
+	Probe func(size int) error
+	// how long to wait for the acknowledgement of a probe, 1s by default
+	Timeout time.Duration
+	// the probes sent for a size before deciding it doesn't go through, 3
+	// by default
+	Tries int
+	// how long after a search to search again for a larger MTU, 10
+	// minutes by default (RFC 8899's PMTU_RAISE_TIMER)
+	Raise time.Duration
+	// called, if set, when the search finds a different MTU, after it's
+	// set on the Interface
+	OnChange func(mtu int)
+}
+
+// PMTUProber searches for the path MTU of a tunnel.
+type PMTUProber struct {
+	t   *Interface
+	cfg PMTUConfig
+
+	mtu     atomic.Int64
+	probing atomic.Int64 // the size of the outstanding probe, 0 if none
+	acks    chan int
+	reprobe chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewPMTUProber returns a prober which sets the MTU of t (if t isn't nil),
+// and starts searching.
+func NewPMTUProber(t *Interface, cfg PMTUConfig) *PMTUProber {
+	if cfg.Min == 0 {
+		cfg.Min = 1280
+	}
+	if cfg.Max < cfg.Min {
+		cfg.Max = 1500
+		if cfg.Max < cfg.Min {
+			cfg.Max = cfg.Min
+		}
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Second
+	}
+	if cfg.Tries == 0 {
+		cfg.Tries = 3
+	}
+	if cfg.Raise == 0 {
+		cfg.Raise = 10 * time.Minute
+	}
+	p := &PMTUProber{
+		t:       t,
+		cfg:     cfg,
+		acks:    make(chan int, 1),
+		reprobe: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	p.mtu.Store(int64(cfg.Min))
+	goLabeled(t, "pmtu", p.run)
+	return p
+}
+
+// MTU returns the largest MTU known to go through.
+func (p *PMTUProber) MTU() int {
+	return int(p.mtu.Load())
+}
+
+// Acked reports the acknowledgement of the probe of size bytes.
+func (p *PMTUProber) Acked(size int) {
+	if size != 0 && int64(size) == p.probing.Load() {
+		select {
+		case p.acks <- size:
+		default:
+		}
+	}
+}
+
+// Reprobe starts a new search from Min, for when the tunnel suspects the
+// path changed (packets of the current MTU are lost, the far end moved...).
+func (p *PMTUProber) Reprobe() {
+	select {
+	case p.reprobe <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the prober.
+func (p *PMTUProber) Close() {
+	p.once.Do(func() { close(p.stop) })
+	<-p.done
+}
+
+func (p *PMTUProber) run() {
+	defer close(p.done)
+	lo := p.cfg.Min
+	for {
+		// binary search, as sizes are cheap to probe compared to the delay
+		// of stepping up one at a time
+		hi := p.cfg.Max
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			ok, stopped := p.probe(mid)
+			if stopped {
+				return
+			}
+			if ok {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		p.set(lo)
+
+		timer := time.NewTimer(p.cfg.Raise)
+		select {
+		case <-timer.C:
+			// search again from what goes through now
+		case <-p.reprobe:
+			timer.Stop()
+			lo = p.cfg.Min
+			p.set(lo)
+		case <-p.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// probe returns whether a probe of size bytes was acknowledged, or that the
+// prober was stopped.
+func (p *PMTUProber) probe(size int) (bool, bool) {
+	p.probing.Store(int64(size))
+	defer p.probing.Store(0)
+	for i := 0; i < p.cfg.Tries; i++ {
+		// an error sending is as good as a lost probe
+		p.cfg.Probe(size)
+		timer := time.NewTimer(p.cfg.Timeout)
+	wait:
+		for {
+			select {
+			case acked := <-p.acks:
+				// the ack of an earlier probe may be late
+				if acked == size {
+					timer.Stop()
+					return true, false
+				}
+			case <-timer.C:
+				break wait
+			case <-p.stop:
+				timer.Stop()
+				return false, true
+			}
+		}
+	}
+	return false, false
+}
+
+// set makes mtu the MTU.
+func (p *PMTUProber) set(mtu int) {
+	if int(p.mtu.Swap(int64(mtu))) == mtu {
+		return
+	}
+	if p.t != nil {
+		// on error the old MTU stays, which still goes through if it's larger
+		// than Min; nothing better to do
+		p.t.SetMTU(mtu)
+	}
+	if p.cfg.OnChange != nil {
+		p.cfg.OnChange(mtu)
+	}
+}
+
+// ClampMSS lowers the MSS option of a TCP SYN in pkt so that the segments of
+// the connection fit in packets of mtu bytes, fixing up the TCP checksum, and
+// returns whether it did. Packets which aren't SYNs are left alone.
+func ClampMSS(pkt *Packet, mtu int) bool {
+	proto, at, frag := pkt.IPProto()
+	b := pkt.Body
+	if proto != 6 || frag || at+20 > len(b) || b[at+13]&0x02 == 0 {
+		return false
+	}
+	end := at + int(b[at+12]>>4)*4
+	if end > len(b) {
+		return false
+	}
+	limit := mtu - (at - pkt.L3Offset) - 20
+	if limit <= 0 {
+		return false
+	}
+	for i := at + 20; i < end; {
+		switch kind := b[i]; kind {
+		case 0: // end of options
+			return false
+		case 1: // no-op
+			i++
+			continue
+		case 2:
+			if i+4 > end || b[i+1] != 4 {
+				return false
+			}
+			mss := int(binary.BigEndian.Uint16(b[i+2 : i+4]))
+			if mss <= limit {
+				return false
+			}
+			binary.BigEndian.PutUint16(b[i+2:i+4], uint16(limit))
+			// incremental update of the checksum (RFC 1624): HC' = ~(~HC +
+			// ~m + m'). After an odd number of no-ops, the field straddles
+			// 16-bit words, which the sum sees byte swapped
+			m, m1 := uint16(mss), uint16(limit)
+			if (i-at)%2 != 0 {
+				m, m1 = m<<8|m>>8, m1<<8|m1>>8
+			}
+			sum := uint32(^binary.BigEndian.Uint16(b[at+16:at+18])) + uint32(^m) + uint32(m1)
+			sum = sum&0xffff + sum>>16
+			sum = sum&0xffff + sum>>16
+			binary.BigEndian.PutUint16(b[at+16:at+18], ^uint16(sum))
+			return true
+		}
+		if i+1 >= end || b[i+1] < 2 {
+			return false
+		}
+		i += int(b[i+1])
+	}
+	return false
+}
+
+//-----------------------------------------------------------------------------
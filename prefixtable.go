@@ -0,0 +1,203 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"net/netip"
+	"sort"
+	"sync"
+)
+
+//-----------------------------------------------------------------------------
+// A longest prefix match table, for routing between Interfaces. The
+// prefixes are kept in a hash table per prefix length, and a lookup tries
+// the lengths in use from the longest down: a handful of hash lookups for
+// the usual tables, which have few distinct lengths, however many prefixes
+// they hold. It never allocates to look up.
+//
+// IPv4-mapped IPv6 prefixes and addresses are taken as the IPv4 ones, and
+// zones are ignored.
+
+// PrefixTable maps IP prefixes to values, and addresses to the value of the
+// longest prefix containing them. It is safe for concurrent use.
+type PrefixTable struct {
+	lock sync.RWMutex
+	// the lengths in use, longest first, for IPv4 and IPv6
+	levels [2][]prefixLevel
+	n      int
+}
+
+type prefixLevel struct {
+	bits     int
+	prefixes map[netip.Prefix]interface{}
+}
+
+// NewPrefixTable returns an empty table.
+func NewPrefixTable() *PrefixTable {
+	return &PrefixTable{}
+}
+
+// canonical returns p masked, in the form the table keeps it, and the
+// family index; false if p isn't valid.
+func canonical(p netip.Prefix) (netip.Prefix, int, bool) {
+	if !p.IsValid() {
+		return p, 0, false
+	}
+	a, bits := p.Addr(), p.Bits()
+	if a.Is4In6() {
+		if bits < 96 {
+			return p, 0, false
+		}
+		a, bits = a.Unmap(), bits-96
+	}
+	p = netip.PrefixFrom(a.WithZone(""), bits).Masked()
+	if a.Is4() {
+		return p, 0, true
+	}
+	return p, 1, true
+}
+
+// Insert maps p to v, replacing the value p had. Invalid prefixes are
+// ignored.
+func (pt *PrefixTable) Insert(p netip.Prefix, v interface{}) {
+	p, fam, ok := canonical(p)
+	if !ok {
+		return
+	}
+	pt.lock.Lock()
+	defer pt.lock.Unlock()
+	levels := pt.levels[fam]
+	i := sort.Search(len(levels), func(i int) bool { return levels[i].bits <= p.Bits() })
+	if i == len(levels) || levels[i].bits != p.Bits() {
+		levels = append(levels, prefixLevel{})
+		copy(levels[i+1:], levels[i:])
+		levels[i] = prefixLevel{bits: p.Bits(), prefixes: make(map[netip.Prefix]interface{})}
+		pt.levels[fam] = levels
+	}
+	if _, ok := levels[i].prefixes[p]; !ok {
+		pt.n++
+	}
+	levels[i].prefixes[p] = v
+}
+
+// Delete removes p from the table, and returns whether it was there.
+func (pt *PrefixTable) Delete(p netip.Prefix) bool {
+	p, fam, ok := canonical(p)
+	if !ok {
+		return false
+	}
+	pt.lock.Lock()
+	defer pt.lock.Unlock()
+	for i, l := range pt.levels[fam] {
+		if l.bits != p.Bits() {
+			continue
+		}
+		if _, ok := l.prefixes[p]; !ok {
+			return false
+		}
+		delete(l.prefixes, p)
+		pt.n--
+		if len(l.prefixes) == 0 {
+			pt.levels[fam] = append(pt.levels[fam][:i], pt.levels[fam][i+1:]...)
+		}
+		return true
+	}
+	return false
+}
+
+// DeleteFunc removes the prefixes for which del returns true, and returns
+// how many it removed. del is called with the table locked, so it mustn't
+// use the table.
+func (pt *PrefixTable) DeleteFunc(del func(p netip.Prefix, v interface{}) bool) int {
+	pt.lock.Lock()
+	defer pt.lock.Unlock()
+	n := 0
+	for fam := range pt.levels {
+		levels := pt.levels[fam][:0]
+		for _, l := range pt.levels[fam] {
+			for p, v := range l.prefixes {
+				if del(p, v) {
+					delete(l.prefixes, p)
+					n++
+				}
+			}
+			if len(l.prefixes) != 0 {
+				levels = append(levels, l)
+			}
+		}
+		pt.levels[fam] = levels
+	}
+	pt.n -= n
+	return n
+}
+
+// Get returns the value of the prefix p itself.
+func (pt *PrefixTable) Get(p netip.Prefix) (interface{}, bool) {
+	p, fam, ok := canonical(p)
+	if !ok {
+		return nil, false
+	}
+	pt.lock.RLock()
+	defer pt.lock.RUnlock()
+	for _, l := range pt.levels[fam] {
+		if l.bits == p.Bits() {
+			v, ok := l.prefixes[p]
+			return v, ok
+		}
+	}
+	return nil, false
+}
+
+// Lookup returns the longest prefix containing a, and its value; false if
+// there's none.
+func (pt *PrefixTable) Lookup(a netip.Addr) (netip.Prefix, interface{}, bool) {
+	if !a.IsValid() {
+		return netip.Prefix{}, nil, false
+	}
+	a = a.Unmap().WithZone("")
+	fam := 1
+	if a.Is4() {
+		fam = 0
+	}
+	pt.lock.RLock()
+	defer pt.lock.RUnlock()
+	for _, l := range pt.levels[fam] {
+		p, _ := a.Prefix(l.bits)
+		if v, ok := l.prefixes[p]; ok {
+			return p, v, true
+		}
+	}
+	return netip.Prefix{}, nil, false
+}
+
+// Len returns the number of prefixes in the table.
+func (pt *PrefixTable) Len() int {
+	pt.lock.RLock()
+	defer pt.lock.RUnlock()
+	return pt.n
+}
+
+// Walk calls fn with each prefix of the table and its value, the IPv4 ones
+// first, longest first, until fn returns false. fn is called with the table
+// locked, so it mustn't change the table.
+func (pt *PrefixTable) Walk(fn func(p netip.Prefix, v interface{}) bool) {
+	pt.lock.RLock()
+	defer pt.lock.RUnlock()
+	for _, levels := range pt.levels {
+		for _, l := range levels {
+			for p, v := range l.prefixes {
+				if !fn(p, v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
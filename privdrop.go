@@ -0,0 +1,101 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"errors"
+)
+
+//-----------------------------------------------------------------------------
+// The secure way to start a daemon using a tun device is to do everything
+// which needs privileges first (creating the device, configuring its
+// addresses, MTU and routes) and then to give them up for good, keeping only
+// the open device. Reading and writing packets doesn't need any privilege,
+// only configuring the interface does. OpenAndDropPrivileges encodes that
+// sequence.
+
+// Credential is the user a process continues as once it drops privileges.
+type Credential struct {
+	UID int
+	GID int
+	// the supplementary groups; none if empty
+	Groups []int
+}
+
+// DropPrivileges irrevocably switches the whole process (all its threads) to
+// the user and groups of c, losing root privileges and any capabilities.
+// Where the platform supports it (linux), it also empties the capability
+// bounding set and prevents the process from gaining privileges again by
+// executing setuid programs. On linux, that needs a program built without
+// cgo: otherwise DropPrivileges returns an error, leaving the privileges.
+func DropPrivileges(c Credential) error {
+	return dropPrivileges(c)
+}
+
+// OpenAndDropPrivileges opens the device as Open does, calls setup to
+// configure it while the process still has privileges, and then drops them
+// with DropPrivileges. The Interface remains usable for reading and writing
+// packets. If anything fails, the Interface is closed, and an error is
+// returned; what to do about privileges then, most likely exiting, is up to
+// the caller.
+func OpenAndDropPrivileges(ifPattern string, kind DevKind, setup func(*Interface) error, c Credential, opts ...Option) (*Interface, error) {
+	t, err := Open(ifPattern, kind, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if setup != nil {
+		if err = setup(t); err != nil {
+			t.Close()
+			return nil, err
+		}
+	}
+	if err = dropPrivileges(c); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// RestrictFilesystem irrevocably limits the filesystem access of the whole
+// process to the device files the package opens (so more Interfaces can still
+// be opened), plus the files and directory trees in extra. It uses Landlock
+// on linux, and returns ErrNotSupported where there's no such mechanism, or
+// the kernel doesn't have it. Methods which write under /proc (IPv6SLAAC,
+// IPv6Forwarding, IPv6) stop working unless their files are in extra.
+func RestrictFilesystem(extra ...string) error {
+	paths := append(append([]string(nil), devicePaths...), extra...)
+	return restrictPaths(paths)
+}
+
+// OpenSandboxed is the most locked down way of opening a device. It opens and
+// sets it up as OpenAndDropPrivileges does, but before dropping privileges it
+// restricts the process' filesystem access with RestrictFilesystem, and
+// afterwards it seals the Interface. If the platform can't restrict
+// filesystem access, that step is skipped.
+func OpenSandboxed(ifPattern string, kind DevKind, setup func(*Interface) error, c Credential, opts ...Option) (*Interface, error) {
+	restricted := func(t *Interface) error {
+		if setup != nil {
+			if err := setup(t); err != nil {
+				return err
+			}
+		}
+		if err := RestrictFilesystem(); err != nil && !errors.Is(err, ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+	t, err := OpenAndDropPrivileges(ifPattern, kind, restricted, c, opts...)
+	if err != nil {
+		return nil, err
+	}
+	t.Seal()
+	return t, nil
+}
+
+//-----------------------------------------------------------------------------
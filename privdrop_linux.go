@@ -0,0 +1,84 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+// Capabilities are per thread, as is no_new_privs, so all of it is done with
+// AllThreadsSyscall. That isn't available in programs using cgo, where
+// dropping privileges fails before changing anything, rather than leave some
+// threads with them.
+
+// dropCapabilityBounds drops all the capabilities from the bounding set, so
+// that no execve can give any back. It needs CAP_SETPCAP, so it's done while
+// the process is still root.
+func dropCapabilityBounds() error {
+	for c := 0; ; c++ {
+		in, err := unix.PrctlRetInt(unix.PR_CAPBSET_READ, uintptr(c), 0, 0, 0)
+		if err == unix.EINVAL {
+			// past the last capability the kernel knows of
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "tuntap: Can't read capability %d of the bounding set", c)
+		}
+		if in == 0 {
+			continue
+		}
+		_, _, errno := syscall.AllThreadsSyscall(syscall.SYS_PRCTL, unix.PR_CAPBSET_DROP, uintptr(c), 0)
+		if errno == syscall.ENOTSUP {
+			return errors.New("tuntap: Can't drop privileges on all threads in a program using cgo")
+		}
+		if errno != 0 {
+			return errors.Wrapf(errno, "tuntap: Can't drop capability %d from the bounding set", c)
+		}
+	}
+}
+
+// lockPrivileges clears the capabilities left, the ambient ones and, should
+// the uid change not have done it (SECBIT_KEEP_CAPS...), the others, and
+// keeps the process from gaining privileges through execve of setuid or file
+// capability binaries.
+func lockPrivileges() error {
+	_, _, errno := syscall.AllThreadsSyscall(syscall.SYS_PRCTL, unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0)
+	if errno != 0 {
+		return lockError("clear the ambient capabilities", errno)
+	}
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	_, _, errno = syscall.AllThreadsSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0)
+	runtime.KeepAlive(&hdr)
+	runtime.KeepAlive(&data)
+	if errno != 0 {
+		return lockError("clear the capabilities", errno)
+	}
+	// no_new_privs is per thread, so it has to be set on all of them
+	_, _, errno = syscall.AllThreadsSyscall(syscall.SYS_PRCTL, unix.PR_SET_NO_NEW_PRIVS, 1, 0)
+	if errno != 0 {
+		return lockError("set no_new_privs", errno)
+	}
+	return nil
+}
+
+func lockError(what string, errno syscall.Errno) error {
+	if errno == syscall.ENOTSUP {
+		return errors.Errorf("tuntap: Can't %s on all threads in a program using cgo", what)
+	}
+	return errors.Wrapf(errno, "tuntap: Can't %s", what)
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,51 @@
+//go:build linux || freebsd || darwin || openbsd || netbsd
+
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+//-----------------------------------------------------------------------------
+
+func dropPrivileges(c Credential) error {
+	if c.UID == 0 {
+		return errors.New("tuntap: Can't drop privileges to uid 0")
+	}
+	// the package syscall functions apply to all the threads of the process,
+	// which those of x/sys/unix don't on linux. The order matters: groups
+	// can't be changed any more once the uid isn't root's.
+	if err := dropCapabilityBounds(); err != nil {
+		return err
+	}
+	groups := c.Groups
+	if groups == nil {
+		groups = []int{}
+	}
+	if err := syscall.Setgroups(groups); err != nil {
+		return errors.Wrap(err, "tuntap: Can't set supplementary groups")
+	}
+	if err := syscall.Setgid(c.GID); err != nil {
+		return errors.Wrapf(err, "tuntap: Can't set gid %d", c.GID)
+	}
+	if err := syscall.Setuid(c.UID); err != nil {
+		return errors.Wrapf(err, "tuntap: Can't set uid %d", c.UID)
+	}
+	// make sure it can't be undone
+	if syscall.Setuid(0) == nil {
+		return errors.New("tuntap: Privileges could be regained after dropping them")
+	}
+	return lockPrivileges()
+}
+
+//-----------------------------------------------------------------------------
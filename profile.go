@@ -0,0 +1,64 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"context"
+	"path"
+	"runtime/pprof"
+	"strconv"
+)
+
+//-----------------------------------------------------------------------------
+// Profile annotations. In a daemon serving many tunnels, a CPU profile
+// shows the time spent reading and writing packets, but not for which
+// interface. The goroutines the package starts for an Interface (the
+// FQCoDelWriter's, the PMTUProber's...) carry pprof labels naming the
+// interface ("tuntap.interface"), the queue of a multi-queue interface
+// ("tuntap.queue") and what they do ("tuntap.worker"), and the application
+// labels its own readers and workers the same way with DoLabeled, so that
+// `go tool pprof -tagfocus` can pick out a tunnel. Batches of packets read
+// and written are runtime/trace regions ("tuntap.ReadPackets" and
+// "tuntap.WritePackets"), which cost nothing unless a trace is being taken.
+
+// ProfileLabels returns the pprof labels of t's goroutines.
+func (t *Interface) ProfileLabels() pprof.LabelSet {
+	return profileLabels(t, "")
+}
+
+// DoLabeled calls f with the labels of t added to those of ctx, as pprof.Do
+// does: until f returns, the goroutine and those f starts are accounted to
+// t.
+func (t *Interface) DoLabeled(ctx context.Context, f func(ctx context.Context)) {
+	pprof.Do(ctx, t.ProfileLabels(), f)
+}
+
+// profileLabels returns the labels of a goroutine doing worker's work for t;
+// either may be missing.
+func profileLabels(t *Interface, worker string) pprof.LabelSet {
+	var labels []string
+	if t != nil {
+		labels = append(labels, "tuntap.interface", path.Base(t.name))
+		if t.queue != 0 {
+			labels = append(labels, "tuntap.queue", strconv.Itoa(t.queue-1))
+		}
+	}
+	if worker != "" {
+		labels = append(labels, "tuntap.worker", worker)
+	}
+	return pprof.Labels(labels...)
+}
+
+// goLabeled runs f in a goroutine labeled as doing worker's work for t,
+// which may be nil.
+func goLabeled(t *Interface, worker string, f func()) {
+	go pprof.Do(context.Background(), profileLabels(t, worker), func(context.Context) { f() })
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,204 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"sync"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// Byte quotas, e.g. 10GB per peer per day. A gateway accounts each packet to
+// the peer it's from or for, in the direction it goes through the Interface;
+// Account says when the peer is over its cap, and the Quotas call back as
+// the usage crosses the configured thresholds, to warn the user at 80%, say.
+// Usage starts over at the beginning of each period.
+
+// Direction is the way a packet goes through an Interface (see Packet.Dir).
+type Direction int
+
+const (
+	// read from the Interface: sent by the host
+	DirRead Direction = iota
+	// written to the Interface: for the host
+	DirWrite
+)
+
+func (d Direction) String() string {
+	if d == DirRead {
+		return "read"
+	}
+	return "write"
+}
+
+// QuotaLimits are the caps of a peer, per period. A cap of 0 is no cap.
+type QuotaLimits struct {
+	Read, Write uint64
+	// Thresholds are the fractions of a cap (e.g. 0.8) at which to call
+	// back, besides 1 which always is, in increasing order.
+	Thresholds []float64
+}
+
+// QuotaEvent is the usage of a peer crossing a threshold.
+type QuotaEvent struct {
+	Peer      string
+	Dir       Direction
+	Used      uint64
+	Limit     uint64
+	Threshold float64 // 1 when the cap is reached
+}
+
+type quotaUsage struct {
+	period time.Time // start of the period the usage is for
+	used   [2]uint64
+	next   [2]int // index of the next threshold to cross, per direction
+	limits *QuotaLimits
+}
+
+// Quotas keeps track of the usage of peers against their caps. It is safe for
+// concurrent use.
+type Quotas struct {
+	period   time.Duration
+	defaults QuotaLimits
+	onCross  func(QuotaEvent)
+
+	lock  sync.Mutex
+	peers map[string]*quotaUsage
+}
+
+// NewQuotas returns Quotas over periods of period, aligned on UTC midnight
+// for periods which divide a day (so 24 * time.Hour is per calendar day),
+// where peers get limits unless given others with SetLimits. onCross, which
+// may be nil, is called when a peer's usage crosses a threshold, from the
+// goroutine calling Account, without the Quotas locked.
+func NewQuotas(period time.Duration, limits QuotaLimits, onCross func(QuotaEvent)) *Quotas {
+	return &Quotas{
+		period:   period,
+		defaults: limits,
+		onCross:  onCross,
+		peers:    make(map[string]*quotaUsage),
+	}
+}
+
+// SetLimits sets the caps of peer, for the current period and later ones.
+func (q *Quotas) SetLimits(peer string, limits QuotaLimits) {
+	q.lock.Lock()
+	u := q.usage(peer, time.Now())
+	u.limits = &limits
+	u.next = [2]int{}
+	// don't report again the thresholds already crossed
+	for d := range u.used {
+		u.next[d] = crossed(limits, Direction(d), u.used[d])
+	}
+	q.lock.Unlock()
+}
+
+// usage returns the usage of peer for the period of now. It's called with q
+// locked.
+func (q *Quotas) usage(peer string, now time.Time) *quotaUsage {
+	start := now.UTC().Truncate(q.period)
+	u := q.peers[peer]
+	if u == nil {
+		u = &quotaUsage{period: start}
+		q.peers[peer] = u
+	} else if !u.period.Equal(start) {
+		u.period = start
+		u.used = [2]uint64{}
+		u.next = [2]int{}
+	}
+	return u
+}
+
+// threshold returns the i-th threshold of limits, the implicit 1 last.
+func threshold(limits *QuotaLimits, i int) (float64, bool) {
+	switch {
+	case i < len(limits.Thresholds):
+		return limits.Thresholds[i], true
+	case i == len(limits.Thresholds):
+		return 1, true
+	}
+	return 0, false
+}
+
+func capOf(limits QuotaLimits, dir Direction) uint64 {
+	if dir == DirRead {
+		return limits.Read
+	}
+	return limits.Write
+}
+
+// crossed returns how many thresholds used is at or over.
+func crossed(limits QuotaLimits, dir Direction, used uint64) int {
+	c := capOf(limits, dir)
+	if c == 0 {
+		return 0
+	}
+	n := 0
+	for th, ok := threshold(&limits, n); ok && float64(used) >= th*float64(c); th, ok = threshold(&limits, n) {
+		n++
+	}
+	return n
+}
+
+// AccountPacket accounts pkt to peer, in the direction pkt.Dir says, as
+// Account does.
+func (q *Quotas) AccountPacket(peer string, pkt Packet) bool {
+	return q.Account(peer, pkt.Dir, len(pkt.Body))
+}
+
+// Account adds n bytes going in direction dir to the usage of peer, and
+// returns false if that puts the peer over its cap, in which case the packet
+// should be dropped. Bytes over the cap still count.
+func (q *Quotas) Account(peer string, dir Direction, n int) bool {
+	q.lock.Lock()
+	u := q.usage(peer, time.Now())
+	limits := q.defaults
+	if u.limits != nil {
+		limits = *u.limits
+	}
+	u.used[dir] += uint64(n)
+	used := u.used[dir]
+	c := capOf(limits, dir)
+	var events []QuotaEvent
+	if c != 0 {
+		for th, ok := threshold(&limits, u.next[dir]); ok && float64(used) >= th*float64(c); th, ok = threshold(&limits, u.next[dir]) {
+			events = append(events, QuotaEvent{Peer: peer, Dir: dir, Used: used, Limit: c, Threshold: th})
+			u.next[dir]++
+		}
+	}
+	q.lock.Unlock()
+
+	if q.onCross != nil {
+		for _, e := range events {
+			q.onCross(e)
+		}
+	}
+	return c == 0 || used <= c
+}
+
+// Usage returns the bytes peer has used in the current period.
+func (q *Quotas) Usage(peer string) (read, write uint64) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.peers[peer] == nil {
+		return 0, 0
+	}
+	u := q.usage(peer, time.Now())
+	return u.used[DirRead], u.used[DirWrite]
+}
+
+// Forget drops what's known of peer, including the limits set with
+// SetLimits.
+func (q *Quotas) Forget(peer string) {
+	q.lock.Lock()
+	delete(q.peers, peer)
+	q.lock.Unlock()
+}
+
+//-----------------------------------------------------------------------------
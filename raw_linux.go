@@ -0,0 +1,173 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+
+// htons converts a 16-bit value to network byte order, as the AF_PACKET API
+// wants the protocol.
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return *(*uint16)(unsafe.Pointer(&b[0]))
+}
+
+func openRaw(ifName string) (*Interface, error) {
+	itf, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "tuntap: Can't find interface %s", ifName)
+	}
+
+	// with protocol 0 the socket receives nothing until it's bound, which
+	// would otherwise be the frames of every interface
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "tuntap: Can't create AF_PACKET socket")
+	}
+
+	sa := unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ALL), Ifindex: itf.Index}
+	err = unix.Bind(fd, &sa)
+	if err != nil {
+		unix.Close(fd)
+		return nil, errors.Wrapf(err, "tuntap: Can't bind AF_PACKET socket to %s", ifName)
+	}
+	// drop anything queued before the bind took effect
+	var discard [1]byte
+	for {
+		if _, _, err := unix.Recvfrom(fd, discard[:], unix.MSG_DONTWAIT); err != nil {
+			break
+		}
+	}
+
+	// not fatal on older kernels; the caller then sees its host's own traffic too
+	unix.SetsockoptInt(fd, unix.SOL_PACKET, unix.PACKET_IGNORE_OUTGOING, 1)
+
+	// the socket is already nonblocking, so the go runtime's poller takes it over
+	file := os.NewFile(uintptr(fd), "packet:"+ifName)
+
+	t := &Interface{name: ifName, file: file, kind: DevTap, framing: frameNone}
+	t.sendBatch = (*Interface).sendmmsg
+	t.recvBatch = (*Interface).recvmmsg
+	return t, nil
+}
+
+// mmsghdr is the linux struct mmsghdr
+type mmsghdr struct {
+	hdr unix.Msghdr
+	len uint32
+}
+
+// sendmmsg transmits pkts with as few sendmmsg(2) calls as possible.
+func (t *Interface) sendmmsg(pkts []Packet) (int, error) {
+	iovs := make([]unix.Iovec, len(pkts))
+	msgs := make([]mmsghdr, len(pkts))
+	for i := range pkts {
+		if len(pkts[i].Body) != 0 {
+			iovs[i].Base = &pkts[i].Body[0]
+			iovs[i].SetLen(len(pkts[i].Body))
+		}
+		msgs[i].hdr.Iov = &iovs[i]
+		msgs[i].hdr.SetIovlen(1)
+	}
+
+	rc, err := t.file.SyscallConn()
+	if err != nil {
+		return 0, t.closedErr(err)
+	}
+	sent := 0
+	var serr error
+	err = rc.Write(func(fd uintptr) bool {
+		for sent < len(msgs) {
+			n, _, errno := unix.Syscall6(unix.SYS_SENDMMSG, fd, uintptr(unsafe.Pointer(&msgs[sent])), uintptr(len(msgs)-sent), 0, 0, 0)
+			switch errno {
+			case 0:
+				sent += int(n)
+			case unix.EINTR:
+			case unix.EAGAIN:
+				// wait for the socket to be writable again
+				return false
+			default:
+				serr = errno
+				return true
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return sent, t.closedErr(err)
+	}
+	if serr != nil {
+		return sent, errors.Wrap(serr, "tuntap: Can't sendmmsg")
+	}
+	return sent, nil
+}
+
+// recvmmsg waits for a packet, then reads it and the packets queued behind
+// it with one recvmmsg(2).
+func (t *Interface) recvmmsg(bufs [][]byte, pkts []Packet) ([]Packet, error) {
+	iovs := make([]unix.Iovec, len(bufs))
+	msgs := make([]mmsghdr, len(bufs))
+	for i := range bufs {
+		if len(bufs[i]) != 0 {
+			iovs[i].Base = &bufs[i][0]
+			iovs[i].SetLen(len(bufs[i]))
+		}
+		msgs[i].hdr.Iov = &iovs[i]
+		msgs[i].hdr.SetIovlen(1)
+	}
+
+	rc, err := t.file.SyscallConn()
+	if err != nil {
+		return pkts, t.closedErr(err)
+	}
+	got := 0
+	var rerr error
+	err = rc.Read(func(fd uintptr) bool {
+		for {
+			// MSG_WAITFORONE: don't wait for more once there's one
+			n, _, errno := unix.Syscall6(unix.SYS_RECVMMSG, fd, uintptr(unsafe.Pointer(&msgs[0])), uintptr(len(msgs)), unix.MSG_WAITFORONE, 0, 0)
+			switch errno {
+			case 0:
+				got = int(n)
+				return true
+			case unix.EINTR:
+			case unix.EAGAIN:
+				// wait for the socket to be readable
+				return false
+			default:
+				rerr = errno
+				return true
+			}
+		}
+	})
+	if err != nil {
+		return pkts, t.closedErr(err)
+	}
+	if rerr != nil {
+		return pkts, errors.Wrap(rerr, "tuntap: Can't recvmmsg")
+	}
+	for i := 0; i < got; i++ {
+		if pkt, err := t.parse(bufs[i], int(msgs[i].len)); err == nil {
+			pkts = append(pkts, pkt)
+		}
+	}
+	return pkts, nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,114 @@
+//go:build linux || freebsd || darwin || openbsd || netbsd
+
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+
+// readNow reads into buffer the next packet if there is one queued, without
+// waiting for one. It returns false if there was none.
+func (t *Interface) readNow(buffer []byte) (int, bool, error) {
+	rc, err := t.file.SyscallConn()
+	if err != nil {
+		return 0, false, t.closedErr(err)
+	}
+	var n int
+	var rerr error
+	timed := t.latency.readDue()
+	err = rc.Read(func(fd uintptr) bool {
+		var start time.Time
+		if timed {
+			start = time.Now()
+		}
+		n, rerr = unix.Read(int(fd), buffer)
+		if timed && rerr != unix.EAGAIN {
+			t.latency.read.since(start)
+		}
+		// done either way; returning false would wait for the fd to be readable
+		return true
+	})
+	if err == nil {
+		err = rerr
+	}
+	if err == unix.EAGAIN {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, t.closedErr(err)
+	}
+	return n, true, nil
+}
+
+// readTimed reads a packet into buffer like os.File.Read, timing the
+// read(2) which gets it.
+func (t *Interface) readTimed(buffer []byte) (int, error) {
+	rc, err := t.file.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	var rerr error
+	err = rc.Read(func(fd uintptr) bool {
+		for {
+			start := time.Now()
+			n, rerr = unix.Read(int(fd), buffer)
+			switch rerr {
+			case unix.EINTR:
+				continue
+			case unix.EAGAIN:
+				// wait for the fd to be readable
+				return false
+			}
+			t.latency.read.since(start)
+			return true
+		}
+	})
+	if err == nil {
+		err = rerr
+	}
+	return n, err
+}
+
+// writeTimed writes b like os.File.Write, timing the write(2).
+func (t *Interface) writeTimed(b []byte) (int, error) {
+	rc, err := t.file.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	var werr error
+	err = rc.Write(func(fd uintptr) bool {
+		for {
+			start := time.Now()
+			n, werr = unix.Write(int(fd), b)
+			switch werr {
+			case unix.EINTR:
+				continue
+			case unix.EAGAIN:
+				// wait for the fd to be writable again
+				return false
+			}
+			t.latency.write.since(start)
+			return true
+		}
+	})
+	if err == nil {
+		err = werr
+	}
+	return n, err
+}
+
+//-----------------------------------------------------------------------------
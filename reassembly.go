@@ -0,0 +1,326 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// Reassembly of IP fragments, for the layers which look at the transport
+// headers (flows, filters, NAT...): only the first fragment of a datagram
+// has them. A Reassembler holds on to the fragments until their datagram is
+// complete, and then hands over the datagram in one Packet, with no fragment
+// header, as the destination would see it.
+//
+// Fragments which overlap are a way to slip data past inspection, and
+// reassemble differently on different hosts, so like Linux the Reassembler
+// drops their datagram (as RFC 5722 requires for IPv6). Datagrams which
+// don't complete in time, or would take more memory than allowed, are
+// dropped, the oldest first.
+
+var ErrReassembly = errors.New("inconsistent IP fragments")
+
+// ReassemblyConfig configures a Reassembler. Zero fields take the defaults.
+type ReassemblyConfig struct {
+	// how long the fragments of a datagram are kept for the datagram to
+	// complete, 30s by default (Linux's ipfrag_time)
+	Timeout time.Duration
+	// the bytes of fragments held at most, 4MB by default (Linux's
+	// ipfrag_high_thresh)
+	MaxBytes int
+}
+
+// ReassemblyStats counts what a Reassembler did.
+type ReassemblyStats struct {
+	// the datagrams being reassembled, and the bytes of their fragments
+	Pending, Bytes int
+	// the datagrams reassembled, and the ones dropped for timing out, for
+	// making room, and for inconsistent fragments
+	Reassembled, TimedOut, Evicted, Invalid uint64
+}
+
+// Reassembler reassembles the fragments of IP datagrams. It is safe for
+// concurrent use.
+type Reassembler struct {
+	cfg ReassemblyConfig
+
+	lock    sync.Mutex
+	pending map[fragKey]*list.Element // of *fragDatagram
+	order   list.List                 // of *fragDatagram, oldest first
+	stats   ReassemblyStats
+}
+
+// what identifies the fragments of a datagram
+type fragKey struct {
+	src, dst [16]byte
+	id       uint32
+	proto    uint8 // IPv4's protocol; IPv6 goes by the id alone
+	v6       bool
+}
+
+type fragDatagram struct {
+	key     fragKey
+	started time.Time
+	// the first fragment, whose headers the datagram gets, and where in its
+	// Body its fragmentable part starts, and the field (if IPv6) to set to
+	// the protocol of the payload
+	first      *Packet
+	data, next int
+	proto      uint8
+	// the payload received, in order of offset
+	frags []fragPiece
+	// the length of the payload, known once the last fragment is received,
+	// -1 until then
+	length int
+	bytes  int
+}
+
+type fragPiece struct {
+	ofs  int
+	data []byte
+}
+
+// NewReassembler returns a Reassembler holding no fragments.
+func NewReassembler(cfg ReassemblyConfig) *Reassembler {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 4 << 20
+	}
+	return &Reassembler{cfg: cfg, pending: make(map[fragKey]*list.Element)}
+}
+
+// fragInfo returns what identifies the datagram of the fragment pkt, the
+// offsets in Body of its fragmentable part and of the header field naming
+// the protocol of the payload (0 for IPv4), the protocol, the offset of
+// the fragment in the datagram, and whether more fragments follow; false if
+// pkt isn't a fragment.
+func fragInfo(pkt *Packet) (key fragKey, data, next int, proto uint8, ofs int, more, ok bool) {
+	b := pkt.ip()
+	switch {
+	case pkt.Protocol == ETH_P_IP && len(b) >= 20:
+		flags := binary.BigEndian.Uint16(b[6:])
+		ofs, more = int(flags&0x1fff)<<3, flags&0x2000 != 0
+		if ofs == 0 && !more {
+			return key, 0, 0, 0, 0, false, false
+		}
+		hl := int(b[0]&0xf) << 2
+		n := int(binary.BigEndian.Uint16(b[2:]))
+		if hl < 20 || n < hl || n > len(b) {
+			return key, 0, 0, 0, 0, false, false
+		}
+		copy(key.src[:], b[12:16])
+		copy(key.dst[:], b[16:20])
+		key.id, key.proto = uint32(binary.BigEndian.Uint16(b[4:])), b[9]
+		return key, pkt.L3Offset + hl, 0, b[9], ofs, more, true
+	case pkt.Protocol == ETH_P_IPV6 && len(b) >= 40:
+		next, at, field := b[6], 40, 6
+		for next == 0 || next == 43 || next == 60 {
+			if at+2 > len(b) {
+				return key, 0, 0, 0, 0, false, false
+			}
+			field = at
+			next = b[at]
+			at += 8 + int(b[at+1])*8
+		}
+		// the headers must be within the payload length, not only the buffer
+		if n := 40 + int(binary.BigEndian.Uint16(b[4:])); next != 44 || at+8 > n || n > len(b) {
+			return key, 0, 0, 0, 0, false, false
+		}
+		fl := binary.BigEndian.Uint16(b[at+2:])
+		copy(key.src[:], b[8:24])
+		copy(key.dst[:], b[24:40])
+		key.id, key.v6 = binary.BigEndian.Uint32(b[at+4:]), true
+		return key, pkt.L3Offset + at + 8, pkt.L3Offset + field, b[at], int(fl &^ 7), fl&1 != 0, true
+	}
+	return key, 0, 0, 0, 0, false, false
+}
+
+// Add accounts pkt, and returns the packet to go on with, and true: pkt
+// itself if it isn't a fragment, or the datagram it completes, in new
+// memory. It returns false while the datagram isn't complete, and
+// ErrReassembly for the fragments of a datagram which can't be, which is
+// dropped. The fragments are copied, so pkt's memory may be reused.
+func (r *Reassembler) Add(pkt Packet) (Packet, bool, error) {
+	key, data, next, proto, ofs, more, ok := fragInfo(&pkt)
+	if !ok {
+		return pkt, true, nil
+	}
+	end := pkt.L3Offset + 40 + int(binary.BigEndian.Uint16(pkt.Body[pkt.L3Offset+4:]))
+	if pkt.Protocol == ETH_P_IP {
+		end = pkt.L3Offset + int(binary.BigEndian.Uint16(pkt.Body[pkt.L3Offset+2:]))
+	}
+	if data > end {
+		return Packet{}, false, ErrReassembly
+	}
+	b := pkt.Body[data:end]
+
+	now := time.Now()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.expire(now)
+
+	e := r.pending[key]
+	if e == nil {
+		e = r.order.PushBack(&fragDatagram{key: key, started: now, length: -1})
+		r.pending[key] = e
+	}
+	d := e.Value.(*fragDatagram)
+	held := d.bytes
+	if pkt.Truncated || ofs+len(b) > 0xffff || (more && len(b)%8 != 0) || !d.add(ofs, b, more) {
+		r.drop(e)
+		r.stats.Invalid++
+		return Packet{}, false, ErrReassembly
+	}
+	if ofs == 0 && d.first == nil {
+		first := pkt
+		first.Body = append([]byte(nil), pkt.Body[:data]...)
+		d.first, d.data, d.next, d.proto = &first, data, next, proto
+		d.bytes += data
+	}
+	r.stats.Bytes += d.bytes - held
+	for r.stats.Bytes > r.cfg.MaxBytes && r.order.Len() != 0 {
+		oldest := r.order.Front()
+		r.drop(oldest)
+		r.stats.Evicted++
+		if oldest == e {
+			return Packet{}, false, nil
+		}
+	}
+	if !d.complete() {
+		return Packet{}, false, nil
+	}
+	r.drop(e)
+	r.stats.Reassembled++
+	return d.datagram(), true, nil
+}
+
+// add adds the payload b at ofs, and returns false if it overlaps what's
+// been received but isn't a duplicate of it, or is past the end.
+func (d *fragDatagram) add(ofs int, b []byte, more bool) bool {
+	end := ofs + len(b)
+	if !more {
+		if d.length >= 0 && d.length != end {
+			return false
+		}
+		if n := len(d.frags); n != 0 && d.frags[n-1].ofs+len(d.frags[n-1].data) > end {
+			return false
+		}
+		d.length = end
+	}
+	if d.length >= 0 && end > d.length {
+		return false
+	}
+	i := sort.Search(len(d.frags), func(i int) bool { return d.frags[i].ofs >= ofs })
+	if i < len(d.frags) && d.frags[i].ofs == ofs && len(d.frags[i].data) == len(b) {
+		// a duplicate; RFC 5722 lets it be ignored
+		return true
+	}
+	if i > 0 && d.frags[i-1].ofs+len(d.frags[i-1].data) > ofs {
+		return false
+	}
+	if i < len(d.frags) && d.frags[i].ofs < end {
+		return false
+	}
+	d.frags = append(d.frags, fragPiece{})
+	copy(d.frags[i+1:], d.frags[i:])
+	d.frags[i] = fragPiece{ofs: ofs, data: append([]byte(nil), b...)}
+	d.bytes += len(b)
+	return true
+}
+
+// complete returns whether all of the datagram has been received.
+func (d *fragDatagram) complete() bool {
+	if d.first == nil || d.length < 0 {
+		return false
+	}
+	at := 0
+	for _, f := range d.frags {
+		if f.ofs != at {
+			return false
+		}
+		at += len(f.data)
+	}
+	return at == d.length
+}
+
+// datagram returns the reassembled datagram.
+func (d *fragDatagram) datagram() Packet {
+	pkt := *d.first
+	body := make([]byte, len(pkt.Body), len(pkt.Body)+d.length)
+	copy(body, pkt.Body)
+	for _, f := range d.frags {
+		body = append(body, f.data...)
+	}
+	pkt.Body = body
+	h := body[pkt.L3Offset:]
+	if pkt.Protocol == ETH_P_IP {
+		binary.BigEndian.PutUint16(h[2:], uint16(len(h)))
+		// no more MF and offset, DF as it was
+		h[6] &= 0x40
+		h[7] = 0
+		h[10], h[11] = 0, 0
+		binary.BigEndian.PutUint16(h[10:], checksum(h[:int(h[0]&0xf)<<2]))
+	} else {
+		// the fragment header goes, and the header before it names the
+		// payload instead
+		hdr := d.data - 8
+		body[d.next] = d.proto
+		pkt.Body = append(body[:hdr], body[d.data:]...)
+		h = pkt.Body[pkt.L3Offset:]
+		binary.BigEndian.PutUint16(h[4:], uint16(len(h)-40))
+	}
+	return pkt
+}
+
+// drop forgets the datagram of e.
+func (r *Reassembler) drop(e *list.Element) {
+	d := e.Value.(*fragDatagram)
+	r.order.Remove(e)
+	delete(r.pending, d.key)
+	r.stats.Bytes -= d.bytes
+}
+
+// expire drops the datagrams which have been pending for longer than the
+// timeout.
+func (r *Reassembler) expire(now time.Time) {
+	for e := r.order.Front(); e != nil; e = r.order.Front() {
+		if now.Sub(e.Value.(*fragDatagram).started) < r.cfg.Timeout {
+			return
+		}
+		r.drop(e)
+		r.stats.TimedOut++
+	}
+}
+
+// Expire drops the datagrams which have timed out. Add does it as it goes;
+// Expire frees their memory when no fragments come.
+func (r *Reassembler) Expire() {
+	r.lock.Lock()
+	r.expire(time.Now())
+	r.lock.Unlock()
+}
+
+// Stats returns what the Reassembler did so far.
+func (r *Reassembler) Stats() ReassemblyStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	s := r.stats
+	s.Pending = r.order.Len()
+	return s
+}
+
+//-----------------------------------------------------------------------------
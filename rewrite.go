@@ -0,0 +1,217 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"net/netip"
+)
+
+//-----------------------------------------------------------------------------
+// In-place header rewrites, for NAT and load balancing in userspace. The
+// Set methods patch the IP and transport headers in Body, and update the
+// checksums covering the bytes changed incrementally (RFC 1624), so the
+// cost doesn't depend on the size of the packet: the IPv4 header checksum,
+// and the TCP, UDP, UDP-Lite and ICMPv6 checksums, whose pseudo-header has
+// the addresses. SCTP's CRC32c has to be computed over the whole packet
+// again, which only the port rewrites need.
+//
+// A packet read WithVnetHdr with VnetHdrNeedsCsum has a partial transport
+// checksum, the sum of the pseudo-header, which the address rewrites update
+// as such. Fragments other than the first have no transport header: their
+// ports can't be set, and setting their addresses only updates the IP
+// header, it being up to the NAT to rewrite the first fragment the same way.
+
+var ErrRewrite = errors.New("packet header can't be rewritten that way")
+
+// csumUpdate returns the checksum sum updated for the 16-bit aligned bytes
+// old becoming new (of the same length), as RFC 1624 eqn. 3 does. With
+// partial, sum is an uncomplemented sum, as a partial checksum is.
+func csumUpdate(sum uint16, old, new []byte, partial bool) uint16 {
+	s := uint32(^sum)
+	if partial {
+		s = uint32(sum)
+	}
+	for i := 0; i+1 < len(old); i += 2 {
+		s += uint32(^binary.BigEndian.Uint16(old[i:]))
+		s += uint32(binary.BigEndian.Uint16(new[i:]))
+	}
+	for s>>16 != 0 {
+		s = s&0xffff + s>>16
+	}
+	if partial {
+		return uint16(s)
+	}
+	return ^uint16(s)
+}
+
+// transportCsum returns the offset in Body of the checksum of the transport
+// header, if the protocol's covers the pseudo-header and the packet has
+// one; whether it's a UDP checksum, for which 0 is none; and whether it's
+// partial.
+func (p *Packet) transportCsum() (int, bool, bool) {
+	proto, at, frag := p.IPProto()
+	if at == 0 || frag {
+		return 0, false, false
+	}
+	ofs, udp := 0, false
+	switch proto {
+	case 6: // TCP
+		ofs = at + 16
+	case 17: // UDP
+		ofs, udp = at+6, p.Protocol == ETH_P_IP
+	case 136: // UDP-Lite
+		ofs = at + 6
+	case 58: // ICMPv6
+		ofs = at + 2
+	default:
+		return 0, false, false
+	}
+	if ofs+2 > len(p.Body) {
+		return 0, false, false
+	}
+	return ofs, udp, p.Vnet.Flags&VnetHdrNeedsCsum != 0
+}
+
+// rewrite replaces the bytes of Body at ofs with new, and updates the IPv4
+// header checksum if the bytes are in it (ip4), and the transport checksum
+// if pseudo.
+func (p *Packet) rewrite(ofs int, new []byte, ip4, pseudo bool) {
+	old := p.Body[ofs : ofs+len(new)]
+	if pseudo {
+		if at, udp, partial := p.transportCsum(); at != 0 {
+			sum := binary.BigEndian.Uint16(p.Body[at:])
+			if !udp || sum != 0 {
+				sum = csumUpdate(sum, old, new, partial)
+				if udp && sum == 0 {
+					sum = 0xffff
+				}
+				binary.BigEndian.PutUint16(p.Body[at:], sum)
+			}
+		}
+	}
+	if ip4 {
+		at := p.L3Offset + 10
+		binary.BigEndian.PutUint16(p.Body[at:], csumUpdate(binary.BigEndian.Uint16(p.Body[at:]), old, new, false))
+	}
+	copy(old, new)
+}
+
+// setAddr sets the address at at4 in an IPv4 header, at at6 in an IPv6 one.
+func (p *Packet) setAddr(a netip.Addr, at4, at6 int) error {
+	b := p.ip()
+	a = a.Unmap()
+	switch {
+	case p.Protocol == ETH_P_IP && len(b) >= 20 && a.Is4():
+		a4 := a.As4()
+		p.rewrite(p.L3Offset+at4, a4[:], true, true)
+	case p.Protocol == ETH_P_IPV6 && len(b) >= 40 && a.Is6():
+		a16 := a.As16()
+		p.rewrite(p.L3Offset+at6, a16[:], false, true)
+	default:
+		return ErrRewrite
+	}
+	return nil
+}
+
+// SetSIP sets the source address of an IP packet, which must be of the same
+// family.
+func (p *Packet) SetSIP(a netip.Addr) error {
+	return p.setAddr(a, 12, 8)
+}
+
+// SetDIP sets the destination address of an IP packet, which must be of the
+// same family.
+func (p *Packet) SetDIP(a netip.Addr) error {
+	return p.setAddr(a, 16, 24)
+}
+
+// setPort sets the port at ofs in the header of a TCP, UDP, UDP-Lite or SCTP
+// segment.
+func (p *Packet) setPort(port uint16, ofs int) error {
+	proto, at, frag := p.IPProto()
+	if at == 0 || frag || at+4 > len(p.Body) {
+		return ErrRewrite
+	}
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], port)
+	switch proto {
+	case 6, 17, 136: // TCP, UDP, UDP-Lite
+		if p.Vnet.Flags&VnetHdrNeedsCsum != 0 {
+			// the ports are left to the checksum offload
+			copy(p.Body[at+ofs:], b[:])
+			return nil
+		}
+		p.rewrite(at+ofs, b[:], false, true)
+	case 132: // SCTP
+		// the IP length must cover the SCTP common header
+		if at+12 > p.ipEnd() || p.Truncated {
+			return ErrRewrite
+		}
+		copy(p.Body[at+ofs:], b[:])
+		p.sctpCsum(at)
+	default:
+		return ErrRewrite
+	}
+	return nil
+}
+
+// ipEnd returns the offset in Body of the end of the IP packet, going by
+// its length field, but within Body.
+func (p *Packet) ipEnd() int {
+	end := len(p.Body)
+	if p.Protocol == ETH_P_IP {
+		if l := p.L3Offset + int(binary.BigEndian.Uint16(p.Body[p.L3Offset+2:])); l < end {
+			end = l
+		}
+	} else if l := p.L3Offset + 40 + int(binary.BigEndian.Uint16(p.Body[p.L3Offset+4:])); l < end {
+		end = l
+	}
+	return end
+}
+
+// sctpCsum computes the CRC32c of the SCTP packet at at again. setPort has
+// checked that the IP packet covers its common header.
+func (p *Packet) sctpCsum(at int) {
+	sctp := p.Body[at:p.ipEnd()]
+	binary.LittleEndian.PutUint32(sctp[8:12], 0)
+	binary.LittleEndian.PutUint32(sctp[8:12], crc32.Checksum(sctp, castagnoli))
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// SetSrcPort sets the source port of a TCP, UDP, UDP-Lite or SCTP packet.
+func (p *Packet) SetSrcPort(port uint16) error {
+	return p.setPort(port, 0)
+}
+
+// SetDstPort sets the destination port of a TCP, UDP, UDP-Lite or SCTP
+// packet.
+func (p *Packet) SetDstPort(port uint16) error {
+	return p.setPort(port, 2)
+}
+
+// SetTTL sets the TTL of an IPv4 packet, or the hop limit of an IPv6 one.
+func (p *Packet) SetTTL(ttl uint8) error {
+	b := p.ip()
+	switch {
+	case p.Protocol == ETH_P_IP && len(b) >= 20:
+		// the checksum goes by 16-bit words: the TTL and the protocol
+		p.rewrite(p.L3Offset+8, []byte{ttl, b[9]}, true, false)
+	case p.Protocol == ETH_P_IPV6 && len(b) >= 40:
+		b[7] = ttl
+	default:
+		return ErrRewrite
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,255 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+)
+
+//-----------------------------------------------------------------------------
+// The recommended way to consume packets at high rates is to dedicate one
+// goroutine to reading the Interface, and hand the packets to the
+// application's goroutine through a PacketRing:
+//
+//	ring := tuntap.NewPacketRing(1024)
+//	go t.ReadToRing(ring)
+//	for {
+//		pkt, ok := ring.Pop()
+//		if !ok {
+//			break // the Interface was closed or failed
+//		}
+//		handle(pkt.Packet)
+//		pkt.Release()
+//	}
+//
+// The reading goroutine then does nothing but syscalls, and the ring itself
+// takes no locks. A side waiting on the ring first spins briefly, which is
+// cheaper than parking when the other side is only a few hundred nanoseconds
+// behind, and then parks on a channel so an idle ring costs no CPU.
+
+// how many times a side polls the ring before parking
+const ringSpins = 64
+
+// PacketRing is a bounded single-producer, single-consumer queue of
+// LeasedPackets. Push must only be called from one goroutine, and Pop from
+// one (other) goroutine.
+type PacketRing struct {
+	slots []unsafe.Pointer // *LeasedPacket
+	mask  uint64
+
+	// head is written by the consumer, and by a DropOldest producer, which
+	// is why it's advanced with CompareAndSwap. tail is only written by the
+	// producer. They're kept on separate cache lines so the two sides don't
+	// contend.
+	head atomic.Uint64
+	_    [56]byte
+	tail atomic.Uint64
+	_    [56]byte
+
+	closed atomic.Bool
+	// set by a side about to park, and the channel it parks on
+	consumerParked atomic.Bool
+	notEmpty       chan struct{}
+	producerParked atomic.Bool
+	notFull        chan struct{}
+
+	budget *MemoryBudget
+	policy DropPolicy
+	drops  atomic.Uint64
+}
+
+// NewPacketRing returns a ring holding up to size packets, rounded up to a
+// power of two. When it's full, Push waits for the consumer.
+func NewPacketRing(size int) *PacketRing {
+	n := 1
+	for n < size {
+		n <<= 1
+	}
+	return &PacketRing{
+		slots:    make([]unsafe.Pointer, n),
+		mask:     uint64(n - 1),
+		notEmpty: make(chan struct{}, 1),
+		notFull:  make(chan struct{}, 1),
+	}
+}
+
+// SetLimits makes the packets in the ring count against budget (which may be
+// nil for no limit beyond the ring's size), and sets what Push does when a
+// packet doesn't fit. It must be called before the ring is used.
+func (r *PacketRing) SetLimits(budget *MemoryBudget, policy DropPolicy) {
+	r.budget = budget
+	r.policy = policy
+}
+
+// Drops returns the number of packets the ring has dropped.
+func (r *PacketRing) Drops() uint64 {
+	return r.drops.Load()
+}
+
+// Len returns the number of packets in the ring.
+func (r *PacketRing) Len() int {
+	return int(r.tail.Load() - r.head.Load())
+}
+
+// Push hands pkt to the ring, which either queues it or, depending on its
+// DropPolicy, drops and releases it. It returns false, leaving pkt to the
+// caller, if the ring is closed.
+func (r *PacketRing) Push(pkt *LeasedPacket) bool {
+	if r.closed.Load() {
+		return false
+	}
+	cost := pkt.footprint()
+	if !r.budget.reserve(cost) && !(r.policy == DropOldest && r.makeRoom(cost)) {
+		r.drop(pkt)
+		return true
+	}
+
+	tail := r.tail.Load()
+	for spins := 0; tail-r.head.Load() == uint64(len(r.slots)); spins++ {
+		if r.closed.Load() {
+			r.budget.release(cost)
+			return false
+		}
+		switch r.policy {
+		case DropTail:
+			r.budget.release(cost)
+			r.drop(pkt)
+			return true
+		case DropOldest:
+			r.dropOldest()
+			continue
+		}
+		if spins < ringSpins {
+			runtime.Gosched()
+			continue
+		}
+		// announce we're parking, then look again in case the consumer made
+		// room before it could have seen the announcement
+		r.producerParked.Store(true)
+		if tail-r.head.Load() == uint64(len(r.slots)) && !r.closed.Load() {
+			<-r.notFull
+		}
+		r.producerParked.Store(false)
+	}
+	atomic.StorePointer(&r.slots[tail&r.mask], unsafe.Pointer(pkt))
+	r.tail.Store(tail + 1)
+	if r.consumerParked.CompareAndSwap(true, false) {
+		wake(r.notEmpty)
+	}
+	return true
+}
+
+// makeRoom drops the oldest packets until cost bytes could be reserved from
+// the budget. It returns false if the ring emptied first.
+func (r *PacketRing) makeRoom(cost int) bool {
+	for r.dropOldest() {
+		if r.budget.reserve(cost) {
+			return true
+		}
+	}
+	return false
+}
+
+// dropOldest drops the packet at the head of the ring, unless the consumer
+// gets it first. It returns false if the ring was empty.
+func (r *PacketRing) dropOldest() bool {
+	head := r.head.Load()
+	if head == r.tail.Load() {
+		return false
+	}
+	pkt := (*LeasedPacket)(atomic.LoadPointer(&r.slots[head&r.mask]))
+	if r.head.CompareAndSwap(head, head+1) {
+		r.budget.release(pkt.footprint())
+		r.drop(pkt)
+	}
+	return true
+}
+
+func (r *PacketRing) drop(pkt *LeasedPacket) {
+	r.drops.Add(1)
+	pkt.Release()
+}
+
+// Pop removes the oldest packet from the ring, waiting while the ring is
+// empty. It returns false once the ring is closed and empty.
+func (r *PacketRing) Pop() (*LeasedPacket, bool) {
+	for spins := 0; ; spins++ {
+		head := r.head.Load()
+		if r.tail.Load() == head {
+			if r.closed.Load() {
+				// a last Push may have raced with Close
+				if r.tail.Load() == head {
+					return nil, false
+				}
+				continue
+			}
+			if spins < ringSpins {
+				runtime.Gosched()
+				continue
+			}
+			r.consumerParked.Store(true)
+			if r.tail.Load() == head && !r.closed.Load() {
+				<-r.notEmpty
+			}
+			r.consumerParked.Store(false)
+			continue
+		}
+		// the slot isn't cleared: once head moves on, the producer may
+		// already be reusing it
+		pkt := (*LeasedPacket)(atomic.LoadPointer(&r.slots[head&r.mask]))
+		if !r.head.CompareAndSwap(head, head+1) {
+			// a DropOldest producer dropped it under our feet
+			continue
+		}
+		r.budget.release(pkt.footprint())
+		if r.producerParked.CompareAndSwap(true, false) {
+			wake(r.notFull)
+		}
+		return pkt, true
+	}
+}
+
+// Close marks the ring closed, waking both sides. The consumer can still Pop
+// the packets left in the ring.
+func (r *PacketRing) Close() {
+	r.closed.Store(true)
+	wake(r.notEmpty)
+	wake(r.notFull)
+}
+
+// wake unparks the goroutine waiting on c, if any. A wakeup nobody takes is
+// left in c, and its only effect is that the next wait on c returns at once
+// and checks the ring again.
+func wake(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// ReadToRing reads packets from the Interface and pushes them onto r until
+// either fails, then closes r and returns the error (nil if r was closed).
+// It's meant to be the body of the dedicated reading goroutine.
+func (t *Interface) ReadToRing(r *PacketRing) error {
+	defer r.Close()
+	for {
+		pkt, err := t.ReadLeasedPacket()
+		if err != nil {
+			return err
+		}
+		if !r.Push(pkt) {
+			pkt.Release()
+			return nil
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
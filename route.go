@@ -0,0 +1,104 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"fmt"
+	"net"
+)
+
+//-----------------------------------------------------------------------------
+// Routes through the interface. Most tunnels need some once the addresses
+// are set: the subnets behind the far end, a default route through the
+// tunnel... AddRoute and DelRoute install and remove them (with netlink on
+// Linux, the routing socket on FreeBSD), and Routes lists those going out
+// of the interface, including the ones the kernel adds for the subnets of
+// the addresses.
+//
+// The routes AddRoute installs are of the package's own routing protocol,
+// RouteProtocol (ip route's "proto"), so that they can be told from the
+// others', and removed selectively with FlushRoutes, after a crash say. On
+// Linux, they can go to other tables than the main one, for policy routing.
+
+// Route is a route through the interface.
+type Route struct {
+	Dst *net.IPNet
+	// the next hop, nil for destinations on the link (always the case on
+	// DevTun interfaces, which have no neighbours)
+	Gateway net.IP
+	// on Linux, the priority of the route among those to the same
+	// destination, lower first; on FreeBSD, the weight of the route among
+	// multipath ones, its share of the traffic. 0 is the default.
+	Metric int
+	// the preferred source address of the traffic the host originates
+	// along the route (RTA_PREFSRC on Linux, the interface address RTA_IFA
+	// on FreeBSD), nil for the kernel's choice
+	Src net.IP
+	// the routing table of the route: on Linux, the table ID; on FreeBSD,
+	// the FIB. 0 is the main table (254 on Linux), or the default FIB.
+	Table int
+	// the routing protocol which installed the route, as Linux has it;
+	// AddRoute takes 0 for RouteProtocol, and DelRoute for any. On FreeBSD,
+	// where routes have no protocol, only RouteProtocol is known, as the
+	// RTF_PROTO1 flag.
+	Protocol int
+}
+
+// RouteProtocol is the routing protocol of the routes the package installs,
+// unassigned in Linux's rt_protos.
+const RouteProtocol = 116
+
+func (r Route) String() string {
+	s := r.Dst.String()
+	if r.Gateway != nil {
+		s += " via " + r.Gateway.String()
+	}
+	if r.Src != nil {
+		s += " src " + r.Src.String()
+	}
+	if r.Metric != 0 {
+		s += fmt.Sprintf(" metric %d", r.Metric)
+	}
+	if r.Table != 0 {
+		s += fmt.Sprintf(" table %d", r.Table)
+	}
+	if r.Protocol != 0 {
+		s += fmt.Sprintf(" proto %d", r.Protocol)
+	}
+	return s
+}
+
+// Routes returns the routes of the main table through the tunnel interface.
+func (t *Interface) Routes() ([]Route, error) {
+	return t.RoutesIn(0)
+}
+
+// FlushRoutes removes the routes of RouteProtocol through the tunnel
+// interface from the main table, and from tables. It goes on past the
+// routes it fails to remove, and returns the first error.
+func (t *Interface) FlushRoutes(tables ...int) error {
+	var first error
+	for _, table := range append([]int{0}, tables...) {
+		routes, err := t.RoutesIn(table)
+		if err != nil {
+			return err
+		}
+		for _, r := range routes {
+			if r.Protocol != RouteProtocol {
+				continue
+			}
+			if err := t.DelRoute(r); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,285 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"net"
+	"path"
+	"reflect"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+// Routes with the routing socket: a struct rt_msghdr followed by the
+// sockaddrs its rtm_addrs says, each padded to a multiple of sizeof(long)
+// (SA_SIZE in net/route.h).
+
+const sizeofLong = int(unsafe.Sizeof(uintptr(0)))
+
+// saSize returns the room a sockaddr of length l takes in a message.
+func saSize(l int) int {
+	if l == 0 {
+		return sizeofLong
+	}
+	return 1 + ((l - 1) | (sizeofLong - 1))
+}
+
+// appendSockaddr appends ip (or a mask) as a sockaddr_in, or a sockaddr_in6
+// if v6.
+func appendSockaddr(b []byte, ip net.IP, v6 bool) []byte {
+	if !v6 {
+		sa := make([]byte, saSize(unix.SizeofSockaddrInet4))
+		sa[0], sa[1] = unix.SizeofSockaddrInet4, unix.AF_INET
+		copy(sa[4:8], ip.To4())
+		if len(ip) == net.IPv6len && ip.To4() == nil {
+			// a 16-byte IPv4 mask
+			copy(sa[4:8], ip[12:])
+		}
+		return append(b, sa...)
+	}
+	sa := make([]byte, saSize(unix.SizeofSockaddrInet6))
+	sa[0], sa[1] = unix.SizeofSockaddrInet6, unix.AF_INET6
+	copy(sa[8:24], ip.To16())
+	return append(b, sa...)
+}
+
+// appendSockaddrLink appends a sockaddr_dl of the interface of index.
+func appendSockaddrLink(b []byte, index int) []byte {
+	sa := make([]byte, saSize(unix.SizeofSockaddrDatalink))
+	sa[0], sa[1] = unix.SizeofSockaddrDatalink, unix.AF_LINK
+	nativeEndian.PutUint16(sa[2:4], uint16(index))
+	return append(b, sa...)
+}
+
+// route sends r through the interface to the routing socket as a message of
+// type typ.
+func (t *Interface) route(typ int, r Route) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	ifi, err := net.InterfaceByName(path.Base(t.Name()))
+	if err != nil {
+		return err
+	}
+	v6 := r.Dst.IP.To4() == nil
+	flags := unix.RTF_UP | unix.RTF_STATIC
+	if r.Protocol == 0 || r.Protocol == RouteProtocol {
+		// the mark of the package's routes
+		flags |= unix.RTF_PROTO1
+	}
+	addrs := unix.RTA_DST | unix.RTA_GATEWAY
+	sas := appendSockaddr(nil, r.Dst.IP, v6)
+	if r.Gateway != nil {
+		flags |= unix.RTF_GATEWAY
+		sas = appendSockaddr(sas, r.Gateway, v6)
+	} else {
+		sas = appendSockaddrLink(sas, ifi.Index)
+	}
+	if ones, bits := r.Dst.Mask.Size(); ones == bits {
+		flags |= unix.RTF_HOST
+	} else {
+		addrs |= unix.RTA_NETMASK
+		sas = appendSockaddr(sas, net.IP(r.Dst.Mask), v6)
+	}
+	if r.Src != nil {
+		addrs |= unix.RTA_IFA
+		sas = appendSockaddr(sas, r.Src, v6)
+	}
+
+	hdr := unix.RtMsghdr{
+		Version: unix.RTM_VERSION,
+		Type:    uint8(typ),
+		Index:   uint16(ifi.Index),
+		Flags:   int32(flags),
+		Addrs:   int32(addrs),
+		Seq:     1,
+	}
+	if r.Metric != 0 {
+		hdr.Inits = unix.RTV_WEIGHT
+		// rmx_weight is a u_long
+		reflect.ValueOf(&hdr.Rmx.Weight).Elem().SetUint(uint64(r.Metric))
+	}
+	msg := make([]byte, unix.SizeofRtMsghdr+len(sas))
+	hdr.Msglen = uint16(len(msg))
+	*(*unix.RtMsghdr)(unsafe.Pointer(&msg[0])) = hdr
+	copy(msg[unix.SizeofRtMsghdr:], sas)
+
+	fd, err := unix.Socket(unix.AF_ROUTE, unix.SOCK_RAW, unix.AF_UNSPEC)
+	if err != nil {
+		return errors.Wrap(err, "tuntap: Can't create routing socket")
+	}
+	defer unix.Close(fd)
+	if r.Table != 0 {
+		if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_SETFIB, r.Table); err != nil {
+			return errors.Wrapf(err, "tuntap: Can't use FIB %d", r.Table)
+		}
+	}
+	if _, err := unix.Write(fd, msg); err != nil {
+		return errors.Wrapf(err, "tuntap: Can't change route %s on %s", r, t.name)
+	}
+	return nil
+}
+
+// AddRoute adds a route through the tunnel interface.
+func (t *Interface) AddRoute(r Route) error {
+	if err := t.route(unix.RTM_ADD, r); err != nil {
+		return err
+	}
+	t.applied(t.routeEntry(r))
+	return nil
+}
+
+// DelRoute removes a route through the tunnel interface.
+func (t *Interface) DelRoute(r Route) error {
+	if err := t.route(unix.RTM_DELETE, r); err != nil {
+		return err
+	}
+	t.reverted(t.routeEntry(r))
+	return nil
+}
+
+// routeDump returns the routing table of the FIB fib, as
+// sysctl(NET_RT_DUMP) does: the routes as RTM_GET messages.
+func routeDump(fib int) ([]byte, error) {
+	mib := [7]int32{unix.CTL_NET, unix.AF_ROUTE, 0, 0, unix.NET_RT_DUMP, 0, int32(fib)}
+	sysctl := func(buf []byte, n *uintptr) error {
+		var p unsafe.Pointer
+		if len(buf) != 0 {
+			p = unsafe.Pointer(&buf[0])
+		}
+		_, _, errno := unix.Syscall6(unix.SYS___SYSCTL, uintptr(unsafe.Pointer(&mib[0])), uintptr(len(mib)), uintptr(p), uintptr(unsafe.Pointer(n)), 0, 0)
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	}
+	for {
+		var n uintptr
+		if err := sysctl(nil, &n); err != nil {
+			return nil, err
+		}
+		// room for routes added in the meantime
+		n += n / 8
+		buf := make([]byte, n)
+		err := sysctl(buf, &n)
+		if err == unix.ENOMEM {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+// sockaddrIP returns the address of the sockaddr sa, or of a mask of the
+// family of the destination, v6 or not, whose sockaddr may be cut short and
+// have no family. It's nil for a sockaddr_dl.
+func sockaddrIP(sa []byte, v6 bool, mask bool) net.IP {
+	l := int(sa[0])
+	if l > len(sa) {
+		l = len(sa)
+	}
+	if !mask {
+		switch sa[1] {
+		case unix.AF_INET:
+			v6 = false
+		case unix.AF_INET6:
+			v6 = true
+		default:
+			return nil
+		}
+	}
+	ofs, n := 4, net.IPv4len
+	if v6 {
+		ofs, n = 8, net.IPv6len
+	}
+	ip := make(net.IP, n)
+	if l > ofs {
+		copy(ip, sa[ofs:l])
+	}
+	if !mask && v6 && ip.IsLinkLocalUnicast() {
+		// the kernel embeds the scope in the address
+		ip[2], ip[3] = 0, 0
+	}
+	return ip
+}
+
+// RoutesIn returns the routes of the FIB table through the tunnel interface.
+func (t *Interface) RoutesIn(table int) ([]Route, error) {
+	if err := t.configurable(); err != nil {
+		return nil, err
+	}
+	ifi, err := net.InterfaceByName(path.Base(t.Name()))
+	if err != nil {
+		return nil, err
+	}
+	b, err := routeDump(table)
+	if err != nil {
+		return nil, errors.Wrap(err, "tuntap: Can't dump the routing table")
+	}
+	var routes []Route
+	for len(b) >= unix.SizeofRtMsghdr {
+		hdr := *(*unix.RtMsghdr)(unsafe.Pointer(&b[0]))
+		l := int(hdr.Msglen)
+		if l < unix.SizeofRtMsghdr || l > len(b) {
+			break
+		}
+		msg := b[unix.SizeofRtMsghdr:l]
+		b = b[l:]
+		if hdr.Version != unix.RTM_VERSION || int(hdr.Index) != ifi.Index {
+			continue
+		}
+
+		var sas [unix.RTAX_MAX][]byte
+		for i := 0; i < unix.RTAX_MAX && len(msg) > 0; i++ {
+			if hdr.Addrs&(1<<uint(i)) == 0 {
+				continue
+			}
+			n := saSize(int(msg[0]))
+			if n > len(msg) {
+				n = len(msg)
+			}
+			sas[i] = msg[:n]
+			msg = msg[n:]
+		}
+		if sas[unix.RTAX_DST] == nil {
+			continue
+		}
+		dst := sockaddrIP(sas[unix.RTAX_DST], false, false)
+		if dst == nil {
+			continue
+		}
+		v6 := len(dst) == net.IPv6len
+		r := Route{Dst: &net.IPNet{IP: dst, Mask: net.CIDRMask(len(dst)*8, len(dst)*8)}, Table: table}
+		if sa := sas[unix.RTAX_IFA]; sa != nil {
+			r.Src = sockaddrIP(sa, v6, false)
+		}
+		if hdr.Flags&unix.RTF_PROTO1 != 0 {
+			r.Protocol = RouteProtocol
+		}
+		if sa := sas[unix.RTAX_NETMASK]; sa != nil && hdr.Flags&unix.RTF_HOST == 0 {
+			r.Dst.Mask = net.IPMask(sockaddrIP(sa, v6, true))
+		}
+		if sa := sas[unix.RTAX_GATEWAY]; sa != nil && hdr.Flags&unix.RTF_GATEWAY != 0 {
+			r.Gateway = sockaddrIP(sa, v6, false)
+		}
+		if hdr.Rmx.Weight != 1 {
+			// 1 is the weight of routes added without one
+			r.Metric = int(hdr.Rmx.Weight)
+		}
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,109 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"net"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+
+// nlRoute returns r as a netlink route through link.
+func nlRoute(link netlink.Link, r Route) *netlink.Route {
+	nr := &netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Dst:       r.Dst,
+		Gw:        r.Gateway,
+		Src:       r.Src,
+		Priority:  r.Metric,
+		Table:     r.Table,
+		Protocol:  netlink.RouteProtocol(r.Protocol),
+	}
+	if r.Gateway == nil {
+		// as ip route does
+		nr.Scope = netlink.SCOPE_LINK
+	}
+	return nr
+}
+
+// AddRoute adds a route through the tunnel interface.
+func (t *Interface) AddRoute(r Route) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	link, err := netlink.LinkByName(t.Name())
+	if err != nil {
+		return err
+	}
+	if r.Protocol == 0 {
+		r.Protocol = RouteProtocol
+	}
+	if err := netlink.RouteAdd(nlRoute(link, r)); err != nil {
+		return err
+	}
+	t.applied(t.routeEntry(r))
+	return nil
+}
+
+// DelRoute removes a route through the tunnel interface.
+func (t *Interface) DelRoute(r Route) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	link, err := netlink.LinkByName(t.Name())
+	if err != nil {
+		return err
+	}
+	if err := netlink.RouteDel(nlRoute(link, r)); err != nil {
+		return err
+	}
+	t.reverted(t.routeEntry(r))
+	return nil
+}
+
+// RoutesIn returns the routes of table through the tunnel interface.
+func (t *Interface) RoutesIn(table int) ([]Route, error) {
+	if err := t.configurable(); err != nil {
+		return nil, err
+	}
+	link, err := netlink.LinkByName(t.Name())
+	if err != nil {
+		return nil, err
+	}
+	if table == 0 {
+		table = unix.RT_TABLE_MAIN
+	}
+	filter := &netlink.Route{LinkIndex: link.Attrs().Index, Table: table}
+	nrs, err := netlink.RouteListFiltered(netlink.FAMILY_ALL, filter, netlink.RT_FILTER_OIF|netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return nil, err
+	}
+	var routes []Route
+	for _, nr := range nrs {
+		if nr.Dst == nil {
+			// the default route, which netlink leaves out
+			bits := 32
+			if nr.Family == netlink.FAMILY_V6 {
+				bits = 128
+			}
+			nr.Dst = &net.IPNet{IP: make(net.IP, bits/8), Mask: net.CIDRMask(0, bits)}
+		}
+		r := Route{Dst: nr.Dst, Gateway: nr.Gw, Src: nr.Src, Metric: nr.Priority, Protocol: int(nr.Protocol)}
+		if nr.Table != unix.RT_TABLE_MAIN {
+			r.Table = nr.Table
+		}
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,301 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// Route exchange between the two ends of a site-to-site tunnel, so that each
+// routes the prefixes behind the other through the tunnel without anyone
+// configuring them. Like RIP, each end sends the prefixes behind it every
+// Interval and when they change, and drops the routes a peer stops
+// sending, or all of them when the peer falls silent for Timeout. Unlike
+// RIP, there are no metrics or hops to count: there's a single peer, and
+// each advertisement is all of its prefixes, which replaces the previous
+// one.
+//
+// The advertisements are the tunnel's business, as the probes of a
+// PMTUProber are: the application sends them to the peer with
+// RouteExchangeConfig.Send, and hands over those it receives to Received.
+// The routes are installed on the Interface with AddRoutePrefix.
+
+var ErrRouteAdvert = errors.New("invalid route advertisement")
+
+// the advertisements start with "TRX" and a version
+var routeAdvertMagic = [4]byte{'T', 'R', 'X', 1}
+
+// the flag of the last advertisement of an exchange, withdrawing its routes
+const routeAdvertGoodbye = 1
+
+// RouteExchangeConfig configures a RouteExchange. Zero fields take the
+// defaults.
+type RouteExchangeConfig struct {
+	// the prefixes behind this end, advertised to the peer
+	Local []netip.Prefix
+	// sends an advertisement to the peer; required
+	Send func(msg []byte) error
+	// if set, the next hop of the routes, for DevTap Interfaces; the routes
+	// are on the link by default
+	Gateway netip.Addr
+	// the peer's address on the underlay, which the tunnel's packets go to
+	Endpoint netip.Addr
+	// if set, says which of the prefixes the peer advertises to install;
+	// the prefixes of Local never are. By default, all are but default
+	// routes and the prefixes covering Endpoint, which would route the
+	// tunnel's own packets into it
+	Accept func(p netip.Prefix) bool
+	// how often to advertise, 30s by default as for RIP
+	Interval time.Duration
+	// how long the peer's routes last without an advertisement, 180s by
+	// default as for RIP
+	Timeout time.Duration
+	// called, if set, with the routes installed and removed
+	OnChange func(added, removed []netip.Prefix)
+}
+
+// RouteExchange advertises prefixes to the peer of a tunnel, and installs
+// the peer's. It is safe for concurrent use.
+type RouteExchange struct {
+	t   *Interface
+	cfg RouteExchangeConfig
+
+	lock      sync.Mutex
+	local     []netip.Prefix
+	seq       uint32
+	peerSeq   uint32
+	heard     bool // from the peer, since the last timeout
+	last      time.Time
+	installed map[netip.Prefix]bool
+
+	update chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewRouteExchange returns an exchange installing the peer's routes on t,
+// and starts advertising.
+func NewRouteExchange(t *Interface, cfg RouteExchangeConfig) *RouteExchange {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 180 * time.Second
+	}
+	x := &RouteExchange{
+		t:         t,
+		cfg:       cfg,
+		local:     append([]netip.Prefix(nil), cfg.Local...),
+		installed: make(map[netip.Prefix]bool),
+		update:    make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	goLabeled(t, "route exchange", x.run)
+	return x
+}
+
+// SetLocal replaces the prefixes advertised, and advertises them right
+// away.
+func (x *RouteExchange) SetLocal(prefixes []netip.Prefix) {
+	x.lock.Lock()
+	x.local = append([]netip.Prefix(nil), prefixes...)
+	x.lock.Unlock()
+	select {
+	case x.update <- struct{}{}:
+	default:
+	}
+}
+
+// Routes returns the peer's prefixes installed.
+func (x *RouteExchange) Routes() []netip.Prefix {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return sortedPrefixes(x.installed)
+}
+
+// Close stops advertising, tells the peer to drop the routes it installed,
+// and removes the peer's.
+func (x *RouteExchange) Close() {
+	x.once.Do(func() { close(x.stop) })
+	<-x.done
+}
+
+func (x *RouteExchange) run() {
+	defer close(x.done)
+	ticker := time.NewTicker(x.cfg.Interval)
+	defer ticker.Stop()
+	x.advertise(0)
+	for {
+		select {
+		case <-ticker.C:
+			x.expire()
+			x.advertise(0)
+		case <-x.update:
+			x.advertise(0)
+		case <-x.stop:
+			x.advertise(routeAdvertGoodbye)
+			x.replace(nil)
+			return
+		}
+	}
+}
+
+// advertise sends the local prefixes.
+func (x *RouteExchange) advertise(flags byte) {
+	x.lock.Lock()
+	x.seq++
+	msg := append([]byte(nil), routeAdvertMagic[:]...)
+	msg = append(msg, flags)
+	msg = binary.BigEndian.AppendUint32(msg, x.seq)
+	if flags&routeAdvertGoodbye == 0 {
+		for _, p := range x.local {
+			p, _, ok := canonical(p)
+			if !ok {
+				continue
+			}
+			a := p.Addr().AsSlice()
+			msg = append(msg, byte(len(a)), byte(p.Bits()))
+			msg = append(msg, a...)
+		}
+	}
+	x.lock.Unlock()
+	// a lost advertisement is made up for by the next
+	x.cfg.Send(msg)
+}
+
+// parseRouteAdvert returns the flags, sequence number and prefixes of the
+// advertisement msg.
+func parseRouteAdvert(msg []byte) (byte, uint32, []netip.Prefix, error) {
+	if len(msg) < 9 || [4]byte{msg[0], msg[1], msg[2], msg[3]} != routeAdvertMagic {
+		return 0, 0, nil, ErrRouteAdvert
+	}
+	flags, seq := msg[4], binary.BigEndian.Uint32(msg[5:])
+	var prefixes []netip.Prefix
+	// each prefix is the length of its address, its length and the address
+	for b := msg[9:]; len(b) != 0; {
+		n := int(b[0])
+		if n != 4 && n != 16 || len(b) < 2+n {
+			return 0, 0, nil, ErrRouteAdvert
+		}
+		a, _ := netip.AddrFromSlice(b[2 : 2+n])
+		p := netip.PrefixFrom(a, int(b[1]))
+		if !p.IsValid() {
+			return 0, 0, nil, ErrRouteAdvert
+		}
+		prefixes = append(prefixes, p.Masked())
+		b = b[2+n:]
+	}
+	return flags, seq, prefixes, nil
+}
+
+// Received handles an advertisement from the peer, installing its routes
+// and removing those it no longer advertises. Advertisements older than
+// the last one received are ignored.
+func (x *RouteExchange) Received(msg []byte) error {
+	flags, seq, prefixes, err := parseRouteAdvert(msg)
+	if err != nil {
+		return err
+	}
+	x.lock.Lock()
+	// the sequence numbers wrap around; the peer restarting starts over
+	if x.heard && int32(seq-x.peerSeq) <= 0 && seq > 1 {
+		x.lock.Unlock()
+		return nil
+	}
+	x.peerSeq, x.heard, x.last = seq, true, time.Now()
+	if flags&routeAdvertGoodbye != 0 {
+		x.heard = false
+		prefixes = nil
+	}
+	x.lock.Unlock()
+	x.replace(prefixes)
+	return nil
+}
+
+// expire removes the peer's routes if it fell silent.
+func (x *RouteExchange) expire() {
+	x.lock.Lock()
+	silent := x.heard && time.Since(x.last) > x.cfg.Timeout
+	if silent {
+		x.heard = false
+	}
+	x.lock.Unlock()
+	if silent {
+		x.replace(nil)
+	}
+}
+
+// replace makes prefixes the peer's routes installed.
+func (x *RouteExchange) replace(prefixes []netip.Prefix) {
+	x.lock.Lock()
+	want := make(map[netip.Prefix]bool)
+	for _, p := range prefixes {
+		if x.accept(p) {
+			want[p] = true
+		}
+	}
+	var added, removed []netip.Prefix
+	for _, p := range sortedPrefixes(x.installed) {
+		if !want[p] {
+			// gone already if the Interface is
+			x.t.DelRoutePrefix(p, x.cfg.Gateway)
+			delete(x.installed, p)
+			removed = append(removed, p)
+		}
+	}
+	for _, p := range sortedPrefixes(want) {
+		if !x.installed[p] && x.t.AddRoutePrefix(p, x.cfg.Gateway) == nil {
+			x.installed[p] = true
+			added = append(added, p)
+		}
+	}
+	x.lock.Unlock()
+	if x.cfg.OnChange != nil && (len(added) != 0 || len(removed) != 0) {
+		x.cfg.OnChange(added, removed)
+	}
+}
+
+// accept returns whether to install the peer's prefix p.
+func (x *RouteExchange) accept(p netip.Prefix) bool {
+	for _, l := range x.local {
+		if l, _, ok := canonical(l); ok && l == p {
+			return false
+		}
+	}
+	if x.cfg.Accept != nil {
+		return x.cfg.Accept(p)
+	}
+	return p.Bits() != 0 && !p.Contains(x.cfg.Endpoint.Unmap())
+}
+
+// sortedPrefixes returns the prefixes of m in order.
+func sortedPrefixes(m map[netip.Prefix]bool) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(m))
+	for p := range m {
+		prefixes = append(prefixes, p)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		a, b := prefixes[i], prefixes[j]
+		if c := a.Addr().Compare(b.Addr()); c != 0 {
+			return c < 0
+		}
+		return a.Bits() < b.Bits()
+	})
+	return prefixes
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,55 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"errors"
+)
+
+//-----------------------------------------------------------------------------
+// Sealing, for daemons running under a seccomp profile. Once an Interface is
+// configured, the data path only needs a handful of syscalls; the ioctls,
+// netlink sockets and /proc writes of the configuration methods aren't needed
+// any more. Seal makes the Interface refuse those methods, so nothing in the
+// process trips over a profile which only allows DataPathSyscalls (plus what
+// the Go runtime itself needs).
+
+var ErrSealed = errors.New("interface sealed; configuration is disabled")
+
+// Seal disables the configuration methods of the Interface (AddAddress,
+// SetMTU, Up, the IPv6 settings, GetAddrList and the platform specific
+// ones), which then return ErrSealed. Reading, writing and closing keep
+// working. There is no way to unseal an Interface.
+func (t *Interface) Seal() {
+	t.sealed.Store(true)
+}
+
+// configurable returns ErrSealed once the Interface is sealed, and ErrClosed
+// once it's closing, but to its OnClose hooks, which undo its configuration.
+func (t *Interface) configurable() error {
+	if t.sealed.Load() {
+		return ErrSealed
+	}
+	if t.state.Load() != stateOpen && !t.inOnClose.Load() {
+		return ErrClosed
+	}
+	return nil
+}
+
+// DataPathSyscalls returns the names of the syscalls the package makes on
+// this platform for reading, writing and closing a sealed Interface,
+// including those the Go runtime's poller makes on its behalf. A seccomp
+// profile needs them on top of the ones the runtime always needs (futex,
+// mmap, signals...). VhostUser, whose protocol keeps running after setup,
+// isn't covered.
+func DataPathSyscalls() []string {
+	return append([]string(nil), dataPathSyscalls...)
+}
+
+//-----------------------------------------------------------------------------
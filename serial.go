@@ -0,0 +1,307 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+//-----------------------------------------------------------------------------
+// Serial line framing. With WithSerialFraming, a DevTun Interface offers a
+// byte stream view (Serial) which carries its packets SLIP (RFC 1055) or PPP
+// in HDLC-like framing (RFC 1662) encoded, so the tun device can be connected
+// to a serial port, a pty or any other byte stream.
+//
+// Note this is only the framing. There is no LCP/IPCP negotiation, so the
+// far end must be configured to pass IP packets without it (for example
+// slattach(8) for SLIP, or a firmware PPP stack in a static mode).
+
+// SerialFraming selects how packets are framed in a byte stream.
+type SerialFraming int
+
+const (
+	SerialNone SerialFraming = iota
+	// RFC 1055 SLIP. IPv6 packets are recognized by their version field.
+	SerialSLIP
+	// PPP in HDLC-like framing, with the default ACCM (all control
+	// characters escaped) and 16-bit FCS. Decoding accepts address/control
+	// field and protocol field compression.
+	SerialPPP
+)
+
+var ErrNoSerialFraming = errors.New("interface not opened with serial framing")
+
+// WithSerialFraming enables the Serial view of a DevTun Interface, using the
+// given framing.
+func WithSerialFraming(f SerialFraming) Option {
+	return func(c *config) { c.serial = f }
+}
+
+const (
+	slipEnd    = 0xc0
+	slipEsc    = 0xdb
+	slipEscEnd = 0xdc
+	slipEscEsc = 0xdd
+
+	pppFlag    = 0x7e
+	pppEsc     = 0x7d
+	pppXor     = 0x20
+	pppAddress = 0xff
+	pppControl = 0x03
+	pppIPv4    = 0x0021
+	pppIPv6    = 0x0057
+
+	pppInitFCS = 0xffff
+	pppGoodFCS = 0xf0b8
+)
+
+// the RFC 1662 FCS-16 lookup table
+var fcs16Table [256]uint16
+
+func init() {
+	for b := 0; b < 256; b++ {
+		v := uint16(b)
+		for i := 0; i < 8; i++ {
+			if v&1 != 0 {
+				v = v>>1 ^ 0x8408
+			} else {
+				v >>= 1
+			}
+		}
+		fcs16Table[b] = v
+	}
+}
+
+func fcs16(fcs uint16, b []byte) uint16 {
+	for _, c := range b {
+		fcs = fcs>>8 ^ fcs16Table[byte(fcs)^c]
+	}
+	return fcs
+}
+
+// AppendSLIP appends the SLIP encoding of the IP packet pkt to dst and
+// returns the extended buffer. The frame starts with an END as well as
+// ending with one, which flushes any line noise at the receiver.
+func AppendSLIP(dst []byte, pkt []byte) []byte {
+	dst = append(dst, slipEnd)
+	for _, c := range pkt {
+		switch c {
+		case slipEnd:
+			dst = append(dst, slipEsc, slipEscEnd)
+		case slipEsc:
+			dst = append(dst, slipEsc, slipEscEsc)
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return append(dst, slipEnd)
+}
+
+func appendPPPEscaped(dst []byte, b []byte) []byte {
+	for _, c := range b {
+		if c < 0x20 || c == pppFlag || c == pppEsc {
+			dst = append(dst, pppEsc, c^pppXor)
+		} else {
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
+
+// AppendPPP appends the PPP HDLC-like framing of the IPv4 or IPv6 packet pkt
+// to dst and returns the extended buffer. Packets of other protocols are
+// ignored.
+func AppendPPP(dst []byte, pkt Packet) []byte {
+	var proto uint16
+	switch pkt.Protocol {
+	case ETH_P_IP:
+		proto = pppIPv4
+	case ETH_P_IPV6:
+		proto = pppIPv6
+	default:
+		return dst
+	}
+	hdr := [4]byte{pppAddress, pppControl, byte(proto >> 8), byte(proto)}
+	fcs := fcs16(fcs16(pppInitFCS, hdr[:]), pkt.Body) ^ 0xffff
+
+	dst = append(dst, pppFlag)
+	dst = appendPPPEscaped(dst, hdr[:])
+	dst = appendPPPEscaped(dst, pkt.Body)
+	dst = appendPPPEscaped(dst, []byte{byte(fcs), byte(fcs >> 8)})
+	return append(dst, pppFlag)
+}
+
+// SerialDecoder reassembles packets from a SLIP or PPP byte stream.
+type SerialDecoder struct {
+	framing SerialFraming
+	frame   []byte
+	esc     bool
+	long    bool // the frame outgrew serialMaxFrame
+	// counters of frames which were discarded
+	BadFrames   int // bad FCS, too short or too long, unknown escape
+	OtherFrames int // not IPv4 or IPv6 (e.g. LCP)
+}
+
+// NewSerialDecoder returns a decoder for the given framing.
+func NewSerialDecoder(f SerialFraming) *SerialDecoder {
+	return &SerialDecoder{framing: f}
+}
+
+// maximum frame we buffer before deciding the stream is garbage
+const serialMaxFrame = 65535 + 8
+
+// Feed decodes the bytes b, calling fn with each complete packet. The
+// Packet's Body is only valid during the call.
+func (d *SerialDecoder) Feed(b []byte, fn func(Packet)) {
+	end, esc := byte(slipEnd), byte(slipEsc)
+	if d.framing == SerialPPP {
+		end, esc = pppFlag, pppEsc
+	}
+	for _, c := range b {
+		switch {
+		case c == end:
+			if d.esc || d.long {
+				// an escape followed by the end of frame aborts the frame,
+				// and what's left of one too long is no packet
+				d.BadFrames++
+			} else if len(d.frame) != 0 {
+				d.frameDone(fn)
+			}
+			d.frame = d.frame[:0]
+			d.esc, d.long = false, false
+			continue
+		case d.esc:
+			d.esc = false
+			if d.framing == SerialPPP {
+				c ^= pppXor
+			} else if c == slipEscEnd {
+				c = slipEnd
+			} else if c == slipEscEsc {
+				c = slipEsc
+			}
+		case c == esc:
+			d.esc = true
+			continue
+		case d.framing == SerialPPP && c < 0x20:
+			// unescaped control characters were inserted by the link
+			continue
+		}
+		if len(d.frame) < serialMaxFrame {
+			d.frame = append(d.frame, c)
+		} else {
+			d.long = true
+		}
+	}
+}
+
+func (d *SerialDecoder) frameDone(fn func(Packet)) {
+	f := d.frame
+	if d.framing == SerialSLIP {
+		if p := ipProtocol(f); p != 0 {
+			fn(Packet{Body: f, Protocol: p, Dir: DirWrite})
+		} else {
+			d.OtherFrames++
+		}
+		return
+	}
+
+	if len(f) < 4 || fcs16(pppInitFCS, f) != pppGoodFCS {
+		d.BadFrames++
+		return
+	}
+	f = f[:len(f)-2]
+	if f[0] == pppAddress && f[1] == pppControl {
+		f = f[2:]
+	}
+	var proto uint16
+	if len(f) > 0 && f[0]&1 != 0 {
+		// compressed protocol field
+		proto = uint16(f[0])
+		f = f[1:]
+	} else if len(f) >= 2 {
+		proto = binary.BigEndian.Uint16(f[:2])
+		f = f[2:]
+	}
+	switch proto {
+	case pppIPv4:
+		fn(Packet{Body: f, Protocol: ETH_P_IP, Dir: DirWrite})
+	case pppIPv6:
+		fn(Packet{Body: f, Protocol: ETH_P_IPV6, Dir: DirWrite})
+	default:
+		d.OtherFrames++
+	}
+}
+
+// serialStream is the io.ReadWriter returned by Serial.
+type serialStream struct {
+	t       *Interface
+	dec     *SerialDecoder
+	pkt     []byte
+	out     []byte // the encoded frame,
+	pending []byte // and what's left of it to be read
+	err     error
+}
+
+// Serial returns a byte stream view of the Interface, which must have been
+// opened WithSerialFraming. Reading from it returns the packets read from
+// the device, framed; writing to it decodes the frames and writes the packets
+// to the device. It's meant to be connected to a serial link with two
+// io.Copy, one in each direction. Reads and writes may be concurrent, but
+// there must be only one reader and one writer.
+func (t *Interface) Serial() io.ReadWriter {
+	s := &serialStream{t: t, dec: NewSerialDecoder(t.serial), pkt: make([]byte, 65536)}
+	if t.serial == SerialNone {
+		s.err = ErrNoSerialFraming
+	}
+	return s
+}
+
+func (s *serialStream) Read(b []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	for len(s.pending) == 0 {
+		pkt, err := s.t.ReadPacket(s.pkt)
+		if err != nil {
+			return 0, err
+		}
+		if pkt.Truncated {
+			continue
+		}
+		if s.t.serial == SerialSLIP {
+			s.out = AppendSLIP(s.out[:0], pkt.Body)
+		} else {
+			s.out = AppendPPP(s.out[:0], pkt)
+		}
+		s.pending = s.out
+	}
+	n := copy(b, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+func (s *serialStream) Write(b []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	var err error
+	s.dec.Feed(b, func(pkt Packet) {
+		if e := s.t.WritePacket(pkt); e != nil && err == nil {
+			err = e
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,460 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// Soak testing. Soak pushes a high volume of generated traffic into one
+// Interface, from several goroutines, each writing packets one at a time and
+// in batches, and reads it back from another, checking that each packet
+// arrives once, intact and (for each sender) in order. Between the two sits
+// whatever is under test: an application forwarding from one OpenPipe pair
+// to another, say, or nothing, the two ends of one pair. It's meant for CI,
+// with the race detector, where a concurrency bug shows up as a packet lost,
+// duplicated, reordered or corrupted, or as a deadlock, which stalls the
+// traffic past the Timeout.
+//
+// The traffic is deterministic: the Seed picks each packet, its family, its
+// size and its bytes, as well as how each sender batches them, so that a
+// failure can be run again. Only the interleaving of the senders varies. The
+// packets are UDP, over IPv4 or IPv6 (in Ethernet frames for DevTap), from
+// 198.18.0.0/15 and 2001:db8::/32, with valid checksums, so that the packets
+// of the soak are told from others: those read which aren't the soak's are
+// counted and otherwise ignored.
+
+// SoakConfig configures a Soak. Zero fields take the defaults.
+type SoakConfig struct {
+	// the seed of the traffic
+	Seed int64
+	// how many packets to send, 1,000,000 by default
+	Packets int
+	// the goroutines writing them, 4 by default, and those reading them, 1
+	// by default
+	Senders, Readers int
+	// the largest packet (Packet.Body), at least the 76 bytes of the soak's
+	// headers; 1400 by default, or the sending Interface's MaxPacket if
+	// smaller
+	MaxSize int
+	// don't check the order of each sender's packets, for what under test
+	// may reorder them. With several Readers, it isn't checked either.
+	Unordered bool
+	// how long the traffic may stall before the packets still missing count
+	// as lost, 5s by default
+	Timeout time.Duration
+}
+
+// SoakResult is what a Soak got through.
+type SoakResult struct {
+	// the packets of the soak read, and their bytes
+	Packets, Bytes uint64
+	// the packets read which weren't the soak's
+	Stray uint64
+	// how long it took
+	Duration time.Duration
+}
+
+// SoakError is what a Soak found wrong with the traffic.
+type SoakError struct {
+	// the packet, as the number of its sender and its sequence number
+	Sender int
+	Seq    uint64
+	// what was wrong: "lost", "duplicated", "reordered" or "corrupted"
+	Problem string
+	// more of the same, for the packets lost
+	More int
+}
+
+func (e *SoakError) Error() string {
+	s := fmt.Sprintf("tuntap: soak packet %d of sender %d %s", e.Seq, e.Sender, e.Problem)
+	if e.More != 0 {
+		s += fmt.Sprintf(", and %d more", e.More)
+	}
+	return s
+}
+
+// Soak sends cfg.Packets packets to send, and reads them from recv, until
+// they're all read, the traffic stalls, or ctx is done. It returns a
+// *SoakError for the first packet found lost, duplicated, reordered or
+// corrupted, the first error writing or reading, or ctx's. When it fails,
+// the senders may still be blocked writing: closing send stops them.
+func Soak(ctx context.Context, send, recv *Interface, cfg SoakConfig) (SoakResult, error) {
+	if cfg.Packets <= 0 {
+		cfg.Packets = 1000000
+	}
+	if cfg.Senders <= 0 {
+		cfg.Senders = 4
+	}
+	if cfg.Readers <= 0 {
+		cfg.Readers = 1
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 1400
+		if n := send.MaxPacket(); n < cfg.MaxSize {
+			cfg.MaxSize = n
+		}
+	}
+	if cfg.MaxSize < soakMinSize {
+		cfg.MaxSize = soakMinSize
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	s := &soak{cfg: cfg, tap: send.Kind() == DevTap, ordered: !cfg.Unordered && cfg.Readers == 1}
+	s.seen = make([][]atomic.Uint64, cfg.Senders)
+	s.next = make([]uint64, cfg.Senders)
+	for i := range s.seen {
+		s.seen[i] = make([]atomic.Uint64, (s.count(i)+63)/64)
+	}
+	parent := ctx
+	ctx, s.cancel = context.WithCancel(ctx)
+	defer s.cancel()
+
+	start := time.Now()
+	var senders, readers sync.WaitGroup
+	for i := 0; i < cfg.Senders; i++ {
+		senders.Add(1)
+		go func(i int) {
+			defer senders.Done()
+			s.send(send, i)
+		}(i)
+	}
+	for i := 0; i < cfg.Readers; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			s.read(ctx, recv)
+		}()
+	}
+	s.watch(ctx)
+	readers.Wait()
+
+	res := SoakResult{Packets: s.packets.Load(), Bytes: s.bytes.Load(), Stray: s.stray.Load(), Duration: time.Since(start)}
+	if err := s.failure(); err != nil {
+		return res, err
+	}
+	if int(res.Packets) < cfg.Packets {
+		if err := parent.Err(); err != nil {
+			return res, err
+		}
+		return res, s.lost()
+	}
+	senders.Wait()
+	return res, nil
+}
+
+// the headers of a soak packet: Ethernet (if DevTap), IPv6 at most, UDP,
+// then the soak's own: "SOAK", the sender and the sequence number
+const soakMinSize = 14 + 40 + 8 + soakHeaderLen
+const soakHeaderLen = 4 + 2 + 8
+
+var soakMagic = []byte("SOAK")
+
+// soak is the state of a Soak.
+type soak struct {
+	cfg     SoakConfig
+	tap     bool
+	ordered bool
+	cancel  func()
+
+	// a bit for each packet read, for each sender
+	seen [][]atomic.Uint64
+	// the next sequence number to come from each sender, if ordered
+	next []uint64
+
+	packets, bytes, stray atomic.Uint64
+
+	errLock sync.Mutex
+	err     error
+}
+
+// count returns how many packets sender sends.
+func (s *soak) count(sender int) int {
+	n := s.cfg.Packets / s.cfg.Senders
+	if sender < s.cfg.Packets%s.cfg.Senders {
+		n++
+	}
+	return n
+}
+
+// fail records the first error, and stops the Soak.
+func (s *soak) fail(err error) {
+	s.errLock.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.errLock.Unlock()
+	s.cancel()
+}
+
+func (s *soak) failure() error {
+	s.errLock.Lock()
+	defer s.errLock.Unlock()
+	return s.err
+}
+
+// send writes the packets of sender to t, in batches of 1 to 8, a batch of
+// 1 with WritePacket.
+func (s *soak) send(t *Interface, sender int) {
+	rng := rand.New(rand.NewSource(s.cfg.Seed ^ int64(sender)<<32))
+	n := uint64(s.count(sender))
+	var batch []Packet
+	for seq := uint64(0); seq < n; {
+		batch = batch[:0]
+		for size := 1 + rng.Intn(8); len(batch) < size && seq < n; seq++ {
+			batch = append(batch, s.packet(nil, sender, seq))
+		}
+		var err error
+		if len(batch) == 1 {
+			err = t.WritePacket(batch[0])
+		} else {
+			_, err = t.WritePackets(batch)
+		}
+		if err != nil {
+			s.fail(err)
+			return
+		}
+		if s.failure() != nil {
+			return
+		}
+	}
+}
+
+// read reads the packets from t and checks them, until they're all read, or
+// ctx is done.
+func (s *soak) read(ctx context.Context, t *Interface) {
+	buf := make([]byte, 65536)
+	var want []byte
+	for {
+		pkt, err := t.ReadPacketContext(ctx, buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.fail(err)
+			}
+			return
+		}
+		sender, seq, ok := s.header(pkt.Body)
+		if !ok {
+			s.stray.Add(1)
+			continue
+		}
+		if want = s.packet(want[:0], sender, seq).Body; !bytes.Equal(pkt.Body, want) {
+			s.fail(&SoakError{Sender: sender, Seq: seq, Problem: "corrupted"})
+			return
+		}
+		if problem := s.check(sender, seq); problem != "" {
+			s.fail(&SoakError{Sender: sender, Seq: seq, Problem: problem})
+			return
+		}
+		s.bytes.Add(uint64(len(pkt.Body)))
+		if int(s.packets.Add(1)) == s.cfg.Packets {
+			s.cancel()
+			return
+		}
+	}
+}
+
+// check marks the packet seq of sender read, and returns what's wrong with
+// it having been read, if anything.
+func (s *soak) check(sender int, seq uint64) string {
+	w := &s.seen[sender][seq/64]
+	bit := uint64(1) << (seq % 64)
+	for {
+		old := w.Load()
+		if old&bit != 0 {
+			return "duplicated"
+		}
+		if w.CompareAndSwap(old, old|bit) {
+			break
+		}
+	}
+	if s.ordered {
+		if seq < s.next[sender] {
+			return "reordered"
+		}
+		s.next[sender] = seq + 1
+	}
+	return ""
+}
+
+// watch returns when ctx is done, or stops the Soak when no packet was read
+// for the Timeout.
+func (s *soak) watch(ctx context.Context) {
+	tick := time.NewTicker(s.cfg.Timeout / 4)
+	defer tick.Stop()
+	last, since := s.packets.Load(), time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-tick.C:
+			if n := s.packets.Load(); n != last {
+				last, since = n, now
+			} else if now.Sub(since) >= s.cfg.Timeout {
+				s.cancel()
+				return
+			}
+		}
+	}
+}
+
+// lost returns the SoakError of the packets never read.
+func (s *soak) lost() error {
+	var e *SoakError
+	for sender := range s.seen {
+		for seq := uint64(0); seq < uint64(s.count(sender)); seq++ {
+			if s.seen[sender][seq/64].Load()&(1<<(seq%64)) != 0 {
+				continue
+			}
+			if e == nil {
+				e = &SoakError{Sender: sender, Seq: seq, Problem: "lost"}
+			} else {
+				e.More++
+			}
+		}
+	}
+	if e == nil {
+		// read, but not counted: the Soak was stopped meanwhile
+		return s.failure()
+	}
+	return e
+}
+
+// soakRand is the random stream of the packet seq of sender.
+type soakRand uint64
+
+func (r *soakRand) next() uint64 {
+	// splitmix64
+	*r += 0x9e3779b97f4a7c15
+	z := uint64(*r)
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return z ^ z>>31
+}
+
+// packet appends to b the packet seq of sender, which the seed decides.
+func (s *soak) packet(b []byte, sender int, seq uint64) Packet {
+	r := soakRand(uint64(s.cfg.Seed)*0x9e3779b97f4a7c15 ^ uint64(sender)<<48 ^ seq)
+	first := r.next()
+	v6 := first&1 != 0
+	l2 := 0
+	if s.tap {
+		l2 = 14
+	}
+	ipLen := 20
+	if v6 {
+		ipLen = 40
+	}
+	hdrs := l2 + ipLen + 8 + soakHeaderLen
+	size := hdrs + int(first>>8%uint64(s.cfg.MaxSize-hdrs+1))
+
+	start := len(b)
+	b = append(b, make([]byte, size)...)
+	p := b[start:]
+	pkt := Packet{Protocol: ETH_P_IP}
+	if v6 {
+		pkt.Protocol = ETH_P_IPV6
+	}
+	if s.tap {
+		copy(p[0:6], []byte{0x02, 0, 0x5e, 0, 0x53, 0x02})
+		copy(p[6:12], []byte{0x02, 0, 0x5e, 0, 0x53, 0x01})
+		binary.BigEndian.PutUint16(p[12:14], pkt.Protocol)
+		pkt.L3Offset = 14
+	}
+	ip := p[l2:]
+	u := ip[ipLen:]
+	var src, dst []byte
+	if v6 {
+		ip[0] = 6 << 4
+		binary.BigEndian.PutUint16(ip[4:6], uint16(len(u)))
+		ip[6], ip[7] = 17, 64
+		copy(ip[8:24], []byte{0x20, 0x01, 0x0d, 0xb8, 14: byte(sender >> 8), 15: byte(sender)})
+		copy(ip[24:40], []byte{0x20, 0x01, 0x0d, 0xb8, 1, 14: 0, 15: 9})
+		src, dst = ip[8:24], ip[24:40]
+	} else {
+		ip[0] = 4<<4 | 5
+		binary.BigEndian.PutUint16(ip[2:4], uint16(len(ip)))
+		ip[8], ip[9] = 64, 17
+		copy(ip[12:16], []byte{198, 18, byte(sender >> 8), byte(sender)})
+		copy(ip[16:20], []byte{198, 19, 0, 9})
+		binary.BigEndian.PutUint16(ip[10:12], checksum(ip[:20]))
+		src, dst = ip[12:16], ip[16:20]
+	}
+	binary.BigEndian.PutUint16(u[0:2], uint16(10000+sender))
+	binary.BigEndian.PutUint16(u[2:4], 9)
+	binary.BigEndian.PutUint16(u[4:6], uint16(len(u)))
+	d := u[8:]
+	copy(d, soakMagic)
+	binary.BigEndian.PutUint16(d[4:6], uint16(sender))
+	binary.BigEndian.PutUint64(d[6:14], seq)
+	for i := soakHeaderLen; i < len(d); i += 8 {
+		var w [8]byte
+		binary.BigEndian.PutUint64(w[:], r.next())
+		copy(d[i:], w[:])
+	}
+	binary.BigEndian.PutUint16(u[6:8], udpChecksum(src, dst, u))
+	pkt.Body = p
+	return pkt
+}
+
+// header returns the sender and the sequence number of the soak packet b,
+// false if it isn't one.
+func (s *soak) header(b []byte) (int, uint64, bool) {
+	l2 := 0
+	if s.tap {
+		l2 = 14
+	}
+	if len(b) < l2+1 {
+		return 0, 0, false
+	}
+	ipLen := 20
+	if b[l2]>>4 == 6 {
+		ipLen = 40
+	}
+	if len(b) < l2+ipLen+8+soakHeaderLen {
+		return 0, 0, false
+	}
+	d := b[l2+ipLen+8:]
+	if !bytes.Equal(d[:4], soakMagic) {
+		return 0, 0, false
+	}
+	sender := int(binary.BigEndian.Uint16(d[4:6]))
+	seq := binary.BigEndian.Uint64(d[6:14])
+	if sender >= s.cfg.Senders || seq >= uint64(s.count(sender)) {
+		return 0, 0, false
+	}
+	return sender, seq, true
+}
+
+// udpChecksum returns the checksum of the UDP datagram u from src to dst,
+// IPv4 or IPv6 addresses. IPv6's pseudo-header sums the same as IPv4's with
+// 4-byte addresses.
+func udpChecksum(src, dst []byte, u []byte) uint16 {
+	b := make([]byte, 0, 40+len(u))
+	b = append(b, src...)
+	b = append(b, dst...)
+	b = append(b, 0, 0, byte(len(u)>>8), byte(len(u)), 0, 0, 0, 17)
+	b = append(b, u...)
+	if sum := checksum(b); sum != 0 {
+		return sum
+	}
+	// 0 is no checksum
+	return 0xffff
+}
+
+//-----------------------------------------------------------------------------
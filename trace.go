@@ -0,0 +1,88 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/hex"
+	"errors"
+)
+
+//-----------------------------------------------------------------------------
+// W3C trace context (https://www.w3.org/TR/trace-context/) for flows. The
+// application tags a FlowTracker's flows with the trace they belong to,
+// either as they start (FlowTracker.TagFlows) or later (FlowTracker.Tag),
+// e.g. once it has seen the request which opened the connection, and the
+// trace context then comes with the flow in FlowObserver events and Flows
+// snapshots, to annotate per-flow logs and metrics with.
+
+var ErrTraceparent = errors.New("malformed traceparent")
+
+// TraceContext is the part of a W3C traceparent which identifies a span.
+type TraceContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte // bit 0 is "sampled"
+}
+
+// IsValid returns whether tc has a trace and a span ID; the zero
+// TraceContext means no trace.
+func (tc TraceContext) IsValid() bool {
+	return tc.TraceID != [16]byte{} && tc.SpanID != [8]byte{}
+}
+
+// String returns tc as a version 00 traceparent header value.
+func (tc TraceContext) String() string {
+	b := make([]byte, 0, 55)
+	b = append(b, "00-"...)
+	b = append(b, hex.EncodeToString(tc.TraceID[:])...)
+	b = append(b, '-')
+	b = append(b, hex.EncodeToString(tc.SpanID[:])...)
+	b = append(b, '-')
+	b = append(b, hex.EncodeToString([]byte{tc.Flags})...)
+	return string(b)
+}
+
+// ParseTraceparent parses a traceparent header value. As the specification
+// asks, versions after 00 are parsed as far as version 00 goes.
+func ParseTraceparent(s string) (TraceContext, error) {
+	var tc TraceContext
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' || (len(s) > 55 && s[55] != '-') {
+		return tc, ErrTraceparent
+	}
+	var version [1]byte
+	if !decodeLowerHex(version[:], s[0:2]) || version[0] == 0xff {
+		return tc, ErrTraceparent
+	}
+	if version[0] == 0 && len(s) != 55 {
+		return tc, ErrTraceparent
+	}
+	var flags [1]byte
+	if !decodeLowerHex(tc.TraceID[:], s[3:35]) || !decodeLowerHex(tc.SpanID[:], s[36:52]) || !decodeLowerHex(flags[:], s[53:55]) {
+		return tc, ErrTraceparent
+	}
+	tc.Flags = flags[0]
+	if !tc.IsValid() {
+		return TraceContext{}, ErrTraceparent
+	}
+	return tc, nil
+}
+
+// decodeLowerHex decodes s into dst, accepting only lowercase hex digits as
+// the specification requires.
+func decodeLowerHex(dst []byte, s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= 'A' && c <= 'F' {
+			return false
+		}
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+//-----------------------------------------------------------------------------
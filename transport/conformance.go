@@ -0,0 +1,148 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package transport
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+)
+
+//-----------------------------------------------------------------------------
+// Conformance checks for Suite implementations. CheckSuite is meant to be
+// called from the test suite of the package providing a Suite:
+//
+//	func TestSuite(t *testing.T) {
+//		if err := transport.CheckSuite(mySuite); err != nil {
+//			t.Fatal(err)
+//		}
+//	}
+
+// known answers for the suites with standard names, from the GCM
+// specification's test cases 4 and 16
+var knownAnswers = map[string]struct {
+	key, nonce, plaintext, ad, sealed string
+}{
+	"AES-128-GCM": {
+		key:       "feffe9928665731c6d6a8f9467308308",
+		nonce:     "cafebabefacedbaddecaf888",
+		plaintext: "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a721c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b39",
+		ad:        "feedfacedeadbeeffeedfacedeadbeefabaddad2",
+		sealed:    "42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091" + "5bc94fbc3221a5db94fae95ae7121a47",
+	},
+	"AES-256-GCM": {
+		key:       "feffe9928665731c6d6a8f9467308308feffe9928665731c6d6a8f9467308308",
+		nonce:     "cafebabefacedbaddecaf888",
+		plaintext: "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a721c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b39",
+		ad:        "feedfacedeadbeeffeedfacedeadbeefabaddad2",
+		sealed:    "522dc1f099567d07f47f37a32a84427d643a8cdcbfe5c0c97598a2bd2555d1aa8cb08e48590dbb3da7b08b1056828838c5f61e6393ba7a0abcc9f662" + "76fc6ece0f4e1768cddf8853bb2d551b",
+	},
+}
+
+// CheckSuite verifies that s is usable by a Framer: that it rejects keys of
+// the wrong size, that it round trips packets of various sizes, that any
+// change to a message or its key makes it fail to open, and, for suites
+// named after a standard cipher, that it gives the standard's known answers.
+// It returns an error describing the first problem found.
+func CheckSuite(s Suite) error {
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("transport: suite %s: %s", s.Name(), fmt.Sprintf(format, args...))
+	}
+
+	n := s.KeySize()
+	if n <= 0 {
+		return fail("key size %d", n)
+	}
+	if _, err := s.New(make([]byte, n+1)); err == nil {
+		return fail("accepts a %d byte key", n+1)
+	}
+	key := make([]byte, n)
+	for i := range key {
+		key[i] = byte(i + 1)
+	}
+	aead, err := s.New(key)
+	if err != nil {
+		return fail("New: %v", err)
+	}
+	if aead.NonceSize() < 8 {
+		return fail("nonce size %d", aead.NonceSize())
+	}
+	if aead.Overhead() < 12 {
+		// shorter than the 96 bits NIST SP 800-38D allows for a tag
+		return fail("overhead %d", aead.Overhead())
+	}
+
+	f, err := NewFramer(s, key, 4)
+	if err != nil {
+		return fail("NewFramer: %v", err)
+	}
+	other := append([]byte(nil), key...)
+	other[0] ^= 1
+	wrong, err := NewFramer(s, other, 4)
+	if err != nil {
+		return fail("NewFramer: %v", err)
+	}
+	prefix := []byte("prefix")
+	for _, size := range []int{0, 1, 15, 16, 17, 64, 1500, 9000} {
+		pkt := make([]byte, size)
+		for i := range pkt {
+			pkt[i] = byte(i * 7)
+		}
+		for _, seq := range []uint64{0, 1, 1 << 63} {
+			msg := f.Seal(append([]byte(nil), prefix...), seq, pkt)
+			if !bytes.Equal(msg[:len(prefix)], prefix) {
+				return fail("Seal clobbers dst")
+			}
+			msg = msg[len(prefix):]
+			if len(msg) != size+f.Overhead() {
+				return fail("%d byte message for a %d byte packet", len(msg), size)
+			}
+			got, out, err := f.Open(nil, msg)
+			if err != nil || got != seq || !bytes.Equal(out, pkt) {
+				return fail("%d byte packet #%d doesn't round trip (%v)", size, seq, err)
+			}
+			if _, _, err = wrong.Open(nil, msg); err == nil {
+				return fail("opens with the wrong key")
+			}
+			// flip a bit of the type, the sequence number, the ciphertext
+			// if any, and the tag
+			for _, at := range []int{0, 1, HeaderLen, len(msg) - 1} {
+				bad := append([]byte(nil), msg...)
+				bad[at] ^= 0x10
+				if _, _, err = f.Open(nil, bad); err == nil {
+					return fail("opens with byte %d of the message changed", at)
+				}
+			}
+			if _, _, err = f.Open(nil, msg[:len(msg)-1]); err == nil {
+				return fail("opens a truncated message")
+			}
+		}
+		if size > 0 && bytes.Equal(f.Seal(nil, 0, pkt), f.Seal(nil, 1, pkt)) {
+			return fail("the sequence number doesn't change the ciphertext")
+		}
+	}
+
+	if ka, ok := knownAnswers[s.Name()]; ok {
+		k, _ := hex.DecodeString(ka.key)
+		nonce, _ := hex.DecodeString(ka.nonce)
+		p, _ := hex.DecodeString(ka.plaintext)
+		ad, _ := hex.DecodeString(ka.ad)
+		want, _ := hex.DecodeString(ka.sealed)
+		aead, err := s.New(k)
+		if err != nil {
+			return fail("New: %v", err)
+		}
+		if len(nonce) != aead.NonceSize() || !bytes.Equal(aead.Seal(nil, nonce, p, ad), want) {
+			return fail("wrong known answer")
+		}
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
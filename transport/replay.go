@@ -0,0 +1,102 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package transport
+
+import (
+	"sync"
+)
+
+//-----------------------------------------------------------------------------
+// Anti-replay as in RFC 6479: the window is a ring of 64-bit blocks, and
+// sliding it forward only clears the blocks it moves over, rather than
+// shifting the whole bitmap. One block of the ring is always being recycled,
+// so the window is one block shorter than the ring.
+
+const replayBlocks = 64
+
+// ReplayWindowSize is how far behind the highest sequence number accepted a
+// ReplayWindow still accepts a sequence number it hasn't seen.
+const ReplayWindowSize = (replayBlocks - 1) * 64
+
+// ReplayWindow rejects replayed and too old sequence numbers. The zero value
+// is an empty window, which accepts any sequence number. It's safe for
+// concurrent use.
+//
+// Check is cheap, and meant to drop replays before the cost of opening the
+// message; the window must only be updated with Accept once the message is
+// authenticated:
+//
+//	seq, err := transport.SeqNumber(msg)
+//	if err != nil || !w.Check(seq) {
+//		return // drop
+//	}
+//	seq, pkt, err := f.Open(nil, msg)
+//	if err != nil || !w.Accept(seq) {
+//		return // drop
+//	}
+type ReplayWindow struct {
+	lock   sync.Mutex
+	last   uint64 // the highest sequence number accepted
+	bitmap [replayBlocks]uint64
+}
+
+func (w *ReplayWindow) seen(seq uint64) (*uint64, uint64) {
+	return &w.bitmap[(seq>>6)%replayBlocks], 1 << (seq & 63)
+}
+
+// Check returns whether seq would be accepted, without updating the window.
+func (w *ReplayWindow) Check(seq uint64) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if seq > w.last {
+		return true
+	}
+	if w.last-seq >= ReplayWindowSize {
+		return false
+	}
+	block, bit := w.seen(seq)
+	return *block&bit == 0
+}
+
+// Accept records seq and returns true, unless it's been accepted before or
+// is too far behind the window, in which case it returns false.
+func (w *ReplayWindow) Accept(seq uint64) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if seq > w.last {
+		// clear the blocks the window slides over
+		cur, next := w.last>>6, seq>>6
+		n := next - cur
+		if n > replayBlocks {
+			n = replayBlocks
+		}
+		for i := uint64(1); i <= n; i++ {
+			w.bitmap[(cur+i)%replayBlocks] = 0
+		}
+		w.last = seq
+	} else if w.last-seq >= ReplayWindowSize {
+		return false
+	}
+	block, bit := w.seen(seq)
+	if *block&bit != 0 {
+		return false
+	}
+	*block |= bit
+	return true
+}
+
+// Reset empties the window, for a new key.
+func (w *ReplayWindow) Reset() {
+	w.lock.Lock()
+	w.last = 0
+	w.bitmap = [replayBlocks]uint64{}
+	w.lock.Unlock()
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,171 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+// Package transport frames the packets of a tuntap Interface for sending over
+// an encrypted transport (typically UDP), leaving the choice of cipher to the
+// application.
+//
+// Ciphers are plugged in as Suites, which build a standard cipher.AEAD from a
+// key. Nothing else in the package touches the cryptography, so a build which
+// must only use a validated module (Go's own FIPS 140 mode, a BoringCrypto
+// toolchain, or a hardware module behind cipher.AEAD) only has to provide
+// Suites built on it. CheckSuite verifies that a Suite behaves as the framing
+// expects.
+//
+// A data message is a type byte, the 64-bit sequence number (big endian),
+// then the sealed packet. The type byte and the sequence number are
+// authenticated as additional data, and the sequence number is the nonce, so
+// it must never repeat for a key. A ReplayWindow keeps track of the sequence
+// numbers received.
+package transport
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+)
+
+//-----------------------------------------------------------------------------
+
+// HeaderLen is the length of the clear header of a data message.
+const HeaderLen = 1 + 8
+
+var ErrShortMessage = errors.New("transport: message too short")
+var ErrMessageType = errors.New("transport: unexpected message type")
+var ErrNonceSize = errors.New("transport: AEAD nonce shorter than 8 bytes")
+
+// Suite describes an AEAD cipher.
+type Suite interface {
+	// Name identifies the suite, e.g. "AES-256-GCM".
+	Name() string
+	// KeySize is the length of the keys New takes.
+	KeySize() int
+	// New returns an AEAD using key. Its nonces must be at least 8 bytes.
+	New(key []byte) (cipher.AEAD, error)
+}
+
+type aesGCM struct {
+	keySize int
+}
+
+// AES128GCM and AES256GCM are AES-GCM with the standard 12-byte nonce and
+// 16-byte tag, from the Go standard library (and so validated when the
+// program runs in FIPS 140 mode).
+var (
+	AES128GCM Suite = aesGCM{16}
+	AES256GCM Suite = aesGCM{32}
+)
+
+func (s aesGCM) Name() string {
+	if s.keySize == 16 {
+		return "AES-128-GCM"
+	}
+	return "AES-256-GCM"
+}
+
+func (s aesGCM) KeySize() int {
+	return s.keySize
+}
+
+func (s aesGCM) New(key []byte) (cipher.AEAD, error) {
+	if len(key) != s.keySize {
+		return nil, aes.KeySizeError(len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Framer seals and opens the data messages of one direction of a session.
+// It holds no state besides the AEAD, so it's safe for concurrent use as
+// long as the AEAD is (the standard library's are); keeping track of the
+// sequence numbers is up to the caller, with a ReplayWindow on the receiving
+// side.
+type Framer struct {
+	aead    cipher.AEAD
+	msgType byte
+}
+
+// NewFramer returns a Framer using suite with key, for messages of type
+// msgType.
+func NewFramer(suite Suite, key []byte, msgType byte) (*Framer, error) {
+	aead, err := suite.New(key)
+	if err != nil {
+		return nil, err
+	}
+	if aead.NonceSize() < 8 {
+		return nil, ErrNonceSize
+	}
+	return &Framer{aead: aead, msgType: msgType}, nil
+}
+
+// Overhead is the number of bytes a data message adds to the packet.
+func (f *Framer) Overhead() int {
+	return HeaderLen + f.aead.Overhead()
+}
+
+// Overhead returns the number of bytes the data messages of Framers using
+// suite add to the packets, for working out the MTU of the tunnel before
+// there's a key (see tuntap.TunnelOverhead).
+func Overhead(suite Suite) (int, error) {
+	aead, err := suite.New(make([]byte, suite.KeySize()))
+	if err != nil {
+		return 0, err
+	}
+	return HeaderLen + aead.Overhead(), nil
+}
+
+func (f *Framer) nonce(seq uint64) []byte {
+	// the sequence number in the last 8 bytes, the rest zero
+	n := make([]byte, f.aead.NonceSize())
+	binary.BigEndian.PutUint64(n[len(n)-8:], seq)
+	return n
+}
+
+// Seal appends the data message carrying pkt with sequence number seq to dst
+// and returns the extended buffer. dst and pkt must not overlap.
+func (f *Framer) Seal(dst []byte, seq uint64, pkt []byte) []byte {
+	start := len(dst)
+	dst = append(dst, f.msgType, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(dst[start+1:], seq)
+	hdr := dst[start : start+HeaderLen]
+	return f.aead.Seal(dst, f.nonce(seq), pkt, hdr)
+}
+
+// SeqNumber returns the sequence number of the data message msg, without
+// authenticating it. It lets a replay window be checked before the cost of
+// opening the message.
+func SeqNumber(msg []byte) (uint64, error) {
+	if len(msg) < HeaderLen {
+		return 0, ErrShortMessage
+	}
+	return binary.BigEndian.Uint64(msg[1:HeaderLen]), nil
+}
+
+// Open authenticates and decrypts the data message msg, appending the packet
+// to dst. It returns the message's sequence number and the extended buffer.
+// The sequence number must only be trusted if err is nil.
+func (f *Framer) Open(dst, msg []byte) (uint64, []byte, error) {
+	if len(msg) < HeaderLen+f.aead.Overhead() {
+		return 0, dst, ErrShortMessage
+	}
+	if msg[0] != f.msgType {
+		return 0, dst, ErrMessageType
+	}
+	seq := binary.BigEndian.Uint64(msg[1:HeaderLen])
+	out, err := f.aead.Open(dst, f.nonce(seq), msg[HeaderLen:], msg[:HeaderLen])
+	if err != nil {
+		return 0, dst, err
+	}
+	return seq, out, nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,28 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package transport
+
+import (
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// The built-in suites, through the conformance checks any Suite should pass.
+
+func TestSuites(t *testing.T) {
+	for _, s := range []Suite{AES128GCM, AES256GCM} {
+		t.Run(s.Name(), func(t *testing.T) {
+			if err := CheckSuite(s); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+//-----------------------------------------------------------------------------
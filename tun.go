@@ -8,21 +8,30 @@
 package tuntap
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/netip"
 	"os"
+	"runtime"
+	"runtime/trace"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
 type DevKind int
 
 var ErrShortRead = errors.New("truncated /dev/tun read")
-var ErrJumboPacket = errors.New("jumbo packet too large for /dev/tun")
+var ErrJumboPacket = errors.New("packet larger than the interface's MaxPacket")
+var ErrNotSupported = errors.New("operation not supported on this platform")
+var ErrClosed = errors.New("interface closed")
+var ErrWouldBlock = errors.New("no packet queued")
 
 const (
 	// Receive/send layer routable 3 packets (IP, IPv6...). Notably,
@@ -33,12 +42,54 @@ const (
 	// would be visible on an Ethernet link, including broadcast and
 	// multicast traffic.
 	DevTap
+	// For Open only: a macvtap interface on top of the physical interface
+	// given WithParent, created if need be (see OpenMacvtap). The Interface
+	// is a DevTap one. Only supported on Linux.
+	DevMacvtap
+	// For Open only: an ipvtap interface, like DevMacvtap (see
+	// OpenIPvtap).
+	DevIpvtap
+	// For Open only: an AF_PACKET socket bound to the existing interface
+	// named, a physical NIC or any other (see OpenRaw), so that the same
+	// code captures and injects on real links. The Interface is a DevTap
+	// one. Only supported on Linux.
+	DevRawSocket
 )
 
+func (k DevKind) String() string {
+	switch k {
+	case DevTun:
+		return "tun"
+	case DevTap:
+		return "tap"
+	case DevMacvtap:
+		return "macvtap"
+	case DevIpvtap:
+		return "ipvtap"
+	case DevRawSocket:
+		return "raw"
+	}
+	return "DevKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// KindMismatchError is returned by Open when the interface already exists
+// (a persistent one) and is of the other kind.
+type KindMismatchError struct {
+	Name      string
+	Want, Got DevKind
+}
+
+func (e *KindMismatchError) Error() string {
+	return fmt.Sprintf("tuntap: %s is a %v device, not a %v one", e.Name, e.Got, e.Want)
+}
+
 const (
 	// various ethernet protocols, using the same names as linux does
-	ETH_P_IP   uint16 = 0x0800
-	ETH_P_IPV6 uint16 = 0x86dd
+	ETH_P_IP     uint16 = 0x0800
+	ETH_P_IPV6   uint16 = 0x86dd
+	ETH_P_ARP    uint16 = 0x0806
+	ETH_P_8021Q  uint16 = 0x8100
+	ETH_P_8021AD uint16 = 0x88a8
 )
 
 type Packet struct {
@@ -46,23 +97,169 @@ type Packet struct {
 	// Ethernet frame (for DevTap).
 	Body []byte
 	// The Ethernet type of the packet. Commonly seen values are
-	// 0x8000 for IPv4 and 0x86dd for IPv6.
+	// 0x8000 for IPv4, 0x86dd for IPv6 and 0x0806 for ARP. For a frame
+	// with 802.1Q tags, the type of the payload behind them.
 	Protocol uint16
+	// The offset in Body of the payload of the Ethernet frame (the IP
+	// header, for IP): 0 for DevTun, which has no Ethernet header, and 14
+	// plus 4 per 802.1Q tag for DevTap. The methods which look into the IP
+	// packet (SIP, DIP, IPProto...) go by it.
+	L3Offset int
 	// True if the packet was too large to be read completely.
 	Truncated bool
+	// The virtio-net header of the packet, on Interfaces opened
+	// WithVnetHdr.
+	Vnet VnetHdr
+	// The way the packet goes: DirRead (the zero value) for the packets
+	// read from the Interface, DirWrite for those the package builds to be
+	// written to it (decapsulated ones, announcements...). WritePacket
+	// doesn't look at it; it's for the layers handling both streams.
+	Dir Direction
+	// Whatever the stages a packet goes through want to tell the later ones
+	// (a classification verdict, the peer it came from...), set by them:
+	// ReadPacket leaves it nil, and WritePacket ignores it. The fragments
+	// of a packet carry its Meta, and a reassembled datagram that of its
+	// first fragment.
+	Meta interface{}
 }
 
+// framing describes what the device puts in front of each packet.
+type framing int
+
+const (
+	// the linux 4-byte packet information header: 2 bytes of flags and
+	// the 2-byte ethernet protocol
+	framePI framing = iota
+	// nothing; the packet starts at the first byte
+	frameNone
+	// the BSD 4-byte header holding the address family, in network order
+	frameAF
+)
+
 type Interface struct {
-	name string
-	file *os.File
+	name    string
+	file    *os.File
+	kind    DevKind
+	framing framing
+	serial  SerialFraming
+	// set by WithNonblocking: reads don't wait for a packet
+	nonblock bool
+	// set by WithIPv6Only
+	ipv6Only    bool
+	rejectIPv4  bool
+	ipv4Refused atomic.Uint64
+	// set by WithSourceRoutePolicy
+	sourceRoute  SourceRoutePolicy
+	sourceRouted atomic.Uint64
+	// set by WithARPResponder, WithNDPResponder and WithDHCPServer
+	arp  *ARPResponder
+	ndp  *NDPResponder
+	dhcp *DHCPServer
+	// set by WithEtherTypeMux
+	etherTypes *EtherTypeMux
+	// set by WithTracerouteHop
+	hop4, hop6 netip.Addr
+	ttlExpired atomic.Uint64
+	// set by WithLatencySampling
+	latency *latencySampler
+	// set by WithVnetHdr: a virtio-net header follows the PI header, or
+	// starts the frames of a FreeBSD tap, which have no PI header, and is
+	// vnetLen bytes long there (see SetVnetHdrLen)
+	vnetHdr  bool
+	vnetLen  int
+	offloads Offload
+	// see MaxPacket: set by WithMaxPacket or SetMaxPacket, and derived from
+	// the MTU
+	maxPacket atomic.Int64
+	mtuPacket atomic.Int64
+	// if set, sends several packets at once more efficiently than one
+	// WritePacket each (see writeBatch)
+	sendBatch func(t *Interface, pkts []Packet) (int, error)
+	// if set, reads several queued packets at once more efficiently than
+	// one read each (see readBatch)
+	recvBatch func(t *Interface, bufs [][]byte, pkts []Packet) ([]Packet, error)
+	// the ReadPacketContext calls whose read deadline interrupts the reads,
+	// and the read deadline of the PacketConn, if any
+	interruptLock sync.Mutex
+	interrupting  int
+	readDeadline  time.Time
+	// the sockets holding the anycast addresses (Linux)
+	anycastLock sync.Mutex
+	anycast     []*os.File
+	// the index of the queue of a multi-queue Interface, plus 1
+	queue int
+	// set by DetectMisuse
+	debug  *misuseDetector
+	state  atomic.Int32 // stateOpen, stateClosing or stateClosed
+	sealed atomic.Bool
+	// set by WithCleanup and WithJournal, and the OnClose hooks
+	cleanup   bool
+	journal   *Journal
+	closeLock sync.Mutex
+	onClose   []func()
+	inOnClose atomic.Bool
+	// the changes the OnClose hooks undo, WithCleanup
+	undo []JournalEntry
 }
 
+// the lifecycle of an Interface
+const (
+	stateOpen int32 = iota
+	stateClosing
+	stateClosed
+)
+
 // Disconnect from the tun/tap interface.
 //
 // If the interface isn't configured to be persistent, it is
 // immediately destroyed by the kernel.
+//
+// Reads and writes in progress return ErrClosed, as do all later
+// operations. Calling Close again is a no-op which returns nil.
 func (t *Interface) Close() error {
-	return t.file.Close()
+	return t.close(true)
+}
+
+// close closes t, running its OnClose hooks (and so undoing its
+// configuration, WithCleanup) and recording it in its journal if teardown,
+// or only letting go of the device if not.
+func (t *Interface) close(teardown bool) error {
+	if !t.state.CompareAndSwap(stateOpen, stateClosing) {
+		return nil
+	}
+	untrack()
+	if teardown {
+		t.runOnClose()
+	} else {
+		t.dropOnClose()
+	}
+	if t.debug != nil {
+		t.debug.close()
+	}
+	// wake up the blocked readers right away: the file is only really closed
+	// once the calls using it (a write into a full socket buffer...) return
+	t.file.SetReadDeadline(time.Unix(1, 0))
+	err := t.file.Close()
+	t.anycastLock.Lock()
+	for _, f := range t.anycast {
+		f.Close()
+	}
+	t.anycast = nil
+	t.anycastLock.Unlock()
+	if teardown {
+		t.closedJournal()
+	}
+	t.state.Store(stateClosed)
+	return err
+}
+
+// closedErr returns ErrClosed if the Interface isn't open, or if err is the
+// os package's way of saying so.
+func (t *Interface) closedErr(err error) error {
+	if t.state.Load() != stateOpen || errors.Is(err, os.ErrClosed) {
+		return ErrClosed
+	}
+	return err
 }
 
 // The name of the interface. May be different from the name given to
@@ -71,42 +268,397 @@ func (t *Interface) Name() string {
 	return t.name
 }
 
-// Read a single packet from the kernel.
-func (t *Interface) ReadPacket(buffer []byte) (Packet, error) {
-	n, err := t.file.Read(buffer)
+// Control calls f with the file descriptor of the device, for the ioctls
+// the package doesn't wrap, like syscall.RawConn.Control: the descriptor
+// stays valid until f returns, even if the Interface is closed meanwhile,
+// but f mustn't keep it, close it, or change its blocking mode. It returns
+// ErrClosed if the Interface is closed, ErrSealed if it's sealed,
+// or f's error.
+func (t *Interface) Control(f func(fd uintptr) error) error {
+	return t.control(f)
+}
+
+// control calls f with the device's file descriptor, without disturbing the
+// file's nonblocking mode the way os.File.Fd() does.
+func (t *Interface) control(f func(fd uintptr) error) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	rc, err := t.file.SyscallConn()
+	if err != nil {
+		return t.closedErr(err)
+	}
+	var ferr error
+	err = rc.Control(func(fd uintptr) { ferr = f(fd) })
 	if err != nil {
+		return t.closedErr(err)
+	}
+	return ferr
+}
+
+// Read a single packet from the kernel. Closing the Interface makes a
+// blocked ReadPacket return ErrClosed.
+func (t *Interface) ReadPacket(buffer []byte) (Packet, error) {
+	if t.debug != nil {
+		defer t.debug.begin("ReadPacket", buffer)()
+	}
+	return t.readPacket(nil, buffer)
+}
+
+// ReadPacketContext reads a single packet like ReadPacket, but gives up when
+// ctx is done, returning ctx.Err(), so that the goroutine reading can be
+// stopped without closing the Interface. Cancelling interrupts the other
+// reads in progress on the Interface for a moment, which they retry without
+// their callers noticing.
+func (t *Interface) ReadPacketContext(ctx context.Context, buffer []byte) (Packet, error) {
+	if t.debug != nil {
+		defer t.debug.begin("ReadPacketContext", buffer)()
+	}
+	if err := ctx.Err(); err != nil {
 		return Packet{}, err
 	}
+	// the read can only be woken up by a deadline, which must stay until the
+	// last of the cancelled calls has been woken up
+	ran := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		t.interruptLock.Lock()
+		t.interrupting++
+		t.file.SetReadDeadline(time.Unix(1, 0))
+		t.interruptLock.Unlock()
+		close(ran)
+	})
+	pkt, err := t.readPacket(ctx, buffer)
+	if !stop() {
+		// AfterFunc ran it, or is running it
+		<-ran
+		t.interruptLock.Lock()
+		t.interrupting--
+		if t.interrupting == 0 {
+			t.file.SetReadDeadline(t.readDeadline)
+		}
+		t.interruptLock.Unlock()
+	}
+	return pkt, err
+}
+
+// readPacket does ReadPacket, or ReadPacketContext with ctx not nil.
+func (t *Interface) readPacket(ctx context.Context, buffer []byte) (Packet, error) {
+	for {
+		if ctx != nil && ctx.Err() != nil {
+			return Packet{}, ctx.Err()
+		}
+		var n int
+		var err error
+		if t.nonblock {
+			var ok bool
+			n, ok, err = t.readNow(buffer)
+			if err == nil && !ok {
+				return Packet{}, ErrWouldBlock
+			}
+		} else {
+			n, err = t.read(buffer)
+		}
+		if err == nil {
+			pkt, err := t.parse(buffer, n)
+			if err == errRefused {
+				continue
+			}
+			return pkt, err
+		}
+		if !t.interrupted(ctx, err) {
+			return Packet{}, t.closedErr(err)
+		}
+	}
+}
+
+// interrupted returns whether err is a ReadPacketContext interrupting the
+// reads to stop its own, which the others must retry. It yields, so that the
+// deadline which interrupted the read is gone when it's retried.
+func (t *Interface) interrupted(ctx context.Context, err error) bool {
+	if !errors.Is(err, os.ErrDeadlineExceeded) || t.state.Load() != stateOpen {
+		// Close's deadline isn't to be retried
+		return false
+	}
+	t.interruptLock.Lock()
+	d := t.readDeadline
+	t.interruptLock.Unlock()
+	if !d.IsZero() && !time.Now().Before(d) {
+		// nor the PacketConn's
+		return false
+	}
+	if ctx == nil || ctx.Err() == nil {
+		runtime.Gosched()
+	}
+	return true
+}
+
+// ReadPackets waits for a packet, and reads it along with the packets already
+// queued behind it, one into each of bufs, returning as many Packets as it
+// read. Queued packets which can't be parsed are dropped. Raw Interfaces
+// read the lot with one recvmmsg(2); a tun/tap file descriptor only gives
+// one packet per read(2) (readv(2) included), so there the packets after the
+// first are read back to back, without waiting for more.
+//
+// WithVnetHdr, the TCP and UDP super-packets are cut into their segments
+// (see SegmentGSO), which take the buffers after theirs; a super-packet the
+// buffers left have no room for is returned whole.
+func (t *Interface) ReadPackets(bufs [][]byte) ([]Packet, error) {
+	if len(bufs) == 0 {
+		return nil, nil
+	}
+	return t.readBatch(bufs, make([]Packet, 0, len(bufs)))
+}
+
+// readBatch does ReadPackets, appending the Packets to pkts.
+func (t *Interface) readBatch(bufs [][]byte, pkts []Packet) ([]Packet, error) {
+	defer trace.StartRegion(context.Background(), "tuntap.ReadPackets").End()
+	if t.recvBatch != nil {
+		if t.debug != nil {
+			defer t.debug.begin("ReadPackets", bufs[0])()
+		}
+		for {
+			got, err := t.recvBatch(t, bufs, pkts)
+			if err == nil || !t.interrupted(nil, err) {
+				return got, err
+			}
+		}
+	}
+	pkt, err := t.ReadPacket(bufs[0])
+	if err != nil {
+		return pkts, err
+	}
+	pkts, i := t.appendRead(pkts, pkt, bufs, 1)
+	for i < len(bufs) {
+		n, ok, err := t.readNow(bufs[i])
+		if err != nil || !ok {
+			// the error, if any, comes back on the next read
+			break
+		}
+		pkt, err = t.parse(bufs[i], n)
+		i++
+		if err == nil {
+			pkts, i = t.appendRead(pkts, pkt, bufs, i)
+		}
+	}
+	return pkts, nil
+}
+
+// appendRead appends the packet read, pkt, to pkts, or its segments if it's
+// a super-packet the buffers from bufs[i] on have room for, and returns the
+// index of the first buffer left.
+func (t *Interface) appendRead(pkts []Packet, pkt Packet, bufs [][]byte, i int) ([]Packet, int) {
+	if t.vnetHdr && pkt.Vnet.GSOType != VnetGSONone {
+		if segs, ok := SegmentGSO(pkt, bufs[i:]); ok {
+			return append(pkts, segs...), i + len(segs) - 1
+		}
+	}
+	return append(pkts, pkt), i
+}
+
+// parse builds the Packet from the n bytes read into buffer, or returns
+// errRefused if the Interface's policy drops it.
+func (t *Interface) parse(buffer []byte, n int) (Packet, error) {
+	pkt, err := t.decode(buffer, n)
+	if err == nil && t.ipv6Only && t.refuseIPv4(&pkt) {
+		return Packet{}, errRefused
+	}
+	if err == nil && t.sourceRoute != SourceRouteAccept && pkt.Protocol == ETH_P_IP && t.checkSourceRoute(&pkt) {
+		return Packet{}, errRefused
+	}
+	if err == nil && t.arp != nil && pkt.Protocol == ETH_P_ARP && t.answerARP(&pkt) {
+		return Packet{}, errRefused
+	}
+	if err == nil && t.ndp != nil && pkt.Protocol == ETH_P_IPV6 && t.answerNDP(&pkt) {
+		return Packet{}, errRefused
+	}
+	if err == nil && t.dhcp != nil && pkt.Protocol == ETH_P_IP && t.answerDHCP(&pkt) {
+		return Packet{}, errRefused
+	}
+	if err == nil && (t.hop4.IsValid() || t.hop6.IsValid()) && t.expireTTL(&pkt) {
+		return Packet{}, errRefused
+	}
+	if err == nil && t.etherTypes != nil && t.etherTypes.Dispatch(&pkt) {
+		return Packet{}, errRefused
+	}
+	return pkt, err
+}
+
+// decode builds the Packet from the n bytes read into buffer.
+func (t *Interface) decode(buffer []byte, n int) (Packet, error) {
+	if t.framing == frameNone {
+		return t.unframed(buffer, n)
+	}
 	if n < 4 {
 		return Packet{}, ErrShortRead
 	}
+	if t.framing == frameAF {
+		pkt := Packet{Body: buffer[4:n]}
+		switch binary.BigEndian.Uint32(buffer[:4]) {
+		case afInet:
+			pkt.Protocol = ETH_P_IP
+		case afInet6:
+			pkt.Protocol = ETH_P_IPV6
+		}
+		// like in unframed, a full buffer is the only hint of truncation
+		pkt.Truncated = n == len(buffer)
+		return pkt, nil
+	}
 
 	pkt := Packet{Body: buffer[4:n]}
+	if t.vnetHdr {
+		if n < 4+VnetHdrLen {
+			return Packet{}, ErrShortRead
+		}
+		pkt.Vnet, _ = ParseVnetHdr(buffer[4:])
+		pkt.Body = buffer[4+VnetHdrLen : n]
+	}
 	pkt.Protocol = binary.BigEndian.Uint16(buffer[2:4])
+	if t.kind == DevTap {
+		// the PI header has the outer type of tagged frames; see ParseFrame
+		frame, err := ParseFrame(pkt.Body)
+		if err != nil {
+			return Packet{}, err
+		}
+		frame.Vnet = pkt.Vnet
+		pkt = frame
+	}
 	flags := *(*uint16)(unsafe.Pointer(&buffer[0]))
 	pkt.Truncated = (flags&flagTruncated != 0)
 	return pkt, nil
 }
 
+// unframed builds the Packet for devices which don't prefix packets with any
+// header, deriving the protocol from the packet itself.
+func (t *Interface) unframed(buffer []byte, n int) (Packet, error) {
+	var vnet VnetHdr
+	if t.vnetHdr {
+		if n < t.vnetLen {
+			return Packet{}, ErrShortRead
+		}
+		vnet, _ = ParseVnetHdr(buffer)
+		buffer, n = buffer[t.vnetLen:], n-t.vnetLen
+	}
+	pkt := Packet{Body: buffer[:n]}
+	if t.kind == DevTap {
+		var err error
+		if pkt, err = ParseFrame(buffer[:n]); err != nil {
+			return Packet{}, err
+		}
+	} else {
+		if n < 1 {
+			return Packet{}, ErrShortRead
+		}
+		pkt.Protocol = ipProtocol(buffer[:n])
+	}
+	// without a header the kernel can't tell us the packet was cut short, so
+	// assume that a completely full buffer means it was
+	pkt.Truncated = n == len(buffer)
+	pkt.Vnet = vnet
+	return pkt, nil
+}
+
+// ParseFrame returns the Ethernet frame b as a DevTap Packet, with the
+// Protocol and the L3Offset of its payload, skipping any 802.1Q (and 802.1ad)
+// tags.
+func ParseFrame(b []byte) (Packet, error) {
+	if len(b) < 14 {
+		return Packet{}, ErrShortRead
+	}
+	pkt := Packet{Body: b, Protocol: binary.BigEndian.Uint16(b[12:14]), L3Offset: 14}
+	for pkt.Protocol == ETH_P_8021Q || pkt.Protocol == ETH_P_8021AD {
+		if pkt.L3Offset+4 > len(b) {
+			return Packet{}, ErrShortRead
+		}
+		pkt.Protocol = binary.BigEndian.Uint16(b[pkt.L3Offset+2 : pkt.L3Offset+4])
+		pkt.L3Offset += 4
+	}
+	return pkt, nil
+}
+
+// ipProtocol returns the ethernet protocol of the IP packet b, going by its
+// version field, or 0 if it isn't IPv4 or IPv6.
+func ipProtocol(b []byte) uint16 {
+	if len(b) > 0 {
+		switch b[0] >> 4 {
+		case 4:
+			return ETH_P_IP
+		case 6:
+			return ETH_P_IPV6
+		}
+	}
+	return 0
+}
+
 // free 1600 byte buffers
 var buffers = sync.Pool{New: func() interface{} { return new([1600]byte) }}
 
-// Send a single packet to the kernel.
+// Send a single packet to the kernel. Packets larger than MaxPacket are
+// refused with ErrJumboPacket.
 func (t *Interface) WritePacket(pkt Packet) error {
+	if t.debug != nil {
+		defer t.debug.begin("WritePacket", pkt.Body)()
+	}
+	if err := t.refuse(&pkt); err != nil {
+		return err
+	}
+	return t.writePacket(pkt)
+}
+
+// refuse returns ErrIPv4Refused if the Interface's policy refuses pkt.
+func (t *Interface) refuse(pkt *Packet) error {
+	if t.ipv6Only && ipv4Packet(pkt) {
+		t.ipv4Refused.Add(1)
+		return ErrIPv4Refused
+	}
+	return nil
+}
+
+// jumbo returns whether pkt is larger than MaxPacket. GSO super-packets
+// aren't, as the kernel segments them.
+func (t *Interface) jumbo(pkt *Packet) bool {
+	return pkt.Vnet.GSOType == VnetGSONone && len(pkt.Body) > t.MaxPacket()
+}
+
+// writePacket does WritePacket, whatever the Interface's policy.
+func (t *Interface) writePacket(pkt Packet) error {
+	if t.jumbo(&pkt) {
+		return ErrJumboPacket
+	}
+	if t.vnetHdr {
+		return t.writeVnet(pkt)
+	}
+	if t.framing == frameNone {
+		a, err := t.write(pkt.Body)
+		if err != nil {
+			return t.closedErr(err)
+		}
+		if a != len(pkt.Body) {
+			return io.ErrShortWrite
+		}
+		return nil
+	}
+
 	// If only we had writev(), I could do zero-copy here...
 	// At least we will manage the buffer so we don't cause the GC extra work
-	buf := buffers.Get().(*[1600]byte)
-
-	binary.BigEndian.PutUint16(buf[2:4], pkt.Protocol)
-	copy(buf[4:], pkt.Body)
 	n := 4 + len(pkt.Body)
-	if n > len(buf) {
-		return ErrJumboPacket
+	var b []byte
+	buf := buffers.Get().(*[1600]byte)
+	if n <= len(buf) {
+		b = buf[:n]
+	} else {
+		// a jumbo packet
+		buffers.Put(buf)
+		buf = nil
+		b = make([]byte, n)
+	}
+	t.header(b[:4], pkt)
+	copy(b[4:], pkt.Body)
+	a, err := t.write(b)
+	if buf != nil {
+		buffers.Put(buf)
 	}
-	a, err := t.file.Write(buf[:n])
-	buffers.Put(buf)
 	if err != nil {
-		return err
+		return t.closedErr(err)
 	}
 	if a != n {
 		return io.ErrShortWrite
@@ -114,6 +666,97 @@ func (t *Interface) WritePacket(pkt Packet) error {
 	return nil
 }
 
+// header fills in the 4-byte header the device expects in front of pkt.
+func (t *Interface) header(h []byte, pkt Packet) {
+	if t.framing == frameAF {
+		af := uint32(afInet)
+		if pkt.Protocol == ETH_P_IPV6 || pkt.Protocol == 0 && ipProtocol(pkt.Body) == ETH_P_IPV6 {
+			af = afInet6
+		}
+		binary.BigEndian.PutUint32(h, af)
+		return
+	}
+	// the buffer may hold anything, so the flags must be cleared
+	h[0], h[1] = 0, 0
+	binary.BigEndian.PutUint16(h[2:4], pkt.Protocol)
+}
+
+// writeVnet writes pkt behind its PI and virtio-net headers, or only the
+// latter without a PI header, with writev(2) rather than copying, as GSO
+// packets can be 64KB.
+func (t *Interface) writeVnet(pkt Packet) error {
+	var buf [4 + VnetHdrLen + 2]byte
+	var hdr []byte
+	if t.framing == frameNone {
+		// the num_buffers of a struct virtio_net_hdr_mrg_rxbuf is left 0
+		hdr = AppendVnetHdr(buf[:0], pkt.Vnet)[:t.vnetLen]
+	} else {
+		t.header(buf[:4], pkt)
+		hdr = AppendVnetHdr(buf[:4], pkt.Vnet)
+	}
+	n, err := t.writev([][]byte{hdr, pkt.Body})
+	if err != nil {
+		return t.closedErr(err)
+	}
+	if n != len(hdr)+len(pkt.Body) {
+		return io.ErrShortWrite
+	}
+	return nil
+}
+
+// AF_INET is 2 everywhere. AF_INET6 isn't, and each platform defines afInet6
+const afInet = 2
+
+// WritePackets sends pkts to the kernel, returning how many were sent before
+// any error, so that the caller can retry the rest. Raw Interfaces send the
+// lot with sendmmsg(2), in as few syscalls as the socket buffer allows. A
+// tun/tap file descriptor takes exactly one packet per write(2), and a
+// writev(2) is one packet made of the pieces, so there the packets are
+// written one by one. They aren't merged into GSO super-packets: WithVnetHdr,
+// the application can write those itself, with the GSO fields of
+// Packet.Vnet set, for the kernel to segment.
+func (t *Interface) WritePackets(pkts []Packet) (int, error) {
+	if len(pkts) == 0 {
+		return 0, nil
+	}
+	if t.sendBatch != nil && t.debug != nil {
+		defer t.debug.begin("WritePackets", pkts[0].Body)()
+	}
+	return t.writeBatch(pkts)
+}
+
+// writeBatch sends pkts to the kernel, returning how many were sent before
+// any error.
+func (t *Interface) writeBatch(pkts []Packet) (int, error) {
+	defer trace.StartRegion(context.Background(), "tuntap.WritePackets").End()
+	if t.sendBatch != nil {
+		// the packets before one refused are sent
+		for i := range pkts {
+			err := t.refuse(&pkts[i])
+			if err == nil && t.jumbo(&pkts[i]) {
+				err = ErrJumboPacket
+			}
+			if err == nil {
+				continue
+			}
+			if i != 0 {
+				if n, serr := t.sendBatch(t, pkts[:i]); serr != nil {
+					return n, serr
+				}
+			}
+			return i, err
+		}
+		return t.sendBatch(t, pkts)
+	}
+	// a tun/tap file takes exactly one packet per write()
+	for i := range pkts {
+		if err := t.WritePacket(pkts[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(pkts), nil
+}
+
 // Open connects to the specified tun/tap interface.
 //
 // If the specified device has been configured as persistent, this
@@ -123,27 +766,302 @@ func (t *Interface) WritePacket(pkt Packet) error {
 // ifPattern can be an exact interface name, e.g. "tun42", or a
 // pattern containing one %d format specifier, e.g. "tun%d". In the
 // latter case, the kernel will select an available interface name and
-// create it.
+// create it. DevMacvtap, DevIpvtap and DevRawSocket interfaces need an
+// exact name.
+//
+// Options can be given to configure the Interface further; the settings
+// which need to be made after the interface is created (WithMTU,
+// WithOwner...) are, before Open returns. If any fails, the Interface is
+// closed and the error returned.
 //
 // Returns a TunTap object with channels to send/receive packets, or
 // nil and an error if connecting to the interface failed.
-func Open(ifPattern string, kind DevKind) (*Interface, error) {
-	return createInterface(ifPattern, kind)
+func Open(ifPattern string, kind DevKind, opts ...Option) (*Interface, error) {
+	var cfg config
+	for _, o := range opts {
+		o(&cfg)
+	}
+	// vtaps and raw sockets are taps, which aren't opened through
+	// /dev/net/tun
+	var vtap string
+	raw := kind == DevRawSocket
+	switch kind {
+	case DevMacvtap:
+		vtap, kind = "macvtap", DevTap
+	case DevIpvtap:
+		vtap, kind = "ipvtap", DevTap
+	case DevRawSocket:
+		kind = DevTap
+	}
+	if (vtap != "" || raw) && (cfg.vnetHdr || cfg.multiQueue) {
+		return nil, errors.New("tuntap: only tun/tap devices take a virtio-net header or queues")
+	}
+	if cfg.serial != SerialNone && kind != DevTun {
+		return nil, errors.New("tuntap: serial framing requires a DevTun interface")
+	}
+	if cfg.arp != nil && kind != DevTap {
+		return nil, errors.New("tuntap: an ARP responder requires a DevTap interface")
+	}
+	if cfg.ndp != nil && kind != DevTap {
+		return nil, errors.New("tuntap: an NDP responder requires a DevTap interface")
+	}
+	if cfg.dhcp != nil && kind != DevTap {
+		return nil, errors.New("tuntap: a DHCP server requires a DevTap interface")
+	}
+	if cfg.etherTypes != nil && kind != DevTap {
+		return nil, errors.New("tuntap: an EtherType mux requires a DevTap interface")
+	}
+	var t *Interface
+	var err error
+	var created bool // the vtap link, which configure failing must delete
+	if raw {
+		t, err = openRaw(ifPattern)
+	} else if vtap != "" {
+		t, created, err = openVtap(vtap, ifPattern, cfg.parent)
+	} else if cfg.vnetHdr || cfg.multiQueue {
+		t, err = openConfigured(ifPattern, kind, &cfg)
+	} else {
+		t, err = createInterface(ifPattern, kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.serial = cfg.serial
+	t.nonblock = cfg.nonblock
+	t.ipv6Only, t.rejectIPv4 = cfg.ipv6Only, cfg.rejectIPv4
+	t.arp, t.ndp, t.dhcp = cfg.arp, cfg.ndp, cfg.dhcp
+	t.etherTypes = cfg.etherTypes
+	t.sourceRoute = cfg.sourceRoute
+	t.hop4, t.hop6 = cfg.hop4, cfg.hop6
+	t.latency = newLatencySampler(cfg.latency)
+	t.SetMaxPacket(cfg.maxPacket)
+	t.cleanup, t.journal = cfg.cleanup, cfg.journal
+	if err = t.configure(&cfg); err != nil {
+		t.file.Close()
+		if created {
+			deleteVtap(t.name)
+		}
+		return nil, err
+	}
+	if t.cleanup {
+		registerCleanup(t)
+	}
+	if t.ndp != nil {
+		t.advertiseRouter()
+	}
+	return track(t, nil)
+}
+
+// configure makes the settings of cfg which apply to an existing interface.
+// Persistence comes last, so that a failure doesn't leave the interface
+// behind.
+func (t *Interface) configure(cfg *config) error {
+	if cfg.mtu != 0 {
+		if err := t.SetMTU(cfg.mtu); err != nil {
+			return err
+		}
+	}
+	if cfg.owner != nil {
+		if err := t.SetOwner(*cfg.owner); err != nil {
+			return err
+		}
+	}
+	if cfg.group != nil {
+		if err := t.SetGroup(*cfg.group); err != nil {
+			return err
+		}
+	}
+	if cfg.persist {
+		return t.SetPersist(true)
+	}
+	return nil
+}
+
+// config collects the Options given to Open.
+type config struct {
+	serial       SerialFraming
+	vnetHdr      bool
+	offloads     Offload
+	multiQueue   bool
+	parent       string
+	nonblock     bool
+	persist      bool
+	owner, group *int
+	mtu          int
+	maxPacket    int
+	ipv6Only     bool
+	rejectIPv4   bool
+	sourceRoute  SourceRoutePolicy
+	cleanup      bool
+	journal      *Journal
+	arp          *ARPResponder
+	ndp          *NDPResponder
+	dhcp         *DHCPServer
+	etherTypes   *EtherTypeMux
+	hop4, hop6   netip.Addr
+	latency      int
+}
+
+// An Option configures an Interface as it is opened.
+type Option func(*config)
+
+// WithPersist makes the interface persistent (see SetPersist). Only supported
+// on Linux.
+func WithPersist() Option {
+	return func(c *config) { c.persist = true }
+}
+
+// WithOwner sets the user allowed to open the interface (see SetOwner). Only
+// supported on Linux.
+func WithOwner(uid int) Option {
+	return func(c *config) { c.owner = &uid }
+}
+
+// WithGroup sets the group allowed to open the interface (see SetGroup). Only
+// supported on Linux.
+func WithGroup(gid int) Option {
+	return func(c *config) { c.group = &gid }
+}
+
+// WithMultiQueue opens one queue of a multi-queue (IFF_MULTI_QUEUE)
+// interface; opening the interface again by name, with WithMultiQueue, adds
+// a queue. See OpenMultiQueue. Only supported on Linux.
+func WithMultiQueue() Option {
+	return func(c *config) { c.multiQueue = true }
+}
+
+// WithParent gives the physical interface a DevMacvtap or DevIpvtap
+// interface is created on top of, if it doesn't exist yet.
+func WithParent(parent string) Option {
+	return func(c *config) { c.parent = parent }
+}
+
+// WithNonblocking makes ReadPacket (and ReadPackets) return ErrWouldBlock
+// when no packet is queued, instead of waiting for one, for applications
+// which poll the Interface from their own loop.
+func WithNonblocking() Option {
+	return func(c *config) { c.nonblock = true }
+}
+
+// WithMTU sets the MTU of the interface.
+func WithMTU(mtu int) Option {
+	return func(c *config) { c.mtu = mtu }
+}
+
+// NewFromFD returns an Interface for the tun/tap device already open on fd,
+// which the caller got from elsewhere: Android's VpnService, systemd's
+// socket activation, another process over a Unix socket (SCM_RIGHTS)... The
+// fd is put in nonblocking mode, and belongs to the Interface from then on:
+// closing the Interface closes it.
+//
+// On Linux the kernel tells the interface's name, its kind (which must be
+// kind) and whether the device was set up with IFF_NO_PI or IFF_VNET_HDR, so
+// name may be empty. Elsewhere, nothing can be asked of the fd, so name must
+// be the interface's, and the device set up as Open does (TUNSIFHEAD on the
+// BSDs' tun devices).
+func NewFromFD(fd int, kind DevKind, name string) (*Interface, error) {
+	if fd < 0 {
+		return nil, fmt.Errorf("tuntap: invalid file descriptor %d", fd)
+	}
+	return track(newFromFD(fd, kind, name))
+}
+
+// OpenRaw binds an AF_PACKET socket to the existing network interface ifName
+// (a physical NIC, a veth, a bridge...) and returns it as a DevTap Interface.
+//
+// ReadPacket returns the Ethernet frames the interface receives from the
+// link, and WritePacket transmits frames onto the link, so code written
+// against a tap device works unchanged against a real NIC. Frames the host
+// itself transmits on the interface are not seen (on kernels which support
+// PACKET_IGNORE_OUTGOING).
+//
+// Many of the configuration methods (AddAddress, SetMTU, Up...) work as
+// usual, but affect the real interface. Open with DevRawSocket does the
+// same, taking the Options of Open (WithNonblocking, WithARPResponder...).
+// Only implemented on Linux.
+func OpenRaw(ifName string) (*Interface, error) {
+	return track(openRaw(ifName))
+}
+
+// OpenMultiQueue creates the multi-queue (IFF_MULTI_QUEUE) tun/tap interface
+// ifPattern with queues queues, and returns one Interface per queue. The
+// kernel spreads the packets it sends to the interface over the queues by
+// flow, so each queue can be served by its own goroutine (and core). Each
+// Interface must be closed; the interface goes away, unless persistent, when
+// the last one is. Only supported on Linux.
+func OpenMultiQueue(ifPattern string, kind DevKind, queues int) ([]*Interface, error) {
+	if queues < 1 {
+		return nil, errors.New("tuntap: a multi-queue interface needs at least one queue")
+	}
+	qs, err := openMultiQueue(ifPattern, kind, queues)
+	if err != nil {
+		return nil, err
+	}
+	for i, q := range qs {
+		q.queue = i + 1
+		track(q, nil)
+	}
+	return qs, nil
+}
+
+// Detach disables the queue of a multi-queue Interface: the kernel stops
+// sending it packets, and the packets it held are dropped. Writing to it is
+// still possible.
+func (t *Interface) Detach() error {
+	return t.setQueue(false)
+}
+
+// Attach enables a queue disabled by Detach.
+func (t *Interface) Attach() error {
+	return t.setQueue(true)
+}
+
+// OpenMacvtap opens the macvtap interface ifName on top of the physical
+// interface parent, creating it in bridge mode if it doesn't exist yet, and
+// returns it as a DevTap Interface.
+//
+// A macvtap gets its own MAC address on the parent's link: ReadPacket returns
+// the frames the parent receives for that address (and broadcasts), and
+// WritePacket transmits frames directly out of the parent, without going
+// through a bridge. Like a persistent tap, the macvtap interface remains when
+// the Interface is closed, and must be deleted explicitly. Only implemented on
+// Linux.
+func OpenMacvtap(ifName string, parent string) (*Interface, error) {
+	t, _, err := openVtap("macvtap", ifName, parent)
+	return track(t, err)
+}
+
+// OpenIPvtap is like OpenMacvtap, but creates an ipvtap interface (in L2
+// mode), which shares the parent's MAC address and demultiplexes on IP
+// address instead. Useful where the link only allows one MAC address.
+func OpenIPvtap(ifName string, parent string) (*Interface, error) {
+	t, _, err := openVtap("ipvtap", ifName, parent)
+	return track(t, err)
 }
 
 // query parts of Packets
 // NOTE: think whether this wouldn't be better done with a interface and two implemenations, one for each protocol
 
+// ip returns the IP packet (or whatever else is behind the Ethernet header)
+// in the Body.
+func (p *Packet) ip() []byte {
+	if p.L3Offset > len(p.Body) {
+		return nil
+	}
+	return p.Body[p.L3Offset:]
+}
+
 // return the destination IP
 func (p *Packet) DIP() net.IP {
+	b := p.ip()
 	switch p.Protocol {
 	case ETH_P_IP:
-		if len(p.Body) >= 20 {
-			return net.IP(p.Body[16:20])
+		if len(b) >= 20 {
+			return net.IP(b[16:20])
 		}
 	case ETH_P_IPV6:
-		if len(p.Body) >= 40 {
-			return net.IP(p.Body[24:40])
+		if len(b) >= 40 {
+			return net.IP(b[24:40])
 		}
 	}
 	return net.IP{}
@@ -151,47 +1069,94 @@ func (p *Packet) DIP() net.IP {
 
 // return the source IP
 func (p *Packet) SIP() net.IP {
+	b := p.ip()
 	switch p.Protocol {
 	case ETH_P_IP:
-		if len(p.Body) >= 20 { // we'll insist the full IPv4 header is present to extract any field
-			return net.IP(p.Body[12:16])
+		if len(b) >= 20 { // we'll insist the full IPv4 header is present to extract any field
+			return net.IP(b[12:16])
 		}
 	case ETH_P_IPV6:
-		if len(p.Body) >= 40 {
-			return net.IP(p.Body[8:24])
+		if len(b) >= 40 {
+			return net.IP(b[8:24])
 		}
 	}
 	return net.IP{}
 }
 
+// SrcAddr returns the source IP address, or the zero Addr if the packet
+// isn't IP. Unlike SIP's, the address is a value: it doesn't alias Body,
+// and keeping it (as a map key, say) doesn't allocate.
+func (p *Packet) SrcAddr() netip.Addr {
+	return p.addr(12, 8)
+}
+
+// DstAddr returns the destination IP address, or the zero Addr if the packet
+// isn't IP. Like SrcAddr, it's a value, free of Body and allocations.
+func (p *Packet) DstAddr() netip.Addr {
+	return p.addr(16, 24)
+}
+
+// addr returns the address at offset at4 of the IPv4 header, or at6 of the
+// IPv6 one.
+func (p *Packet) addr(at4, at6 int) netip.Addr {
+	b := p.ip()
+	var a netip.Addr
+	switch p.Protocol {
+	case ETH_P_IP:
+		if len(b) >= 20 {
+			a, _ = netip.AddrFromSlice(b[at4 : at4+4])
+		}
+	case ETH_P_IPV6:
+		if len(b) >= 40 {
+			a, _ = netip.AddrFromSlice(b[at6 : at6+16])
+		}
+	}
+	return a
+}
+
 // return the 6-bit DSCP field
 func (p *Packet) DSCP() int {
+	b := p.ip()
 	switch p.Protocol {
 	case ETH_P_IP:
-		if len(p.Body) >= 20 { // we'll insist the full IPv4 header is present to extract any field
-			return int(p.Body[1] >> 2)
+		if len(b) >= 20 { // we'll insist the full IPv4 header is present to extract any field
+			return int(b[1] >> 2)
 		}
 	case ETH_P_IPV6:
-		if len(p.Body) >= 40 {
-			return int((p.Body[0]&0x0f)<<2 | (p.Body[1]&0xf0)>>6)
+		if len(b) >= 40 {
+			return int((b[0]&0x0f)<<2 | (b[1]&0xf0)>>6)
 		}
 	}
 	return 0
 }
 
-// return the IP protocol, the offset to the IP datagram payload, and true if the payload is from a non-first fragment
+// return the IP protocol, the offset in Body to the IP datagram payload, and true if the payload is from a non-first fragment
 // returns 0,0,false if parsing fails or 0,len(Body),false if the IPv6 header 59 (no-next-header) is found
 func (p *Packet) IPProto() (uint8, int, bool) {
-	switch p.Protocol {
+	proto, at, frag := ipProto(p.Protocol, p.ip())
+	if at != 0 {
+		at += p.L3Offset
+	}
+	return proto, at, frag
+}
+
+// ipProto does IPProto on the IP packet b, of ethernet protocol protocol.
+func ipProto(protocol uint16, b []byte) (uint8, int, bool) {
+	switch protocol {
 	case ETH_P_IP:
-		if len(p.Body) >= 20 { // we'll insist the full IPv4 header is present to extract any field
-			fragment := (p.Body[6]&0x1f)|p.Body[7] != 0
-			return p.Body[9], int(p.Body[0]&0xf) << 2, fragment
+		if len(b) >= 20 { // we'll insist the full IPv4 header is present to extract any field
+			fragment := (b[6]&0x1f)|b[7] != 0
+			ihl := int(b[0]&0xf) << 2
+			if ihl < 20 || ihl > len(b) {
+				// the header length is garbage
+				return 0, 0, false
+			}
+			return b[9], ihl, fragment
 		}
 	case ETH_P_IPV6:
-		if len(p.Body) >= 40 {
+		if len(b) >= 40 {
 			// finding the IP protocol in the case of IPv6 is slightly messy. we have to scan down the IPv6 header chain and find the last one
-			next := p.Body[6]
+			next := b[6]
 			at := 40
 			for {
 				switch next {
@@ -199,36 +1164,36 @@ func (p *Packet) IPProto() (uint8, int, bool) {
 					43, // routing extension
 					60: // destination options extension
 					// skip over this header and continue to the next one
-					if at+4 > len(p.Body) {
+					if at+4 > len(b) {
 						// off the end of the body. there must have been a garbage value somewhere
 						return 0, 0, false
 					}
-					next = p.Body[at]
-					at += 8 + int(p.Body[at+1])*8
+					next = b[at]
+					at += 8 + int(b[at+1])*8
 				case 44: // fragment extension
-					if at+8 > len(p.Body) {
+					if at+8 > len(b) {
 						return 0, 0, false
 					}
-					next = p.Body[at]
-					fragment := p.Body[at+2]|(p.Body[at+3]&0xf8) != 0
+					next = b[at]
+					fragment := b[at+2]|(b[at+3]&0xf8) != 0
 					at += 8
 					if fragment {
 						// this isn't the 1st fragment; are no further headers, only datagram body
 						return next, at, true
 					}
 				case 51: // AH header
-					if at+8 > len(p.Body) {
+					if at+8 > len(b) {
 						return 0, 0, false
 					}
-					next = p.Body[at]
-					at += 8 + int(p.Body[at+1])*4 // note unlike most IPv6 headers the length of AH is in 4-byte units
+					next = b[at]
+					at += 8 + int(b[at+1])*4 // note unlike most IPv6 headers the length of AH is in 4-byte units
 				case 59: // no next header
-					if at > len(p.Body) {
+					if at > len(b) {
 						return 0, 0, false
 					}
-					return 0, len(p.Body), false
+					return 0, len(b), false
 				default:
-					if at > len(p.Body) {
+					if at > len(b) {
 						return 0, 0, false
 					}
 					return next, at, false
@@ -258,6 +1223,115 @@ func (p *Packet) ICMPType() (int, int, int) {
 	return 0, 0, 0
 }
 
+// TCP flags, as TCPFlags returns them
+const (
+	TCPFin = 1 << iota
+	TCPSyn
+	TCPRst
+	TCPPsh
+	TCPAck
+	TCPUrg
+	TCPEce
+	TCPCwr
+)
+
+// transport returns the IP protocol, and the offset in Body to the header of
+// the TCP, UDP, UDP-Lite or SCTP segment and its length; 0,0,0 for other
+// packets, and for fragments other than the first, which have no header.
+func (p *Packet) transport() (uint8, int, int) {
+	proto, at, frag := p.IPProto()
+	if frag {
+		return 0, 0, 0
+	}
+	var hl int
+	switch proto {
+	case 6: // TCP
+		if at+20 > len(p.Body) {
+			return 0, 0, 0
+		}
+		hl = int(p.Body[at+12]>>4) << 2
+		if hl < 20 {
+			return 0, 0, 0
+		}
+	case 17, 136: // UDP, UDP-Lite
+		hl = 8
+	case 132: // SCTP: the common header; the chunks follow
+		hl = 12
+	default:
+		return 0, 0, 0
+	}
+	if at+hl > len(p.Body) {
+		return 0, 0, 0
+	}
+	return proto, at, hl
+}
+
+// ports returns the source and destination ports of a TCP, UDP, UDP-Lite or
+// SCTP segment, which are in the same place. Only they need be there, so
+// that the ports of a truncated packet are known.
+func (p *Packet) ports() (uint16, uint16, bool) {
+	proto, at, frag := p.IPProto()
+	switch proto {
+	case 6, 17, 132, 136: // TCP, UDP, SCTP, UDP-Lite
+		if !frag && at+4 <= len(p.Body) {
+			return binary.BigEndian.Uint16(p.Body[at:]), binary.BigEndian.Uint16(p.Body[at+2:]), true
+		}
+	}
+	return 0, 0, false
+}
+
+// SrcPort returns the source port of a TCP, UDP, UDP-Lite or SCTP packet, or
+// 0 for other packets and fragments other than the first.
+func (p *Packet) SrcPort() uint16 {
+	sp, _, _ := p.ports()
+	return sp
+}
+
+// DstPort returns the destination port of a TCP, UDP, UDP-Lite or SCTP
+// packet, or 0 for other packets and fragments other than the first.
+func (p *Packet) DstPort() uint16 {
+	_, dp, _ := p.ports()
+	return dp
+}
+
+// TCPFlags returns the flags of a TCP segment (TCPSyn|TCPAck...), and false
+// if the packet isn't one, or is a fragment other than the first.
+func (p *Packet) TCPFlags() (uint8, bool) {
+	proto, at, _ := p.transport()
+	if proto != 6 {
+		return 0, false
+	}
+	return p.Body[at+13], true
+}
+
+// TransportPayload returns the payload of a TCP, UDP, UDP-Lite or SCTP
+// packet, behind its header (the chunks of an SCTP packet), or nil for
+// other packets and fragments other than the first. Unlike Payload's, it
+// stops at the end of the IP packet, leaving out the padding of a short
+// Ethernet frame.
+func (p *Packet) TransportPayload() []byte {
+	proto, at, hl := p.transport()
+	if proto == 0 {
+		return nil
+	}
+	end := len(p.Body)
+	b := p.ip()
+	switch p.Protocol {
+	case ETH_P_IP:
+		if l := p.L3Offset + int(binary.BigEndian.Uint16(b[2:4])); l < end {
+			end = l
+		}
+	case ETH_P_IPV6:
+		if l := p.L3Offset + 40 + int(binary.BigEndian.Uint16(b[4:6])); l < end {
+			end = l
+		}
+	}
+	if at+hl > end {
+		return nil
+	}
+	return p.Body[at+hl : end]
+}
+
 func (p *Packet) String() string {
 	s := fmt.Sprintf("%v -> %v", p.SIP(), p.DIP())
 	dscp := p.DSCP()
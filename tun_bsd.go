@@ -0,0 +1,398 @@
+//go:build freebsd || darwin || openbsd || netbsd
+
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2022-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path"
+	"runtime"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+// What the BSDs and macOS have in common: the interface is configured with
+// the same socket ioctls, on structures which only differ in their sizes.
+
+var nativeEndian binary.ByteOrder
+
+func init() {
+	buf := [2]byte{}
+	*(*uint16)(unsafe.Pointer(&buf[0])) = uint16(0xABCD)
+	switch buf {
+	case [2]byte{0xCD, 0xAB}:
+		nativeEndian = binary.LittleEndian
+	case [2]byte{0xAB, 0xCD}:
+		nativeEndian = binary.BigEndian
+	default:
+		panic("Could not determine native endianness.")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// none of the BSDs has multi-queue tun devices
+func openMultiQueue(ifPattern string, kind DevKind, queues int) ([]*Interface, error) {
+	return nil, ErrNotSupported
+}
+
+// openConfigured opens the interface ifPattern as createInterface does, with
+// the virtio-net header of cfg, which only FreeBSD's tap has
+func openConfigured(ifPattern string, kind DevKind, cfg *config) (*Interface, error) {
+	if cfg.multiQueue || cfg.vnetHdr && (kind != DevTap || runtime.GOOS != "freebsd") {
+		return nil, ErrNotSupported
+	}
+	if cfg.offloads&^OffloadCsum != 0 && cfg.offloads&OffloadCsum == 0 {
+		return nil, errors.New("tuntap: segmentation offloads need OffloadCsum")
+	}
+	t, err := createInterface(ifPattern, kind)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.vnetHdr {
+		if err = t.vnetTap(cfg.offloads); err != nil {
+			t.file.Close()
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// newFromFD wraps fd with the framing createInterface gives the interface of
+// kind, as there's no asking the fd how it was set up.
+func newFromFD(fd int, kind DevKind, name string) (*Interface, error) {
+	if kind != DevTun && (kind != DevTap || runtime.GOOS == "darwin") {
+		return nil, fmt.Errorf("tuntap: unsupported tuntap interface type %d", int(kind))
+	}
+	if name == "" {
+		return nil, errors.New("tuntap: the name of the interface on fd is needed")
+	}
+	if err := unix.SetNonblock(fd, true); err != nil {
+		return nil, errors.Wrapf(err, "tuntap: can't set nonblocking mode on %s", name)
+	}
+	t := &Interface{name: name, file: os.NewFile(uintptr(fd), name), kind: kind, framing: frameNone}
+	if kind == DevTun {
+		t.framing = frameAF
+	}
+	return t, nil
+}
+
+// SetPersist is only supported on Linux.
+func (t *Interface) SetPersist(persist bool) error {
+	return ErrNotSupported
+}
+
+// AddAddressLabel is only supported on Linux.
+func (t *Interface) AddAddressLabel(p netip.Prefix, label uint32) error {
+	return ErrNotSupported
+}
+
+// DelAddressLabel is only supported on Linux.
+func (t *Interface) DelAddressLabel(p netip.Prefix, label uint32) error {
+	return ErrNotSupported
+}
+
+// PreferSource is only supported on Linux.
+func (t *Interface) PreferSource(dst netip.Prefix, src netip.Addr) error {
+	return ErrNotSupported
+}
+
+// InterfaceLabel is only supported on Linux.
+func (t *Interface) InterfaceLabel() (uint32, error) {
+	return 0, ErrNotSupported
+}
+
+// the BSDs have no SO_BINDTODEVICE (IP_BOUND_IF is macOS's)
+func listenICMP(ifName string, ipv6 bool) (*ICMPConn, error) {
+	return nil, ErrNotSupported
+}
+
+// network namespaces are Linux's
+func inNamespace(nsPath string, f func() error) error {
+	return ErrNotSupported
+}
+
+// MoveToNamespace is only supported on Linux.
+func (t *Interface) MoveToNamespace(nsPath string) error {
+	return ErrNotSupported
+}
+
+// Announce is only supported on Linux.
+func (t *Interface) Announce() error {
+	return ErrNotSupported
+}
+
+// AttachFilter is only supported on Linux.
+func (t *Interface) AttachFilter(prog []BPFInstruction) error {
+	return ErrNotSupported
+}
+
+// DetachFilter is only supported on Linux.
+func (t *Interface) DetachFilter() error {
+	return ErrNotSupported
+}
+
+// SetSteeringEBPF is only supported on Linux.
+func (t *Interface) SetSteeringEBPF(progFD int) error {
+	return ErrNotSupported
+}
+
+// SetFilterEBPF is only supported on Linux.
+func (t *Interface) SetFilterEBPF(progFD int) error {
+	return ErrNotSupported
+}
+
+// SetOwner is only supported on Linux.
+func (t *Interface) SetOwner(uid int) error {
+	return ErrNotSupported
+}
+
+// SetGroup is only supported on Linux.
+func (t *Interface) SetGroup(gid int) error {
+	return ErrNotSupported
+}
+
+// writev writes bufs as one packet. There's no writev(2) in x/sys/unix on
+// the BSDs, so the pieces are gathered into one buffer for write(2).
+func (t *Interface) writev(bufs [][]byte) (int, error) {
+	n := 0
+	for _, b := range bufs {
+		n += len(b)
+	}
+	buf, b := getBuffer(n)
+	defer buf.put()
+	b = b[:0]
+	for _, p := range bufs {
+		b = append(b, p...)
+	}
+	return t.write(b)
+}
+
+func (t *Interface) setQueue(attach bool) error {
+	return ErrNotSupported
+}
+
+func isIPv4(ip net.IP) bool {
+	return ip.To4().To16().Equal(ip)
+}
+
+func in6SockAddr(buf []byte, ip net.IP) {
+	if ip == nil {
+		return
+	}
+	// uint8 sin6_len, length of this struct
+	buf[0] = sizeofIn6SockAddr
+	// uint8 sin6_family, AF_INET6
+	buf[1] = unix.AF_INET6
+	// uint16 sin6_port, Transport layer port #
+	nativeEndian.PutUint16(buf[2:], 0)
+	// uint32 sin6_flowinfo, IP6 flow information
+	nativeEndian.PutUint32(buf[4:], 0)
+	// [16]byte sin6_addr, IP6 address
+	copy(buf[8:], ip)
+	// uint32 sin6_scope_id, scope zone index
+	nativeEndian.PutUint32(buf[24:], 0)
+}
+
+func in6AddrLifetime(buf []byte) {
+	ofs := 0
+	// time_t ia6t_expire, valid lifetime expiration time
+	ofs += sizeofTime
+	// time_t ia6t_preferred, preferred lifetime expiration time
+	ofs += sizeofTime
+	// u_int32_t ia6t_vltime, valid lifetime
+	nativeEndian.PutUint32(buf[ofs:], ND6_INFINITE_LIFETIME)
+	ofs += 4
+	// u_int32_t ia6t_pltime, prefix lifetime
+	nativeEndian.PutUint32(buf[ofs:], ND6_INFINITE_LIFETIME)
+	ofs += 4
+}
+
+// AddAddressWith adds an IP address with options to the tunnel interface.
+func (t *Interface) AddAddressWith(ip net.IP, subnet *net.IPNet, opts AddressOptions) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	if err := opts.check(ip); err != nil {
+		return err
+	}
+	if isIPv4(ip) {
+		if err := t.addAddress4(ip, subnet, opts.Broadcast); err != nil {
+			return err
+		}
+		t.applied(t.addressEntry(ip, subnet))
+		return nil
+	}
+
+	// build the in6_aliasreq structure
+	var ifra [sizeofIn6AliasReq]byte
+
+	ifName := path.Base(t.Name())
+	copy(ifra[:IFNAMSIZ], []byte(ifName))
+	ofs := IFNAMSIZ
+	// ifra_addr
+	in6SockAddr(ifra[ofs:], ip)
+	ofs += sizeofIn6SockAddr
+	// ifra_dstaddr
+	in6SockAddr(ifra[ofs:], nil)
+	ofs += sizeofIn6SockAddr
+	// ifra_prefixmask
+	in6SockAddr(ifra[ofs:], net.IP(subnet.Mask))
+	ofs += sizeofIn6SockAddr
+	// ifra_flags
+	var flags uint32
+	if opts.Anycast {
+		flags = IN6_IFF_ANYCAST
+	}
+	nativeEndian.PutUint32(ifra[ofs:], flags)
+	ofs += sizeofInt
+	// ifra_lifetime
+	in6AddrLifetime(ifra[ofs:])
+	// and on FreeBSD ifra_vhid, left 0
+
+	// do the ioctl
+	fd, err := unix.Socket(unix.AF_INET6, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+
+	err = ioctl(fd, SIOCAIFADDR_IN6, unsafe.Pointer(&ifra))
+	if err != nil {
+		return err
+	}
+	t.applied(t.addressEntry(ip, subnet))
+
+	return unix.Close(fd)
+
+}
+
+// SetMTU sets the tunnel interface MTU size.
+func (t *Interface) SetMTU(mtu int) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	// build the ifreq structure
+	var ifreq [sizeofIfreq]byte
+	ifName := path.Base(t.Name())
+	copy(ifreq[:IFNAMSIZ], []byte(ifName))
+	nativeEndian.PutUint32(ifreq[IFNAMSIZ:], uint32(mtu)) // sizeof(int) == 4
+	// do the ioctl
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	err = ioctl(fd, unix.SIOCSIFMTU, unsafe.Pointer(&ifreq))
+	if err != nil {
+		return err
+	}
+	t.mtuChanged(mtu)
+	return unix.Close(fd)
+}
+
+// Up sets the tunnel interface to the UP state.
+func (t *Interface) Up() error {
+	return t.setUp(true)
+}
+
+// Down sets the tunnel interface to the DOWN state.
+func (t *Interface) Down() error {
+	return t.setUp(false)
+}
+
+// Flags returns the flags of the interface, with SIOCGIFFLAGS.
+func (t *Interface) Flags() (net.Flags, error) {
+	if err := t.configurable(); err != nil {
+		return 0, err
+	}
+	var ifreq [sizeofIfreq]byte
+	copy(ifreq[:IFNAMSIZ], path.Base(t.Name()))
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer unix.Close(fd)
+	if err = ioctl(fd, unix.SIOCGIFFLAGS, unsafe.Pointer(&ifreq)); err != nil {
+		return 0, err
+	}
+	return netFlags(int(nativeEndian.Uint16(ifreq[IFNAMSIZ:]))), nil
+}
+
+// setUp sets or clears IFF_UP on the interface.
+func (t *Interface) setUp(up bool) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	// build the ifreq structure
+	var ifreq [sizeofIfreq]byte
+	ifName := path.Base(t.Name())
+	copy(ifreq[:IFNAMSIZ], []byte(ifName))
+	// get the interface flags
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	err = ioctl(fd, unix.SIOCGIFFLAGS, unsafe.Pointer(&ifreq))
+	if err != nil {
+		return err
+	}
+	// set the interface flags
+	flagsLo := nativeEndian.Uint16(ifreq[IFNAMSIZ:])
+	if up {
+		flagsLo |= unix.IFF_UP
+	} else {
+		flagsLo &^= unix.IFF_UP
+	}
+	nativeEndian.PutUint16(ifreq[IFNAMSIZ:], flagsLo)
+	err = ioctl(fd, unix.SIOCSIFFLAGS, unsafe.Pointer(&ifreq))
+	if err != nil {
+		return err
+	}
+	return unix.Close(fd)
+}
+
+// GetAddrList returns the IP addresses (as bytes) associated with the interface.
+func (t *Interface) GetAddrList() ([][]byte, error) {
+	if err := t.configurable(); err != nil {
+		return nil, err
+	}
+	// get the net.Interface using the tunnel name
+	itf, err := net.InterfaceByName(path.Base(t.Name()))
+	if err != nil {
+		return nil, err
+	}
+	// get the ip address list for the interface
+	addrList, err := itf.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	// parse the address strings and convert to bytes
+	addrs := [][]byte{}
+	for _, addr := range addrList {
+		ip, _, err := net.ParseCIDR(addr.String())
+		if err != nil {
+			return nil, err
+		}
+		if isIPv4(ip) {
+			// it's an IPv4 address- just use the 4 bytes
+			ip = ip.To4()
+		}
+		addrs = append(addrs, ip)
+	}
+	return addrs, nil
+}
+
+//-----------------------------------------------------------------------------
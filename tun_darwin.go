@@ -0,0 +1,197 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+// macOS has no tun driver, but a utun kernel control, which is what VPN
+// clients use: a PF_SYSTEM socket connected to the utun control creates the
+// utunN interface, and then carries its packets, each one prefixed with its
+// address family in 4 bytes. There is no tap equivalent.
+
+const utunControlName = "com.apple.net.utun_control"
+
+const afInet6 = unix.AF_INET6
+
+// utunUnit returns the utun control unit for the interface name: 0 to let the
+// kernel pick the first free utun for a pattern (any name with a %d, so that
+// the "tun%d" of other platforms works), or N+1 for "utunN".
+func utunUnit(ifPattern string) (uint32, error) {
+	if ifPattern == "" || strings.Contains(ifPattern, "%d") {
+		return 0, nil
+	}
+	if strings.HasPrefix(ifPattern, "utun") {
+		if n, err := strconv.ParseUint(ifPattern[4:], 10, 31); err == nil {
+			return uint32(n) + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("tuntap: utun interfaces must be named utunN, not %q", ifPattern)
+}
+
+func createInterface(ifPattern string, kind DevKind) (*Interface, error) {
+	if kind != DevTun {
+		return nil, fmt.Errorf("tuntap: unsupported tuntap interface type %d; macOS only has DevTun", int(kind))
+	}
+	unit, err := utunUnit(ifPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := unix.Socket(unix.AF_SYSTEM, unix.SOCK_DGRAM, SYSPROTO_CONTROL)
+	if err != nil {
+		return nil, errors.Wrap(err, "tuntap: Can't create utun control socket")
+	}
+	unix.CloseOnExec(fd)
+
+	info := unix.CtlInfo{}
+	copy(info.Name[:], utunControlName)
+	if err = unix.IoctlCtlInfo(fd, &info); err != nil {
+		unix.Close(fd)
+		return nil, errors.Wrapf(err, "tuntap: Can't ioctl(CTLIOCGINFO) for %s", utunControlName)
+	}
+	if err = unix.Connect(fd, &unix.SockaddrCtl{ID: info.Id, Unit: unit}); err != nil {
+		unix.Close(fd)
+		return nil, errors.Wrapf(err, "tuntap: Can't connect to %s", utunControlName)
+	}
+	ifName, err := unix.GetsockoptString(fd, SYSPROTO_CONTROL, UTUN_OPT_IFNAME)
+	if err != nil {
+		unix.Close(fd)
+		return nil, errors.Wrap(err, "tuntap: Can't get the utun interface name")
+	}
+
+	if err = unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, errors.Wrapf(err, "tuntap: Can't set nonblocking mode on %s", ifName)
+	}
+	file := os.NewFile(uintptr(fd), ifName)
+	return &Interface{name: ifName, file: file, kind: DevTun, framing: frameAF}, nil
+}
+
+// the syscalls of a sealed Interface
+var dataPathSyscalls = []string{"read", "write", "close", "kevent"}
+
+// utun interfaces have no device file
+var devicePaths []string
+
+//-----------------------------------------------------------------------------
+
+func openRaw(ifName string) (*Interface, error) {
+	// the equivalent would be bpf(4)
+	return nil, ErrNotSupported
+}
+
+func openVtap(typ, ifName, parent string) (*Interface, bool, error) {
+	return nil, false, ErrNotSupported
+}
+
+func deleteVtap(ifName string) error {
+	return ErrNotSupported
+}
+
+func createVethPair(nameA, nameB string, cfg vethConfig) error {
+	return ErrNotSupported
+}
+
+// vnetTap has no counterpart: only FreeBSD's tap takes a virtio-net header
+func (t *Interface) vnetTap(offloads Offload) error {
+	return ErrNotSupported
+}
+
+// there are no capabilities, only root's privileges
+func dropCapabilityBounds() error {
+	return nil
+}
+
+// macOS has no no_new_privs
+func lockPrivileges() error {
+	return nil
+}
+
+// the App Sandbox is set up by entitlements, not at run time
+func restrictPaths(paths []string) error {
+	return ErrNotSupported
+}
+
+//-----------------------------------------------------------------------------
+
+// DelAddress is only supported on Linux and FreeBSD.
+func (t *Interface) DelAddress(ip net.IP, subnet *net.IPNet) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// AddRoute is only supported on Linux and FreeBSD.
+func (t *Interface) AddRoute(r Route) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// DelRoute is only supported on Linux and FreeBSD.
+func (t *Interface) DelRoute(r Route) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// RoutesIn is only supported on Linux and FreeBSD.
+func (t *Interface) RoutesIn(table int) ([]Route, error) {
+	if err := t.configurable(); err != nil {
+		return nil, err
+	}
+	return nil, ErrNotSupported
+}
+
+// SetHardwareAddr is only supported on Linux and FreeBSD.
+func (t *Interface) SetHardwareAddr(mac net.HardwareAddr) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// IPv6SLAAC enables/disables stateless address auto-configuration (SLAAC) for the interface.
+func (t *Interface) IPv6SLAAC(ctrl bool) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// IPv6Forwarding enables/disables ipv6 forwarding for the interface.
+func (t *Interface) IPv6Forwarding(ctrl bool) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// IPv6 enables/disable ipv6 for the interface.
+func (t *Interface) IPv6(ctrl bool) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+//-----------------------------------------------------------------------------
@@ -9,7 +9,6 @@ Copyright Juniper Networks Inc. 2022-2022. All rights reserved.
 package tuntap
 
 import (
-	"encoding/binary"
 	"fmt"
 	"net"
 	"os"
@@ -22,23 +21,13 @@ import (
 )
 
 //-----------------------------------------------------------------------------
-
-var nativeEndian binary.ByteOrder
-
-func init() {
-	buf := [2]byte{}
-	*(*uint16)(unsafe.Pointer(&buf[0])) = uint16(0xABCD)
-	switch buf {
-	case [2]byte{0xCD, 0xAB}:
-		nativeEndian = binary.LittleEndian
-	case [2]byte{0xAB, 0xCD}:
-		nativeEndian = binary.BigEndian
-	default:
-		panic("Could not determine native endianness.")
-	}
-}
-
-//-----------------------------------------------------------------------------
+// FreeBSD's tun(4) only puts the packet's address family in front of it in
+// "interface head" mode (TUNSIFHEAD), and without it can only send IPv4, so
+// the mode is always turned on. tap(4) frames have no header. Opening
+// /dev/tunN or /dev/tapN creates the interface if need be, and opening
+// /dev/tun or /dev/tap clones a new one, whose name TUNGIFNAME (TAPGIFNAME)
+// gives. As both are served by the same driver, the type of the interface
+// (TAPGIFINFO) must match the kind asked for.
 
 func createInterface(ifPattern string, kind DevKind) (*Interface, error) {
 
@@ -66,160 +55,261 @@ func createInterface(ifPattern string, kind DevKind) (*Interface, error) {
 		return nil, errors.Wrapf(err, "tuntap: can't open %s", ifName)
 	}
 
+	// the name of the interface, which a cloned one didn't have yet
+	var ifr [sizeofIfreq]byte
+	if err = ioctl(fd, TAPGIFNAME, unsafe.Pointer(&ifr)); err != nil {
+		unix.Close(fd)
+		return nil, errors.Wrapf(err, "tuntap: can't get the interface name of %s", ifName)
+	}
+	ifName = "/dev/" + unix.ByteSliceToString(ifr[:IFNAMSIZ])
+
+	var info tapInfo
+	if err = ioctl(fd, TAPGIFINFO, unsafe.Pointer(&info)); err != nil {
+		unix.Close(fd)
+		return nil, errors.Wrapf(err, "tuntap: can't get TAPGIFINFO on %s", ifName)
+	}
+	if (info.Type == IFT_ETHER) != (kind == DevTap) {
+		unix.Close(fd)
+		return nil, &KindMismatchError{Name: ifName, Want: kind, Got: 1 - kind}
+	}
+
 	if kind == DevTun {
-		// Disable extended modes
+		// Disable link-layer mode, and put the address family in front
 		if err = unix.IoctlSetPointerInt(fd, TUNSLMODE, 0); err != nil {
+			unix.Close(fd)
 			return nil, errors.Wrapf(err, "tuntap: can't clear TUNSLMODE on %s", ifName)
 		}
-		if err = unix.IoctlSetPointerInt(fd, TUNSIFHEAD, 0); err != nil {
-			return nil, errors.Wrapf(err, "tuntap: can't clear TUNSIFHEAD on %s", ifName)
+		if err = unix.IoctlSetPointerInt(fd, TUNSIFHEAD, 1); err != nil {
+			unix.Close(fd)
+			return nil, errors.Wrapf(err, "tuntap: can't set TUNSIFHEAD on %s", ifName)
 		}
 	}
 
-	if kind == DevTap {
-		// TODO
+	// in nonblocking mode the fd is handled by go's runtime poller
+	if err = unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, errors.Wrapf(err, "tuntap: can't set nonblocking mode on %s", ifName)
 	}
 
 	file := os.NewFile(uintptr(fd), ifName)
-	return &Interface{ifName, file}, nil
+	t := &Interface{name: ifName, file: file, kind: kind, framing: frameNone}
+	if kind == DevTun {
+		t.framing = frameAF
+	}
+	return t, nil
 }
 
+const afInet6 = unix.AF_INET6
+
+// the syscalls of a sealed Interface
+var dataPathSyscalls = []string{"read", "write", "close", "kevent"}
+
 //-----------------------------------------------------------------------------
 
-func ioctl(fd int, req uint, arg uintptr) error {
-	_, _, err := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg))
-	if err != 0 {
-		return fmt.Errorf("(%d) %s", err, unix.ErrnoName(err))
-	}
-	return nil
+func openRaw(ifName string) (*Interface, error) {
+	// the equivalent on FreeBSD would be bpf(4)
+	return nil, ErrNotSupported
 }
 
-func isIPv4(ip net.IP) bool {
-	return ip.To4().To16().Equal(ip)
+func openVtap(typ, ifName, parent string) (*Interface, bool, error) {
+	return nil, false, ErrNotSupported
 }
 
-func in6SockAddr(buf []byte, ip net.IP) {
-	if ip == nil {
-		return
-	}
-	// uint8 sin6_len, length of this struct
-	buf[0] = sizeofIn6SockAddr
-	// uint8 sin6_family, AF_INET6
-	buf[1] = unix.AF_INET6
-	// uint16 sin6_port, Transport layer port #
-	nativeEndian.PutUint16(buf[2:], 0)
-	// uint32 sin6_flowinfo, IP6 flow information
-	nativeEndian.PutUint32(buf[4:], 0)
-	// [16]byte sin6_addr, IP6 address
-	copy(buf[8:], ip)
-	// uint32 sin6_scope_id, scope zone index
-	nativeEndian.PutUint32(buf[24:], 0)
+func deleteVtap(ifName string) error {
+	return ErrNotSupported
+}
+
+func createVethPair(nameA, nameB string, cfg vethConfig) error {
+	// epair(4) is the closest thing, but it can't be given arbitrary names at creation
+	return ErrNotSupported
+}
+
+var devicePaths = []string{"/dev/tun", "/dev/tap"}
+
+// restrictPaths has no counterpart; FreeBSD's Capsicum restricts a process
+// to the descriptors it has, with no paths at all, which is another model.
+func restrictPaths(paths []string) error {
+	return ErrNotSupported
+}
+
+// there are no capabilities, only root's privileges
+func dropCapabilityBounds() error {
+	return nil
 }
 
-func in6AddrLifetime(buf []byte) {
-	ofs := 0
-	// time_t ia6t_expire, valid lifetime expiration time
-	ofs += sizeofTime
-	// time_t ia6t_preferred, preferred lifetime expiration time
-	ofs += sizeofTime
-	// u_int32_t ia6t_vltime, valid lifetime
-	nativeEndian.PutUint32(buf[ofs:], ND6_INFINITE_LIFETIME)
-	ofs += 4
-	// u_int32_t ia6t_pltime, prefix lifetime
-	nativeEndian.PutUint32(buf[ofs:], ND6_INFINITE_LIFETIME)
-	ofs += 4
+// lockPrivileges would be procctl(PROC_NO_NEW_PRIVS_CTL), but that's only
+// in FreeBSD 14 and later, and changing the uid is what matters.
+func lockPrivileges() error {
+	return nil
 }
 
-// AddAddress adds an IP address to the tunnel interface.
-func (t *Interface) AddAddress(ip net.IP, subnet *net.IPNet) error {
+//-----------------------------------------------------------------------------
 
+func (t *Interface) addAddress4(ip net.IP, subnet *net.IPNet, brd net.IP) error {
+	return errors.New("ipv4 addresses not supported")
+}
+
+// DelAddress removes an IP address from the tunnel interface. The kernel
+// goes by the address alone; subnet is unused.
+func (t *Interface) DelAddress(ip net.IP, subnet *net.IPNet) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
 	if isIPv4(ip) {
 		return errors.New("ipv4 addresses not supported")
 	}
 
-	// build the in6_aliasreq structure
-	var ifra [sizeofIn6AliasReq]byte
-
-	ifName := path.Base(t.Name())
-	copy(ifra[:IFNAMSIZ], []byte(ifName))
-	ofs := IFNAMSIZ
-	// ifra_addr
-	in6SockAddr(ifra[ofs:], ip)
-	ofs += sizeofIn6SockAddr
-	// ifra_dstaddr
-	in6SockAddr(ifra[ofs:], nil)
-	ofs += sizeofIn6SockAddr
-	// ifra_prefixmask
-	in6SockAddr(ifra[ofs:], net.IP(subnet.Mask))
-	ofs += sizeofIn6SockAddr
-	// ifra_flags
-	nativeEndian.PutUint32(ifra[ofs:], 0)
-	ofs += sizeofInt
-	// ifra_lifetime
-	in6AddrLifetime(ifra[ofs:])
-	ofs += sizeofIn6AddrLifetime
-	// ifra_vhid
-	nativeEndian.PutUint32(ifra[ofs:], 0)
-	ofs += sizeofInt
-
-	// do the ioctl
+	// build the in6_ifreq structure
+	var ifr [sizeofIn6Ifreq]byte
+	copy(ifr[:IFNAMSIZ], path.Base(t.Name()))
+	// ifr_addr
+	in6SockAddr(ifr[IFNAMSIZ:], ip)
+
 	fd, err := unix.Socket(unix.AF_INET6, unix.SOCK_DGRAM, 0)
 	if err != nil {
 		return err
 	}
-
-	err = ioctl(fd, SIOCAIFADDR_IN6, uintptr(unsafe.Pointer(&ifra)))
-	if err != nil {
+	defer unix.Close(fd)
+	if err := ioctl(fd, SIOCDIFADDR_IN6, unsafe.Pointer(&ifr)); err != nil {
 		return err
 	}
-
-	return unix.Close(fd)
-
+	t.reverted(t.addressEntry(ip, subnet))
+	return nil
 }
 
-// SetMTU sets the tunnel interface MTU size.
-func (t *Interface) SetMTU(mtu int) error {
-	// build the ifreq structure
+// SetHardwareAddr sets the MAC address of a DevTap interface with
+// SIOCSIFLLADDR.
+func (t *Interface) SetHardwareAddr(mac net.HardwareAddr) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	if t.kind != DevTap {
+		return ErrNotSupported
+	}
+	if len(mac) != 6 {
+		return errors.New("not an Ethernet address")
+	}
+
+	// build the ifreq structure: ifr_addr holds the address in sa_data
 	var ifreq [sizeofIfreq]byte
-	ifName := path.Base(t.Name())
-	copy(ifreq[:IFNAMSIZ], []byte(ifName))
-	nativeEndian.PutUint32(ifreq[IFNAMSIZ:], uint32(mtu)) // sizeof(int) == 4
-	// do the ioctl
+	copy(ifreq[:IFNAMSIZ], path.Base(t.Name()))
+	ifreq[IFNAMSIZ] = byte(len(mac))
+	ifreq[IFNAMSIZ+1] = unix.AF_LINK
+	copy(ifreq[IFNAMSIZ+2:], mac)
+
 	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
 	if err != nil {
 		return err
 	}
-	err = ioctl(fd, unix.SIOCSIFMTU, uintptr(unsafe.Pointer(&ifreq)))
-	if err != nil {
-		return err
+	defer unix.Close(fd)
+	return ioctl(fd, unix.SIOCSIFLLADDR, unsafe.Pointer(&ifreq))
+}
+
+// SetPointToPoint switches the tun interface between point-to-point (true)
+// and broadcast (false) mode with TUNSIFMODE. Interfaces start out
+// point-to-point. The mode can't be changed while the interface is UP.
+// FreeBSD only.
+func (t *Interface) SetPointToPoint(p2p bool) error {
+	if t.kind != DevTun {
+		return ErrNotSupported
+	}
+	mode := unix.IFF_BROADCAST
+	if p2p {
+		mode = unix.IFF_POINTOPOINT
 	}
-	return unix.Close(fd)
+	return t.control(func(fd uintptr) error {
+		return unix.IoctlSetPointerInt(int(fd), TUNSIFMODE, mode|unix.IFF_MULTICAST)
+	})
 }
 
-// Up sets the tunnel interface to the UP state.
-func (t *Interface) Up() error {
-	// build the ifreq structure
-	var ifreq [sizeofIfreq]byte
-	ifName := path.Base(t.Name())
-	copy(ifreq[:IFNAMSIZ], []byte(ifName))
-	// get the interface flags
-	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
-	if err != nil {
+// SetDebug sets the debug level of the tun/tap driver for the interface
+// (TUNSDEBUG/TAPSDEBUG). Non-zero levels log to the kernel message buffer.
+// FreeBSD only.
+func (t *Interface) SetDebug(level int) error {
+	req := uint(TUNSDEBUG)
+	if t.kind == DevTap {
+		req = TAPSDEBUG
+	}
+	return t.control(func(fd uintptr) error {
+		return unix.IoctlSetPointerInt(int(fd), req, level)
+	})
+}
+
+// Debug returns the debug level of the tun/tap driver for the interface
+// (TUNGDEBUG/TAPGDEBUG). FreeBSD only.
+func (t *Interface) Debug() (int, error) {
+	req := uint(TUNGDEBUG)
+	if t.kind == DevTap {
+		req = TAPGDEBUG
+	}
+	var level int
+	err := t.control(func(fd uintptr) error {
+		var err error
+		level, err = unix.IoctlGetInt(int(fd), req)
 		return err
+	})
+	return level, err
+}
+
+// SetOwnerPID records the calling process as the owner of the tun device
+// (TUNSIFPID), as reported by ifconfig. Useful when the device was opened by
+// a parent process and handed down. FreeBSD only.
+func (t *Interface) SetOwnerPID() error {
+	if t.kind != DevTun {
+		return ErrNotSupported
+	}
+	return t.control(func(fd uintptr) error {
+		return ioctl(int(fd), TUNSIFPID, nil)
+	})
+}
+
+// SetVnetHdrLen sets the length of the virtio-net header the tap device puts
+// in front of each frame (TAPSVNETHDR): 0 to disable it, 10 for struct
+// virtio_net_hdr or 12 for struct virtio_net_hdr_mrg_rxbuf. Enabling the
+// header also enables the checksum and segmentation offloads it describes.
+// The header of each frame read is then in Packet.Vnet, and that of each
+// frame written is taken from there, as WithVnetHdr has it; it must not be
+// changed while packets are read or written. FreeBSD tap only.
+func (t *Interface) SetVnetHdrLen(n int) error {
+	if t.kind != DevTap || t.framing != frameNone {
+		return ErrNotSupported
 	}
-	err = ioctl(fd, unix.SIOCGIFFLAGS, uintptr(unsafe.Pointer(&ifreq)))
+	if n != 0 && n != VnetHdrLen && n != VnetHdrLen+2 {
+		return errors.Errorf("tuntap: can't set a virtio-net header of %d bytes", n)
+	}
+	err := t.control(func(fd uintptr) error {
+		return unix.IoctlSetPointerInt(int(fd), TAPSVNETHDR, n)
+	})
 	if err != nil {
 		return err
 	}
-	// set the interface flags
-	flagsLo := nativeEndian.Uint16(ifreq[IFNAMSIZ:])
-	flagsLo |= unix.IFF_UP
-	nativeEndian.PutUint16(ifreq[IFNAMSIZ:], flagsLo)
-	err = ioctl(fd, unix.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&ifreq)))
-	if err != nil {
+	t.vnetHdr, t.vnetLen = n != 0, n
+	return nil
+}
+
+// vnetTap enables the virtio-net header of the tap t for WithVnetHdr, which
+// comes with the offloads (TSO, and checksums) but USO.
+func (t *Interface) vnetTap(offloads Offload) error {
+	if err := t.SetVnetHdrLen(VnetHdrLen); err != nil {
 		return err
 	}
-	return unix.Close(fd)
+	t.offloads = offloads &^ OffloadUSO
+	return nil
+}
+
+// VnetHdrLen returns the length of the virtio-net header the tap device puts
+// in front of each frame (TAPGVNETHDR), 0 if none. FreeBSD tap only.
+func (t *Interface) VnetHdrLen() (int, error) {
+	if t.kind != DevTap {
+		return 0, nil
+	}
+	var n int
+	err := t.control(func(fd uintptr) error {
+		var err error
+		n, err = unix.IoctlGetInt(int(fd), TAPGVNETHDR)
+		return err
+	})
+	return n, err
 }
 
 // IPv6SLAAC enables/disables stateless address auto-configuration (SLAAC) for the interface.
@@ -237,32 +327,4 @@ func (t *Interface) IPv6(ctrl bool) error {
 	return errors.New("TODO")
 }
 
-// GetAddrList returns the IP addresses (as bytes) associated with the interface.
-func (t *Interface) GetAddrList() ([][]byte, error) {
-	// get the net.Interface using the tunnel name
-	itf, err := net.InterfaceByName(path.Base(t.Name()))
-	if err != nil {
-		return nil, err
-	}
-	// get the ip address list for the interface
-	addrList, err := itf.Addrs()
-	if err != nil {
-		return nil, err
-	}
-	// parse the address strings and convert to bytes
-	addrs := [][]byte{}
-	for _, addr := range addrList {
-		ip, _, err := net.ParseCIDR(addr.String())
-		if err != nil {
-			return nil, err
-		}
-		if isIPv4(ip) {
-			// it's an IPv4 address- just use the 4 bytes
-			ip = ip.To4()
-		}
-		addrs = append(addrs, ip)
-	}
-	return addrs, nil
-}
-
 //-----------------------------------------------------------------------------
@@ -13,8 +13,10 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"github.com/pkg/errors"
@@ -25,6 +27,12 @@ import (
 //-----------------------------------------------------------------------------
 
 func createInterface(ifPattern string, kind DevKind) (*Interface, error) {
+	return openTun(ifPattern, kind, 0)
+}
+
+// openTun opens /dev/net/tun and attaches it to the interface ifPattern,
+// with flags added to the TUNSETIFF flags.
+func openTun(ifPattern string, kind DevKind, flags uint16) (*Interface, error) {
 	// Note there is a complication because in go, if a device node is opened,
 	// go sets it to use nonblocking I/O. However a /dev/net/tun doesn't work
 	// with epoll until after the TUNSETIFF ioctl has been done. So we open
@@ -54,10 +62,17 @@ func createInterface(ifPattern string, kind DevKind) (*Interface, error) {
 	default:
 		panic(fmt.Sprintf("tuntap: Unknown tuntap interface type %d", int(kind)))
 	}
-	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(unix.TUNSETIFF), uintptr(unsafe.Pointer(&req)))
-	if errno != 0 {
+	req.Flags |= flags
+	err = ioctlIfReq(fd, unix.TUNSETIFF, &req)
+	if err != nil {
 		unix.Close(fd)
-		return nil, errors.Wrapf(errno, "tuntap: Can't ioctl(TUNSETIFF) on %s", TUN)
+		if err == unix.EINVAL {
+			// what the kernel says when attaching to a device of the other kind
+			if got, ok := existingKind(ifPattern); ok && got != kind {
+				return nil, &KindMismatchError{Name: ifPattern, Want: kind, Got: got}
+			}
+		}
+		return nil, errors.Wrapf(err, "tuntap: Can't ioctl(TUNSETIFF) on %s", TUN)
 	}
 	ifName := string(req.Name[:])
 	if idx := strings.IndexByte(ifName, 0); idx >= 0 {
@@ -74,26 +89,277 @@ func createInterface(ifPattern string, kind DevKind) (*Interface, error) {
 	// and the fd will operate properly with go's runtime net poller/epoll(2).
 	file := os.NewFile(uintptr(fd), TUN)
 
-	return &Interface{ifName, file}, nil
+	return &Interface{name: ifName, file: file, kind: kind}, nil
+}
+
+// newFromFD wraps fd, which must be attached to a tun/tap interface of the
+// given kind, asking the kernel (TUNGETIFF) for the interface's name and
+// flags.
+func newFromFD(fd int, kind DevKind, name string) (*Interface, error) {
+	var req ifReq
+	if err := ioctlIfReq(fd, unix.TUNGETIFF, &req); err != nil {
+		return nil, errors.Wrapf(err, "tuntap: Can't ioctl(TUNGETIFF) on fd %d", fd)
+	}
+	ifName := unix.ByteSliceToString(req.Name[:])
+	if name != "" && name != ifName {
+		return nil, fmt.Errorf("tuntap: fd %d is attached to %s, not %s", fd, ifName, name)
+	}
+	got := DevTun
+	if req.Flags&(unix.IFF_TUN|unix.IFF_TAP) == unix.IFF_TAP {
+		got = DevTap
+	}
+	if got != kind {
+		return nil, &KindMismatchError{Name: ifName, Want: kind, Got: got}
+	}
+	if req.Flags&unix.IFF_NO_PI != 0 && req.Flags&unix.IFF_VNET_HDR != 0 {
+		// virtio-net headers are only handled behind the PI header
+		return nil, errors.Wrapf(ErrNotSupported, "tuntap: %s has IFF_VNET_HDR without a PI header", ifName)
+	}
+
+	if err := unix.SetNonblock(fd, true); err != nil {
+		return nil, errors.Wrapf(err, "tuntap: Can't set nonblocking mode on fd %d", fd)
+	}
+	t := &Interface{name: ifName, file: os.NewFile(uintptr(fd), "/dev/net/tun"), kind: kind}
+	if req.Flags&unix.IFF_NO_PI != 0 {
+		t.framing = frameNone
+	}
+	// the offloads the fd was given can't be read back, so none are assumed
+	t.vnetHdr = req.Flags&unix.IFF_VNET_HDR != 0
+	return t, nil
+}
+
+// existingKind returns the kind of the existing tun/tap device name, from its
+// IFF_TUN/IFF_TAP flags; false if there's no such device.
+func existingKind(name string) (DevKind, bool) {
+	if strings.ContainsAny(name, "%/") {
+		return 0, false
+	}
+	b, err := ioutil.ReadFile("/sys/class/net/" + name + "/tun_flags")
+	if err != nil {
+		return 0, false
+	}
+	flags, err := strconv.ParseUint(strings.TrimSpace(string(b)), 0, 16)
+	if err != nil {
+		return 0, false
+	}
+	switch flags & (unix.IFF_TUN | unix.IFF_TAP) {
+	case unix.IFF_TUN:
+		return DevTun, true
+	case unix.IFF_TAP:
+		return DevTap, true
+	}
+	return 0, false
+}
+
+// openMultiQueue creates the multi-queue interface ifPattern with its first
+// queue, and then opens the other queues on it by name.
+func openMultiQueue(ifPattern string, kind DevKind, queues int) ([]*Interface, error) {
+	qs := make([]*Interface, 0, queues)
+	for len(qs) < queues {
+		name := ifPattern
+		if len(qs) != 0 {
+			name = qs[0].name
+		}
+		q, err := openTun(name, kind, unix.IFF_MULTI_QUEUE)
+		if err != nil {
+			for _, q := range qs {
+				q.file.Close()
+			}
+			return nil, err
+		}
+		qs = append(qs, q)
+	}
+	return qs, nil
+}
+
+// openConfigured creates the interface ifPattern with the TUNSETIFF flags cfg
+// asks for, and takes on what the kernel accepts of its offloads.
+func openConfigured(ifPattern string, kind DevKind, cfg *config) (*Interface, error) {
+	if cfg.offloads&^OffloadCsum != 0 && cfg.offloads&OffloadCsum == 0 {
+		return nil, errors.New("tuntap: segmentation offloads need OffloadCsum")
+	}
+	var flags uint16
+	if cfg.vnetHdr {
+		flags |= unix.IFF_VNET_HDR
+	}
+	if cfg.multiQueue {
+		flags |= unix.IFF_MULTI_QUEUE
+	}
+	t, err := openTun(ifPattern, kind, flags)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.vnetHdr {
+		t.vnetHdr = true
+		t.offloads, err = t.setOffload(cfg.offloads)
+		if err != nil {
+			t.file.Close()
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// setOffload does TUNSETOFFLOAD with offloads, and returns the offloads the
+// kernel took. Kernels before 6.2 know nothing of USO, and refuse it.
+func (t *Interface) setOffload(offloads Offload) (Offload, error) {
+	for {
+		var flags int
+		if offloads&OffloadCsum != 0 {
+			flags |= tunOffloadCsum
+		}
+		if offloads&OffloadTSO4 != 0 {
+			flags |= tunOffloadTSO4
+		}
+		if offloads&OffloadTSO6 != 0 {
+			flags |= tunOffloadTSO6
+		}
+		if offloads&OffloadUSO != 0 {
+			flags |= tunOffloadUSO4 | tunOffloadUSO6
+		}
+		err := t.control(func(fd uintptr) error {
+			return unix.IoctlSetInt(int(fd), unix.TUNSETOFFLOAD, flags)
+		})
+		if err == unix.EINVAL && offloads&OffloadUSO != 0 {
+			offloads &^= OffloadUSO
+			continue
+		}
+		if err != nil {
+			return 0, errors.Wrapf(err, "tuntap: Can't ioctl(TUNSETOFFLOAD) on %s", t.name)
+		}
+		return offloads, nil
+	}
+}
+
+// setQueue attaches (IFF_ATTACH_QUEUE) or detaches (IFF_DETACH_QUEUE) the
+// queue of a multi-queue interface.
+func (t *Interface) setQueue(attach bool) error {
+	return t.control(func(fd uintptr) error {
+		var req ifReq
+		req.Flags = unix.IFF_DETACH_QUEUE
+		if attach {
+			req.Flags = unix.IFF_ATTACH_QUEUE
+		}
+		if err := ioctlIfReq(int(fd), unix.TUNSETQUEUE, &req); err != nil {
+			return errors.Wrapf(err, "tuntap: Can't ioctl(TUNSETQUEUE) on %s", t.name)
+		}
+		return nil
+	})
+}
+
+// writev writes bufs with one writev(2), so as one packet.
+func (t *Interface) writev(bufs [][]byte) (int, error) {
+	rc, err := t.file.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	var werr error
+	timed := t.latency.writeDue()
+	err = rc.Write(func(fd uintptr) bool {
+		var start time.Time
+		if timed {
+			start = time.Now()
+		}
+		n, werr = unix.Writev(int(fd), bufs)
+		if werr == unix.EAGAIN {
+			// wait for the fd to be writable again
+			return false
+		}
+		if timed {
+			t.latency.write.since(start)
+		}
+		return true
+	})
+	if err == nil {
+		err = werr
+	}
+	return n, err
+}
+
+// Linux tun Interfaces use the PI header, not frameAF
+const afInet6 = unix.AF_INET6
+
+// the syscalls of a sealed Interface; sendmmsg and recvmmsg are used by raw
+// Interfaces, writev with virtio-net headers
+var dataPathSyscalls = []string{"read", "write", "writev", "sendmmsg", "recvmmsg", "close", "epoll_ctl", "epoll_pwait"}
+
+// ioctlIfReq does one of the tun ioctls which take a struct ifreq.
+func ioctlIfReq(fd int, req uint, ifr *ifReq) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(unsafe.Pointer(ifr)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
 }
 
 //-----------------------------------------------------------------------------
 
-// AddAddress adds an IP address to the tunnel interface.
-func (t *Interface) AddAddress(ip net.IP, subnet *net.IPNet) error {
+// AddAddressWith adds an IP address with options to the tunnel interface.
+func (t *Interface) AddAddressWith(ip net.IP, subnet *net.IPNet, opts AddressOptions) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	if err := opts.check(ip); err != nil {
+		return err
+	}
 	iface, err := netlink.LinkByName(t.Name())
 	if err != nil {
 		return err
 	}
-	err = netlink.AddrAdd(iface, &netlink.Addr{IPNet: &net.IPNet{IP: ip, Mask: subnet.Mask}})
+	if opts.Anycast {
+		return t.joinAnycast(ip, iface.Attrs().Index)
+	}
+	err = netlink.AddrAdd(iface, &netlink.Addr{IPNet: &net.IPNet{IP: ip, Mask: subnet.Mask}, Broadcast: opts.Broadcast.To4()})
 	if err != nil {
 		return err
 	}
+	t.applied(t.addressEntry(ip, subnet))
+	return nil
+}
+
+// DelAddress removes an IP address from the tunnel interface.
+func (t *Interface) DelAddress(ip net.IP, subnet *net.IPNet) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	iface, err := netlink.LinkByName(t.Name())
+	if err != nil {
+		return err
+	}
+	err = netlink.AddrDel(iface, &netlink.Addr{IPNet: &net.IPNet{IP: ip, Mask: subnet.Mask}})
+	if err != nil {
+		return err
+	}
+	t.reverted(t.addressEntry(ip, subnet))
+	return nil
+}
+
+// joinAnycast makes ip an anycast address of the interface with
+// IPV6_JOIN_ANYCAST, on a socket kept until the Interface is closed.
+func (t *Interface) joinAnycast(ip net.IP, ifindex int) error {
+	fd, err := unix.Socket(unix.AF_INET6, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	mreq := unix.IPv6Mreq{Interface: uint32(ifindex)}
+	copy(mreq.Multiaddr[:], ip.To16())
+	err = unix.SetsockoptIPv6Mreq(fd, unix.IPPROTO_IPV6, unix.IPV6_JOIN_ANYCAST, &mreq)
+	if err != nil {
+		unix.Close(fd)
+		return errors.Wrapf(err, "tuntap: Can't setsockopt(IPV6_JOIN_ANYCAST) on %s", t.name)
+	}
+	t.anycastLock.Lock()
+	t.anycast = append(t.anycast, os.NewFile(uintptr(fd), "anycast"))
+	t.anycastLock.Unlock()
 	return nil
 }
 
 // SetMTU sets the tunnel interface MTU size.
 func (t *Interface) SetMTU(mtu int) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
 	iface, err := netlink.LinkByName(t.Name())
 	if err != nil {
 		return err
@@ -102,11 +368,51 @@ func (t *Interface) SetMTU(mtu int) error {
 	if err != nil {
 		return err
 	}
+	t.mtuChanged(mtu)
 	return nil
 }
 
+// SetHardwareAddr sets the MAC address of a DevTap interface, which the
+// kernel otherwise picks at random, with netlink. The interface may be UP.
+func (t *Interface) SetHardwareAddr(mac net.HardwareAddr) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	if t.kind != DevTap {
+		return ErrNotSupported
+	}
+	iface, err := netlink.LinkByName(t.Name())
+	if err != nil {
+		return err
+	}
+	return netlink.LinkSetHardwareAddr(iface, mac)
+}
+
+// Flags returns the flags of the interface, with SIOCGIFFLAGS.
+func (t *Interface) Flags() (net.Flags, error) {
+	if err := t.configurable(); err != nil {
+		return 0, err
+	}
+	ifr, err := unix.NewIfreq(t.name)
+	if err != nil {
+		return 0, err
+	}
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer unix.Close(fd)
+	if err = unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return 0, errors.Wrapf(err, "tuntap: Can't ioctl(SIOCGIFFLAGS) on %s", t.name)
+	}
+	return netFlags(int(ifr.Uint16())), nil
+}
+
 // Up sets the tunnel interface to the UP state.
 func (t *Interface) Up() error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
 	iface, err := netlink.LinkByName(t.Name())
 	if err != nil {
 		return err
@@ -118,6 +424,51 @@ func (t *Interface) Up() error {
 	return nil
 }
 
+// SetPersist makes the interface persistent (TUNSETPERSIST), so that it
+// remains when the Interface is closed, or not, so that it goes away.
+func (t *Interface) SetPersist(persist bool) error {
+	return t.control(func(fd uintptr) error {
+		if err := unix.IoctlSetInt(int(fd), unix.TUNSETPERSIST, int(boolToByte(persist))); err != nil {
+			return errors.Wrapf(err, "tuntap: Can't ioctl(TUNSETPERSIST) on %s", t.name)
+		}
+		return nil
+	})
+}
+
+// SetOwner lets the user uid open the interface without privileges
+// (TUNSETOWNER), once it's persistent.
+func (t *Interface) SetOwner(uid int) error {
+	return t.control(func(fd uintptr) error {
+		if err := unix.IoctlSetInt(int(fd), unix.TUNSETOWNER, uid); err != nil {
+			return errors.Wrapf(err, "tuntap: Can't ioctl(TUNSETOWNER) on %s", t.name)
+		}
+		return nil
+	})
+}
+
+// SetGroup lets the group gid open the interface without privileges
+// (TUNSETGROUP), once it's persistent.
+func (t *Interface) SetGroup(gid int) error {
+	return t.control(func(fd uintptr) error {
+		if err := unix.IoctlSetInt(int(fd), unix.TUNSETGROUP, gid); err != nil {
+			return errors.Wrapf(err, "tuntap: Can't ioctl(TUNSETGROUP) on %s", t.name)
+		}
+		return nil
+	})
+}
+
+// Down sets the tunnel interface to the DOWN state.
+func (t *Interface) Down() error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	iface, err := netlink.LinkByName(t.Name())
+	if err != nil {
+		return err
+	}
+	return netlink.LinkSetDown(iface)
+}
+
 func boolToByte(x bool) byte {
 	if x {
 		return 1
@@ -127,24 +478,48 @@ func boolToByte(x bool) byte {
 
 // IPv6SLAAC enables/disables stateless address auto-configuration (SLAAC) for the interface.
 func (t *Interface) IPv6SLAAC(ctrl bool) error {
-	k := boolToByte(ctrl)
-	return ioutil.WriteFile("/proc/sys/net/ipv6/conf/"+t.Name()+"/autoconf", []byte{k}, 0)
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return t.setSysctl("autoconf", ctrl)
 }
 
 // IPv6Forwarding enables/disables ipv6 forwarding for the interface.
 func (t *Interface) IPv6Forwarding(ctrl bool) error {
-	k := boolToByte(ctrl)
-	return ioutil.WriteFile("/proc/sys/net/ipv6/conf/"+t.Name()+"/forwarding", []byte{k}, 0)
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return t.setSysctl("forwarding", ctrl)
 }
 
 // IPv6 enables/disable ipv6 for the interface.
 func (t *Interface) IPv6(ctrl bool) error {
-	k := boolToByte(!ctrl)
-	return ioutil.WriteFile("/proc/sys/net/ipv6/conf/"+t.Name()+"/disable_ipv6", []byte{k}, 0)
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return t.setSysctl("disable_ipv6", !ctrl)
+}
+
+// setSysctl sets the IPv6 sysctl name of the interface, which the kernel
+// parses as a number: "0" or "1".
+func (t *Interface) setSysctl(name string, v bool) error {
+	file := "/proc/sys/net/ipv6/conf/" + t.Name() + "/" + name
+	old, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(file, []byte{'0' + boolToByte(v)}, 0); err != nil {
+		return err
+	}
+	t.applied(JournalEntry{Kind: JournalSysctl, Interface: t.Name(), Path: file, Value: strings.TrimSpace(string(old))})
+	return nil
 }
 
 // GetAddrList returns the IP addresses (as bytes) associated with the interface.
 func (t *Interface) GetAddrList() ([][]byte, error) {
+	if err := t.configurable(); err != nil {
+		return nil, err
+	}
 	iface, err := netlink.LinkByName(t.Name())
 	if err != nil {
 		return nil, err
@@ -165,4 +540,48 @@ func (t *Interface) GetAddrList() ([][]byte, error) {
 	return addrs, nil
 }
 
+// Announce sends a gratuitous ARP or unsolicited Neighbor Advertisement for
+// each of the addresses of the interface, through an AF_PACKET socket.
+// Addresses still doing duplicate address detection are left out.
+func (t *Interface) Announce() error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	iface, err := netlink.LinkByName(t.Name())
+	if err != nil {
+		return err
+	}
+	nladdrs, err := netlink.AddrList(iface, netlink.FAMILY_ALL)
+	if err != nil {
+		return err
+	}
+	var ips []net.IP
+	for _, a := range nladdrs {
+		if a.Flags&(unix.IFA_F_TENTATIVE|unix.IFA_F_DADFAILED) == 0 {
+			ips = append(ips, a.IP)
+		}
+	}
+	var mac net.HardwareAddr
+	if t.kind == DevTap {
+		mac = iface.Attrs().HardwareAddr
+	}
+	pkts := announcements(mac, ips)
+	if len(pkts) == 0 {
+		return nil
+	}
+
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return errors.Wrap(err, "tuntap: Can't create AF_PACKET socket")
+	}
+	defer unix.Close(fd)
+	for _, pkt := range pkts {
+		sa := unix.SockaddrLinklayer{Protocol: htons(pkt.Protocol), Ifindex: iface.Attrs().Index}
+		if err := unix.Sendto(fd, pkt.Body, 0, &sa); err != nil {
+			return errors.Wrapf(err, "tuntap: Can't send announcement on %s", t.name)
+		}
+	}
+	return nil
+}
+
 //-----------------------------------------------------------------------------
@@ -0,0 +1,186 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+// NetBSD's tun(4) only puts the packet's address family in front of it in
+// "interface head" mode (TUNSIFHEAD), and without it can only send IPv4, so
+// the mode is always turned on. tap(4) frames have no header. Opening
+// /dev/tunN or /dev/tapN creates the interface if need be.
+
+func createInterface(ifPattern string, kind DevKind) (*Interface, error) {
+
+	if kind != DevTun && kind != DevTap {
+		return nil, fmt.Errorf("tuntap: unsupported tuntap interface type %d", int(kind))
+	}
+
+	ifName := "/dev/" + ifPattern
+	var fd int
+	var err error
+
+	if strings.Contains(ifName, "%d") {
+		for i := 0; i < 256; i++ {
+			fd, err = unix.Open(fmt.Sprintf(ifName, i), os.O_RDWR, 0)
+			if err == nil {
+				ifName = fmt.Sprintf(ifName, i)
+				break
+			}
+		}
+	} else {
+		fd, err = unix.Open(ifName, os.O_RDWR, 0)
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "tuntap: can't open %s", ifName)
+	}
+
+	if kind == DevTun {
+		if err = unix.IoctlSetPointerInt(fd, TUNSIFHEAD, 1); err != nil {
+			unix.Close(fd)
+			return nil, errors.Wrapf(err, "tuntap: can't set TUNSIFHEAD on %s", ifName)
+		}
+	}
+
+	// in nonblocking mode the fd is handled by go's runtime poller
+	if err = unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, errors.Wrapf(err, "tuntap: can't set nonblocking mode on %s", ifName)
+	}
+
+	file := os.NewFile(uintptr(fd), ifName)
+	t := &Interface{name: ifName, file: file, kind: kind, framing: frameNone}
+	if kind == DevTun {
+		t.framing = frameAF
+	}
+	return t, nil
+}
+
+const afInet6 = unix.AF_INET6
+
+// the syscalls of a sealed Interface
+var dataPathSyscalls = []string{"read", "write", "close", "kevent"}
+
+var devicePaths = []string{"/dev/tun", "/dev/tap"}
+
+//-----------------------------------------------------------------------------
+
+func openRaw(ifName string) (*Interface, error) {
+	// the equivalent would be bpf(4)
+	return nil, ErrNotSupported
+}
+
+func openVtap(typ, ifName, parent string) (*Interface, bool, error) {
+	return nil, false, ErrNotSupported
+}
+
+func deleteVtap(ifName string) error {
+	return ErrNotSupported
+}
+
+func createVethPair(nameA, nameB string, cfg vethConfig) error {
+	return ErrNotSupported
+}
+
+// NetBSD has no way for a process to restrict its own filesystem access
+func restrictPaths(paths []string) error {
+	return ErrNotSupported
+}
+
+// vnetTap has no counterpart: only FreeBSD's tap takes a virtio-net header
+func (t *Interface) vnetTap(offloads Offload) error {
+	return ErrNotSupported
+}
+
+// there are no capabilities, only root's privileges
+func dropCapabilityBounds() error {
+	return nil
+}
+
+// NetBSD has no no_new_privs
+func lockPrivileges() error {
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+
+// DelAddress is only supported on Linux and FreeBSD.
+func (t *Interface) DelAddress(ip net.IP, subnet *net.IPNet) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// AddRoute is only supported on Linux and FreeBSD.
+func (t *Interface) AddRoute(r Route) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// DelRoute is only supported on Linux and FreeBSD.
+func (t *Interface) DelRoute(r Route) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// RoutesIn is only supported on Linux and FreeBSD.
+func (t *Interface) RoutesIn(table int) ([]Route, error) {
+	if err := t.configurable(); err != nil {
+		return nil, err
+	}
+	return nil, ErrNotSupported
+}
+
+// SetHardwareAddr is only supported on Linux and FreeBSD.
+func (t *Interface) SetHardwareAddr(mac net.HardwareAddr) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// IPv6SLAAC enables/disables stateless address auto-configuration (SLAAC) for the interface.
+func (t *Interface) IPv6SLAAC(ctrl bool) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// IPv6Forwarding enables/disables ipv6 forwarding for the interface.
+func (t *Interface) IPv6Forwarding(ctrl bool) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// IPv6 enables/disable ipv6 for the interface.
+func (t *Interface) IPv6(ctrl bool) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,196 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+// OpenBSD's tun(4) always puts the packet's address family in front of it, in
+// 4 bytes, and there's no ioctl to turn that off. tap(4) frames have no
+// header. Opening /dev/tunN or /dev/tapN creates the interface if need be.
+
+func createInterface(ifPattern string, kind DevKind) (*Interface, error) {
+
+	if kind != DevTun && kind != DevTap {
+		return nil, fmt.Errorf("tuntap: unsupported tuntap interface type %d", int(kind))
+	}
+
+	ifName := "/dev/" + ifPattern
+	var fd int
+	var err error
+
+	if strings.Contains(ifName, "%d") {
+		for i := 0; i < 256; i++ {
+			fd, err = unix.Open(fmt.Sprintf(ifName, i), os.O_RDWR, 0)
+			if err == nil {
+				ifName = fmt.Sprintf(ifName, i)
+				break
+			}
+		}
+	} else {
+		fd, err = unix.Open(ifName, os.O_RDWR, 0)
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "tuntap: can't open %s", ifName)
+	}
+
+	// in nonblocking mode the fd is handled by go's runtime poller
+	if err = unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, errors.Wrapf(err, "tuntap: can't set nonblocking mode on %s", ifName)
+	}
+
+	file := os.NewFile(uintptr(fd), ifName)
+	t := &Interface{name: ifName, file: file, kind: kind, framing: frameNone}
+	if kind == DevTun {
+		t.framing = frameAF
+	}
+	return t, nil
+}
+
+const afInet6 = unix.AF_INET6
+
+// the syscalls of a sealed Interface
+var dataPathSyscalls = []string{"read", "write", "close", "kevent"}
+
+// unveil(2) needs the device files by name, so these are the ones present
+var devicePaths = deviceFiles()
+
+func deviceFiles() []string {
+	tun, _ := filepath.Glob("/dev/tun[0-9]*")
+	tap, _ := filepath.Glob("/dev/tap[0-9]*")
+	return append(tun, tap...)
+}
+
+//-----------------------------------------------------------------------------
+
+func openRaw(ifName string) (*Interface, error) {
+	// the equivalent would be bpf(4)
+	return nil, ErrNotSupported
+}
+
+func openVtap(typ, ifName, parent string) (*Interface, bool, error) {
+	return nil, false, ErrNotSupported
+}
+
+func deleteVtap(ifName string) error {
+	return ErrNotSupported
+}
+
+func createVethPair(nameA, nameB string, cfg vethConfig) error {
+	return ErrNotSupported
+}
+
+// restrictPaths unveils paths read-write (the whole tree, for directories),
+// and then nothing else.
+func restrictPaths(paths []string) error {
+	for _, p := range paths {
+		if err := unix.Unveil(p, "rw"); err != nil {
+			return errors.Wrapf(err, "tuntap: Can't unveil %s", p)
+		}
+	}
+	if err := unix.UnveilBlock(); err != nil {
+		return errors.Wrap(err, "tuntap: Can't lock unveil")
+	}
+	return nil
+}
+
+// vnetTap has no counterpart: only FreeBSD's tap takes a virtio-net header
+func (t *Interface) vnetTap(offloads Offload) error {
+	return ErrNotSupported
+}
+
+// there are no capabilities, only root's privileges
+func dropCapabilityBounds() error {
+	return nil
+}
+
+// OpenBSD has no no_new_privs; a process which wants more can pledge(2)
+// itself, which is up to the application since the promises depend on it.
+func lockPrivileges() error {
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+
+// DelAddress is only supported on Linux and FreeBSD.
+func (t *Interface) DelAddress(ip net.IP, subnet *net.IPNet) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// AddRoute is only supported on Linux and FreeBSD.
+func (t *Interface) AddRoute(r Route) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// DelRoute is only supported on Linux and FreeBSD.
+func (t *Interface) DelRoute(r Route) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// RoutesIn is only supported on Linux and FreeBSD.
+func (t *Interface) RoutesIn(table int) ([]Route, error) {
+	if err := t.configurable(); err != nil {
+		return nil, err
+	}
+	return nil, ErrNotSupported
+}
+
+// SetHardwareAddr is only supported on Linux and FreeBSD.
+func (t *Interface) SetHardwareAddr(mac net.HardwareAddr) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// IPv6SLAAC enables/disables stateless address auto-configuration (SLAAC) for the interface.
+func (t *Interface) IPv6SLAAC(ctrl bool) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// IPv6Forwarding enables/disables ipv6 forwarding for the interface.
+func (t *Interface) IPv6Forwarding(ctrl bool) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+// IPv6 enables/disable ipv6 for the interface.
+func (t *Interface) IPv6(ctrl bool) error {
+	if err := t.configurable(); err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+//-----------------------------------------------------------------------------
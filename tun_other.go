@@ -1,17 +1,105 @@
-//go:build !linux && !freebsd
+//go:build !linux && !freebsd && !darwin && !openbsd && !netbsd
 
 package tuntap
 
 import (
 	"net"
+	"net/netip"
 )
 
 const flagTruncated = 0
 
+// unused, there's no AF header on these platforms
+const afInet6 = 0
+
+var dataPathSyscalls []string
+
 func createInterface(ifPattern string, kind DevKind) (*Interface, error) {
 	panic("tuntap: Not implemented on this platform")
 }
 
+func (t *Interface) readNow(buffer []byte) (int, bool, error) {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func (t *Interface) readTimed(buffer []byte) (int, error) {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func (t *Interface) writeTimed(b []byte) (int, error) {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func newFromFD(fd int, kind DevKind, name string) (*Interface, error) {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func openRaw(ifName string) (*Interface, error) {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func OpenPipe(kind DevKind) (*Interface, *Interface, error) {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func SendInterface(c *net.UnixConn, t *Interface) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func ReceiveInterface(c *net.UnixConn) (*Interface, error) {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func openVtap(typ, ifName, parent string) (*Interface, bool, error) {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func deleteVtap(ifName string) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func openMultiQueue(ifPattern string, kind DevKind, queues int) ([]*Interface, error) {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func openConfigured(ifPattern string, kind DevKind, cfg *config) (*Interface, error) {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func (t *Interface) writev(bufs [][]byte) (int, error) {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func (t *Interface) setQueue(attach bool) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func listenICMP(ifName string, ipv6 bool) (*ICMPConn, error) {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func inNamespace(nsPath string, f func() error) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func (t *Interface) MoveToNamespace(nsPath string) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func createVethPair(nameA, nameB string, cfg vethConfig) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+var devicePaths []string
+
+func restrictPaths(paths []string) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+func dropPrivileges(c Credential) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
 // IPv6SLAAC enables/disables stateless address auto-configuration (SLAAC) for the interface.
 func (t *Interface) IPv6SLAAC(ctrl bool) error {
 	panic("tuntap: Not implemented on this platform")
@@ -27,8 +115,28 @@ func (t *Interface) IPv6(ctrl bool) error {
 	panic("tuntap: Not implemented on this platform")
 }
 
-// AddAddress adds an IP address to the tunnel interface.
-func (t *Interface) AddAddress(ip net.IP, subnet *net.IPNet) error {
+// AddAddressWith adds an IP address with options to the tunnel interface.
+func (t *Interface) AddAddressWith(ip net.IP, subnet *net.IPNet, opts AddressOptions) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+// DelAddress removes an IP address from the tunnel interface.
+func (t *Interface) DelAddress(ip net.IP, subnet *net.IPNet) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+// AddRoute adds a route through the tunnel interface.
+func (t *Interface) AddRoute(r Route) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+// DelRoute removes a route through the tunnel interface.
+func (t *Interface) DelRoute(r Route) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+// RoutesIn returns the routes of table through the tunnel interface.
+func (t *Interface) RoutesIn(table int) ([]Route, error) {
 	panic("tuntap: Not implemented on this platform")
 }
 
@@ -37,11 +145,90 @@ func (t *Interface) SetMTU(mtu int) error {
 	panic("tuntap: Not implemented on this platform")
 }
 
+// Flags returns the flags of the interface.
+func (t *Interface) Flags() (net.Flags, error) {
+	panic("tuntap: Not implemented on this platform")
+}
+
+// SetHardwareAddr sets the MAC address of a DevTap interface.
+func (t *Interface) SetHardwareAddr(mac net.HardwareAddr) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
 // Up sets the tunnel interface to the UP state.
 func (t *Interface) Up() error {
 	panic("tuntap: Not implemented on this platform")
 }
 
+// AddAddressLabel gives an IPv6 prefix an address label on the tunnel
+// interface.
+func (t *Interface) AddAddressLabel(p netip.Prefix, label uint32) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+// DelAddressLabel removes the address label of an IPv6 prefix on the tunnel
+// interface.
+func (t *Interface) DelAddressLabel(p netip.Prefix, label uint32) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+// PreferSource makes the host prefer src as the source of the traffic to
+// dst through the tunnel interface.
+func (t *Interface) PreferSource(dst netip.Prefix, src netip.Addr) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+// InterfaceLabel returns the address label of the tunnel interface.
+func (t *Interface) InterfaceLabel() (uint32, error) {
+	panic("tuntap: Not implemented on this platform")
+}
+
+// SetPersist makes the interface persistent, or not.
+func (t *Interface) SetPersist(persist bool) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+// Announce sends a gratuitous ARP or unsolicited Neighbor Advertisement for
+// each of the addresses of the interface.
+func (t *Interface) Announce() error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+// AttachFilter attaches a classic BPF program to the Interface.
+func (t *Interface) AttachFilter(prog []BPFInstruction) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+// DetachFilter detaches the program attached with AttachFilter.
+func (t *Interface) DetachFilter() error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+// SetSteeringEBPF makes an eBPF program choose the queue of each packet.
+func (t *Interface) SetSteeringEBPF(progFD int) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+// SetFilterEBPF makes an eBPF program filter the packets.
+func (t *Interface) SetFilterEBPF(progFD int) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+// SetOwner lets the user uid open the interface.
+func (t *Interface) SetOwner(uid int) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+// SetGroup lets the group gid open the interface.
+func (t *Interface) SetGroup(gid int) error {
+	panic("tuntap: Not implemented on this platform")
+}
+
+// Down sets the tunnel interface to the DOWN state.
+func (t *Interface) Down() error {
+	panic("tuntap: Not implemented on this platform")
+}
+
 // GetAddrList returns the IP addresses (as bytes) associated with the interface.
 func (t *Interface) GetAddrList() ([][]byte, error) {
 	panic("tuntap: Not implemented on this platform")
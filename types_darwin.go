@@ -0,0 +1,39 @@
+//go:build ignore
+
+// run "bash ./mkdefs.sh"
+
+package tuntap
+
+/*
+#include <sys/ioctl.h>
+#include <sys/kern_control.h>
+#include <sys/sys_domain.h>
+#include <net/if.h>
+#include <net/if_utun.h>
+#include <netinet/in.h>
+#include <netinet6/in6_var.h>
+#include <netinet6/nd6.h>
+*/
+import "C"
+
+const flagTruncated = 0
+
+const sizeofInt = C.sizeof_int
+const sizeofTime = C.sizeof_time_t
+const sizeofIfreq = C.sizeof_struct_ifreq
+const sizeofIfAliasReq = C.sizeof_struct_ifaliasreq
+const sizeofIn6AliasReq = C.sizeof_struct_in6_aliasreq
+const sizeofInSockAddr = C.sizeof_struct_sockaddr_in
+const sizeofIn6SockAddr = C.sizeof_struct_sockaddr_in6
+const sizeofIn6AddrLifetime = C.sizeof_struct_in6_addrlifetime
+
+const (
+	IFNAMSIZ              = C.IFNAMSIZ
+	ND6_INFINITE_LIFETIME = C.ND6_INFINITE_LIFETIME
+	IN6_IFF_ANYCAST       = C.IN6_IFF_ANYCAST
+	SIOCAIFADDR_IN6       = C.SIOCAIFADDR_IN6
+
+	// utun
+	SYSPROTO_CONTROL = C.SYSPROTO_CONTROL
+	UTUN_OPT_IFNAME  = C.UTUN_OPT_IFNAME
+)
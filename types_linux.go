@@ -13,7 +13,12 @@ package tuntap
 import "C"
 
 const (
-	flagTruncated = C.TUN_PKT_STRIP
+	flagTruncated  = C.TUN_PKT_STRIP
+	tunOffloadCsum = C.TUN_F_CSUM
+	tunOffloadTSO4 = C.TUN_F_TSO4
+	tunOffloadTSO6 = C.TUN_F_TSO6
+	tunOffloadUSO4 = C.TUN_F_USO4
+	tunOffloadUSO6 = C.TUN_F_USO6
 )
 
 type ifReq struct {
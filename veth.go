@@ -0,0 +1,48 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+//-----------------------------------------------------------------------------
+
+// vethConfig collects the VethOptions given to CreateVethPair.
+type vethConfig struct {
+	nsA string // network namespace paths; "" leaves the end in our namespace
+	nsB string
+}
+
+// A VethOption controls where CreateVethPair places the ends of the pair.
+type VethOption func(*vethConfig)
+
+// VethNamespaceA creates the first end of the pair in the network namespace
+// at nsPath, e.g. "/var/run/netns/blue" or "/proc/1234/ns/net".
+func VethNamespaceA(nsPath string) VethOption {
+	return func(c *vethConfig) { c.nsA = nsPath }
+}
+
+// VethNamespaceB creates the second end of the pair in the network namespace
+// at nsPath.
+func VethNamespaceB(nsPath string) VethOption {
+	return func(c *vethConfig) { c.nsB = nsPath }
+}
+
+// CreateVethPair creates a pair of connected veth interfaces named nameA and
+// nameB. Without options both ends are created in the caller's network
+// namespace. The interfaces are left DOWN, and persist until one end is
+// deleted (which deletes both) or its namespace is destroyed.
+//
+// Only implemented on Linux.
+func CreateVethPair(nameA, nameB string, opts ...VethOption) error {
+	var cfg vethConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	return createVethPair(nameA, nameB, cfg)
+}
+
+//-----------------------------------------------------------------------------
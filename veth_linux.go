@@ -0,0 +1,60 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+
+// nsFd opens the network namespace at path, returning nil if path is "".
+// The caller must close the returned fd, if any.
+func nsFd(path string) (interface{}, int, error) {
+	if path == "" {
+		return nil, -1, nil
+	}
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, -1, errors.Wrapf(err, "tuntap: Can't open network namespace %s", path)
+	}
+	return netlink.NsFd(fd), fd, nil
+}
+
+func createVethPair(nameA, nameB string, cfg vethConfig) error {
+	nsA, fdA, err := nsFd(cfg.nsA)
+	if err != nil {
+		return err
+	}
+	if fdA >= 0 {
+		defer unix.Close(fdA)
+	}
+	nsB, fdB, err := nsFd(cfg.nsB)
+	if err != nil {
+		return err
+	}
+	if fdB >= 0 {
+		defer unix.Close(fdB)
+	}
+
+	// the kernel places both ends atomically as part of creating the pair
+	attrs := netlink.NewLinkAttrs()
+	attrs.Name = nameA
+	attrs.Namespace = nsA
+	veth := &netlink.Veth{LinkAttrs: attrs, PeerName: nameB, PeerNamespace: nsB}
+	err = netlink.LinkAdd(veth)
+	if err != nil {
+		return errors.Wrapf(err, "tuntap: Can't create veth pair %s/%s", nameA, nameB)
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
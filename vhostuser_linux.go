@@ -0,0 +1,759 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+//-----------------------------------------------------------------------------
+// vhost-user backend (EXPERIMENTAL)
+//
+// A VhostUser bridges a DevTap Interface to a vhost-user frontend such as
+// QEMU (-chardev socket + -netdev vhost-user) or DPDK's virtio-user PMD. The
+// frontend shares the guest memory with us over the unix socket, and we
+// process the virtio-net split rings directly: frames the guest transmits are
+// written to the Interface, and frames read from the Interface are placed in
+// the guest's receive ring.
+//
+// Only the minimum feature set is negotiated: VIRTIO_F_VERSION_1, one queue
+// pair, no offloads, no indirect descriptors and no event index. That keeps
+// the ring handling simple while still avoiding any copies through the kernel
+// on the guest side.
+
+// vhost-user requests we handle
+const (
+	vhostUserGetFeatures         = 1
+	vhostUserSetFeatures         = 2
+	vhostUserSetOwner            = 3
+	vhostUserResetOwner          = 4
+	vhostUserSetMemTable         = 5
+	vhostUserSetLogBase          = 6
+	vhostUserSetLogFD            = 7
+	vhostUserSetVringNum         = 8
+	vhostUserSetVringAddr        = 9
+	vhostUserSetVringBase        = 10
+	vhostUserGetVringBase        = 11
+	vhostUserSetVringKick        = 12
+	vhostUserSetVringCall        = 13
+	vhostUserSetVringErr         = 14
+	vhostUserGetProtocolFeatures = 15
+	vhostUserSetProtocolFeatures = 16
+	vhostUserGetQueueNum         = 17
+	vhostUserSetVringEnable      = 18
+)
+
+const (
+	vhostUserVersion   = 0x1
+	vhostUserReplyMask = 0x4
+	vhostUserNeedReply = 0x8
+
+	vhostUserHeaderSize = 12
+	vhostUserMaxFDs     = 8
+	vhostUserVringNoFD  = 0x100
+	vhostUserVringIdx   = 0xff
+
+	// feature bits
+	virtioNetFMrgRxbuf            = 1 << 15
+	vhostUserFProtocolFeatures    = 1 << 30
+	virtioFVersion1               = 1 << 32
+	vhostUserSupportedFeatures    = virtioFVersion1 | vhostUserFProtocolFeatures
+	vhostUserSupportedProtocolFts = 0
+
+	// virtqueue descriptor flags
+	vringDescFNext  = 1
+	vringDescFWrite = 2
+
+	// avail ring flags
+	vringAvailFNoInterrupt = 1
+
+	vhostUserRxQueue = 0 // frames toward the guest
+	vhostUserTxQueue = 1 // frames from the guest
+)
+
+// ErrVhostUserProtocol is returned when the frontend sends a message we can't
+// make sense of.
+var ErrVhostUserProtocol = errors.New("tuntap: vhost-user protocol error")
+
+// one region of guest memory shared by the frontend
+type vhostMemRegion struct {
+	guestAddr uint64 // guest physical address
+	size      uint64
+	userAddr  uint64 // frontend's virtual address
+	mmap      []byte // our mapping, including the mmap offset
+	data      []byte // mmap[offset:offset+size]
+}
+
+// one split virtqueue
+type vhostVring struct {
+	num       uint16
+	desc      []byte
+	avail     []byte
+	used      []byte
+	lastAvail uint16
+	usedIdx   uint16
+	kick      *os.File
+	call      int
+	enabled   bool
+	started   bool
+}
+
+// VhostUser is an experimental vhost-user backend which exposes an Interface
+// to a virtio-net frontend over a unix socket.
+type VhostUser struct {
+	t        *Interface
+	listener *net.UnixListener
+	// done when Close is called
+	ctx  context.Context
+	stop context.CancelFunc
+
+	lock     sync.Mutex
+	conn     *net.UnixConn // the frontend being served
+	features uint64
+	protoFts uint64
+	regions  []vhostMemRegion
+	vrings   [2]vhostVring
+	hdrLen   int
+
+	rxDrops uint64 // frames dropped because the guest had no rx buffers
+	closed  int32
+}
+
+// NewVhostUser creates a vhost-user backend for the DevTap interface t,
+// listening on the unix socket at path. Call Serve to accept a frontend.
+func NewVhostUser(t *Interface, path string) (*VhostUser, error) {
+	if t.kind != DevTap {
+		return nil, errors.New("tuntap: vhost-user requires a DevTap interface")
+	}
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, errors.Wrapf(err, "tuntap: Can't listen on vhost-user socket %s", path)
+	}
+	v := &VhostUser{t: t, listener: l, hdrLen: 12}
+	v.ctx, v.stop = context.WithCancel(context.Background())
+	for i := range v.vrings {
+		v.vrings[i].call = -1
+	}
+	return v, nil
+}
+
+// Close stops serving, disconnecting the frontend, and removes the listening
+// socket. It does not close the Interface.
+func (v *VhostUser) Close() error {
+	atomic.StoreInt32(&v.closed, 1)
+	v.stop()
+	err := v.listener.Close()
+	// Serve forgets the frontend's state once it's done with the connection
+	v.lock.Lock()
+	if v.conn != nil {
+		v.conn.Close()
+	}
+	v.lock.Unlock()
+	return err
+}
+
+// RxDrops returns the number of frames read from the Interface which were
+// dropped because the guest had not posted any receive buffers.
+func (v *VhostUser) RxDrops() uint64 {
+	return atomic.LoadUint64(&v.rxDrops)
+}
+
+// Serve accepts frontend connections one at a time and runs the datapath
+// between the frontend and the Interface until Close is called.
+func (v *VhostUser) Serve() error {
+	ctx, cancel := context.WithCancel(v.ctx)
+	defer cancel()
+	goLabeled(v.t, "vhost-user rx", func() { v.rxLoop(ctx) })
+	for {
+		conn, err := v.listener.AcceptUnix()
+		if err != nil {
+			if atomic.LoadInt32(&v.closed) != 0 {
+				return nil
+			}
+			return err
+		}
+		v.lock.Lock()
+		if atomic.LoadInt32(&v.closed) != 0 {
+			v.lock.Unlock()
+			conn.Close()
+			return nil
+		}
+		v.conn = conn
+		v.lock.Unlock()
+		err = v.serveConn(conn)
+		conn.Close()
+		v.lock.Lock()
+		v.conn = nil
+		v.reset()
+		v.lock.Unlock()
+		if err != nil && err != io.EOF && atomic.LoadInt32(&v.closed) == 0 {
+			return err
+		}
+	}
+}
+
+// reset forgets all the state negotiated with a frontend. Called with v.lock held.
+func (v *VhostUser) reset() {
+	for i := range v.vrings {
+		v.stopVring(i)
+		vr := &v.vrings[i]
+		if vr.call >= 0 {
+			unix.Close(vr.call)
+		}
+		*vr = vhostVring{call: -1}
+	}
+	for _, r := range v.regions {
+		unix.Munmap(r.mmap)
+	}
+	v.regions = nil
+	v.features = 0
+	v.protoFts = 0
+}
+
+func (v *VhostUser) serveConn(conn *net.UnixConn) error {
+	hdr := make([]byte, vhostUserHeaderSize)
+	oob := make([]byte, unix.CmsgSpace(vhostUserMaxFDs*4))
+	for {
+		n, oobn, _, _, err := conn.ReadMsgUnix(hdr, oob)
+		if err != nil {
+			return err
+		}
+		var fds []int
+		if oobn > 0 {
+			fds, err = parseRights(oob[:oobn])
+			if err != nil {
+				return err
+			}
+		}
+		if n < vhostUserHeaderSize {
+			if _, err = io.ReadFull(conn, hdr[n:]); err != nil {
+				closeFDs(fds)
+				return err
+			}
+		}
+		req := binary.LittleEndian.Uint32(hdr[0:])
+		flags := binary.LittleEndian.Uint32(hdr[4:])
+		size := binary.LittleEndian.Uint32(hdr[8:])
+		if size > 4096 {
+			closeFDs(fds)
+			return ErrVhostUserProtocol
+		}
+		payload := make([]byte, size)
+		if _, err = io.ReadFull(conn, payload); err != nil {
+			closeFDs(fds)
+			return err
+		}
+
+		v.lock.Lock()
+		reply, err := v.handle(req, payload, fds)
+		v.lock.Unlock()
+		if err != nil {
+			return err
+		}
+		if reply == nil && flags&vhostUserNeedReply != 0 {
+			// REPLY_ACK is never negotiated, but answer politely anyway
+			reply = make([]byte, 8)
+		}
+		if reply != nil {
+			msg := make([]byte, vhostUserHeaderSize+len(reply))
+			binary.LittleEndian.PutUint32(msg[0:], req)
+			binary.LittleEndian.PutUint32(msg[4:], vhostUserVersion|vhostUserReplyMask)
+			binary.LittleEndian.PutUint32(msg[8:], uint32(len(reply)))
+			copy(msg[vhostUserHeaderSize:], reply)
+			if _, err = conn.Write(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func parseRights(oob []byte) ([]int, error) {
+	msgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, err
+	}
+	var fds []int
+	for i := range msgs {
+		f, err := unix.ParseUnixRights(&msgs[i])
+		if err != nil {
+			closeFDs(fds)
+			return nil, err
+		}
+		fds = append(fds, f...)
+	}
+	return fds, nil
+}
+
+func closeFDs(fds []int) {
+	for _, fd := range fds {
+		unix.Close(fd)
+	}
+}
+
+func u64Reply(x uint64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, x)
+	return b
+}
+
+// handle processes one request. It takes ownership of fds. Called with v.lock held.
+func (v *VhostUser) handle(req uint32, p []byte, fds []int) ([]byte, error) {
+	need := func(n int) error {
+		if len(p) < n {
+			closeFDs(fds)
+			return errors.Wrapf(ErrVhostUserProtocol, "request %d too short", req)
+		}
+		return nil
+	}
+	vring := func() (*vhostVring, int, error) {
+		if err := need(4); err != nil {
+			return nil, 0, err
+		}
+		idx := int(binary.LittleEndian.Uint32(p) & vhostUserVringIdx)
+		if idx >= len(v.vrings) {
+			closeFDs(fds)
+			return nil, 0, errors.Wrapf(ErrVhostUserProtocol, "vring %d out of range", idx)
+		}
+		return &v.vrings[idx], idx, nil
+	}
+
+	switch req {
+	case vhostUserGetFeatures:
+		return u64Reply(vhostUserSupportedFeatures), nil
+	case vhostUserSetFeatures:
+		if err := need(8); err != nil {
+			return nil, err
+		}
+		v.features = binary.LittleEndian.Uint64(p) & vhostUserSupportedFeatures
+		if v.features&(virtioFVersion1|virtioNetFMrgRxbuf) != 0 {
+			v.hdrLen = 12
+		} else {
+			v.hdrLen = 10
+		}
+	case vhostUserGetProtocolFeatures:
+		return u64Reply(vhostUserSupportedProtocolFts), nil
+	case vhostUserSetProtocolFeatures:
+		if err := need(8); err != nil {
+			return nil, err
+		}
+		v.protoFts = binary.LittleEndian.Uint64(p) & vhostUserSupportedProtocolFts
+	case vhostUserGetQueueNum:
+		return u64Reply(1), nil
+	case vhostUserSetOwner, vhostUserResetOwner:
+		// nothing to do
+	case vhostUserSetMemTable:
+		return nil, v.setMemTable(p, fds)
+	case vhostUserSetLogBase, vhostUserSetLogFD, vhostUserSetVringErr:
+		// we don't support live migration logging or error reporting
+		closeFDs(fds)
+	case vhostUserSetVringNum:
+		vr, _, err := vring()
+		if err != nil {
+			return nil, err
+		}
+		if err = need(8); err != nil {
+			return nil, err
+		}
+		num := binary.LittleEndian.Uint32(p[4:])
+		if num == 0 || num > 32768 || num&(num-1) != 0 {
+			return nil, errors.Wrapf(ErrVhostUserProtocol, "bad vring size %d", num)
+		}
+		vr.num = uint16(num)
+	case vhostUserSetVringAddr:
+		vr, _, err := vring()
+		if err != nil {
+			return nil, err
+		}
+		if err = need(40); err != nil {
+			return nil, err
+		}
+		if vr.num == 0 {
+			return nil, errors.Wrap(ErrVhostUserProtocol, "vring address set before its size")
+		}
+		n := int(vr.num)
+		vr.desc = v.userMem(binary.LittleEndian.Uint64(p[8:]), 16*n)
+		vr.used = v.userMem(binary.LittleEndian.Uint64(p[16:]), 6+8*n)
+		vr.avail = v.userMem(binary.LittleEndian.Uint64(p[24:]), 6+2*n)
+		if vr.desc == nil || vr.used == nil || vr.avail == nil {
+			return nil, errors.Wrap(ErrVhostUserProtocol, "vring address outside of shared memory")
+		}
+		if uintptr(unsafe.Pointer(&vr.avail[0]))&3 != 0 || uintptr(unsafe.Pointer(&vr.used[0]))&3 != 0 {
+			// legal per the spec for the avail ring, but no frontend does it and we need 32-bit atomics
+			return nil, errors.Wrap(ErrVhostUserProtocol, "vring not 4-byte aligned")
+		}
+	case vhostUserSetVringBase:
+		vr, _, err := vring()
+		if err != nil {
+			return nil, err
+		}
+		if err = need(8); err != nil {
+			return nil, err
+		}
+		vr.lastAvail = uint16(binary.LittleEndian.Uint32(p[4:]))
+		vr.usedIdx = vr.lastAvail
+	case vhostUserGetVringBase:
+		vr, idx, err := vring()
+		if err != nil {
+			return nil, err
+		}
+		v.stopVring(idx)
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint32(b[0:], uint32(idx))
+		binary.LittleEndian.PutUint32(b[4:], uint32(vr.lastAvail))
+		return b, nil
+	case vhostUserSetVringKick, vhostUserSetVringCall:
+		vr, idx, err := vring()
+		if err != nil {
+			return nil, err
+		}
+		fd := -1
+		if binary.LittleEndian.Uint64(p)&vhostUserVringNoFD == 0 {
+			if len(fds) != 1 {
+				closeFDs(fds)
+				return nil, errors.Wrap(ErrVhostUserProtocol, "missing eventfd")
+			}
+			fd = fds[0]
+		}
+		if req == vhostUserSetVringCall {
+			if vr.call >= 0 {
+				unix.Close(vr.call)
+			}
+			vr.call = fd
+			return nil, nil
+		}
+		v.stopVring(idx)
+		if fd >= 0 {
+			if err = unix.SetNonblock(fd, true); err != nil {
+				unix.Close(fd)
+				return nil, err
+			}
+			vr.kick = os.NewFile(uintptr(fd), "vhost-user-kick")
+		}
+		// without VHOST_USER_F_PROTOCOL_FEATURES rings start enabled
+		if v.features&vhostUserFProtocolFeatures == 0 {
+			vr.enabled = true
+		}
+		v.startVring(idx)
+	case vhostUserSetVringEnable:
+		vr, _, err := vring()
+		if err != nil {
+			return nil, err
+		}
+		if err = need(8); err != nil {
+			return nil, err
+		}
+		vr.enabled = binary.LittleEndian.Uint32(p[4:]) != 0
+	default:
+		closeFDs(fds)
+		return nil, errors.Wrapf(ErrVhostUserProtocol, "unsupported request %d", req)
+	}
+	return nil, nil
+}
+
+func (v *VhostUser) setMemTable(p []byte, fds []int) error {
+	if len(p) < 8 {
+		closeFDs(fds)
+		return ErrVhostUserProtocol
+	}
+	n := int(binary.LittleEndian.Uint32(p))
+	if n != len(fds) || len(p) < 8+32*n {
+		closeFDs(fds)
+		return errors.Wrap(ErrVhostUserProtocol, "memory table doesn't match passed fds")
+	}
+	for i := range v.vrings {
+		v.stopVring(i)
+	}
+	for _, r := range v.regions {
+		unix.Munmap(r.mmap)
+	}
+	v.regions = nil
+
+	var err error
+	for i := 0; i < n; i++ {
+		q := p[8+32*i:]
+		r := vhostMemRegion{
+			guestAddr: binary.LittleEndian.Uint64(q[0:]),
+			size:      binary.LittleEndian.Uint64(q[8:]),
+			userAddr:  binary.LittleEndian.Uint64(q[16:]),
+		}
+		offset := binary.LittleEndian.Uint64(q[24:])
+		if end := offset + r.size; err == nil && (end < offset || int(end) < 0 || uint64(int(end)) != end) {
+			err = errors.Wrap(ErrVhostUserProtocol, "memory region too large")
+		}
+		if err == nil {
+			r.mmap, err = unix.Mmap(fds[i], 0, int(offset+r.size), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+			if err == nil {
+				r.data = r.mmap[offset:]
+				v.regions = append(v.regions, r)
+			}
+		}
+		// the mapping keeps the memory alive; we don't need the fd
+		unix.Close(fds[i])
+	}
+	if err != nil {
+		return errors.Wrap(err, "tuntap: Can't mmap vhost-user memory region")
+	}
+	return nil
+}
+
+// userMem translates a frontend virtual address into our mapping.
+func (v *VhostUser) userMem(addr uint64, n int) []byte {
+	for _, r := range v.regions {
+		if b := r.slice(r.userAddr, addr, uint64(n)); b != nil {
+			return b
+		}
+	}
+	return nil
+}
+
+// guestMem translates a guest physical address into our mapping.
+func (v *VhostUser) guestMem(addr uint64, n uint32) []byte {
+	for _, r := range v.regions {
+		if b := r.slice(r.guestAddr, addr, uint64(n)); b != nil {
+			return b
+		}
+	}
+	return nil
+}
+
+// slice returns the n bytes at addr of the region, which starts at base in
+// the address space addr is in, or nil if they aren't all in it. The
+// addresses come from the frontend and the guest, so nothing may wrap.
+func (r *vhostMemRegion) slice(base, addr, n uint64) []byte {
+	if addr < base || n > r.size || addr-base > r.size-n {
+		return nil
+	}
+	off := addr - base
+	return r.data[off : off+n]
+}
+
+func (v *VhostUser) startVring(idx int) {
+	vr := &v.vrings[idx]
+	if vr.desc == nil || vr.kick == nil || vr.started {
+		return
+	}
+	vr.started = true
+	if idx == vhostUserTxQueue {
+		kick := vr.kick
+		goLabeled(v.t, "vhost-user tx", func() { v.txLoop(kick) })
+	}
+}
+
+// stopVring stops processing the ring. Called with v.lock held.
+func (v *VhostUser) stopVring(idx int) {
+	vr := &v.vrings[idx]
+	vr.started = false
+	if vr.kick != nil {
+		// closing the kick eventfd wakes up and terminates txLoop
+		vr.kick.Close()
+		vr.kick = nil
+	}
+}
+
+// ring accessors. Virtio 1.0 rings are little-endian, and the idx fields
+// share an aligned 32-bit word with the flags, which lets us use 32-bit
+// atomics to order them against the ring contents.
+
+func availFlagsIdx(vr *vhostVring) (uint16, uint16) {
+	w := atomic.LoadUint32((*uint32)(unsafe.Pointer(&vr.avail[0])))
+	return uint16(w), uint16(w >> 16)
+}
+
+func (vr *vhostVring) availRing(i uint16) uint16 {
+	return binary.LittleEndian.Uint16(vr.avail[4+2*int(i%vr.num):])
+}
+
+func (vr *vhostVring) pushUsed(id uint16, n uint32) {
+	e := vr.used[4+8*int(vr.usedIdx%vr.num):]
+	binary.LittleEndian.PutUint32(e[0:], uint32(id))
+	binary.LittleEndian.PutUint32(e[4:], n)
+	vr.usedIdx++
+	atomic.StoreUint32((*uint32)(unsafe.Pointer(&vr.used[0])), uint32(vr.usedIdx)<<16)
+}
+
+func (vr *vhostVring) notify() {
+	flags, _ := availFlagsIdx(vr)
+	if flags&vringAvailFNoInterrupt == 0 && vr.call >= 0 {
+		one := [8]byte{1}
+		unix.Write(vr.call, one[:])
+	}
+}
+
+// vhostDesc is one element of a descriptor chain.
+type vhostDesc struct {
+	buf   []byte
+	write bool
+}
+
+// chain walks the descriptor chain starting at head.
+func (v *VhostUser) chain(vr *vhostVring, head uint16, out []vhostDesc) ([]vhostDesc, error) {
+	out = out[:0]
+	i := head
+	for n := 0; n < int(vr.num); n++ {
+		if i >= vr.num {
+			break
+		}
+		d := vr.desc[16*int(i):]
+		addr := binary.LittleEndian.Uint64(d[0:])
+		length := binary.LittleEndian.Uint32(d[8:])
+		flags := binary.LittleEndian.Uint16(d[12:])
+		buf := v.guestMem(addr, length)
+		if buf == nil {
+			break
+		}
+		out = append(out, vhostDesc{buf, flags&vringDescFWrite != 0})
+		if flags&vringDescFNext == 0 {
+			return out, nil
+		}
+		i = binary.LittleEndian.Uint16(d[14:])
+	}
+	return out, errors.Wrapf(ErrVhostUserProtocol, "bad descriptor chain at %d", head)
+}
+
+// txLoop moves frames transmitted by the guest to the Interface until kick is closed.
+func (v *VhostUser) txLoop(kick *os.File) {
+	var ev [8]byte
+	frame := make([]byte, 65536)
+	var descs []vhostDesc
+	for {
+		if _, err := kick.Read(ev[:]); err != nil {
+			return
+		}
+		v.lock.Lock()
+		vr := &v.vrings[vhostUserTxQueue]
+		if vr.kick != kick {
+			v.lock.Unlock()
+			return
+		}
+		if !vr.enabled {
+			v.lock.Unlock()
+			continue
+		}
+		var err error
+		for {
+			_, idx := availFlagsIdx(vr)
+			if vr.lastAvail == idx {
+				break
+			}
+			head := vr.availRing(vr.lastAvail)
+			vr.lastAvail++
+			descs, err = v.chain(vr, head, descs)
+			if err != nil {
+				// given back unused, so the guest doesn't lose it
+				vr.pushUsed(head, 0)
+				err = nil
+				continue
+			}
+			n := 0
+			for _, d := range descs {
+				if !d.write {
+					n += copy(frame[n:], d.buf)
+				}
+			}
+			vr.pushUsed(head, 0)
+			if n <= v.hdrLen+14 {
+				continue
+			}
+			body := frame[v.hdrLen:n]
+			// errors writing to the device are dropped frames, like on a real link
+			if pkt, err := ParseFrame(body); err == nil {
+				v.t.WritePacket(pkt)
+			}
+		}
+		vr.notify()
+		v.lock.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// rxLoop moves frames read from the Interface into the guest until ctx is
+// done, when Serve returns, or the Interface is closed.
+func (v *VhostUser) rxLoop(ctx context.Context) {
+	buf := make([]byte, 65536+4)
+	var descs []vhostDesc
+	for {
+		pkt, err := v.t.ReadPacketContext(ctx, buf)
+		if err != nil {
+			if err == ErrShortRead {
+				continue
+			}
+			return
+		}
+		v.lock.Lock()
+		if err = v.deliver(pkt.Body, descs); err != nil {
+			atomic.AddUint64(&v.rxDrops, 1)
+		}
+		v.lock.Unlock()
+	}
+}
+
+var errNoRxBuffers = errors.New("tuntap: no vhost-user rx buffers")
+
+// deliver places one frame in the guest's receive ring. Called with v.lock held.
+func (v *VhostUser) deliver(frame []byte, descs []vhostDesc) error {
+	vr := &v.vrings[vhostUserRxQueue]
+	if !vr.started || !vr.enabled {
+		return errNoRxBuffers
+	}
+	_, idx := availFlagsIdx(vr)
+	if vr.lastAvail == idx {
+		return errNoRxBuffers
+	}
+	head := vr.availRing(vr.lastAvail)
+	vr.lastAvail++
+	descs, err := v.chain(vr, head, descs)
+	if err != nil {
+		// given back unused, so the guest doesn't lose it
+		vr.pushUsed(head, 0)
+		vr.notify()
+		return err
+	}
+
+	// the virtio_net_hdr is all zeros (no offloads) except num_buffers, which
+	// is always 1 since we don't merge rx buffers
+	var hdr [12]byte
+	binary.LittleEndian.PutUint16(hdr[10:], 1)
+	src := [2][]byte{hdr[:v.hdrLen], frame}
+	written := 0
+	for _, d := range descs {
+		if !d.write {
+			continue
+		}
+		b := d.buf
+		for len(b) > 0 && (len(src[0]) > 0 || len(src[1]) > 0) {
+			s := &src[0]
+			if len(*s) == 0 {
+				s = &src[1]
+			}
+			n := copy(b, *s)
+			*s = (*s)[n:]
+			b = b[n:]
+			written += n
+		}
+	}
+	// a frame which doesn't fit is truncated, as a real NIC with small
+	// buffers would do; the guest's stack will drop it
+	vr.pushUsed(head, uint32(written))
+	vr.notify()
+	return nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,250 @@
+//-----------------------------------------------------------------------------
+/*
+
+Copyright Juniper Networks Inc. 2026-2026. All rights reserved.
+
+*/
+//-----------------------------------------------------------------------------
+
+package tuntap
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+//-----------------------------------------------------------------------------
+// virtio-net headers (IFF_VNET_HDR). With WithVnetHdr, each packet of the
+// Interface comes with a struct virtio_net_hdr, by which the kernel and the
+// application hand each other the work of checksumming and segmenting: the
+// kernel can pass up TCP (and UDP) super-packets of up to 64KB, to be
+// segmented only if they leave the host, along with packets whose checksum
+// is left to compute, and takes the same from the application. This saves
+// most of the per-packet cost of a tunnel, but an application which
+// forwards the packets must then segment and checksum them itself (or let
+// the far end's kernel do it) where the offloads don't carry over.
+
+// the flags of a VnetHdr
+const (
+	// the checksum at CsumStart+CsumOffset covers from CsumStart to the end,
+	// and only holds the pseudo-header's sum yet
+	VnetHdrNeedsCsum uint8 = 1
+	// the checksums have been verified
+	VnetHdrDataValid uint8 = 2
+)
+
+// the GSOType of a VnetHdr
+const (
+	VnetGSONone  uint8 = 0
+	VnetGSOTCPv4 uint8 = 1
+	VnetGSOUDP   uint8 = 3 // UFO, which the kernel no longer accepts
+	VnetGSOTCPv6 uint8 = 4
+	VnetGSOUDPL4 uint8 = 5 // USO, IPv4 or IPv6
+	// or'ed in for TCP super-packets with CWR set
+	VnetGSOECN uint8 = 0x80
+)
+
+// VnetHdrLen is the length of a struct virtio_net_hdr.
+const VnetHdrLen = 10
+
+var ErrVnetHdr = errors.New("truncated virtio-net header")
+
+// VnetHdr is a struct virtio_net_hdr. The zero VnetHdr describes a plain,
+// checksummed packet.
+type VnetHdr struct {
+	Flags   uint8
+	GSOType uint8
+	// the length of the headers, up to the TCP or UDP payload
+	HdrLen uint16
+	// the size of the segments to cut the payload into
+	GSOSize    uint16
+	CsumStart  uint16
+	CsumOffset uint16
+}
+
+// AppendVnetHdr appends h to dst and returns the extended buffer. The fields
+// are in host order, as tun devices use.
+func AppendVnetHdr(dst []byte, h VnetHdr) []byte {
+	dst = append(dst, h.Flags, h.GSOType)
+	dst = binary.NativeEndian.AppendUint16(dst, h.HdrLen)
+	dst = binary.NativeEndian.AppendUint16(dst, h.GSOSize)
+	dst = binary.NativeEndian.AppendUint16(dst, h.CsumStart)
+	return binary.NativeEndian.AppendUint16(dst, h.CsumOffset)
+}
+
+// ParseVnetHdr decodes the virtio-net header at the start of b.
+func ParseVnetHdr(b []byte) (VnetHdr, error) {
+	if len(b) < VnetHdrLen {
+		return VnetHdr{}, ErrVnetHdr
+	}
+	return VnetHdr{
+		Flags:      b[0],
+		GSOType:    b[1],
+		HdrLen:     binary.NativeEndian.Uint16(b[2:4]),
+		GSOSize:    binary.NativeEndian.Uint16(b[4:6]),
+		CsumStart:  binary.NativeEndian.Uint16(b[6:8]),
+		CsumOffset: binary.NativeEndian.Uint16(b[8:10]),
+	}, nil
+}
+
+// Offload is a set of offloads the application takes on (TUNSETOFFLOAD):
+// the kernel may give it packets needing the work done.
+type Offload int
+
+const (
+	// packets with VnetHdrNeedsCsum
+	OffloadCsum Offload = 1 << iota
+	// TCP over IPv4 super-packets; needs OffloadCsum
+	OffloadTSO4
+	// TCP over IPv6 super-packets; needs OffloadCsum
+	OffloadTSO6
+	// UDP super-packets (linux 6.2 and later); needs OffloadCsum
+	OffloadUSO
+)
+
+// WithVnetHdr opens the Interface with virtio-net headers, taking on the
+// offloads the kernel accepts among offloads (see Interface.Offloads). The
+// header of each packet read is in Packet.Vnet, and the header of each packet
+// written is taken from there. With TSO or USO, ReadPacket must be given
+// buffers of 64KB or more. Supported on Linux, and for DevTap on FreeBSD
+// (see SetVnetHdrLen), without OffloadUSO.
+func WithVnetHdr(offloads Offload) Option {
+	return func(c *config) {
+		c.vnetHdr = true
+		c.offloads = offloads
+	}
+}
+
+// Offloads returns the offloads of an Interface opened WithVnetHdr: those
+// asked for, minus any the kernel doesn't support.
+func (t *Interface) Offloads() Offload {
+	return t.offloads
+}
+
+// SegmentGSO cuts the TCP or UDP super-packet pkt (see VnetHdr) into the
+// segments of GSOSize bytes of payload it stands for, with their lengths,
+// sequence numbers and checksums set, as the kernel would: the first in
+// place, in pkt.Body, and the others into bufs, one each. It returns false,
+// leaving pkt as it is, if pkt isn't a super-packet it can cut, or if bufs
+// are too few or too small.
+func SegmentGSO(pkt Packet, bufs [][]byte) ([]Packet, bool) {
+	gso := pkt.Vnet.GSOType &^ VnetGSOECN
+	proto, at, frag := pkt.IPProto()
+	if at == 0 || frag || pkt.Truncated || pkt.Vnet.GSOSize == 0 {
+		return nil, false
+	}
+	end := at + 8
+	switch {
+	case proto == 6 && (gso == VnetGSOTCPv4 && pkt.Protocol == ETH_P_IP || gso == VnetGSOTCPv6 && pkt.Protocol == ETH_P_IPV6):
+		if at+20 > len(pkt.Body) {
+			return nil, false
+		}
+		end = at + int(pkt.Body[at+12]>>4)*4
+		if end < at+20 {
+			return nil, false
+		}
+	case proto == 17 && (gso == VnetGSOUDPL4 || gso == VnetGSOUDP):
+	default:
+		return nil, false
+	}
+	mss := int(pkt.Vnet.GSOSize)
+	if end > len(pkt.Body) {
+		return nil, false
+	}
+	n := (len(pkt.Body) - end + mss - 1) / mss
+	if n < 1 || n-1 > len(bufs) {
+		return nil, false
+	}
+	for i := 1; i < n; i++ {
+		if len(bufs[i-1]) < end+mss {
+			return nil, false
+		}
+	}
+
+	segs := make([]Packet, n)
+	payload := pkt.Body[end:]
+	// the first last, as the others are copied from it
+	for i := n - 1; i >= 0; i-- {
+		chunk := payload[i*mss:]
+		if len(chunk) > mss {
+			chunk = chunk[:mss]
+		}
+		seg := pkt
+		seg.Vnet = VnetHdr{}
+		if i == 0 {
+			seg.Body = pkt.Body[:end+len(chunk)]
+		} else {
+			b := bufs[i-1]
+			copy(b, pkt.Body[:end])
+			seg.Body = b[:end+copy(b[end:], chunk)]
+		}
+		seg.fixSegment(i, mss, at, proto, i == n-1)
+		segs[i] = seg
+	}
+	return segs, true
+}
+
+// fixSegment sets the lengths, and the IPv4 ID, of the i-th segment p cut
+// out of a super-packet of segments of mss bytes, the TCP sequence number
+// and flags (proto, the TCP or UDP header, is at at), and the checksums.
+func (p *Packet) fixSegment(i, mss, at int, proto uint8, last bool) {
+	ip := p.ip()
+	if p.Protocol == ETH_P_IP {
+		ihl := int(ip[0]&0xf) << 2
+		binary.BigEndian.PutUint16(ip[2:], uint16(len(ip)))
+		binary.BigEndian.PutUint16(ip[4:], binary.BigEndian.Uint16(ip[4:])+uint16(i))
+		ip[10], ip[11] = 0, 0
+		binary.BigEndian.PutUint16(ip[10:], checksum(ip[:ihl]))
+	} else {
+		binary.BigEndian.PutUint16(ip[4:], uint16(len(ip)-40))
+	}
+	l4 := p.Body[at:]
+	csum := 16
+	if proto == 17 {
+		binary.BigEndian.PutUint16(l4[4:], uint16(len(l4)))
+		csum = 6
+	} else {
+		seq := binary.BigEndian.Uint32(l4[4:])
+		binary.BigEndian.PutUint32(l4[4:], seq+uint32(i*mss))
+		if i != 0 {
+			l4[13] &^= 0x80 // CWR
+		}
+		if !last {
+			l4[13] &^= 0x01 | 0x08 // FIN, PSH
+		}
+	}
+	l4[csum], l4[csum+1] = 0, 0
+	// the pseudo-header: the addresses, the protocol and the length
+	src, dst := ip[12:16], ip[16:20]
+	if p.Protocol == ETH_P_IPV6 {
+		src, dst = ip[8:24], ip[24:40]
+	}
+	sum := sumWords(sumWords(0, src), dst) + uint32(proto) + uint32(len(l4))
+	c := ^fold(sumWords(sum, l4))
+	if c == 0 && csum == 6 {
+		// 0 is no UDP checksum
+		c = 0xffff
+	}
+	binary.BigEndian.PutUint16(l4[csum:], c)
+}
+
+// sumWords adds the 16-bit words of b to sum.
+func sumWords(sum uint32, b []byte) uint32 {
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+	if len(b)%2 != 0 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	return sum
+}
+
+// fold folds sum into 16 bits.
+func fold(sum uint32) uint16 {
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return uint16(sum)
+}
+
+//-----------------------------------------------------------------------------
@@ -10,30 +10,41 @@ const sizeofTime = 0x8
 const sizeofIfreq = 0x20
 const sizeofIn6AliasReq = 0x88
 const sizeofIn6SockAddr = 0x1c
+const sizeofIn6Ifreq = 0x120
 const sizeofIn6AddrLifetime = 0x18
 
 const (
 	IFNAMSIZ              = 0x10
 	ND6_INFINITE_LIFETIME = 0xffffffff
+	IN6_IFF_ANYCAST       = 0x1
 	SIOCDIFADDR_IN6       = 0x81206919
 	SIOCAIFADDR_IN6       = 0x8088691b
 
+	IFT_ETHER = 0x6
+
 	TUNSDEBUG  = 0x8004745a
 	TUNGDEBUG  = 0x40047459
 	TUNSIFINFO = 0x8008745b
 	TUNGIFINFO = 0x4008745c
 	TUNSLMODE  = 0x8004745d
-
+	TUNGIFNAME = 0x4020745d
 	TUNSIFMODE = 0x8004745e
 	TUNSIFPID  = 0x2000745f
 	TUNSIFHEAD = 0x80047460
 	TUNGIFHEAD = 0x40047461
 
-	TAPSDEBUG  = 0x8004745a
-	TAPGDEBUG  = 0x40047459
-	TAPSIFINFO = 0x8008745b
-	TAPGIFINFO = 0x4008745c
-
+	TAPSDEBUG   = 0x8004745a
+	TAPGDEBUG   = 0x40047459
+	TAPSIFINFO  = 0x8008745b
+	TAPGIFINFO  = 0x4008745c
+	TAPGIFNAME  = 0x4020745d
 	TAPSVNETHDR = 0x8004745b
 	TAPGVNETHDR = 0x4004745e
 )
+
+type tapInfo struct {
+	Baudrate int32
+	Mtu      int16
+	Type     uint8
+	Dummy    uint8
+}
@@ -4,7 +4,12 @@
 package tuntap
 
 const (
-	flagTruncated = 0x1
+	flagTruncated  = 0x1
+	tunOffloadCsum = 0x1
+	tunOffloadTSO4 = 0x2
+	tunOffloadTSO6 = 0x4
+	tunOffloadUSO4 = 0x20
+	tunOffloadUSO6 = 0x40
 )
 
 type ifReq struct {
@@ -0,0 +1,23 @@
+// Written to match the output of cgo -godefs=true types_openbsd.go, which
+// can only be run on OpenBSD (see mkdefs.sh). The values are the same on all
+// the 64-bit architectures (OpenBSD's time_t is 64 bits everywhere).
+
+package tuntap
+
+const flagTruncated = 0
+
+const sizeofInt = 0x4
+const sizeofTime = 0x8
+const sizeofIfreq = 0x20
+const sizeofIfAliasReq = 0x40
+const sizeofIn6AliasReq = 0x80
+const sizeofInSockAddr = 0x10
+const sizeofIn6SockAddr = 0x1c
+const sizeofIn6AddrLifetime = 0x18
+
+const (
+	IFNAMSIZ              = 0x10
+	ND6_INFINITE_LIFETIME = 0xffffffff
+	IN6_IFF_ANYCAST       = 0x1
+	SIOCAIFADDR_IN6       = 0x8080691a
+)